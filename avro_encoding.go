@@ -0,0 +1,786 @@
+package columbus
+
+import (
+	"crypto/rand"
+	"database/sql"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"github.com/shopspring/decimal"
+	"io"
+	"math"
+	"math/big"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// AvroEncoding is a RowEncoding that writes rows as an Avro object container file - each row is written as an Avro record
+//
+// the schema of the record is derived from the mapper:
+//
+//   - properties read from columns - from the column types (database type and scan type) and nullability
+//   - nested properties (i.e. mapped with a Path) - as nested records
+//   - decimal properties (see UseDecimals) - as the decimal logical type (using the precision and scale of the column, if known) -
+//     float columns whose precision and scale are not known are written as doubles
+//   - time properties - as the timestamp-millis logical type
+//
+// the schema of properties whose type cannot be determined from the columns (i.e. sub-query and row post processor properties,
+// JSON columns and columns mapped with a Scanner, PostProcess or NullDefault) is inferred from the values in the first block of
+// rows - sub-query properties become arrays of records (a property with no values in the first block is written as a string).
+// Inferred properties are always nullable
+//
+// rows are written in blocks (of BlockSize rows) and the writer is flushed after each block is written - the first block is held in
+// memory until it is complete (so that the schema can be inferred)
+type AvroEncoding struct {
+	// Name is the name of the row record schema (default "Row")
+	Name string
+	// Namespace is the (optional) namespace of the row record schema
+	Namespace string
+	// BlockSize is the number of rows written in each block (default 100)
+	BlockSize int
+	// DecimalPrecision is the precision used for decimal properties where the column precision is not known - if
+	// DecimalPrecision is not set, a precision of 38 and a scale of 9 are used
+	DecimalPrecision int
+	// DecimalScale is the scale used for decimal properties where the column scale is not known
+	DecimalScale int
+}
+
+var _ RowEncoding = (*AvroEncoding)(nil)
+
+// Avro is the RowEncoding that writes rows as an Avro object container file (see AvroEncoding)
+var Avro RowEncoding = &AvroEncoding{}
+
+const (
+	defaultAvroBlockSize        = 100
+	defaultAvroDecimalPrecision = 38
+	defaultAvroDecimalScale     = 9
+)
+
+func (e *AvroEncoding) ContentType() string {
+	return "application/avro"
+}
+
+func (e *AvroEncoding) NewEncoder(w io.Writer) RowEncoder {
+	result := &avroEncoder{
+		w:         w,
+		name:      e.Name,
+		namespace: e.Namespace,
+		blockSize: e.BlockSize,
+		precision: e.DecimalPrecision,
+		scale:     e.DecimalScale,
+	}
+	if result.name == "" {
+		result.name = "Row"
+	}
+	if result.blockSize <= 0 {
+		result.blockSize = defaultAvroBlockSize
+	}
+	if result.precision <= 0 {
+		result.precision, result.scale = defaultAvroDecimalPrecision, defaultAvroDecimalScale
+	}
+	return result
+}
+
+type avroEncoder struct {
+	w         io.Writer
+	name      string
+	namespace string
+	blockSize int
+	precision int
+	scale     int
+	info      EncodeInfo
+	schema    *avroType
+	pending   []map[string]any
+	block     []byte
+	count     int
+	sync      [16]byte
+}
+
+func (e *avroEncoder) Begin(info EncodeInfo) error {
+	e.info = info
+	return nil
+}
+
+func (e *avroEncoder) Row(row map[string]any) (err error) {
+	if e.schema == nil {
+		if e.pending = append(e.pending, row); len(e.pending) >= e.blockSize {
+			err = e.writeHeader()
+		}
+		return err
+	}
+	return e.addRow(row)
+}
+
+func (e *avroEncoder) End() (err error) {
+	if e.schema == nil {
+		err = e.writeHeader()
+	}
+	if err == nil && e.count > 0 {
+		err = e.writeBlock()
+	}
+	return err
+}
+
+// writeHeader builds the schema (from the encode info and the pending rows), writes the file header and then writes the pending rows
+func (e *avroEncoder) writeHeader() (err error) {
+	e.schema = e.buildSchema()
+	var schema []byte
+	if schema, err = json.Marshal(e.schema.json(e.namespace)); err != nil {
+		return err
+	}
+	if _, err = rand.Read(e.sync[:]); err != nil {
+		return err
+	}
+	b := append([]byte{}, "Obj\x01"...)
+	b = binary.AppendVarint(b, 2)
+	b = appendAvroBytes(appendAvroBytes(b, []byte("avro.schema")), schema)
+	b = appendAvroBytes(appendAvroBytes(b, []byte("avro.codec")), []byte("null"))
+	b = append(binary.AppendVarint(b, 0), e.sync[:]...)
+	if _, err = e.w.Write(b); err == nil {
+		pending := e.pending
+		e.pending = nil
+		for i := 0; err == nil && i < len(pending); i++ {
+			err = e.addRow(pending[i])
+		}
+	}
+	return err
+}
+
+func (e *avroEncoder) addRow(row map[string]any) (err error) {
+	var b []byte
+	if b, err = e.schema.append(e.block, row, ""); err != nil {
+		return err
+	}
+	e.block = b
+	if e.count++; e.count >= e.blockSize {
+		err = e.writeBlock()
+	}
+	return err
+}
+
+func (e *avroEncoder) writeBlock() (err error) {
+	b := binary.AppendVarint(nil, int64(e.count))
+	b = binary.AppendVarint(b, int64(len(e.block)))
+	if _, err = e.w.Write(b); err == nil {
+		if _, err = e.w.Write(e.block); err == nil {
+			if _, err = e.w.Write(e.sync[:]); err == nil {
+				err = flush(e.w)
+			}
+		}
+	}
+	e.block = e.block[:0]
+	e.count = 0
+	return err
+}
+
+type avroKind int
+
+const (
+	avroUnknown avroKind = iota
+	avroBoolean
+	avroLong
+	avroDouble
+	avroString
+	avroBytes
+	avroTimestamp
+	avroDecimal
+	avroRecord
+	avroArray
+)
+
+var avroKindNames = map[avroKind]string{
+	avroUnknown:   "string",
+	avroBoolean:   "boolean",
+	avroLong:      "long",
+	avroDouble:    "double",
+	avroString:    "string",
+	avroBytes:     "bytes",
+	avroTimestamp: "timestamp-millis",
+	avroDecimal:   "decimal",
+	avroRecord:    "record",
+	avroArray:     "array",
+}
+
+// avroType is an Avro schema type - nullable types are written as a union with null
+type avroType struct {
+	kind      avroKind
+	nullable  bool
+	inferred  bool
+	name      string
+	fields    []*avroField
+	items     *avroType
+	precision int
+	scale     int
+}
+
+type avroField struct {
+	name     string
+	property string
+	schema   *avroType
+}
+
+func (t *avroType) field(property string) *avroField {
+	for _, f := range t.fields {
+		if f.property == property {
+			return f
+		}
+	}
+	return nil
+}
+
+func (t *avroType) addField(property string, schema *avroType) *avroField {
+	name := avroName(property)
+	for i := 2; slices.ContainsFunc(t.fields, func(f *avroField) bool { return f.name == name }); i++ {
+		name = avroName(property) + "_" + strconv.Itoa(i)
+	}
+	f := &avroField{name: name, property: property, schema: schema}
+	t.fields = append(t.fields, f)
+	return f
+}
+
+// buildSchema builds the row record schema - nested properties become nested records and properties whose type
+// is not known from the columns are inferred from the pending rows
+func (e *avroEncoder) buildSchema() *avroType {
+	result := &avroType{kind: avroRecord}
+	columns := make(map[string]ColumnInfo, len(e.info.Columns))
+	for _, col := range e.info.Columns {
+		columns[col.Property] = col
+	}
+	for _, property := range e.info.Properties {
+		path := strings.Split(property, ".")
+		record := result
+		for _, name := range path[:len(path)-1] {
+			f := record.field(name)
+			if f == nil {
+				f = record.addField(name, &avroType{kind: avroRecord, nullable: true})
+			}
+			if record = f.schema; record.kind != avroRecord {
+				break
+			}
+		}
+		name := path[len(path)-1]
+		if record.kind != avroRecord || record.field(name) != nil {
+			continue
+		}
+		var schema *avroType
+		if col, ok := columns[property]; ok {
+			schema = e.columnType(col)
+		}
+		if schema == nil {
+			for _, row := range e.pending {
+				schema = e.inferType(schema, pathValue(row, path))
+			}
+			if schema == nil {
+				schema = &avroType{kind: avroUnknown}
+			}
+			schema.nullable, schema.inferred = true, true
+		}
+		record.addField(name, schema)
+	}
+	result.nameRecords(avroName(e.name), map[string]bool{})
+	return result
+}
+
+// isFloatColumn determines whether a column is a floating point column (by database type or scan type) - float columns
+// read as decimals whose precision and scale are not known are written as doubles (rather than decimals of the default scale)
+func isFloatColumn(dbType string, st reflect.Type) bool {
+	switch {
+	case dbType == "DOUBLE" || dbType == "REAL" || strings.HasPrefix(dbType, "FLOAT") || strings.HasPrefix(dbType, "DOUBLE"):
+		return true
+	case st == nullFloat64Type:
+		return true
+	}
+	return st != nil && (st.Kind() == reflect.Float32 || st.Kind() == reflect.Float64)
+}
+
+var (
+	timeType        = reflect.TypeOf(time.Time{})
+	nullTimeType    = reflect.TypeOf(sql.NullTime{})
+	nullInt64Type   = reflect.TypeOf(sql.NullInt64{})
+	nullInt32Type   = reflect.TypeOf(sql.NullInt32{})
+	nullInt16Type   = reflect.TypeOf(sql.NullInt16{})
+	nullByteType    = reflect.TypeOf(sql.NullByte{})
+	nullFloat64Type = reflect.TypeOf(sql.NullFloat64{})
+	nullBoolType    = reflect.TypeOf(sql.NullBool{})
+	nullStringType  = reflect.TypeOf(sql.NullString{})
+	rawBytesType    = reflect.TypeOf(sql.RawBytes{})
+)
+
+// columnType returns the schema type for a column - or nil if the type cannot be determined from the column
+func (e *avroEncoder) columnType(col ColumnInfo) *avroType {
+	if col.Converted {
+		return nil
+	}
+	result := &avroType{nullable: col.Nullable}
+	st := col.ScanType
+	for st != nil && st.Kind() == reflect.Ptr {
+		st = st.Elem()
+	}
+	switch {
+	case col.Decimal && !col.DecimalSize && isFloatColumn(col.DatabaseType, st):
+		result.kind = avroDouble
+	case col.Decimal || st == decimalType:
+		result.kind, result.precision, result.scale = avroDecimal, e.precision, e.scale
+		if col.DecimalSize && col.Precision > 0 {
+			result.precision, result.scale = int(col.Precision), int(col.Scale)
+		}
+	case st == nil:
+		return nil
+	case st == timeType || st == nullTimeType:
+		result.kind = avroTimestamp
+	case st == nullInt64Type || st == nullInt32Type || st == nullInt16Type || st == nullByteType:
+		result.kind = avroLong
+	case st == nullFloat64Type:
+		result.kind = avroDouble
+	case st == nullBoolType:
+		result.kind = avroBoolean
+	case st == nullStringType:
+		result.kind = avroString
+	case st == rawBytesType || (st.Kind() == reflect.Slice && st.Elem().Kind() == reflect.Uint8):
+		result.kind = avroBytes
+	default:
+		switch st.Kind() {
+		case reflect.Bool:
+			result.kind = avroBoolean
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+			reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+			result.kind = avroLong
+		case reflect.Float32, reflect.Float64:
+			result.kind = avroDouble
+		case reflect.String:
+			result.kind = avroString
+		default:
+			return nil
+		}
+	}
+	return result
+}
+
+// inferType merges the type of a value into an inferred schema type (which may be nil) - conflicting types are inferred as string
+func (e *avroEncoder) inferType(t *avroType, v any) *avroType {
+	v, err := avroValue(v)
+	if err != nil || v == nil {
+		return t
+	}
+	var kind avroKind
+	switch v.(type) {
+	case bool:
+		kind = avroBoolean
+	case int64, uint64:
+		kind = avroLong
+	case float64:
+		kind = avroDouble
+	case []byte:
+		kind = avroBytes
+	case time.Time:
+		kind = avroTimestamp
+	case decimal.Decimal:
+		kind = avroDecimal
+	case map[string]any:
+		kind = avroRecord
+	case []any:
+		kind = avroArray
+	default:
+		kind = avroString
+	}
+	if t == nil || t.kind == avroUnknown {
+		t = &avroType{kind: kind, nullable: true, inferred: true}
+		if kind == avroDecimal {
+			t.precision, t.scale = e.precision, e.scale
+		}
+	} else if t.kind != kind {
+		if (t.kind == avroLong && kind == avroDouble) || (t.kind == avroDouble && kind == avroLong) {
+			t.kind = avroDouble
+		} else {
+			*t = avroType{kind: avroString, nullable: true, inferred: true}
+		}
+		return t
+	}
+	switch vt := v.(type) {
+	case map[string]any:
+		keys := make([]string, 0, len(vt))
+		for k := range vt {
+			keys = append(keys, k)
+		}
+		slices.Sort(keys)
+		for _, k := range keys {
+			if f := t.field(k); f != nil {
+				f.schema = e.inferType(f.schema, vt[k])
+			} else if ft := e.inferType(nil, vt[k]); ft != nil {
+				t.addField(k, ft)
+			} else {
+				t.addField(k, &avroType{kind: avroUnknown, nullable: true, inferred: true})
+			}
+		}
+		slices.SortFunc(t.fields, func(a, b *avroField) int {
+			return strings.Compare(a.name, b.name)
+		})
+	case []any:
+		for _, item := range vt {
+			t.items = e.inferType(t.items, item)
+		}
+	}
+	return t
+}
+
+// nameRecords names the record types (nested records are named using the names of their parents) and sets the
+// items of arrays (with no inferred items) to string
+func (t *avroType) nameRecords(name string, used map[string]bool) {
+	switch t.kind {
+	case avroRecord:
+		t.name = name
+		for i := 2; used[t.name]; i++ {
+			t.name = name + "_" + strconv.Itoa(i)
+		}
+		used[t.name] = true
+		for _, f := range t.fields {
+			f.schema.nameRecords(t.name+"_"+f.name, used)
+		}
+	case avroArray:
+		if t.items == nil {
+			t.items = &avroType{kind: avroUnknown, nullable: true, inferred: true}
+		}
+		t.items.nameRecords(name+"_item", used)
+	}
+}
+
+// json returns the JSON representation of the schema type
+func (t *avroType) json(namespace string) any {
+	var result any
+	switch t.kind {
+	case avroTimestamp:
+		result = map[string]any{"type": "long", "logicalType": "timestamp-millis"}
+	case avroDecimal:
+		result = map[string]any{"type": "bytes", "logicalType": "decimal", "precision": t.precision, "scale": t.scale}
+	case avroArray:
+		result = map[string]any{"type": "array", "items": t.items.json("")}
+	case avroRecord:
+		fields := make([]any, len(t.fields))
+		for i, f := range t.fields {
+			field := map[string]any{"name": f.name, "type": f.schema.json("")}
+			if f.schema.nullable {
+				field["default"] = nil
+			}
+			fields[i] = field
+		}
+		record := map[string]any{"type": "record", "name": t.name, "fields": fields}
+		if namespace != "" {
+			record["namespace"] = namespace
+		}
+		result = record
+	default:
+		result = avroKindNames[t.kind]
+	}
+	if t.nullable {
+		return []any{"null", result}
+	}
+	return result
+}
+
+// append appends the Avro binary encoding of a value
+func (t *avroType) append(b []byte, v any, property string) ([]byte, error) {
+	v, err := avroValue(v)
+	if err != nil {
+		return nil, err
+	}
+	if t.nullable {
+		if v == nil {
+			return binary.AppendVarint(b, 0), nil
+		}
+		b = binary.AppendVarint(b, 1)
+	} else if v == nil {
+		return nil, fmt.Errorf("avro property '%s' cannot be null", property)
+	}
+	ok := true
+	switch t.kind {
+	case avroBoolean:
+		var bv bool
+		if bv, ok = avroBool(v); ok {
+			if bv {
+				b = append(b, 1)
+			} else {
+				b = append(b, 0)
+			}
+		}
+	case avroLong:
+		var i int64
+		if i, ok = avroLongValue(v); ok {
+			b = binary.AppendVarint(b, i)
+		}
+	case avroDouble:
+		var f float64
+		if f, ok = avroDoubleValue(v); ok {
+			b = binary.LittleEndian.AppendUint64(b, math.Float64bits(f))
+		}
+	case avroBytes:
+		switch vt := v.(type) {
+		case []byte:
+			b = appendAvroBytes(b, vt)
+		case string:
+			b = appendAvroBytes(b, []byte(vt))
+		default:
+			ok = false
+		}
+	case avroTimestamp:
+		var tv time.Time
+		if tv, ok = avroTimeValue(v); ok {
+			b = binary.AppendVarint(b, tv.UnixMilli())
+		}
+	case avroDecimal:
+		var d decimal.Decimal
+		if d, ok = avroDecimalValue(v); ok {
+			return appendAvroDecimal(b, d, t.precision, t.scale, property)
+		}
+	case avroRecord:
+		var obj map[string]any
+		if obj, ok = v.(map[string]any); ok {
+			return t.appendRecord(b, obj, property)
+		}
+	case avroArray:
+		var items []any
+		if items, ok = v.([]any); ok {
+			if len(items) > 0 {
+				b = binary.AppendVarint(b, int64(len(items)))
+				for _, item := range items {
+					if b, err = t.items.append(b, item, property); err != nil {
+						return nil, err
+					}
+				}
+			}
+			b = binary.AppendVarint(b, 0)
+		}
+	default:
+		var s string
+		if s, err = avroStringValue(v); err != nil {
+			return nil, err
+		}
+		b = appendAvroBytes(b, []byte(s))
+	}
+	if !ok {
+		return nil, fmt.Errorf("avro property '%s' cannot encode %T as %s", property, v, avroKindNames[t.kind])
+	}
+	return b, nil
+}
+
+func (t *avroType) appendRecord(b []byte, obj map[string]any, property string) (_ []byte, err error) {
+	prefix := property
+	if prefix != "" {
+		prefix += "."
+	}
+	if t.inferred {
+		for k := range obj {
+			if t.field(k) == nil {
+				return nil, fmt.Errorf("avro property '%s' is not in the inferred schema", prefix+k)
+			}
+		}
+	}
+	for _, f := range t.fields {
+		if b, err = f.schema.append(b, obj[f.property], prefix+f.property); err != nil {
+			return nil, err
+		}
+	}
+	return b, nil
+}
+
+// avroValue normalizes a value for encoding (see plainValue) - time.Time and decimal.Decimal values are not converted
+func avroValue(v any) (any, error) {
+	for {
+		switch vt := v.(type) {
+		case nil, bool, int64, uint64, float64, string, []byte, time.Time, decimal.Decimal, map[string]any, []any:
+			return v, nil
+		case *time.Time:
+			if vt == nil {
+				return nil, nil
+			}
+			return *vt, nil
+		case *decimal.Decimal:
+			if vt == nil {
+				return nil, nil
+			}
+			return *vt, nil
+		case decimal.NullDecimal:
+			if !vt.Valid {
+				return nil, nil
+			}
+			return vt.Decimal, nil
+		}
+		var err error
+		if v, err = plainValue(v); err != nil {
+			return nil, err
+		}
+	}
+}
+
+func avroBool(v any) (bool, bool) {
+	switch vt := v.(type) {
+	case bool:
+		return vt, true
+	case int64:
+		return vt != 0, true
+	case uint64:
+		return vt != 0, true
+	case string:
+		b, err := strconv.ParseBool(vt)
+		return b, err == nil
+	case []byte:
+		b, err := strconv.ParseBool(string(vt))
+		return b, err == nil
+	}
+	return false, false
+}
+
+func avroLongValue(v any) (int64, bool) {
+	switch vt := v.(type) {
+	case int64:
+		return vt, true
+	case uint64:
+		return int64(vt), vt <= math.MaxInt64
+	case float64:
+		return int64(vt), vt == math.Trunc(vt) && vt >= math.MinInt64 && vt < math.MaxInt64
+	case bool:
+		if vt {
+			return 1, true
+		}
+		return 0, true
+	case string:
+		i, err := strconv.ParseInt(vt, 10, 64)
+		return i, err == nil
+	case []byte:
+		i, err := strconv.ParseInt(string(vt), 10, 64)
+		return i, err == nil
+	}
+	return 0, false
+}
+
+func avroDoubleValue(v any) (float64, bool) {
+	switch vt := v.(type) {
+	case float64:
+		return vt, true
+	case int64:
+		return float64(vt), true
+	case uint64:
+		return float64(vt), true
+	case decimal.Decimal:
+		return vt.InexactFloat64(), true
+	case string:
+		f, err := strconv.ParseFloat(vt, 64)
+		return f, err == nil
+	case []byte:
+		f, err := strconv.ParseFloat(string(vt), 64)
+		return f, err == nil
+	}
+	return 0, false
+}
+
+func avroDecimalValue(v any) (decimal.Decimal, bool) {
+	switch vt := v.(type) {
+	case decimal.Decimal:
+		return vt, true
+	case int64:
+		return decimal.New(vt, 0), true
+	case uint64:
+		return decimal.NewFromBigInt(new(big.Int).SetUint64(vt), 0), true
+	case float64:
+		return decimal.NewFromFloat(vt), !math.IsNaN(vt) && !math.IsInf(vt, 0)
+	case string:
+		d, err := decimal.NewFromString(vt)
+		return d, err == nil
+	case []byte:
+		d, err := decimal.NewFromString(string(vt))
+		return d, err == nil
+	}
+	return decimal.Decimal{}, false
+}
+
+var avroTimeLayouts = []string{time.RFC3339Nano, "2006-01-02 15:04:05.999999999", "2006-01-02"}
+
+func avroTimeValue(v any) (time.Time, bool) {
+	var s string
+	switch vt := v.(type) {
+	case time.Time:
+		return vt, true
+	case string:
+		s = vt
+	case []byte:
+		s = string(vt)
+	default:
+		return time.Time{}, false
+	}
+	for _, layout := range avroTimeLayouts {
+		if t, err := time.Parse(layout, s); err == nil {
+			return t, true
+		}
+	}
+	return time.Time{}, false
+}
+
+func avroStringValue(v any) (string, error) {
+	switch vt := v.(type) {
+	case string:
+		return vt, nil
+	case []byte:
+		return string(vt), nil
+	case bool:
+		return strconv.FormatBool(vt), nil
+	case int64:
+		return strconv.FormatInt(vt, 10), nil
+	case uint64:
+		return strconv.FormatUint(vt, 10), nil
+	case float64:
+		return strconv.FormatFloat(vt, 'f', -1, 64), nil
+	case decimal.Decimal:
+		return vt.String(), nil
+	case time.Time:
+		return vt.Format(time.RFC3339Nano), nil
+	}
+	data, err := json.Marshal(v)
+	return string(data), err
+}
+
+func appendAvroBytes(b []byte, data []byte) []byte {
+	return append(binary.AppendVarint(b, int64(len(data))), data...)
+}
+
+// appendAvroDecimal appends a decimal as the (big-endian two's complement) unscaled value at the scale
+func appendAvroDecimal(b []byte, d decimal.Decimal, precision int, scale int, property string) ([]byte, error) {
+	if -int(d.Exponent()) > scale && !d.Equal(d.Truncate(int32(scale))) {
+		return nil, fmt.Errorf("avro property '%s' decimal %s exceeds scale %d", property, d.String(), scale)
+	}
+	unscaled := d.Shift(int32(scale)).BigInt()
+	if digits := len(new(big.Int).Abs(unscaled).String()); digits > precision {
+		return nil, fmt.Errorf("avro property '%s' decimal %s exceeds precision %d", property, d.String(), precision)
+	}
+	var data []byte
+	if unscaled.Sign() >= 0 {
+		if data = unscaled.Bytes(); len(data) == 0 || data[0]&0x80 != 0 {
+			data = append([]byte{0}, data...)
+		}
+	} else {
+		// the two's complement of a negative value is the complement of its magnitude less one
+		m := new(big.Int).Sub(new(big.Int).Neg(unscaled), big.NewInt(1))
+		data = m.FillBytes(make([]byte, m.BitLen()/8+1))
+		for i := range data {
+			data[i] = ^data[i]
+		}
+	}
+	return appendAvroBytes(b, data), nil
+}
+
+// avroName converts a property name to a valid Avro name (i.e. matching `[A-Za-z_][A-Za-z0-9_]*`)
+func avroName(name string) string {
+	result := make([]byte, 0, len(name)+1)
+	if name == "" || (name[0] >= '0' && name[0] <= '9') {
+		result = append(result, '_')
+	}
+	for i := 0; i < len(name); i++ {
+		if c := name[i]; c == '_' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || (c >= '0' && c <= '9') {
+			result = append(result, c)
+		} else {
+			result = append(result, '_')
+		}
+	}
+	return string(result)
+}
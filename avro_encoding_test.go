@@ -0,0 +1,364 @@
+package columbus
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"io"
+	"math"
+	"math/big"
+	"testing"
+	"time"
+)
+
+func TestAppendAvroDecimal(t *testing.T) {
+	testCases := []struct {
+		value  string
+		scale  int
+		expect string
+	}{
+		{value: "0", scale: 0, expect: "0200"},
+		{value: "127", scale: 0, expect: "027f"},
+		{value: "128", scale: 0, expect: "040080"},
+		{value: "-1", scale: 0, expect: "02ff"},
+		{value: "-128", scale: 0, expect: "0280"},
+		{value: "-129", scale: 0, expect: "04ff7f"},
+		{value: "1.5", scale: 2, expect: "040096"},
+		{value: "-1.50", scale: 2, expect: "04ff6a"},
+		{value: "1.2300", scale: 2, expect: "027b"},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.value, func(t *testing.T) {
+			b, err := appendAvroDecimal(nil, decimal.RequireFromString(tc.value), 10, tc.scale, "p")
+			require.NoError(t, err)
+			assert.Equal(t, tc.expect, hex.EncodeToString(b))
+		})
+	}
+	_, err := appendAvroDecimal(nil, decimal.RequireFromString("1.234"), 10, 2, "p")
+	require.Error(t, err)
+	assert.Equal(t, "avro property 'p' decimal 1.234 exceeds scale 2", err.Error())
+	_, err = appendAvroDecimal(nil, decimal.RequireFromString("123.4"), 4, 2, "p")
+	require.Error(t, err)
+	assert.Equal(t, "avro property 'p' decimal 123.4 exceeds precision 4", err.Error())
+}
+
+func TestAvroName(t *testing.T) {
+	assert.Equal(t, "abc_1", avroName("abc_1"))
+	assert.Equal(t, "first_name", avroName("first name"))
+	assert.Equal(t, "_1st", avroName("1st"))
+	assert.Equal(t, "_", avroName(""))
+	assert.Equal(t, "caf__", avroName("café"))
+}
+
+func TestMapper_Write_Avro(t *testing.T) {
+	m, err := newMapper("id,name,amount,created,city,data", Query(`FROM table`), UseDecimals(true), Mappings{
+		"city": {Path: []string{"address"}},
+		"data": {Scanner: func(value any) (any, error) {
+			return map[string]any{"size": int64(len(value.([]byte)))}, nil
+		}},
+	}, NewSubQuery("subs", `SELECT sub_id FROM sub_table WHERE id = ?`, []string{"id"}, nil, false))
+	require.NoError(t, err)
+	db, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+	require.NoError(t, err)
+	defer func() {
+		_ = db.Close()
+	}()
+	created := time.Date(2026, 1, 2, 3, 4, 5, 6000000, time.UTC)
+	newRows := func() *sqlmock.Rows {
+		return sqlmock.NewRowsWithColumnDefinition(
+			sqlmock.NewColumn("id").OfType("BIGINT", int64(0)).Nullable(false),
+			sqlmock.NewColumn("name").OfType("VARCHAR", "").Nullable(true),
+			sqlmock.NewColumn("amount").OfType("DECIMAL", "").Nullable(false).WithPrecisionAndScale(10, 2),
+			sqlmock.NewColumn("created").OfType("TIMESTAMP", time.Time{}).Nullable(false),
+			sqlmock.NewColumn("city").OfType("VARCHAR", "").Nullable(true),
+			sqlmock.NewColumn("data").OfType("BLOB", []byte{}).Nullable(false)).
+			AddRow(1, "foo", "1.50", created, "London", []byte{1, 2}).
+			AddRow(2, nil, "-3", created, nil, []byte{})
+	}
+	expectSubs := func() {
+		mock.ExpectQuery("SELECT sub_id FROM sub_table WHERE id = ?").WithArgs(1).WillReturnRows(sqlmock.NewRows([]string{"sub_id"}).AddRow(11))
+		mock.ExpectQuery("SELECT sub_id FROM sub_table WHERE id = ?").WithArgs(2).WillReturnRows(sqlmock.NewRows([]string{"sub_id"}))
+	}
+	expectSchema := `{"fields":[` +
+		`{"name":"id","type":"long"},` +
+		`{"default":null,"name":"name","type":["null","string"]},` +
+		`{"name":"amount","type":{"logicalType":"decimal","precision":10,"scale":2,"type":"bytes"}},` +
+		`{"name":"created","type":{"logicalType":"timestamp-millis","type":"long"}},` +
+		`{"default":null,"name":"address","type":["null",{"fields":[{"default":null,"name":"city","type":["null","string"]}],"name":"Row_address","type":"record"}]},` +
+		`{"default":null,"name":"data","type":["null",{"fields":[{"default":null,"name":"size","type":["null","long"]}],"name":"Row_data","type":"record"}]},` +
+		`{"default":null,"name":"subs","type":["null",{"items":["null",{"fields":[{"default":null,"name":"sub_id","type":["null","long"]}],"name":"Row_subs_item","type":"record"}],"type":"array"}]}` +
+		`],"name":"Row","type":"record"}`
+	row1 := map[string]any{"id": int64(1), "name": "foo", "amount": decimal.New(150, -2), "created": created,
+		"address": map[string]any{"city": "London"}, "data": map[string]any{"size": int64(2)},
+		"subs": []any{map[string]any{"sub_id": int64(11)}}}
+	row2 := map[string]any{"id": int64(2), "name": nil, "amount": decimal.New(-300, -2), "created": created,
+		"address": map[string]any{"city": nil}, "data": map[string]any{"size": int64(0)},
+		"subs": []any{}}
+
+	mock.ExpectQuery("SELECT id,name,amount,created,city,data FROM table").WillReturnRows(newRows())
+	expectSubs()
+	w := &testFlushWriter{}
+	err = m.WriteRows(ctx, w, db, nil, Accept("application/avro"))
+	require.NoError(t, err)
+	require.NoError(t, mock.ExpectationsWereMet())
+	assert.Len(t, w.flushed, 1)
+	schema, rows, blocks := readAvroFile(t, w.Bytes())
+	assert.Equal(t, expectSchema, schema)
+	assert.Equal(t, []any{row1, row2}, rows)
+	assert.Equal(t, 1, blocks)
+
+	mock.ExpectQuery("SELECT id,name,amount,created,city,data FROM table").WillReturnRows(newRows())
+	expectSubs()
+	w = &testFlushWriter{}
+	err = m.WriteRows(ctx, w, db, nil, &AvroEncoding{BlockSize: 1, Name: "Item", Namespace: "com.example"})
+	require.NoError(t, err)
+	require.NoError(t, mock.ExpectationsWereMet())
+	assert.Len(t, w.flushed, 2)
+	schema, rows, blocks = readAvroFile(t, w.Bytes())
+	assert.Contains(t, schema, `"name":"Item","namespace":"com.example","type":"record"}`)
+	assert.Equal(t, []any{row1, row2}, rows)
+	assert.Equal(t, 2, blocks)
+
+	mock.ExpectQuery("SELECT id,name,amount,created,city,data FROM table").WillReturnRows(sqlmock.NewRows([]string{"id", "name", "amount", "created", "city", "data"}))
+	w = &testFlushWriter{}
+	err = m.WriteRows(ctx, w, db, nil, Avro, ConditionalExclude(func(property string, path []string) bool {
+		return property == "subs" || property == "data"
+	}))
+	require.NoError(t, err)
+	require.NoError(t, mock.ExpectationsWereMet())
+	schema, rows, blocks = readAvroFile(t, w.Bytes())
+	assert.Equal(t, `{"fields":[`+
+		`{"default":null,"name":"id","type":["null","string"]},`+
+		`{"default":null,"name":"name","type":["null","string"]},`+
+		`{"default":null,"name":"amount","type":["null","string"]},`+
+		`{"default":null,"name":"created","type":["null","string"]},`+
+		`{"default":null,"name":"address","type":["null",{"fields":[{"default":null,"name":"city","type":["null","string"]}],"name":"Row_address","type":"record"}]}`+
+		`],"name":"Row","type":"record"}`, schema)
+	assert.Empty(t, rows)
+	assert.Equal(t, 0, blocks)
+
+	mock.ExpectQuery("SELECT id,name,amount,created,city,data FROM table").WillReturnRows(newRows().AddRow(3, nil, "1.234", created, nil, []byte{}))
+	expectSubs()
+	mock.ExpectQuery("SELECT sub_id FROM sub_table WHERE id = ?").WithArgs(3).WillReturnRows(sqlmock.NewRows([]string{"sub_id"}))
+	err = m.WriteRows(ctx, &testFlushWriter{}, db, nil, Avro)
+	require.Error(t, err)
+	assert.Equal(t, "avro property 'amount' decimal 1.234 exceeds scale 2", err.Error())
+}
+
+func TestMapper_Write_Avro_FloatColumns(t *testing.T) {
+	m, err := newMapper("ratio,total", Query(`FROM table`))
+	require.NoError(t, err)
+	db, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+	require.NoError(t, err)
+	defer func() {
+		_ = db.Close()
+	}()
+	mock.ExpectQuery("SELECT ratio,total FROM table").WillReturnRows(sqlmock.NewRowsWithColumnDefinition(
+		sqlmock.NewColumn("ratio").OfType("DOUBLE", float64(0)).Nullable(false),
+		sqlmock.NewColumn("total").OfType("FLOAT", float64(0)).Nullable(false).WithPrecisionAndScale(10, 2)).
+		AddRow(0.1234567890123, "1.50"))
+	w := &testFlushWriter{}
+	err = m.WriteRows(ctx, w, db, nil, Avro)
+	require.NoError(t, err)
+	require.NoError(t, mock.ExpectationsWereMet())
+	schema, rows, _ := readAvroFile(t, w.Bytes())
+	assert.Equal(t, `{"fields":[`+
+		`{"name":"ratio","type":"double"},`+
+		`{"name":"total","type":{"logicalType":"decimal","precision":10,"scale":2,"type":"bytes"}}`+
+		`],"name":"Row","type":"record"}`, schema)
+	assert.Equal(t, []any{map[string]any{"ratio": 0.1234567890123, "total": decimal.New(150, -2)}}, rows)
+}
+
+func TestStructMapper_WriteRows_Avro(t *testing.T) {
+	m, err := NewStructMapper[testCsvPerson]("id,name,amount,city", Query(`FROM people`))
+	require.NoError(t, err)
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer func() {
+		_ = db.Close()
+	}()
+	mock.ExpectQuery("").WillReturnRows(sqlmock.NewRows([]string{"id", "name", "amount", "city"}).
+		AddRow(1, "foo", "1.50", "London").
+		AddRow(2, "bar", "2", nil))
+	w := &testFlushWriter{}
+	err = m.WriteRows(ctx, w, db, nil, &AvroEncoding{DecimalPrecision: 10, DecimalScale: 2})
+	require.NoError(t, err)
+	require.NoError(t, mock.ExpectationsWereMet())
+	schema, rows, _ := readAvroFile(t, w.Bytes())
+	assert.Equal(t, `{"fields":[`+
+		`{"name":"id","type":"long"},`+
+		`{"name":"name","type":"string"},`+
+		`{"name":"amount","type":{"logicalType":"decimal","precision":10,"scale":2,"type":"bytes"}},`+
+		`{"default":null,"name":"Address","type":["null",{"fields":[{"default":null,"name":"city","type":["null","string"]}],"name":"Row_Address","type":"record"}]},`+
+		`{"default":null,"name":"Tags","type":["null","string"]}`+
+		`],"name":"Row","type":"record"}`, schema)
+	assert.Equal(t, []any{
+		map[string]any{"id": int64(1), "name": "foo", "amount": decimal.New(150, -2), "Address": map[string]any{"city": "London"}, "Tags": nil},
+		map[string]any{"id": int64(2), "name": "bar", "amount": decimal.New(200, -2), "Address": map[string]any{"city": nil}, "Tags": nil},
+	}, rows)
+}
+
+func TestAvroEncoder_InferredTypes(t *testing.T) {
+	buf := bytes.NewBuffer(nil)
+	enc := (&AvroEncoding{}).NewEncoder(buf)
+	require.NoError(t, enc.Begin(EncodeInfo{Properties: []string{"a", "b", "c", "d", "e"}}))
+	require.NoError(t, enc.Row(map[string]any{"a": 1, "b": "x", "c": []string{"x"}, "d": true, "e": nil}))
+	require.NoError(t, enc.Row(map[string]any{"a": 1.5, "b": 2, "c": nil, "d": nil, "e": nil}))
+	require.NoError(t, enc.End())
+	schema, rows, _ := readAvroFile(t, buf.Bytes())
+	assert.Equal(t, `{"fields":[`+
+		`{"default":null,"name":"a","type":["null","double"]},`+
+		`{"default":null,"name":"b","type":["null","string"]},`+
+		`{"default":null,"name":"c","type":["null",{"items":["null","string"],"type":"array"}]},`+
+		`{"default":null,"name":"d","type":["null","boolean"]},`+
+		`{"default":null,"name":"e","type":["null","string"]}`+
+		`],"name":"Row","type":"record"}`, schema)
+	assert.Equal(t, []any{
+		map[string]any{"a": 1.0, "b": "x", "c": []any{"x"}, "d": true, "e": nil},
+		map[string]any{"a": 1.5, "b": "2", "c": nil, "d": nil, "e": nil},
+	}, rows)
+
+	enc = (&AvroEncoding{BlockSize: 1}).NewEncoder(bytes.NewBuffer(nil))
+	require.NoError(t, enc.Begin(EncodeInfo{Properties: []string{"obj"}}))
+	require.NoError(t, enc.Row(map[string]any{"obj": map[string]any{"a": 1}}))
+	err := enc.Row(map[string]any{"obj": map[string]any{"a": 1, "b": 2}})
+	require.Error(t, err)
+	assert.Equal(t, "avro property 'obj.b' is not in the inferred schema", err.Error())
+	err = enc.Row(map[string]any{"obj": "x"})
+	require.Error(t, err)
+	assert.Equal(t, "avro property 'obj' cannot encode string as record", err.Error())
+	require.NoError(t, enc.End())
+}
+
+// readAvroFile reads an Avro object container file - returning the schema, the decoded rows and the number of blocks
+func readAvroFile(t *testing.T, data []byte) (string, []any, int) {
+	r := bytes.NewReader(data)
+	magic := make([]byte, 4)
+	_, err := io.ReadFull(r, magic)
+	require.NoError(t, err)
+	require.Equal(t, "Obj\x01", string(magic))
+	meta := map[string]string{}
+	for {
+		count, err := binary.ReadVarint(r)
+		require.NoError(t, err)
+		if count == 0 {
+			break
+		}
+		for ; count > 0; count-- {
+			k, v := readAvroTestBytes(t, r), readAvroTestBytes(t, r)
+			meta[string(k)] = string(v)
+		}
+	}
+	require.Equal(t, "null", meta["avro.codec"])
+	var schema any
+	require.NoError(t, json.Unmarshal([]byte(meta["avro.schema"]), &schema))
+	sync := make([]byte, 16)
+	_, err = io.ReadFull(r, sync)
+	require.NoError(t, err)
+	rows := make([]any, 0)
+	blocks := 0
+	for {
+		count, err := binary.ReadVarint(r)
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		require.NoError(t, err)
+		size, err := binary.ReadVarint(r)
+		require.NoError(t, err)
+		before := r.Len()
+		for ; count > 0; count-- {
+			rows = append(rows, readAvroTestValue(t, r, schema))
+		}
+		require.Equal(t, int(size), before-r.Len())
+		blockSync := make([]byte, 16)
+		_, err = io.ReadFull(r, blockSync)
+		require.NoError(t, err)
+		require.Equal(t, sync, blockSync)
+		blocks++
+	}
+	return meta["avro.schema"], rows, blocks
+}
+
+func readAvroTestBytes(t *testing.T, r *bytes.Reader) []byte {
+	n, err := binary.ReadVarint(r)
+	require.NoError(t, err)
+	b := make([]byte, n)
+	_, err = io.ReadFull(r, b)
+	require.NoError(t, err)
+	return b
+}
+
+func readAvroTestValue(t *testing.T, r *bytes.Reader, schema any) any {
+	switch st := schema.(type) {
+	case []any:
+		index, err := binary.ReadVarint(r)
+		require.NoError(t, err)
+		return readAvroTestValue(t, r, st[index])
+	case map[string]any:
+		switch st["logicalType"] {
+		case "timestamp-millis":
+			ms, err := binary.ReadVarint(r)
+			require.NoError(t, err)
+			return time.UnixMilli(ms).UTC()
+		case "decimal":
+			b := readAvroTestBytes(t, r)
+			i := new(big.Int).SetBytes(b)
+			if len(b) > 0 && b[0]&0x80 != 0 {
+				i.Sub(i, new(big.Int).Lsh(big.NewInt(1), uint(len(b)*8)))
+			}
+			return decimal.NewFromBigInt(i, -int32(st["scale"].(float64)))
+		}
+		switch st["type"] {
+		case "record":
+			result := map[string]any{}
+			for _, f := range st["fields"].([]any) {
+				field := f.(map[string]any)
+				result[field["name"].(string)] = readAvroTestValue(t, r, field["type"])
+			}
+			return result
+		case "array":
+			result := make([]any, 0)
+			for {
+				count, err := binary.ReadVarint(r)
+				require.NoError(t, err)
+				if count == 0 {
+					break
+				}
+				for ; count > 0; count-- {
+					result = append(result, readAvroTestValue(t, r, st["items"]))
+				}
+			}
+			return result
+		}
+	case string:
+		switch st {
+		case "null":
+			return nil
+		case "boolean":
+			b, err := r.ReadByte()
+			require.NoError(t, err)
+			return b == 1
+		case "long":
+			i, err := binary.ReadVarint(r)
+			require.NoError(t, err)
+			return i
+		case "double":
+			b := make([]byte, 8)
+			_, err := io.ReadFull(r, b)
+			require.NoError(t, err)
+			return math.Float64frombits(binary.LittleEndian.Uint64(b))
+		case "string":
+			return string(readAvroTestBytes(t, r))
+		case "bytes":
+			return readAvroTestBytes(t, r)
+		}
+	}
+	t.Fatalf("unsupported schema %v", schema)
+	return nil
+}
@@ -0,0 +1,293 @@
+package columbus
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// BatchSubQuery is a SubQuery that can also be executed once for a batch of rows
+//
+// use NewBatchSubQuery, NewBatchObjectSubQuery or NewBatchMergeSubQuery to create appropriate batch sub-query type
+//
+// When a Mapper has any BatchSubQuery(s), mapped rows are collected into batches (see BatchSize) and each
+// BatchSubQuery is executed once per batch - rather than once per row
+type BatchSubQuery interface {
+	SubQuery
+	// ExecuteBatch executes the BatchSubQuery for all the supplied rows
+	ExecuteBatch(ctx context.Context, sqli SqlInterface, rows []map[string]any, exclusions PropertyExclusions) error
+}
+
+// NewBatchSubQuery creates a new batch sub-query that creates an array property in the mapped rows
+//
+// the query should contain a single '?' arg marker, which is expanded to the arg values of all rows in the batch - e.g.
+//
+//	`SELECT * FROM addresses WHERE person_id IN (?)`
+//
+// where there are multiple argColumns, the marker is expanded to row value lists - e.g.
+//
+//	`SELECT * FROM addresses WHERE (person_id, kind) IN (?)`
+//
+// keyColumns are the properties in the sub-query rows that correspond to argColumns - and are used to assign the sub-query rows back to each row
+func NewBatchSubQuery(propertyName string, query string, argColumns []string, keyColumns []string, mappings Mappings, emptyNil bool) SubQuery {
+	return &sliceBatchSubQuery{batchSubQuery{
+		subQuery: subQuery{
+			propertyName: propertyName,
+			query:        query,
+			argColumns:   argColumns,
+			mappings:     mappings,
+			emptyNil:     emptyNil,
+		},
+		keyColumns: keyColumns,
+	}}
+}
+
+// NewBatchObjectSubQuery creates a new batch sub-query that creates an object property in the mapped rows
+//
+// the query and keyColumns are as described for NewBatchSubQuery - where more than one sub-query row matches, the first is used
+func NewBatchObjectSubQuery(propertyName string, query string, argColumns []string, keyColumns []string, mappings Mappings, emptyNil bool) SubQuery {
+	return &objectBatchSubQuery{batchSubQuery{
+		subQuery: subQuery{
+			propertyName: propertyName,
+			query:        query,
+			argColumns:   argColumns,
+			mappings:     mappings,
+			emptyNil:     emptyNil,
+		},
+		keyColumns: keyColumns,
+	}}
+}
+
+// NewBatchMergeSubQuery creates a new batch sub-query that reads an object for each mapped row and merges the properties from
+// that object into the mapped row
+//
+// the query and keyColumns are as described for NewBatchSubQuery - where more than one sub-query row matches, the first is used
+func NewBatchMergeSubQuery(query string, argColumns []string, keyColumns []string, mappings Mappings, noOverwrite bool) SubQuery {
+	return &mergeBatchSubQuery{
+		noOverwrite: noOverwrite,
+		batchSubQuery: batchSubQuery{
+			subQuery: subQuery{
+				query:      query,
+				argColumns: argColumns,
+				mappings:   mappings,
+			},
+			keyColumns: keyColumns,
+		}}
+}
+
+type batchSubQuery struct {
+	subQuery
+	// keyColumns is the properties in the sub-query rows that correspond to argColumns
+	keyColumns []string
+}
+
+type sliceBatchSubQuery struct {
+	batchSubQuery
+}
+
+var _ internalSubQuery = (*sliceBatchSubQuery)(nil)
+var _ BatchSubQuery = (*sliceBatchSubQuery)(nil)
+
+func (sq *sliceBatchSubQuery) Execute(ctx context.Context, sqli SqlInterface, row map[string]any, exclusions PropertyExclusions) error {
+	return sq.ExecuteBatch(ctx, sqli, []map[string]any{row}, exclusions)
+}
+
+func (sq *sliceBatchSubQuery) ExecuteBatch(ctx context.Context, sqli SqlInterface, rows []map[string]any, exclusions PropertyExclusions) error {
+	return sq.executeBatch(ctx, sqli, sq, rows, exclusions, func(row map[string]any, matched []map[string]any) {
+		if sq.emptyNil && len(matched) == 0 {
+			row[sq.propertyName] = nil
+		} else if matched == nil {
+			row[sq.propertyName] = make([]map[string]any, 0)
+		} else {
+			row[sq.propertyName] = matched
+		}
+	})
+}
+
+type objectBatchSubQuery struct {
+	batchSubQuery
+}
+
+var _ internalSubQuery = (*objectBatchSubQuery)(nil)
+var _ BatchSubQuery = (*objectBatchSubQuery)(nil)
+
+func (sq *objectBatchSubQuery) Execute(ctx context.Context, sqli SqlInterface, row map[string]any, exclusions PropertyExclusions) error {
+	return sq.ExecuteBatch(ctx, sqli, []map[string]any{row}, exclusions)
+}
+
+func (sq *objectBatchSubQuery) ExecuteBatch(ctx context.Context, sqli SqlInterface, rows []map[string]any, exclusions PropertyExclusions) error {
+	return sq.executeBatch(ctx, sqli, sq, rows, exclusions, func(row map[string]any, matched []map[string]any) {
+		var obj map[string]any
+		if len(matched) > 0 {
+			obj = matched[0]
+		}
+		if sq.emptyNil && len(obj) == 0 {
+			row[sq.propertyName] = nil
+		} else {
+			row[sq.propertyName] = obj
+		}
+	})
+}
+
+type mergeBatchSubQuery struct {
+	noOverwrite bool
+	batchSubQuery
+}
+
+var _ internalSubQuery = (*mergeBatchSubQuery)(nil)
+var _ BatchSubQuery = (*mergeBatchSubQuery)(nil)
+
+func (sq *mergeBatchSubQuery) Execute(ctx context.Context, sqli SqlInterface, row map[string]any, exclusions PropertyExclusions) error {
+	return sq.ExecuteBatch(ctx, sqli, []map[string]any{row}, exclusions)
+}
+
+func (sq *mergeBatchSubQuery) ExecuteBatch(ctx context.Context, sqli SqlInterface, rows []map[string]any, exclusions PropertyExclusions) error {
+	return sq.executeBatch(ctx, sqli, sq, rows, exclusions, func(row map[string]any, matched []map[string]any) {
+		if len(matched) > 0 {
+			for k, v := range matched[0] {
+				if _, ok := row[k]; !ok || !sq.noOverwrite {
+					row[k] = v
+				}
+			}
+		}
+	})
+}
+
+// executeBatch runs the batch query for all the rows and calls assign for each row with the sub-query rows that matched by key
+func (sq *batchSubQuery) executeBatch(ctx context.Context, sqli SqlInterface, asq internalSubQuery, rows []map[string]any, exclusions PropertyExclusions, assign func(row map[string]any, matched []map[string]any)) error {
+	if len(sq.keyColumns) != len(sq.argColumns) {
+		return fmt.Errorf("batch sub-query key columns (%d) does not match arg columns (%d)", len(sq.keyColumns), len(sq.argColumns))
+	}
+	rowKeys := make([]string, len(rows))
+	seen := make(map[string]struct{}, len(rows))
+	args := make([]any, 0, len(rows)*len(sq.argColumns))
+	for i, row := range rows {
+		rowArgs, err := sq.argColumnValues(row)
+		if err != nil {
+			return err
+		}
+		rowKeys[i] = batchKey(rowArgs)
+		if _, ok := seen[rowKeys[i]]; !ok {
+			seen[rowKeys[i]] = struct{}{}
+			args = append(args, rowArgs...)
+		}
+	}
+	matches := make(map[string][]map[string]any, len(seen))
+	if len(seen) > 0 {
+		keyExcluded := make([]bool, len(sq.keyColumns))
+		var subPath []string
+		if sq.propertyName != "" {
+			subPath = []string{sq.propertyName}
+		}
+		for i, k := range sq.keyColumns {
+			keyExcluded[i] = exclusions.Exclude(k, subPath)
+		}
+		rm := sq.rowMapper(asq)
+		subRows, err := rm.Rows(ctx, sqli, args, rawQuery(sq.expandQuery(len(seen))), &keepPropertiesExcluder{keys: sq.keyColumns, path: subPath, exclusions: exclusions})
+		if err != nil {
+			return err
+		}
+		keyValues := make([]any, len(sq.keyColumns))
+		for _, subRow := range subRows {
+			for i, k := range sq.keyColumns {
+				if v, ok := subRow[k]; ok {
+					keyValues[i] = v
+				} else {
+					return fmt.Errorf("batch sub-query key property '%s' does not exist", k)
+				}
+			}
+			for i, k := range sq.keyColumns {
+				if keyExcluded[i] {
+					delete(subRow, k)
+				}
+			}
+			key := batchKey(keyValues)
+			matches[key] = append(matches[key], subRow)
+		}
+	}
+	for i, row := range rows {
+		assign(row, matches[rowKeys[i]])
+	}
+	return nil
+}
+
+// expandQuery expands the single '?' arg marker in the query to the number of keys in the batch
+func (sq *batchSubQuery) expandQuery(keys int) string {
+	return expandBatchQuery(sq.query, len(sq.argColumns), keys)
+}
+
+// expandBatchQuery expands the single '?' arg marker in a batch query to the number of keys in the batch (where there are
+// multiple args per key, the marker is expanded to row value lists)
+func expandBatchQuery(query string, args int, keys int) string {
+	marker := "?"
+	if args > 1 {
+		marker = "(" + strings.Repeat(",?", args)[1:] + ")"
+	}
+	at := -1
+	scanSql(query, false, func(i int, depth int) {
+		if at == -1 && query[i] == '?' {
+			at = i
+		}
+	})
+	if at == -1 {
+		return query
+	}
+	return query[:at] + strings.Repeat(","+marker, keys)[1:] + query[at+1:]
+}
+
+// batchKey builds the key for matching sub-query rows to rows - each value is tagged with its type category (so that,
+// e.g., the string "1" and the int 1 are different keys) but values of the same category match regardless of the
+// actual type (e.g. int32 and int64, or []byte and string - as drivers may return different types for the same column)
+func batchKey(values []any) string {
+	var sb strings.Builder
+	for i, v := range values {
+		if i > 0 {
+			sb.WriteByte(0)
+		}
+		switch vt := v.(type) {
+		case nil:
+			sb.WriteString("n:")
+		case []byte:
+			sb.WriteString("s" + strconv.Itoa(len(vt)) + ":")
+			sb.Write(vt)
+		case string:
+			sb.WriteString("s" + strconv.Itoa(len(vt)) + ":")
+			sb.WriteString(vt)
+		default:
+			switch rv := reflect.ValueOf(v); rv.Kind() {
+			case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+				sb.WriteString("i:" + strconv.FormatInt(rv.Int(), 10))
+			case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+				sb.WriteString("i:" + strconv.FormatUint(rv.Uint(), 10))
+			case reflect.Float32, reflect.Float64:
+				sb.WriteString("f:" + strconv.FormatFloat(rv.Float(), 'g', -1, 64))
+			default:
+				_, _ = fmt.Fprintf(&sb, "%T:%v", vt, vt)
+			}
+		}
+	}
+	return sb.String()
+}
+
+// keepPropertiesExcluder ensures that specific properties are not excluded (e.g. key properties of batch sub-query rows, so that they can be matched)
+type keepPropertiesExcluder struct {
+	keys       []string
+	path       []string
+	exclusions PropertyExclusions
+}
+
+var _ PropertyExcluder = (*keepPropertiesExcluder)(nil)
+
+func (e *keepPropertiesExcluder) Exclude(property string, path []string) bool {
+	if slices.Equal(path, e.path) {
+		for _, k := range e.keys {
+			if k == property {
+				return false
+			}
+		}
+	}
+	return e.exclusions.Exclude(property, path)
+}
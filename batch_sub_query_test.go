@@ -0,0 +1,335 @@
+package columbus
+
+import (
+	"bytes"
+	"errors"
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestNewBatchSubQuery_Rows(t *testing.T) {
+	m, err := newMapper("id",
+		Query(`FROM people`),
+		NewBatchSubQuery("addresses", `SELECT person_id,city FROM addresses WHERE person_id IN (?)`, []string{"id"}, []string{"person_id"}, nil, false),
+	)
+	require.NoError(t, err)
+
+	db, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+	require.NoError(t, err)
+	defer func() {
+		_ = db.Close()
+	}()
+	mock.ExpectQuery("SELECT id FROM people").WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(int64(1)).AddRow(int64(2)).AddRow(int64(3)))
+	mock.ExpectQuery("SELECT person_id,city FROM addresses WHERE person_id IN (?,?,?)").WithArgs(int64(1), int64(2), int64(3)).
+		WillReturnRows(sqlmock.NewRows([]string{"person_id", "city"}).AddRow(int64(1), "London").AddRow(int64(3), "Paris").AddRow(int64(1), "Rome"))
+
+	rows, err := m.Rows(ctx, db, nil)
+	require.NoError(t, err)
+	require.NoError(t, mock.ExpectationsWereMet())
+	require.Len(t, rows, 3)
+	addrs := rows[0]["addresses"].([]map[string]any)
+	require.Len(t, addrs, 2)
+	assert.Equal(t, "London", addrs[0]["city"])
+	assert.Equal(t, "Rome", addrs[1]["city"])
+	addrs = rows[1]["addresses"].([]map[string]any)
+	require.Len(t, addrs, 0)
+	addrs = rows[2]["addresses"].([]map[string]any)
+	require.Len(t, addrs, 1)
+	assert.Equal(t, "Paris", addrs[0]["city"])
+}
+
+func TestNewBatchSubQuery_Rows_EmptyNil(t *testing.T) {
+	m, err := newMapper("id",
+		Query(`FROM people`),
+		NewBatchSubQuery("addresses", `SELECT person_id,city FROM addresses WHERE person_id IN (?)`, []string{"id"}, []string{"person_id"}, nil, true),
+	)
+	require.NoError(t, err)
+
+	db, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+	require.NoError(t, err)
+	defer func() {
+		_ = db.Close()
+	}()
+	mock.ExpectQuery("SELECT id FROM people").WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(int64(1)).AddRow(int64(2)))
+	mock.ExpectQuery("SELECT person_id,city FROM addresses WHERE person_id IN (?,?)").WithArgs(int64(1), int64(2)).
+		WillReturnRows(sqlmock.NewRows([]string{"person_id", "city"}).AddRow(int64(1), "London"))
+
+	rows, err := m.Rows(ctx, db, nil)
+	require.NoError(t, err)
+	require.NoError(t, mock.ExpectationsWereMet())
+	require.Len(t, rows, 2)
+	require.Len(t, rows[0]["addresses"], 1)
+	require.Nil(t, rows[1]["addresses"])
+}
+
+func TestNewBatchSubQuery_Rows_BatchSize(t *testing.T) {
+	m, err := newMapper("id",
+		Query(`FROM people`),
+		NewBatchSubQuery("addresses", `SELECT person_id,city FROM addresses WHERE person_id IN (?)`, []string{"id"}, []string{"person_id"}, nil, false),
+		BatchSize(2),
+	)
+	require.NoError(t, err)
+
+	db, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+	require.NoError(t, err)
+	defer func() {
+		_ = db.Close()
+	}()
+	mock.ExpectQuery("SELECT id FROM people").WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(int64(1)).AddRow(int64(2)).AddRow(int64(3)))
+	mock.ExpectQuery("SELECT person_id,city FROM addresses WHERE person_id IN (?,?)").WithArgs(int64(1), int64(2)).
+		WillReturnRows(sqlmock.NewRows([]string{"person_id", "city"}).AddRow(int64(1), "London"))
+	mock.ExpectQuery("SELECT person_id,city FROM addresses WHERE person_id IN (?)").WithArgs(int64(3)).
+		WillReturnRows(sqlmock.NewRows([]string{"person_id", "city"}).AddRow(int64(3), "Paris"))
+
+	rows, err := m.Rows(ctx, db, nil)
+	require.NoError(t, err)
+	require.NoError(t, mock.ExpectationsWereMet())
+	require.Len(t, rows, 3)
+	require.Len(t, rows[0]["addresses"], 1)
+	require.Len(t, rows[1]["addresses"], 0)
+	require.Len(t, rows[2]["addresses"], 1)
+}
+
+func TestNewBatchSubQuery_Rows_DuplicateKeys(t *testing.T) {
+	m, err := newMapper("id,kind",
+		Query(`FROM people`),
+		NewBatchSubQuery("addresses", `SELECT person_id,kind,city FROM addresses WHERE (person_id,kind) IN (?)`, []string{"id", "kind"}, []string{"person_id", "kind"}, nil, false),
+	)
+	require.NoError(t, err)
+
+	db, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+	require.NoError(t, err)
+	defer func() {
+		_ = db.Close()
+	}()
+	mock.ExpectQuery("SELECT id,kind FROM people").WillReturnRows(sqlmock.NewRows([]string{"id", "kind"}).AddRow(int64(1), "home").AddRow(int64(1), "home"))
+	mock.ExpectQuery("SELECT person_id,kind,city FROM addresses WHERE (person_id,kind) IN ((?,?))").WithArgs(int64(1), "home").
+		WillReturnRows(sqlmock.NewRows([]string{"person_id", "kind", "city"}).AddRow(int64(1), "home", "London"))
+
+	rows, err := m.Rows(ctx, db, nil)
+	require.NoError(t, err)
+	require.NoError(t, mock.ExpectationsWereMet())
+	require.Len(t, rows, 2)
+	require.Len(t, rows[0]["addresses"], 1)
+	require.Len(t, rows[1]["addresses"], 1)
+}
+
+func TestNewBatchSubQuery_Rows_ExcludedKey(t *testing.T) {
+	m, err := newMapper("id",
+		Query(`FROM people`),
+		NewBatchSubQuery("addresses", `SELECT person_id,city FROM addresses WHERE person_id IN (?)`, []string{"id"}, []string{"person_id"}, nil, false),
+	)
+	require.NoError(t, err)
+
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer func() {
+		_ = db.Close()
+	}()
+	mock.ExpectQuery("").WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(int64(1)))
+	mock.ExpectQuery("").WithArgs(int64(1)).
+		WillReturnRows(sqlmock.NewRows([]string{"person_id", "city"}).AddRow(int64(1), "London"))
+
+	excluder := func(property string, path []string) bool {
+		return property == "person_id"
+	}
+	rows, err := m.Rows(ctx, db, nil, excluder)
+	require.NoError(t, err)
+	require.NoError(t, mock.ExpectationsWereMet())
+	require.Len(t, rows, 1)
+	addrs := rows[0]["addresses"].([]map[string]any)
+	require.Len(t, addrs, 1)
+	assert.Equal(t, map[string]any{"city": "London"}, addrs[0])
+}
+
+func TestNewBatchSubQuery_Rows_Excluded(t *testing.T) {
+	m, err := newMapper("id",
+		Query(`FROM people`),
+		NewBatchSubQuery("addresses", `SELECT person_id,city FROM addresses WHERE person_id IN (?)`, []string{"id"}, []string{"person_id"}, nil, false),
+	)
+	require.NoError(t, err)
+
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer func() {
+		_ = db.Close()
+	}()
+	mock.ExpectQuery("").WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(int64(1)))
+
+	rows, err := m.Rows(ctx, db, nil, AllowedProperties{"id": nil})
+	require.NoError(t, err)
+	require.NoError(t, mock.ExpectationsWereMet())
+	require.Len(t, rows, 1)
+	assert.False(t, hasProperties(rows[0], "addresses"))
+}
+
+func TestNewBatchSubQuery_Rows_Errors(t *testing.T) {
+	m, err := newMapper("id",
+		Query(`FROM people`),
+		NewBatchSubQuery("addresses", `SELECT person_id,city FROM addresses WHERE person_id IN (?)`, []string{"id"}, []string{"person_id"}, nil, false),
+	)
+	require.NoError(t, err)
+
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer func() {
+		_ = db.Close()
+	}()
+	mock.ExpectQuery("").WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(int64(1)))
+	mock.ExpectQuery("").WithArgs(int64(1)).WillReturnError(errors.New("fooey"))
+
+	_, err = m.Rows(ctx, db, nil)
+	require.Error(t, err)
+	require.Equal(t, "fooey", err.Error())
+}
+
+func TestNewBatchSubQuery_Execute_KeyErrors(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer func() {
+		_ = db.Close()
+	}()
+
+	sq := NewBatchSubQuery("test", `SELECT * FROM test_table WHERE id IN (?)`, []string{"parent_id"}, nil, nil, false)
+	err = sq.Execute(ctx, db, map[string]any{"parent_id": int64(16)}, nil)
+	require.Error(t, err)
+	require.Equal(t, "batch sub-query key columns (0) does not match arg columns (1)", err.Error())
+
+	sq = NewBatchSubQuery("test", `SELECT * FROM test_table WHERE id IN (?)`, []string{"parent_id"}, []string{"id"}, nil, false)
+	err = sq.Execute(ctx, db, map[string]any{"no_parent_id": int64(16)}, nil)
+	require.Error(t, err)
+	require.Equal(t, "sub-query arg property 'parent_id' does not exist", err.Error())
+
+	mock.ExpectQuery("").WithArgs(int64(16)).WillReturnRows(sqlmock.NewRows([]string{"other"}).AddRow("name"))
+	err = sq.Execute(ctx, db, map[string]any{"parent_id": int64(16)}, nil)
+	require.Error(t, err)
+	require.Equal(t, "batch sub-query key property 'id' does not exist", err.Error())
+}
+
+func TestNewBatchObjectSubQuery_Execute(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer func() {
+		_ = db.Close()
+	}()
+
+	sq := NewBatchObjectSubQuery("test", `SELECT * FROM test_table WHERE id IN (?)`, []string{"parent_id"}, []string{"id"}, nil, true).(BatchSubQuery)
+	rows := []map[string]any{{"parent_id": int64(16)}, {"parent_id": int64(17)}}
+	mock.ExpectQuery("").WithArgs(int64(16), int64(17)).WillReturnRows(sqlmock.NewRows([]string{"id", "name"}).AddRow(int64(16), "name"))
+	err = sq.ExecuteBatch(ctx, db, rows, nil)
+	require.NoError(t, err)
+	require.NoError(t, mock.ExpectationsWereMet())
+	require.Equal(t, map[string]any{"id": int64(16), "name": "name"}, rows[0]["test"])
+	require.Nil(t, rows[1]["test"])
+}
+
+func TestNewBatchMergeSubQuery_Execute(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer func() {
+		_ = db.Close()
+	}()
+
+	sq := NewBatchMergeSubQuery(`SELECT * FROM test_table WHERE id IN (?)`, []string{"parent_id"}, []string{"id"}, nil, true).(BatchSubQuery)
+	rows := []map[string]any{{"parent_id": int64(16), "name": "original"}, {"parent_id": int64(17)}}
+	mock.ExpectQuery("").WithArgs(int64(16), int64(17)).WillReturnRows(sqlmock.NewRows([]string{"id", "name", "other"}).AddRow(int64(16), "name", "other"))
+	err = sq.ExecuteBatch(ctx, db, rows, nil)
+	require.NoError(t, err)
+	require.NoError(t, mock.ExpectationsWereMet())
+	require.Equal(t, "original", rows[0]["name"])
+	require.Equal(t, "other", rows[0]["other"])
+	require.Equal(t, 1, len(rows[1]))
+}
+
+func TestNewBatchSubQuery_WriteRows(t *testing.T) {
+	m, err := newMapper("id",
+		Query(`FROM people`),
+		NewBatchSubQuery("addresses", `SELECT person_id,city FROM addresses WHERE person_id IN (?)`, []string{"id"}, []string{"person_id"}, nil, false),
+		BatchSize(1),
+	)
+	require.NoError(t, err)
+
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer func() {
+		_ = db.Close()
+	}()
+	mock.ExpectQuery("").WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(int64(1)).AddRow(int64(2)))
+	mock.ExpectQuery("").WithArgs(int64(1)).WillReturnRows(sqlmock.NewRows([]string{"person_id", "city"}).AddRow(int64(1), "London"))
+	mock.ExpectQuery("").WithArgs(int64(2)).WillReturnRows(sqlmock.NewRows([]string{"person_id", "city"}))
+
+	var buffer bytes.Buffer
+	err = m.WriteRows(ctx, &buffer, db, nil)
+	require.NoError(t, err)
+	require.NoError(t, mock.ExpectationsWereMet())
+	require.Equal(t, `[{"addresses":[{"city":"London","person_id":1}],"id":1}`+"\n"+`,{"addresses":[],"id":2}`+"\n"+`]`, buffer.String())
+}
+
+func TestNewBatchSubQuery_Iterate(t *testing.T) {
+	m, err := newMapper("id",
+		Query(`FROM people`),
+		NewBatchSubQuery("addresses", `SELECT person_id,city FROM addresses WHERE person_id IN (?)`, []string{"id"}, []string{"person_id"}, nil, false),
+	)
+	require.NoError(t, err)
+
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer func() {
+		_ = db.Close()
+	}()
+	mock.ExpectQuery("").WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(int64(1)).AddRow(int64(2)))
+	mock.ExpectQuery("").WithArgs(int64(1), int64(2)).WillReturnRows(sqlmock.NewRows([]string{"person_id", "city"}).AddRow(int64(2), "London"))
+
+	count := 0
+	err = m.Iterate(ctx, db, nil, func(row map[string]any) (bool, error) {
+		count++
+		require.Len(t, row["addresses"], int(row["id"].(int64))-1)
+		return true, nil
+	})
+	require.NoError(t, err)
+	require.NoError(t, mock.ExpectationsWereMet())
+	require.Equal(t, 2, count)
+}
+
+func TestNewBatchSubQuery_Iterator(t *testing.T) {
+	m, err := newMapper("id",
+		Query(`FROM people`),
+		NewBatchSubQuery("addresses", `SELECT person_id,city FROM addresses WHERE person_id IN (?)`, []string{"id"}, []string{"person_id"}, nil, false),
+	)
+	require.NoError(t, err)
+
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer func() {
+		_ = db.Close()
+	}()
+	mock.ExpectQuery("").WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(int64(1)).AddRow(int64(2)))
+	mock.ExpectQuery("").WithArgs(int64(1), int64(2)).WillReturnRows(sqlmock.NewRows([]string{"person_id", "city"}).AddRow(int64(2), "London"))
+
+	indices := make([]int, 0)
+	for i, row := range m.Iterator(ctx, db, nil) {
+		indices = append(indices, i)
+		require.Len(t, row["addresses"], i)
+	}
+	require.NoError(t, mock.ExpectationsWereMet())
+	require.Equal(t, []int{0, 1}, indices)
+}
+
+func TestExpandBatchQuery(t *testing.T) {
+	assert.Equal(t, "SELECT * FROM t WHERE id IN (?,?,?)", expandBatchQuery("SELECT * FROM t WHERE id IN (?)", 1, 3))
+	assert.Equal(t, "SELECT * FROM t WHERE (a,b) IN ((?,?),(?,?))", expandBatchQuery("SELECT * FROM t WHERE (a,b) IN (?)", 2, 2))
+	assert.Equal(t, "SELECT * FROM t WHERE note <> '?' /* ? */ AND id IN (?,?)", expandBatchQuery("SELECT * FROM t WHERE note <> '?' /* ? */ AND id IN (?)", 1, 2))
+	assert.Equal(t, "SELECT * FROM t", expandBatchQuery("SELECT * FROM t", 1, 2))
+}
+
+func TestBatchKey(t *testing.T) {
+	assert.NotEqual(t, batchKey([]any{"1"}), batchKey([]any{1}))
+	assert.NotEqual(t, batchKey([]any{nil}), batchKey([]any{"<nil>"}))
+	assert.NotEqual(t, batchKey([]any{1.0}), batchKey([]any{1}))
+	assert.Equal(t, batchKey([]any{int32(1)}), batchKey([]any{int64(1)}))
+	assert.Equal(t, batchKey([]any{uint8(1)}), batchKey([]any{int64(1)}))
+	assert.Equal(t, batchKey([]any{[]byte("a"), 2}), batchKey([]any{"a", int64(2)}))
+	assert.NotEqual(t, batchKey([]any{"a", "b"}), batchKey([]any{"a\x00s:b"}))
+}
@@ -0,0 +1,140 @@
+package columbus
+
+import (
+	"bufio"
+	"bytes"
+	"encoding"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"io"
+	"math"
+	"reflect"
+)
+
+// plainValue converts a value, that is not directly supported by the binary encodings (MsgPack and CBOR), to a value
+// that is - i.e. nil, bool, string, []byte, int64, uint64, float64, map[string]any or []any
+//
+// pointers are dereferenced, encoding.TextMarshaler values are converted to strings and any other unsupported values (e.g.
+// structs) are converted using their JSON representation
+func plainValue(v any) (any, error) {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() == reflect.Pointer || rv.Kind() == reflect.Interface {
+		if rv.IsNil() {
+			return nil, nil
+		}
+		return rv.Elem().Interface(), nil
+	} else if tm, ok := v.(encoding.TextMarshaler); ok {
+		data, err := tm.MarshalText()
+		return string(data), err
+	}
+	switch rv.Kind() {
+	case reflect.Bool:
+		return rv.Bool(), nil
+	case reflect.String:
+		return rv.String(), nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return rv.Int(), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return rv.Uint(), nil
+	case reflect.Float32, reflect.Float64:
+		return rv.Float(), nil
+	case reflect.Slice:
+		if rv.IsNil() {
+			return nil, nil
+		} else if rv.Type().Elem().Kind() == reflect.Uint8 {
+			return rv.Bytes(), nil
+		}
+		fallthrough
+	case reflect.Array:
+		items := make([]any, rv.Len())
+		for i := range items {
+			items[i] = rv.Index(i).Interface()
+		}
+		return items, nil
+	case reflect.Map:
+		if rv.IsNil() {
+			return nil, nil
+		} else if rv.Type().Key().Kind() == reflect.String && !isMarshaler(rv.Type()) {
+			obj := make(map[string]any, rv.Len())
+			for iter := rv.MapRange(); iter.Next(); {
+				obj[iter.Key().String()] = iter.Value().Interface()
+			}
+			return obj, nil
+		}
+	}
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	var result any
+	err = json.Unmarshal(data, &result)
+	return result, err
+}
+
+// maxDecodeLength is the maximum length of strings, byte strings, arrays and maps accepted by the binary decoders
+const maxDecodeLength = math.MaxInt32
+
+// binaryReader is the reader used by the binary decoders (MsgPackDecoder and CBORDecoder)
+type binaryReader struct {
+	r *bufio.Reader
+}
+
+func newBinaryReader(r io.Reader) binaryReader {
+	return binaryReader{r: bufio.NewReader(r)}
+}
+
+func (br binaryReader) readByte() (byte, error) {
+	b, err := br.r.ReadByte()
+	if errors.Is(err, io.EOF) {
+		err = io.ErrUnexpectedEOF
+	}
+	return b, err
+}
+
+func (br binaryReader) readBytes(n uint64) ([]byte, error) {
+	if n > maxDecodeLength {
+		return nil, errors.New("decode length too large")
+	}
+	var buf bytes.Buffer
+	if _, err := io.CopyN(&buf, br.r, int64(n)); err != nil {
+		if errors.Is(err, io.EOF) {
+			err = io.ErrUnexpectedEOF
+		}
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// readUint reads a big-endian unsigned integer of size bytes (1, 2, 4 or 8)
+func (br binaryReader) readUint(size int) (uint64, error) {
+	data, err := br.readBytes(uint64(size))
+	if err != nil {
+		return 0, err
+	}
+	switch size {
+	case 1:
+		return uint64(data[0]), nil
+	case 2:
+		return uint64(binary.BigEndian.Uint16(data)), nil
+	case 4:
+		return uint64(binary.BigEndian.Uint32(data)), nil
+	}
+	return binary.BigEndian.Uint64(data), nil
+}
+
+// decodeInt converts a decoded unsigned integer to an int64 (or, if it overflows an int64, a uint64)
+func decodeInt(u uint64) any {
+	if u <= math.MaxInt64 {
+		return int64(u)
+	}
+	return u
+}
+
+// decodeLength checks a decoded length (of an array or map) and returns the initial capacity to allocate
+func decodeLength(n uint64) (int, error) {
+	if n > maxDecodeLength {
+		return 0, errors.New("decode length too large")
+	}
+	return int(min(n, 1024)), nil
+}
@@ -0,0 +1,497 @@
+package columbus
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"github.com/shopspring/decimal"
+	"io"
+	"math"
+	"math/big"
+	"slices"
+	"time"
+)
+
+// CBOREncoding is a RowEncoding that writes rows as CBOR (RFC 8949) - rows are written as an indefinite length array of
+// maps and a single row is written as a map
+//
+// values are encoded as follows:
+//
+//   - decimal.Decimal - as a decimal fraction (tag 4), or as a string if DecimalStrings is set
+//   - time.Time - as an epoch-based date/time (tag 1) - an integer, or a float if the time has fractional seconds
+//   - []byte - as a byte string
+//   - nested objects and arrays - as maps and arrays
+//
+// rows are streamed (each row is written, and the writer flushed, as it is encoded)
+//
+// when writing a page, the page is written as an (indefinite length) map with "rows", "page", "size", "total" and "pages" entries
+//
+// see CBORDecoder for decoding
+type CBOREncoding struct {
+	// DecimalStrings indicates that decimal.Decimal values are encoded as strings (rather than as decimal fractions)
+	DecimalStrings bool
+}
+
+var (
+	_ RowEncoding  = (*CBOREncoding)(nil)
+	_ PageEncoding = (*CBOREncoding)(nil)
+)
+
+// CBOR is the RowEncoding that writes rows as CBOR (see CBOREncoding)
+var CBOR RowEncoding = &CBOREncoding{}
+
+func (e *CBOREncoding) ContentType() string {
+	return "application/cbor"
+}
+
+func (e *CBOREncoding) NewEncoder(w io.Writer) RowEncoder {
+	return &cborEncoder{encoding: e, w: w}
+}
+
+func (e *CBOREncoding) NewPageEncoder(w io.Writer) PageEncoder {
+	return &cborPageEncoder{cborEncoder{encoding: e, w: w}}
+}
+
+const (
+	cborUint byte = iota << 5
+	cborNegInt
+	cborBytes
+	cborText
+	cborArray
+	cborMap
+	cborTag
+	cborSimple
+)
+
+const (
+	cborFalse            = cborSimple | 20
+	cborTrue             = cborSimple | 21
+	cborNull             = cborSimple | 22
+	cborUndefined        = cborSimple | 23
+	cborFloat16          = cborSimple | 25
+	cborFloat32          = cborSimple | 26
+	cborFloat64          = cborSimple | 27
+	cborIndefinite       = 31
+	cborBreak            = cborSimple | cborIndefinite
+	cborTagDateTime      = 0
+	cborTagEpoch         = 1
+	cborTagBignum        = 2
+	cborTagNegBignum     = 3
+	cborTagDecimal       = 4
+	cborIndefiniteArray  = cborArray | cborIndefinite
+	cborIndefiniteMap    = cborMap | cborIndefinite
+	cborIndefiniteString = cborText | cborIndefinite
+	cborIndefiniteBytes  = cborBytes | cborIndefinite
+)
+
+type cborEncoder struct {
+	encoding *CBOREncoding
+	w        io.Writer
+	buf      []byte
+	single   bool
+}
+
+func (e *cborEncoder) Begin(info EncodeInfo) (err error) {
+	if e.single = info.Single; !e.single {
+		_, err = e.w.Write([]byte{cborIndefiniteArray})
+	}
+	return err
+}
+
+func (e *cborEncoder) Row(row map[string]any) (err error) {
+	if e.buf, err = e.append(e.buf[:0], row); err == nil {
+		if _, err = e.w.Write(e.buf); err == nil {
+			err = flush(e.w)
+		}
+	}
+	return err
+}
+
+func (e *cborEncoder) End() (err error) {
+	if !e.single {
+		_, err = e.w.Write([]byte{cborBreak})
+	}
+	return err
+}
+
+type cborPageEncoder struct {
+	cborEncoder
+}
+
+func (e *cborPageEncoder) Begin(info EncodeInfo) (err error) {
+	if _, err = e.w.Write(appendCborText([]byte{cborIndefiniteMap}, "rows")); err == nil {
+		err = e.cborEncoder.Begin(info)
+	}
+	return err
+}
+
+func (e *cborPageEncoder) Page(info PageResult) (err error) {
+	buf := appendCborInt(appendCborText(nil, "page"), int64(info.Page))
+	buf = appendCborInt(appendCborText(buf, "size"), int64(info.Size))
+	buf = appendCborInt(appendCborText(buf, "total"), info.Total)
+	buf = appendCborInt(appendCborText(buf, "pages"), info.Pages)
+	_, err = e.w.Write(append(buf, cborBreak))
+	return err
+}
+
+func (e *cborEncoder) append(b []byte, v any) (_ []byte, err error) {
+	switch vt := v.(type) {
+	case nil:
+		return append(b, cborNull), nil
+	case bool:
+		if vt {
+			return append(b, cborTrue), nil
+		}
+		return append(b, cborFalse), nil
+	case string:
+		return appendCborText(b, vt), nil
+	case []byte:
+		return append(appendCborHead(b, cborBytes, uint64(len(vt))), vt...), nil
+	case int:
+		return appendCborInt(b, int64(vt)), nil
+	case int64:
+		return appendCborInt(b, vt), nil
+	case int32:
+		return appendCborInt(b, int64(vt)), nil
+	case uint64:
+		return appendCborHead(b, cborUint, vt), nil
+	case float64:
+		return binary.BigEndian.AppendUint64(append(b, cborFloat64), math.Float64bits(vt)), nil
+	case float32:
+		return binary.BigEndian.AppendUint32(append(b, cborFloat32), math.Float32bits(vt)), nil
+	case decimal.Decimal:
+		if e.encoding.DecimalStrings {
+			return appendCborText(b, vt.String()), nil
+		}
+		return appendCborDecimal(b, vt), nil
+	case time.Time:
+		b = appendCborHead(b, cborTag, cborTagEpoch)
+		if vt.Nanosecond() == 0 {
+			return appendCborInt(b, vt.Unix()), nil
+		}
+		return binary.BigEndian.AppendUint64(append(b, cborFloat64), math.Float64bits(float64(vt.Unix())+float64(vt.Nanosecond())/1e9)), nil
+	case map[string]any:
+		keys := make([]string, 0, len(vt))
+		for k := range vt {
+			keys = append(keys, k)
+		}
+		slices.Sort(keys)
+		b = appendCborHead(b, cborMap, uint64(len(vt)))
+		for _, k := range keys {
+			if b, err = e.append(appendCborText(b, k), vt[k]); err != nil {
+				return nil, err
+			}
+		}
+		return b, nil
+	case []map[string]any:
+		b = appendCborHead(b, cborArray, uint64(len(vt)))
+		for _, item := range vt {
+			if b, err = e.append(b, item); err != nil {
+				return nil, err
+			}
+		}
+		return b, nil
+	case []any:
+		b = appendCborHead(b, cborArray, uint64(len(vt)))
+		for _, item := range vt {
+			if b, err = e.append(b, item); err != nil {
+				return nil, err
+			}
+		}
+		return b, nil
+	}
+	pv, err := plainValue(v)
+	if err != nil {
+		return nil, err
+	}
+	return e.append(b, pv)
+}
+
+// appendCborHead appends an initial byte (major type) and argument - using the shortest form
+func appendCborHead(b []byte, major byte, n uint64) []byte {
+	switch {
+	case n < 24:
+		return append(b, major|byte(n))
+	case n <= math.MaxUint8:
+		return append(b, major|24, byte(n))
+	case n <= math.MaxUint16:
+		return binary.BigEndian.AppendUint16(append(b, major|25), uint16(n))
+	case n <= math.MaxUint32:
+		return binary.BigEndian.AppendUint32(append(b, major|26), uint32(n))
+	}
+	return binary.BigEndian.AppendUint64(append(b, major|27), n)
+}
+
+func appendCborInt(b []byte, i int64) []byte {
+	if i < 0 {
+		return appendCborHead(b, cborNegInt, uint64(-1-i))
+	}
+	return appendCborHead(b, cborUint, uint64(i))
+}
+
+func appendCborText(b []byte, s string) []byte {
+	return append(appendCborHead(b, cborText, uint64(len(s))), s...)
+}
+
+// appendCborDecimal appends a decimal fraction (tag 4) - an array of the exponent and mantissa (the mantissa is a bignum
+// if it overflows an int64)
+func appendCborDecimal(b []byte, d decimal.Decimal) []byte {
+	b = appendCborHead(appendCborHead(b, cborTag, cborTagDecimal), cborArray, 2)
+	b = appendCborInt(b, int64(d.Exponent()))
+	coef := d.Coefficient()
+	if coef.IsInt64() {
+		return appendCborInt(b, coef.Int64())
+	}
+	tag := uint64(cborTagBignum)
+	if coef.Sign() < 0 {
+		tag = cborTagNegBignum
+		coef.Sub(coef.Neg(coef), big.NewInt(1))
+	}
+	data := coef.Bytes()
+	return append(appendCborHead(appendCborHead(b, cborTag, tag), cborBytes, uint64(len(data))), data...)
+}
+
+// CBORDecoder decodes CBOR values (e.g. as written by the CBOR encoding) from a reader
+//
+// decoded values are: nil (for null and undefined), bool, string, []byte, int64 (or uint64 if the value overflows an int64),
+// float64, *big.Int (for bignums), decimal.Decimal (for decimal fractions), time.Time (for date/times - epoch-based date/times
+// are in UTC), map[string]any and []any - the content of other tags is decoded (i.e. the tag is ignored)
+type CBORDecoder struct {
+	r binaryReader
+}
+
+// NewCBORDecoder creates a new CBORDecoder that reads from the supplied reader
+func NewCBORDecoder(r io.Reader) *CBORDecoder {
+	return &CBORDecoder{r: newBinaryReader(r)}
+}
+
+// Decode decodes the next value - io.EOF is returned when there are no more values
+func (d *CBORDecoder) Decode() (any, error) {
+	if _, err := d.r.r.Peek(1); err != nil {
+		return nil, err
+	}
+	return d.decode()
+}
+
+var errCborBreak = errors.New("unexpected cbor break")
+
+func (d *CBORDecoder) decode() (any, error) {
+	ib, err := d.r.readByte()
+	if err != nil {
+		return nil, err
+	}
+	major, info := ib&0xe0, ib&0x1f
+	if major == cborSimple {
+		return d.decodeSimple(ib)
+	} else if info == cborIndefinite {
+		return d.decodeIndefinite(ib)
+	}
+	n, err := d.decodeArgument(info)
+	if err != nil {
+		return nil, err
+	}
+	switch major {
+	case cborUint:
+		return decodeInt(n), nil
+	case cborNegInt:
+		if n > math.MaxInt64 {
+			return nil, errors.New("cbor negative integer overflow")
+		}
+		return -1 - int64(n), nil
+	case cborBytes:
+		return d.r.readBytes(n)
+	case cborText:
+		data, err := d.r.readBytes(n)
+		return string(data), err
+	case cborArray:
+		c, err := decodeLength(n)
+		if err != nil {
+			return nil, err
+		}
+		result := make([]any, 0, c)
+		for i := uint64(0); i < n; i++ {
+			v, err := d.decode()
+			if err != nil {
+				return nil, err
+			}
+			result = append(result, v)
+		}
+		return result, nil
+	case cborMap:
+		c, err := decodeLength(n)
+		if err != nil {
+			return nil, err
+		}
+		result := make(map[string]any, c)
+		for i := uint64(0); i < n; i++ {
+			if err = d.decodeEntry(result); err != nil {
+				return nil, err
+			}
+		}
+		return result, nil
+	}
+	return d.decodeTag(n)
+}
+
+func (d *CBORDecoder) decodeArgument(info byte) (uint64, error) {
+	switch {
+	case info < 24:
+		return uint64(info), nil
+	case info <= 27:
+		return d.r.readUint(1 << (info - 24))
+	}
+	return 0, fmt.Errorf("invalid cbor additional information %d", info)
+}
+
+func (d *CBORDecoder) decodeSimple(ib byte) (any, error) {
+	switch ib {
+	case cborFalse:
+		return false, nil
+	case cborTrue:
+		return true, nil
+	case cborNull, cborUndefined:
+		return nil, nil
+	case cborFloat16:
+		u, err := d.r.readUint(2)
+		return float16(uint16(u)), err
+	case cborFloat32:
+		u, err := d.r.readUint(4)
+		return float64(math.Float32frombits(uint32(u))), err
+	case cborFloat64:
+		u, err := d.r.readUint(8)
+		return math.Float64frombits(u), err
+	case cborBreak:
+		return nil, errCborBreak
+	}
+	return nil, fmt.Errorf("unsupported cbor simple value 0x%02x", ib)
+}
+
+func (d *CBORDecoder) decodeIndefinite(ib byte) (any, error) {
+	switch ib {
+	case cborIndefiniteBytes, cborIndefiniteString:
+		data := make([]byte, 0)
+		for {
+			v, err := d.decode()
+			if errors.Is(err, errCborBreak) {
+				break
+			} else if err != nil {
+				return nil, err
+			}
+			switch chunk := v.(type) {
+			case []byte:
+				data = append(data, chunk...)
+			case string:
+				data = append(data, chunk...)
+			default:
+				return nil, errors.New("invalid cbor indefinite length string chunk")
+			}
+		}
+		if ib == cborIndefiniteString {
+			return string(data), nil
+		}
+		return data, nil
+	case cborIndefiniteArray:
+		result := make([]any, 0)
+		for {
+			v, err := d.decode()
+			if errors.Is(err, errCborBreak) {
+				return result, nil
+			} else if err != nil {
+				return nil, err
+			}
+			result = append(result, v)
+		}
+	case cborIndefiniteMap:
+		result := make(map[string]any)
+		for {
+			if err := d.decodeEntry(result); errors.Is(err, errCborBreak) {
+				return result, nil
+			} else if err != nil {
+				return nil, err
+			}
+		}
+	}
+	return nil, fmt.Errorf("invalid cbor indefinite length type 0x%02x", ib)
+}
+
+func (d *CBORDecoder) decodeEntry(m map[string]any) error {
+	k, err := d.decode()
+	if err != nil {
+		return err
+	}
+	key, ok := k.(string)
+	if !ok {
+		return fmt.Errorf("unsupported cbor map key type %T", k)
+	}
+	m[key], err = d.decode()
+	if errors.Is(err, errCborBreak) {
+		err = errors.New("unexpected cbor break in map entry")
+	}
+	return err
+}
+
+func (d *CBORDecoder) decodeTag(tag uint64) (any, error) {
+	v, err := d.decode()
+	if err != nil {
+		return nil, err
+	}
+	switch tag {
+	case cborTagDateTime:
+		if s, ok := v.(string); ok {
+			return time.Parse(time.RFC3339Nano, s)
+		}
+	case cborTagEpoch:
+		switch vt := v.(type) {
+		case int64:
+			return time.Unix(vt, 0).UTC(), nil
+		case float64:
+			secs, frac := math.Modf(vt)
+			return time.Unix(int64(secs), int64(math.Round(frac*1e9))).UTC(), nil
+		}
+	case cborTagBignum, cborTagNegBignum:
+		if data, ok := v.([]byte); ok {
+			n := new(big.Int).SetBytes(data)
+			if tag == cborTagNegBignum {
+				n.Sub(n.Neg(n), big.NewInt(1))
+			}
+			return n, nil
+		}
+	case cborTagDecimal:
+		if items, ok := v.([]any); ok && len(items) == 2 {
+			if exp, ok := items[0].(int64); ok && exp >= math.MinInt32 && exp <= math.MaxInt32 {
+				switch mt := items[1].(type) {
+				case int64:
+					return decimal.New(mt, int32(exp)), nil
+				case *big.Int:
+					return decimal.NewFromBigInt(mt, int32(exp)), nil
+				}
+			}
+		}
+	default:
+		return v, nil
+	}
+	return nil, fmt.Errorf("invalid cbor tag %d content", tag)
+}
+
+// float16 converts IEEE 754 half-precision bits to a float64
+func float16(h uint16) float64 {
+	exp := int(h>>10) & 0x1f
+	mant := float64(h & 0x3ff)
+	var f float64
+	switch exp {
+	case 0:
+		f = math.Ldexp(mant, -24)
+	case 0x1f:
+		if mant == 0 {
+			f = math.Inf(1)
+		} else {
+			f = math.NaN()
+		}
+	default:
+		f = math.Ldexp(mant+1024, exp-25)
+	}
+	if h&0x8000 != 0 {
+		return -f
+	}
+	return f
+}
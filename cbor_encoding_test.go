@@ -0,0 +1,191 @@
+package columbus
+
+import (
+	"bytes"
+	"encoding/hex"
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"io"
+	"math"
+	"math/big"
+	"testing"
+	"time"
+)
+
+func TestCborEncoder_Append(t *testing.T) {
+	// expectations from RFC 8949 Appendix A (where applicable)
+	testCases := []struct {
+		value  any
+		expect string
+	}{
+		{value: 0, expect: "00"},
+		{value: 23, expect: "17"},
+		{value: 24, expect: "1818"},
+		{value: 100, expect: "1864"},
+		{value: 1000, expect: "1903e8"},
+		{value: 1000000, expect: "1a000f4240"},
+		{value: int64(1000000000000), expect: "1b000000e8d4a51000"},
+		{value: uint64(math.MaxUint64), expect: "1bffffffffffffffff"},
+		{value: -1, expect: "20"},
+		{value: -10, expect: "29"},
+		{value: -100, expect: "3863"},
+		{value: -1000, expect: "3903e7"},
+		{value: 1.1, expect: "fb3ff199999999999a"},
+		{value: float32(100000.0), expect: "fa47c35000"},
+		{value: false, expect: "f4"},
+		{value: true, expect: "f5"},
+		{value: nil, expect: "f6"},
+		{value: "a", expect: "6161"},
+		{value: "ü", expect: "62c3bc"},
+		{value: []byte{1, 2, 3, 4}, expect: "4401020304"},
+		{value: []any{1, []any{2, 3}}, expect: "8201820203"},
+		{value: map[string]any{"b": []any{2, 3}, "a": 1}, expect: "a26161016162820203"},
+		{value: time.Unix(1363896240, 0), expect: "c11a514b67b0"},
+		{value: time.Unix(1363896240, 500000000), expect: "c1fb41d452d9ec200000"},
+		{value: decimal.RequireFromString("273.15"), expect: "c48221196ab3"},
+		{value: decimal.RequireFromString("-1.5"), expect: "c482202e"},
+		{value: decimal.RequireFromString("18446744073709551616"), expect: "c48200c249010000000000000000"},
+		{value: decimal.RequireFromString("-18446744073709551617"), expect: "c48200c349010000000000000000"},
+		{value: []string{"a"}, expect: "816161"},
+	}
+	enc := &cborEncoder{encoding: &CBOREncoding{}}
+	for _, tc := range testCases {
+		t.Run(tc.expect, func(t *testing.T) {
+			b, err := enc.append(nil, tc.value)
+			require.NoError(t, err)
+			assert.Equal(t, tc.expect, hex.EncodeToString(b))
+		})
+	}
+	b, err := (&cborEncoder{encoding: &CBOREncoding{DecimalStrings: true}}).append(nil, decimal.RequireFromString("1.5"))
+	require.NoError(t, err)
+	assert.Equal(t, "63312e35", hex.EncodeToString(b))
+}
+
+func TestCBORDecoder(t *testing.T) {
+	testCases := []struct {
+		data   string
+		expect any
+	}{
+		{data: "00", expect: int64(0)},
+		{data: "1b000000e8d4a51000", expect: int64(1000000000000)},
+		{data: "1bffffffffffffffff", expect: uint64(math.MaxUint64)},
+		{data: "3903e7", expect: int64(-1000)},
+		{data: "f90000", expect: 0.0},
+		{data: "f93c00", expect: 1.0},
+		{data: "f9c400", expect: -4.0},
+		{data: "f97bff", expect: 65504.0},
+		{data: "f90001", expect: 5.960464477539063e-8},
+		{data: "f97c00", expect: math.Inf(1)},
+		{data: "fa47c35000", expect: 100000.0},
+		{data: "fb3ff199999999999a", expect: 1.1},
+		{data: "f4", expect: false},
+		{data: "f5", expect: true},
+		{data: "f6", expect: nil},
+		{data: "f7", expect: nil},
+		{data: "62c3bc", expect: "ü"},
+		{data: "4401020304", expect: []byte{1, 2, 3, 4}},
+		{data: "5f42010243030405ff", expect: []byte{1, 2, 3, 4, 5}},
+		{data: "7f657374726561646d696e67ff", expect: "streaming"},
+		{data: "8301820203820405", expect: []any{int64(1), []any{int64(2), int64(3)}, []any{int64(4), int64(5)}}},
+		{data: "9f018202039f0405ffff", expect: []any{int64(1), []any{int64(2), int64(3)}, []any{int64(4), int64(5)}}},
+		{data: "a26161016162820203", expect: map[string]any{"a": int64(1), "b": []any{int64(2), int64(3)}}},
+		{data: "bf61610161629f0203ffff", expect: map[string]any{"a": int64(1), "b": []any{int64(2), int64(3)}}},
+		{data: "c074323031332d30332d32315432303a30343a30305a", expect: time.Date(2013, 3, 21, 20, 4, 0, 0, time.UTC)},
+		{data: "c11a514b67b0", expect: time.Unix(1363896240, 0).UTC()},
+		{data: "c1fb41d452d9ec200000", expect: time.Unix(1363896240, 500000000).UTC()},
+		{data: "c249010000000000000000", expect: new(big.Int).Lsh(big.NewInt(1), 64)},
+		{data: "c349010000000000000000", expect: new(big.Int).Sub(new(big.Int).Neg(new(big.Int).Lsh(big.NewInt(1), 64)), big.NewInt(1))},
+		{data: "c48221196ab3", expect: decimal.New(27315, -2)},
+		{data: "d82076687474703a2f2f7777772e6578616d706c652e636f6d", expect: "http://www.example.com"},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.data, func(t *testing.T) {
+			data, err := hex.DecodeString(tc.data)
+			require.NoError(t, err)
+			d := NewCBORDecoder(bytes.NewReader(data))
+			v, err := d.Decode()
+			require.NoError(t, err)
+			assert.Equal(t, tc.expect, v)
+			_, err = d.Decode()
+			assert.Equal(t, io.EOF, err)
+		})
+	}
+
+	errCases := map[string]string{
+		"8201":     "unexpected EOF",
+		"ff":       "unexpected cbor break",
+		"a10101":   "unsupported cbor map key type int64",
+		"bf6161ff": "unexpected cbor break in map entry",
+		"1c":       "invalid cbor additional information 28",
+		"c16161":   "invalid cbor tag 1 content",
+		"f8ff":     "unsupported cbor simple value 0xf8",
+	}
+	for data, expectErr := range errCases {
+		t.Run(data, func(t *testing.T) {
+			b, err := hex.DecodeString(data)
+			require.NoError(t, err)
+			_, err = NewCBORDecoder(bytes.NewReader(b)).Decode()
+			require.Error(t, err)
+			assert.Equal(t, expectErr, err.Error())
+		})
+	}
+}
+
+func TestMapper_Write_CBOR(t *testing.T) {
+	m, err := newMapper("id,amount,created", Query(`FROM table`), Mappings{
+		"amount": {Scanner: func(value any) (any, error) {
+			return decimal.NewFromString(string(value.([]byte)))
+		}},
+	})
+	require.NoError(t, err)
+	db, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+	require.NoError(t, err)
+	defer func() {
+		_ = db.Close()
+	}()
+	created := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	newRows := func() *sqlmock.Rows {
+		return sqlmock.NewRows([]string{"id", "amount", "created"}).
+			AddRow(1, []byte("1.50"), created).
+			AddRow(2, []byte("-3"), created)
+	}
+	row1 := map[string]any{"id": int64(1), "amount": decimal.New(150, -2), "created": created}
+	row2 := map[string]any{"id": int64(2), "amount": decimal.New(-3, 0), "created": created}
+
+	mock.ExpectQuery("SELECT id,amount,created FROM table").WillReturnRows(newRows())
+	w := &testFlushWriter{}
+	err = m.WriteRows(ctx, w, db, nil, Accept("application/cbor"))
+	require.NoError(t, err)
+	require.NoError(t, mock.ExpectationsWereMet())
+	assert.Len(t, w.flushed, 2)
+	v, err := NewCBORDecoder(&w.Buffer).Decode()
+	require.NoError(t, err)
+	assert.Equal(t, []any{row1, row2}, v)
+
+	mock.ExpectQuery("SELECT id,amount,created FROM table").WillReturnRows(sqlmock.NewRows([]string{"id", "amount", "created"}).
+		AddRow(1, []byte("1.50"), created))
+	buf := bytes.NewBuffer(nil)
+	err = m.WriteExactlyOneRow(ctx, buf, db, nil, &CBOREncoding{DecimalStrings: true})
+	require.NoError(t, err)
+	v, err = NewCBORDecoder(buf).Decode()
+	require.NoError(t, err)
+	assert.Equal(t, map[string]any{"id": int64(1), "amount": "1.5", "created": created}, v)
+
+	mock.ExpectQuery("SELECT COUNT(*) FROM (SELECT id,amount,created FROM table) _count").WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(2))
+	mock.ExpectQuery("SELECT id,amount,created FROM table LIMIT 10").WillReturnRows(newRows())
+	buf = bytes.NewBuffer(nil)
+	err = m.WritePage(ctx, buf, db, nil, 1, 10, CBOR)
+	require.NoError(t, err)
+	require.NoError(t, mock.ExpectationsWereMet())
+	v, err = NewCBORDecoder(buf).Decode()
+	require.NoError(t, err)
+	assert.Equal(t, map[string]any{
+		"rows":  []any{row1, row2},
+		"page":  int64(1),
+		"size":  int64(10),
+		"total": int64(2),
+		"pages": int64(1),
+	}, v)
+}
@@ -35,15 +35,24 @@ func BoolColumn(src any) (any, error) {
 }
 
 type columnsInfo struct {
-	count       int
-	names       []string
-	scanTypes   []reflect.Type
-	dbTypes     []string
-	mappings    Mappings
-	useDecimals bool
+	count        int
+	names        []string
+	scanTypes    []reflect.Type
+	dbTypes      []string
+	nullables    []bool
+	decimalSizes []decimalSize
+	mappings     Mappings
+	useDecimals  bool
+}
+
+type decimalSize struct {
+	precision int64
+	scale     int64
+	ok        bool
 }
 
 type columnsReader struct {
+	info     *columnsInfo
 	count    int
 	names    []string
 	values   []any
@@ -53,26 +62,41 @@ type columnsReader struct {
 func newColumnsInfo(rows *sql.Rows, useDecimals bool, mappings Mappings) (result *columnsInfo, err error) {
 	var cts []*sql.ColumnType
 	if cts, err = rows.ColumnTypes(); err == nil {
-		count := len(cts)
-		result = &columnsInfo{
-			count:       count,
-			names:       make([]string, count),
-			scanTypes:   make([]reflect.Type, count),
-			dbTypes:     make([]string, count),
-			mappings:    mappings,
-			useDecimals: useDecimals,
-		}
-		for i, ct := range cts {
-			result.names[i] = ct.Name()
-			result.scanTypes[i] = ct.ScanType()
-			result.dbTypes[i] = ct.DatabaseTypeName()
-		}
+		result = newColumnsInfoFromTypes(cts, useDecimals, mappings)
 	}
 	return result, err
 }
 
+func newColumnsInfoFromTypes(cts []*sql.ColumnType, useDecimals bool, mappings Mappings) *columnsInfo {
+	count := len(cts)
+	result := &columnsInfo{
+		count:        count,
+		names:        make([]string, count),
+		scanTypes:    make([]reflect.Type, count),
+		dbTypes:      make([]string, count),
+		nullables:    make([]bool, count),
+		decimalSizes: make([]decimalSize, count),
+		mappings:     mappings,
+		useDecimals:  useDecimals,
+	}
+	for i, ct := range cts {
+		result.names[i] = ct.Name()
+		result.scanTypes[i] = ct.ScanType()
+		result.dbTypes[i] = ct.DatabaseTypeName()
+		if nullable, ok := ct.Nullable(); ok {
+			result.nullables[i] = nullable
+		} else {
+			result.nullables[i] = true
+		}
+		ds := &result.decimalSizes[i]
+		ds.precision, ds.scale, ds.ok = ct.DecimalSize()
+	}
+	return result
+}
+
 func (ci *columnsInfo) reader() *columnsReader {
 	r := &columnsReader{
+		info:     ci,
 		count:    ci.count,
 		values:   make([]any, ci.count),
 		scanArgs: make([]any, ci.count),
@@ -84,48 +108,69 @@ func (ci *columnsInfo) reader() *columnsReader {
 	return r
 }
 
-func (ci *columnsInfo) buildScanner(cr *columnsReader, index int) sql.Scanner {
+// columnScannerKind is the kind of scanner used to read a column (see columnsInfo.scannerKind)
+type columnScannerKind int
+
+const (
+	rawScanner columnScannerKind = iota
+	customScanner
+	jsonScanner
+	decimalScanner
+	stringScanner
+)
+
+// scannerKind determines the kind of scanner used to read a column - a Mapping Scanner, JSON (for JSON/JSONB columns),
+// decimal (for decimal/float columns, when using decimals), string or raw
+func (ci *columnsInfo) scannerKind(index int) columnScannerKind {
 	if m, ok := ci.mappings[ci.names[index]]; ok && m.Scanner != nil {
-		return &customColumnScanner{
-			columns: cr,
-			index:   index,
-			scanner: m.Scanner,
-		}
+		return customScanner
 	}
 	switch ci.dbTypes[index] {
 	case "JSON", "JSONB":
-		return &jsonColumnScanner{
-			columns: cr,
-			index:   index,
-		}
+		return jsonScanner
 	case "DECIMAL", "FLOAT", "DOUBLE", "NUMERIC":
 		if ci.useDecimals {
-			return &decimalColumnScanner{
-				columns: cr,
-				index:   index,
-			}
+			return decimalScanner
 		}
 	default:
 		if ci.useDecimals && strings.HasPrefix(ci.dbTypes[index], "FLOAT") {
-			return &decimalColumnScanner{
-				columns: cr,
-				index:   index,
-			}
+			return decimalScanner
 		}
 	}
 	v := reflect.New(ci.scanTypes[index]).Interface()
 	switch v.(type) {
 	case *string, string, *sql.NullString:
-		return &stringColumnScanner{
+		return stringScanner
+	case *float32, *float64, float32, float64, *sql.NullFloat64:
+		if ci.useDecimals {
+			return decimalScanner
+		}
+	}
+	return rawScanner
+}
+
+func (ci *columnsInfo) buildScanner(cr *columnsReader, index int) sql.Scanner {
+	switch ci.scannerKind(index) {
+	case customScanner:
+		return &customColumnScanner{
 			columns: cr,
 			index:   index,
+			scanner: ci.mappings[ci.names[index]].Scanner,
 		}
-	case *float32, *float64, float32, float64, *sql.NullFloat64:
-		if ci.useDecimals {
-			return &decimalColumnScanner{
-				columns: cr,
-				index:   index,
-			}
+	case jsonScanner:
+		return &jsonColumnScanner{
+			columns: cr,
+			index:   index,
+		}
+	case decimalScanner:
+		return &decimalColumnScanner{
+			columns: cr,
+			index:   index,
+		}
+	case stringScanner:
+		return &stringColumnScanner{
+			columns: cr,
+			index:   index,
 		}
 	}
 	return &rawColumnScanner{
@@ -134,6 +179,28 @@ func (ci *columnsInfo) buildScanner(cr *columnsReader, index int) sql.Scanner {
 	}
 }
 
+// columnInfo returns the ColumnInfo (for encoding) of a column - the mapping is the mapping of the column (if any)
+func (ci *columnsInfo) columnInfo(index int, property string, mapping *Mapping) ColumnInfo {
+	kind := ci.scannerKind(index)
+	result := ColumnInfo{
+		Property:     property,
+		Column:       ci.names[index],
+		DatabaseType: ci.dbTypes[index],
+		ScanType:     ci.scanTypes[index],
+		Nullable:     ci.nullables[index],
+		Decimal:      kind == decimalScanner,
+		Precision:    ci.decimalSizes[index].precision,
+		Scale:        ci.decimalSizes[index].scale,
+		DecimalSize:  ci.decimalSizes[index].ok,
+		Converted:    kind == customScanner || kind == jsonScanner,
+	}
+	if mapping != nil {
+		result.Nullable = result.Nullable || mapping.OmitNull
+		result.Converted = result.Converted || mapping.PostProcess != nil || mapping.NullDefault != nil
+	}
+	return result
+}
+
 type customColumnScanner struct {
 	columns *columnsReader
 	index   int
@@ -0,0 +1,93 @@
+package columbus
+
+import (
+	"container/list"
+	"database/sql"
+	"strconv"
+	"strings"
+)
+
+// ColumnsCacheSize is an option that can be passed to NewMapper and determines the maximum number of
+// result shapes (i.e. the column names and types of a query result) for which column information is cached
+//
+// a mapper used with many different Query options (or ad-hoc AddClause) may produce many different result shapes - the least
+// recently used column information is discarded when the cache is full
+//
+// by default, Mapper caches column information for up to 16 result shapes
+type ColumnsCacheSize int
+
+const defaultColumnsCacheSize = 16
+
+// columnsCache is an LRU cache of columnsInfo keyed by result shape
+//
+// it is not safe for concurrent use - callers must synchronise access
+type columnsCache struct {
+	size    int
+	order   *list.List
+	entries map[string]*list.Element
+}
+
+type columnsCacheEntry struct {
+	key  string
+	info *columnsInfo
+}
+
+func newColumnsCache(size int) *columnsCache {
+	if size <= 0 {
+		size = defaultColumnsCacheSize
+	}
+	return &columnsCache{
+		size:    size,
+		order:   list.New(),
+		entries: make(map[string]*list.Element, size),
+	}
+}
+
+func (c *columnsCache) get(key string) (*columnsInfo, bool) {
+	if elem, ok := c.entries[key]; ok {
+		c.order.MoveToFront(elem)
+		return elem.Value.(*columnsCacheEntry).info, true
+	}
+	return nil, false
+}
+
+func (c *columnsCache) put(key string, info *columnsInfo) {
+	if elem, ok := c.entries[key]; ok {
+		elem.Value.(*columnsCacheEntry).info = info
+		c.order.MoveToFront(elem)
+		return
+	}
+	c.entries[key] = c.order.PushFront(&columnsCacheEntry{key: key, info: info})
+	for c.order.Len() > c.size {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.entries, oldest.Value.(*columnsCacheEntry).key)
+	}
+}
+
+func (c *columnsCache) len() int {
+	return c.order.Len()
+}
+
+// columnsShapeKey derives the cache key for a result shape from the column names and types (and, where known, the
+// nullability and decimal size of the columns)
+func columnsShapeKey(cts []*sql.ColumnType) string {
+	var sb strings.Builder
+	for _, ct := range cts {
+		sb.WriteString(ct.Name())
+		sb.WriteByte(0)
+		sb.WriteString(ct.DatabaseTypeName())
+		sb.WriteByte(0)
+		if st := ct.ScanType(); st != nil {
+			sb.WriteString(st.String())
+		}
+		if nullable, ok := ct.Nullable(); ok {
+			sb.WriteString("\x00null=" + strconv.FormatBool(nullable))
+		}
+		if precision, scale, ok := ct.DecimalSize(); ok {
+			sb.WriteString("\x00size=" + strconv.FormatInt(precision, 10) + "," + strconv.FormatInt(scale, 10))
+		}
+		sb.WriteByte(1)
+	}
+	return sb.String()
+}
@@ -0,0 +1,81 @@
+package columbus
+
+import (
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestColumnsCache(t *testing.T) {
+	c := newColumnsCache(2)
+	_, ok := c.get("a")
+	require.False(t, ok)
+
+	ciA := &columnsInfo{}
+	ciB := &columnsInfo{}
+	ciC := &columnsInfo{}
+	c.put("a", ciA)
+	c.put("b", ciB)
+	require.Equal(t, 2, c.len())
+	ci, ok := c.get("a")
+	require.True(t, ok)
+	require.Same(t, ciA, ci)
+
+	// "b" is now least recently used...
+	c.put("c", ciC)
+	require.Equal(t, 2, c.len())
+	_, ok = c.get("b")
+	require.False(t, ok)
+	ci, ok = c.get("a")
+	require.True(t, ok)
+	require.Same(t, ciA, ci)
+	ci, ok = c.get("c")
+	require.True(t, ok)
+	require.Same(t, ciC, ci)
+
+	c.put("a", ciB)
+	require.Equal(t, 2, c.len())
+	ci, ok = c.get("a")
+	require.True(t, ok)
+	require.Same(t, ciB, ci)
+
+	c = newColumnsCache(0)
+	require.Equal(t, defaultColumnsCacheSize, c.size)
+}
+
+func TestColumnsShapeKey(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer func() {
+		_ = db.Close()
+	}()
+	mock.ExpectQuery("").WillReturnRows(sqlmock.NewRowsWithColumnDefinition(
+		sqlmock.NewColumn("a").OfType("VARCHAR", ""),
+		sqlmock.NewColumn("b").OfType("INT", int64(0))))
+	mock.ExpectQuery("").WillReturnRows(sqlmock.NewRowsWithColumnDefinition(
+		sqlmock.NewColumn("a").OfType("VARCHAR", ""),
+		sqlmock.NewColumn("b").OfType("DECIMAL", float64(0))))
+	rows, err := db.Query("")
+	require.NoError(t, err)
+	cts, err := rows.ColumnTypes()
+	require.NoError(t, err)
+	key1 := columnsShapeKey(cts)
+	_ = rows.Close()
+	rows, err = db.Query("")
+	require.NoError(t, err)
+	cts, err = rows.ColumnTypes()
+	require.NoError(t, err)
+	key2 := columnsShapeKey(cts)
+	_ = rows.Close()
+	require.NotEqual(t, key1, key2)
+	require.Equal(t, "a\x00VARCHAR\x00string\x01b\x00INT\x00int64\x01", key1)
+
+	mock.ExpectQuery("").WillReturnRows(sqlmock.NewRowsWithColumnDefinition(
+		sqlmock.NewColumn("a").OfType("DECIMAL", "").Nullable(true).WithPrecisionAndScale(10, 2)))
+	rows, err = db.Query("")
+	require.NoError(t, err)
+	cts, err = rows.ColumnTypes()
+	require.NoError(t, err)
+	_ = rows.Close()
+	require.Equal(t, "a\x00DECIMAL\x00string\x00null=true\x00size=10,2\x01", columnsShapeKey(cts))
+}
@@ -0,0 +1,170 @@
+package columbus
+
+import (
+	"context"
+	"database/sql"
+	"maps"
+	"sync"
+)
+
+// SubQueryConcurrency is an option that can be passed to NewMapper (or any of the row reading methods) and
+// determines whether sub-queries are executed concurrently
+//
+// Only sub-queries that provide a property (i.e. SubQuery.ProvidesProperty returns a non-empty property name) are
+// considered independent and executed concurrently - each is executed against a copy of the row and only the provided
+// property is copied back into the row (in the order the sub-queries were specified, so that output is deterministic)
+//
+// Other sub-queries (e.g. merge sub-queries) and row post processors are executed sequentially once the independent sub-queries have completed
+//
+// If the SqlInterface is a *sql.Tx (or *sql.Conn) sub-queries are always executed sequentially - as these are bound to a single connection
+type SubQueryConcurrency struct {
+	// Limit is the maximum number of sub-queries that can be executing at any one time
+	//
+	// a limit of 1 (or less) means sub-queries are executed sequentially
+	Limit int
+	// Rows when true, the sub-queries for all rows in a batch (see BatchSize) are executed concurrently - otherwise
+	// only the sub-queries for each row are executed concurrently
+	Rows bool
+}
+
+func (c SubQueryConcurrency) enabled(sqli SqlInterface) bool {
+	if c.Limit <= 1 {
+		return false
+	}
+	switch unwrapSqlInterface(sqli).(type) {
+	case *sql.Tx, *sql.Conn:
+		return false
+	}
+	return true
+}
+
+// executeSubQueriesConcurrently executes the independent sub-queries concurrently - then any other sub-queries sequentially
+func (o *mapOptions) executeSubQueriesConcurrently(ctx context.Context, sqli SqlInterface, rows []map[string]any) error {
+	independent := make([]SubQuery, 0, len(o.subQueries))
+	dependent := make([]SubQuery, 0, len(o.subQueries))
+	for _, sq := range o.subQueries {
+		if sq != nil {
+			if sq.ProvidesProperty() == "" {
+				dependent = append(dependent, sq)
+			} else if !o.exclusions.Exclude(sq.ProvidesProperty(), o.subPath) {
+				independent = append(independent, sq)
+			}
+		}
+	}
+	if len(independent) > 0 {
+		batchTasks := make([]*subQueryTask, 0, len(independent))
+		for _, sq := range independent {
+			if bsq, ok := sq.(BatchSubQuery); ok {
+				batchTasks = append(batchTasks, &subQueryTask{execute: batchSubQueryExecuteFunc(bsq), property: sq.ProvidesProperty(), rows: rows})
+			}
+		}
+		if o.concurrency.Rows {
+			tasks := batchTasks
+			for _, row := range rows {
+				tasks = appendRowTasks(tasks, independent, row)
+			}
+			if err := o.runSubQueryTasks(ctx, sqli, tasks); err != nil {
+				return err
+			}
+		} else {
+			if err := o.runSubQueryTasks(ctx, sqli, batchTasks); err != nil {
+				return err
+			}
+			for _, row := range rows {
+				if err := o.runSubQueryTasks(ctx, sqli, appendRowTasks(nil, independent, row)); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	return o.executeSubQueries(ctx, sqli, rows, dependent)
+}
+
+func appendRowTasks(tasks []*subQueryTask, subQueries []SubQuery, row map[string]any) []*subQueryTask {
+	for _, sq := range subQueries {
+		if _, ok := sq.(BatchSubQuery); !ok {
+			tasks = append(tasks, &subQueryTask{execute: subQueryExecuteFunc(sq), property: sq.ProvidesProperty(), rows: []map[string]any{row}})
+		}
+	}
+	return tasks
+}
+
+func subQueryExecuteFunc(sq SubQuery) func(ctx context.Context, sqli SqlInterface, rows []map[string]any, exclusions PropertyExclusions) error {
+	return func(ctx context.Context, sqli SqlInterface, rows []map[string]any, exclusions PropertyExclusions) error {
+		return sq.Execute(ctx, subQuerySqlInterface(sq, sqli), rows[0], exclusions)
+	}
+}
+
+func batchSubQueryExecuteFunc(bsq BatchSubQuery) func(ctx context.Context, sqli SqlInterface, rows []map[string]any, exclusions PropertyExclusions) error {
+	return func(ctx context.Context, sqli SqlInterface, rows []map[string]any, exclusions PropertyExclusions) error {
+		return bsq.ExecuteBatch(ctx, subQuerySqlInterface(bsq, sqli), rows, exclusions)
+	}
+}
+
+// runSubQueryTasks runs the tasks with at most concurrency.Limit running at any one time - the first error cancels the context of the remaining tasks
+//
+// once all tasks have completed (successfully), the provided properties are copied back into the rows - in task order
+func (o *mapOptions) runSubQueryTasks(ctx context.Context, sqli SqlInterface, tasks []*subQueryTask) (err error) {
+	if len(tasks) == 0 {
+		return nil
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	sem := make(chan struct{}, o.concurrency.Limit)
+	var wg sync.WaitGroup
+	var once sync.Once
+	for _, task := range tasks {
+		select {
+		case sem <- struct{}{}:
+		case <-ctx.Done():
+		}
+		if ctx.Err() != nil {
+			break
+		}
+		wg.Add(1)
+		go func(task *subQueryTask) {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+			if tErr := task.run(ctx, sqli, o.subQueryExclusions()); tErr != nil {
+				once.Do(func() {
+					err = tErr
+					cancel()
+				})
+			}
+		}(task)
+	}
+	wg.Wait()
+	if err == nil {
+		if err = ctx.Err(); err == nil {
+			for _, task := range tasks {
+				task.apply()
+			}
+		}
+	}
+	return err
+}
+
+type subQueryTask struct {
+	execute  func(ctx context.Context, sqli SqlInterface, rows []map[string]any, exclusions PropertyExclusions) error
+	property string
+	rows     []map[string]any
+	copies   []map[string]any
+}
+
+func (t *subQueryTask) run(ctx context.Context, sqli SqlInterface, exclusions PropertyExclusions) error {
+	t.copies = make([]map[string]any, len(t.rows))
+	for i, row := range t.rows {
+		t.copies[i] = maps.Clone(row)
+	}
+	return t.execute(ctx, sqli, t.copies, exclusions)
+}
+
+func (t *subQueryTask) apply() {
+	for i, row := range t.rows {
+		if v, ok := t.copies[i][t.property]; ok {
+			row[t.property] = v
+		}
+	}
+}
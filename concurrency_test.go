@@ -0,0 +1,224 @@
+package columbus
+
+import (
+	"context"
+	"errors"
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+type testConcurrentSubQuery struct {
+	propertyName string
+	barrier      *testBarrier
+	err          error
+}
+
+var _ SubQuery = (*testConcurrentSubQuery)(nil)
+
+func (sq *testConcurrentSubQuery) Execute(ctx context.Context, sqli SqlInterface, row map[string]any, exclusions PropertyExclusions) error {
+	if sq.err != nil {
+		return sq.err
+	}
+	if sq.barrier != nil {
+		if err := sq.barrier.wait(ctx); err != nil {
+			return err
+		}
+	}
+	row[sq.propertyName] = row["a"].(string) + " " + sq.propertyName
+	row["ignored"] = true
+	return nil
+}
+
+func (sq *testConcurrentSubQuery) ProvidesProperty() string {
+	return sq.propertyName
+}
+
+// testBarrier blocks until the expected number of waiters have arrived (or the context is done)
+type testBarrier struct {
+	mu       sync.Mutex
+	expected int
+	arrived  int
+	done     chan struct{}
+	running  atomic.Int32
+	max      atomic.Int32
+}
+
+func newTestBarrier(expected int) *testBarrier {
+	return &testBarrier{expected: expected, done: make(chan struct{})}
+}
+
+func (b *testBarrier) wait(ctx context.Context) error {
+	if r := b.running.Add(1); r > b.max.Load() {
+		b.max.Store(r)
+	}
+	defer b.running.Add(-1)
+	b.mu.Lock()
+	b.arrived++
+	if b.arrived == b.expected {
+		close(b.done)
+	}
+	b.mu.Unlock()
+	select {
+	case <-b.done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func TestSubQueryConcurrency_enabled(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer func() {
+		_ = db.Close()
+	}()
+	require.False(t, SubQueryConcurrency{}.enabled(db))
+	require.False(t, SubQueryConcurrency{Limit: 1}.enabled(db))
+	require.True(t, SubQueryConcurrency{Limit: 2}.enabled(db))
+	mock.ExpectBegin()
+	tx, err := db.Begin()
+	require.NoError(t, err)
+	require.False(t, SubQueryConcurrency{Limit: 2}.enabled(tx))
+}
+
+func TestMapper_SubQueryConcurrency(t *testing.T) {
+	barrier := newTestBarrier(2)
+	m, err := newMapper("a",
+		Query(`FROM table`),
+		&testConcurrentSubQuery{propertyName: "foo", barrier: barrier},
+		&testConcurrentSubQuery{propertyName: "bar", barrier: barrier},
+		SubQueryConcurrency{Limit: 2},
+	)
+	require.NoError(t, err)
+
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer func() {
+		_ = db.Close()
+	}()
+	mock.ExpectQuery("").WillReturnRows(sqlmock.NewRows([]string{"a"}).AddRow("a value"))
+
+	tctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+	row, err := m.FirstRow(tctx, db, nil)
+	require.NoError(t, err)
+	require.NoError(t, mock.ExpectationsWereMet())
+	assert.Equal(t, map[string]any{"a": "a value", "foo": "a value foo", "bar": "a value bar"}, row)
+	assert.Equal(t, int32(2), barrier.max.Load())
+}
+
+func TestMapper_SubQueryConcurrency_Rows(t *testing.T) {
+	barrier := newTestBarrier(2)
+	m, err := newMapper("a",
+		Query(`FROM table`),
+		&testConcurrentSubQuery{propertyName: "foo", barrier: barrier},
+	)
+	require.NoError(t, err)
+
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer func() {
+		_ = db.Close()
+	}()
+	mock.ExpectQuery("").WillReturnRows(sqlmock.NewRows([]string{"a"}).AddRow("a1").AddRow("a2"))
+
+	tctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+	// rows are only collected into batches when there are batch sub-queries...
+	bsq := NewBatchSubQuery("batched", `SELECT * FROM other WHERE a IN (?)`, []string{"a"}, []string{"a"}, nil, false)
+	mock.ExpectQuery("").WithArgs("a1", "a2").WillReturnRows(sqlmock.NewRows([]string{"a"}).AddRow("a2"))
+	rows, err := m.Rows(tctx, db, nil, bsq, SubQueryConcurrency{Limit: 4, Rows: true})
+	require.NoError(t, err)
+	require.NoError(t, mock.ExpectationsWereMet())
+	require.Len(t, rows, 2)
+	assert.Equal(t, "a1 foo", rows[0]["foo"])
+	assert.Len(t, rows[0]["batched"], 0)
+	assert.Equal(t, "a2 foo", rows[1]["foo"])
+	assert.Len(t, rows[1]["batched"], 1)
+	assert.Equal(t, int32(2), barrier.max.Load())
+}
+
+func TestMapper_SubQueryConcurrency_Limited(t *testing.T) {
+	barrier := newTestBarrier(1)
+	m, err := newMapper("a",
+		Query(`FROM table`),
+		&testConcurrentSubQuery{propertyName: "foo", barrier: barrier},
+		&testConcurrentSubQuery{propertyName: "bar", barrier: barrier},
+		&testConcurrentSubQuery{propertyName: "baz", barrier: barrier},
+		NewMergeSubQuery(`SELECT * FROM other WHERE a = ?`, []string{"a"}, nil, false),
+		SubQueryConcurrency{Limit: 2},
+	)
+	require.NoError(t, err)
+
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer func() {
+		_ = db.Close()
+	}()
+	mock.ExpectQuery("").WillReturnRows(sqlmock.NewRows([]string{"a"}).AddRow("a value"))
+	mock.ExpectQuery("").WithArgs("a value").WillReturnRows(sqlmock.NewRows([]string{"merged"}).AddRow("merged value"))
+
+	row, err := m.FirstRow(ctx, db, nil)
+	require.NoError(t, err)
+	require.NoError(t, mock.ExpectationsWereMet())
+	assert.Equal(t, map[string]any{"a": "a value", "foo": "a value foo", "bar": "a value bar", "baz": "a value baz", "merged": "merged value"}, row)
+	assert.LessOrEqual(t, barrier.max.Load(), int32(2))
+}
+
+func TestMapper_SubQueryConcurrency_Errors(t *testing.T) {
+	barrier := newTestBarrier(2)
+	m, err := newMapper("a",
+		Query(`FROM table`),
+		&testConcurrentSubQuery{propertyName: "foo", barrier: barrier},
+		&testConcurrentSubQuery{propertyName: "bar", err: errors.New("fooey")},
+		SubQueryConcurrency{Limit: 2},
+	)
+	require.NoError(t, err)
+
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer func() {
+		_ = db.Close()
+	}()
+	mock.ExpectQuery("").WillReturnRows(sqlmock.NewRows([]string{"a"}).AddRow("a value"))
+
+	tctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+	_, err = m.FirstRow(tctx, db, nil)
+	require.Error(t, err)
+	require.Equal(t, "fooey", err.Error())
+	require.NoError(t, tctx.Err())
+}
+
+func TestMapper_SubQueryConcurrency_Tx(t *testing.T) {
+	barrier := newTestBarrier(1)
+	m, err := newMapper("a",
+		Query(`FROM table`),
+		&testConcurrentSubQuery{propertyName: "foo", barrier: barrier},
+		&testConcurrentSubQuery{propertyName: "bar", barrier: barrier},
+		SubQueryConcurrency{Limit: 2},
+	)
+	require.NoError(t, err)
+
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer func() {
+		_ = db.Close()
+	}()
+	mock.ExpectBegin()
+	mock.ExpectQuery("").WillReturnRows(sqlmock.NewRows([]string{"a"}).AddRow("a value"))
+	tx, err := db.Begin()
+	require.NoError(t, err)
+
+	row, err := m.FirstRow(ctx, tx, nil)
+	require.NoError(t, err)
+	require.NoError(t, mock.ExpectationsWereMet())
+	assert.Equal(t, "a value foo", row["foo"])
+	assert.Equal(t, "a value bar", row["bar"])
+	assert.Equal(t, int32(1), barrier.max.Load())
+}
@@ -0,0 +1,333 @@
+package columbus
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"github.com/shopspring/decimal"
+	"io"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ArrayPolicy determines how CSVEncoding writes array property values (e.g. sub-query results)
+type ArrayPolicy int
+
+const (
+	// ArrayJson writes an array as JSON (the default)
+	ArrayJson ArrayPolicy = iota
+	// ArrayJoin writes an array as its formatted items joined with the CSVEncoding.ArraySeparator (object items are written as JSON)
+	ArrayJoin
+	// ArrayIndex writes an array as index columns - e.g. `tags.0`, `tags.1` (the number of index columns is CSVEncoding.ArrayColumns)
+	ArrayIndex
+)
+
+// CSVEncoding is a RowEncoding that writes rows as CSV (or TSV) - with a header row of property names
+//
+// nested objects (e.g. properties mapped with a Path, JSON columns or object sub-queries) are flattened into dotted header
+// names - e.g. `address.city`
+//
+// the header order is derived from the column order (followed by grouping, sub-query and row post processor properties) -
+// the header layout is fixed before the first row is written:
+//   - properties that are arrays of objects (e.g. slice sub-queries and Grouping) are always written according to the
+//     Arrays policy (regardless of the first row values)
+//   - the properties of other nested objects that are not mapped columns (e.g. object sub-queries or JSON columns) are
+//     derived, in name order, from the first row - as are the index columns of other arrays (e.g. JSON columns) for the
+//     ArrayIndex policy
+//
+// where a later row has an object (or array) value for a property that was written as a single column, the value is
+// written as JSON
+//
+// rows are streamed (each row is written, and the writer flushed, as it is encoded)
+type CSVEncoding struct {
+	// Delimiter is the field delimiter (if zero, a comma is used)
+	Delimiter rune
+	// Arrays is the policy for writing array property values
+	Arrays ArrayPolicy
+	// ArraySeparator is the separator used for ArrayJoin (if empty, "|" is used)
+	ArraySeparator string
+	// ArrayColumns is the number of index columns used for ArrayIndex (if zero or less, 1 is used) - array items beyond the
+	// number of index columns are not written
+	ArrayColumns int
+	// TimeFormat is the format used for time.Time values (if empty, time.RFC3339Nano is used)
+	TimeFormat string
+	// Null is the value written for nulls (defaults to an empty string)
+	Null string
+	// NoHeader indicates that the header row is not written
+	NoHeader bool
+	// MediaType is the content type of the encoding (if empty, `text/csv` - or, if the Delimiter is a tab, `text/tab-separated-values`)
+	MediaType string
+}
+
+var _ RowEncoding = (*CSVEncoding)(nil)
+
+func (e *CSVEncoding) ContentType() string {
+	if e.MediaType != "" {
+		return e.MediaType
+	} else if e.Delimiter == '\t' {
+		return "text/tab-separated-values"
+	}
+	return "text/csv"
+}
+
+func (e *CSVEncoding) NewEncoder(w io.Writer) RowEncoder {
+	cw := csv.NewWriter(w)
+	if e.Delimiter != 0 {
+		cw.Comma = e.Delimiter
+	}
+	return &csvEncoder{
+		encoding: e,
+		cw:       cw,
+		w:        w,
+	}
+}
+
+type csvEncoder struct {
+	encoding   *CSVEncoding
+	cw         *csv.Writer
+	w          io.Writer
+	properties []string
+	nested     []NestedInfo
+	columns    []flatColumn
+}
+
+func (e *csvEncoder) Begin(info EncodeInfo) error {
+	e.properties = info.Properties
+	e.nested = info.Nested
+	return nil
+}
+
+func (e *csvEncoder) Row(row map[string]any) (err error) {
+	if e.columns == nil {
+		if err = e.writeHeader(row); err != nil {
+			return err
+		}
+	}
+	record := make([]string, len(e.columns))
+	for i, col := range e.columns {
+		if record[i], err = e.format(col.value(row), true); err != nil {
+			return err
+		}
+	}
+	if err = e.cw.Write(record); err == nil {
+		e.cw.Flush()
+		if err = e.cw.Error(); err == nil {
+			err = flush(e.w)
+		}
+	}
+	return err
+}
+
+func (e *csvEncoder) End() (err error) {
+	if e.columns == nil {
+		err = e.writeHeader(nil)
+	}
+	e.cw.Flush()
+	if err == nil {
+		err = e.cw.Error()
+	}
+	return err
+}
+
+// writeHeader resolves the columns (using the nested structure and the first row, if any, to flatten nested objects and arrays)
+// and writes the header
+func (e *csvEncoder) writeHeader(row map[string]any) error {
+	arrayColumns := 0
+	if e.encoding.Arrays == ArrayIndex {
+		arrayColumns = max(e.encoding.ArrayColumns, 1)
+	}
+	e.columns = flatColumns(e.properties, e.nested, row, arrayColumns, nil)
+	if e.encoding.NoHeader {
+		return nil
+	}
+	header := make([]string, len(e.columns))
+	for i, col := range e.columns {
+		header[i] = col.name
+	}
+	return e.cw.Write(header)
+}
+
+// format formats a value - arrays are formatted according to the array policy (if top is true, otherwise as JSON)
+func (e *csvEncoder) format(v any, top bool) (string, error) {
+	switch vt := v.(type) {
+	case nil:
+		return e.encoding.Null, nil
+	case string:
+		return vt, nil
+	case []byte:
+		return string(vt), nil
+	case bool:
+		return strconv.FormatBool(vt), nil
+	case int:
+		return strconv.Itoa(vt), nil
+	case int64:
+		return strconv.FormatInt(vt, 10), nil
+	case int32:
+		return strconv.FormatInt(int64(vt), 10), nil
+	case float64:
+		return strconv.FormatFloat(vt, 'f', -1, 64), nil
+	case float32:
+		return strconv.FormatFloat(float64(vt), 'f', -1, 32), nil
+	case decimal.Decimal:
+		return vt.String(), nil
+	case time.Time:
+		if e.encoding.TimeFormat != "" {
+			return vt.Format(e.encoding.TimeFormat), nil
+		}
+		return vt.Format(time.RFC3339Nano), nil
+	}
+	if top && e.encoding.Arrays == ArrayJoin && isArray(v) {
+		rv := reflect.ValueOf(v)
+		items := make([]string, rv.Len())
+		for i := range items {
+			s, err := e.format(rv.Index(i).Interface(), false)
+			if err != nil {
+				return "", err
+			}
+			items[i] = s
+		}
+		sep := e.encoding.ArraySeparator
+		if sep == "" {
+			sep = "|"
+		}
+		return strings.Join(items, sep), nil
+	}
+	data, err := json.Marshal(v)
+	return string(data), err
+}
+
+// flatColumn is a flattened column - index is the array index (-1 if not an array index column)
+type flatColumn struct {
+	name  string
+	path  []string
+	index int
+}
+
+// flatColumns resolves the flattened columns for properties - using the nested structure and the first row (if any) to
+// flatten nested objects (in name order, where the object properties are not known) and arrays (into arrayColumns index
+// columns - if arrayColumns is greater than zero)
+//
+// skip is an optional func that determines whether the value for a property path is omitted from the columns
+func flatColumns(properties []string, nested []NestedInfo, row map[string]any, arrayColumns int, skip func(path []string, v any) bool) []flatColumn {
+	fc := &flatColumnsBuilder{
+		columns:      make([]flatColumn, 0, len(properties)),
+		seen:         map[string]bool{},
+		nested:       make(map[string]NestedInfo, len(nested)),
+		arrayColumns: arrayColumns,
+		skip:         skip,
+	}
+	for _, n := range nested {
+		fc.nested[n.Property] = n
+	}
+	for _, p := range properties {
+		path := strings.Split(p, ".")
+		fc.add(path, pathValue(row, path))
+	}
+	return fc.columns
+}
+
+type flatColumnsBuilder struct {
+	columns      []flatColumn
+	seen         map[string]bool
+	nested       map[string]NestedInfo
+	arrayColumns int
+	skip         func(path []string, v any) bool
+}
+
+func (fc *flatColumnsBuilder) add(path []string, v any) {
+	if fc.skip != nil && fc.skip(path, v) {
+		return
+	}
+	name := strings.Join(path, ".")
+	if n, ok := fc.nested[name]; ok {
+		if n.Array {
+			fc.addArray(name, path)
+			return
+		} else if n.Properties != nil {
+			for _, p := range n.Properties {
+				childPath := append(path[:len(path):len(path)], strings.Split(p, ".")...)
+				obj, _ := v.(map[string]any)
+				fc.add(childPath, pathValue(obj, childPath[len(path):]))
+			}
+			return
+		}
+	}
+	if obj, ok := v.(map[string]any); ok && len(obj) > 0 {
+		keys := make([]string, 0, len(obj))
+		for k := range obj {
+			keys = append(keys, k)
+		}
+		slices.Sort(keys)
+		for _, k := range keys {
+			fc.add(append(path[:len(path):len(path)], k), obj[k])
+		}
+		return
+	}
+	if isArray(v) {
+		fc.addArray(name, path)
+		return
+	}
+	fc.addColumn(flatColumn{name: name, path: path, index: -1})
+}
+
+// addArray adds the column(s) for an array property - index columns if arrayColumns is greater than zero
+func (fc *flatColumnsBuilder) addArray(name string, path []string) {
+	if fc.arrayColumns > 0 {
+		for i := 0; i < fc.arrayColumns; i++ {
+			fc.addColumn(flatColumn{name: name + "." + strconv.Itoa(i), path: path, index: i})
+		}
+		return
+	}
+	fc.addColumn(flatColumn{name: name, path: path, index: -1})
+}
+
+func (fc *flatColumnsBuilder) addColumn(col flatColumn) {
+	if !fc.seen[col.name] {
+		fc.seen[col.name] = true
+		fc.columns = append(fc.columns, col)
+	}
+}
+
+// value returns the value for the column from a row
+func (col flatColumn) value(row map[string]any) any {
+	v := pathValue(row, col.path)
+	if col.index >= 0 {
+		v = arrayItem(v, col.index)
+	}
+	return v
+}
+
+// pathValue returns the value at a property path in a row (nil if the path does not exist)
+func pathValue(row map[string]any, path []string) any {
+	var v any = row
+	for _, name := range path {
+		obj, ok := v.(map[string]any)
+		if !ok {
+			return nil
+		}
+		v = obj[name]
+	}
+	return v
+}
+
+func isArray(v any) bool {
+	if v == nil {
+		return false
+	}
+	switch v.(type) {
+	case []byte:
+		return false
+	}
+	return reflect.TypeOf(v).Kind() == reflect.Slice
+}
+
+func arrayItem(v any, index int) any {
+	if isArray(v) {
+		if rv := reflect.ValueOf(v); index < rv.Len() {
+			return rv.Index(index).Interface()
+		}
+	}
+	return nil
+}
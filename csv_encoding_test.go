@@ -0,0 +1,206 @@
+package columbus
+
+import (
+	"bytes"
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestCSVEncoding_ContentType(t *testing.T) {
+	assert.Equal(t, "text/csv", CSV.ContentType())
+	assert.Equal(t, "text/tab-separated-values", TSV.ContentType())
+	assert.Equal(t, "application/vnd.ms-excel", (&CSVEncoding{MediaType: "application/vnd.ms-excel"}).ContentType())
+}
+
+func TestCSVEncoding_Flattening(t *testing.T) {
+	rows := []map[string]any{
+		{
+			"id":      int64(1),
+			"address": map[string]any{"city": "London", "street": "Main St"},
+			"meta":    map[string]any{"z": true, "a": map[string]any{"b": 1.5}},
+			"tags":    []any{"x", "y", "z"},
+			"orders":  []map[string]any{{"total": decimal.RequireFromString("10.50")}},
+			"at":      time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC),
+		},
+		{
+			"id":      int64(2),
+			"address": map[string]any{"city": nil},
+			"meta":    nil,
+			"tags":    []any{},
+			"orders":  []map[string]any{},
+			"at":      nil,
+		},
+	}
+	info := EncodeInfo{Properties: []string{"id", "address.street", "address.city", "meta", "tags", "orders", "at"}}
+	testCases := []struct {
+		encoding *CSVEncoding
+		expect   string
+	}{
+		{
+			encoding: &CSVEncoding{},
+			expect: `id,address.street,address.city,meta.a.b,meta.z,tags,orders,at
+1,Main St,London,1.5,true,"[""x"",""y"",""z""]","[{""total"":""10.5""}]",2026-01-02T03:04:05Z
+2,,,,,[],[],
+`,
+		},
+		{
+			encoding: &CSVEncoding{Delimiter: '\t', Arrays: ArrayJoin, TimeFormat: time.DateOnly, Null: "NULL"},
+			expect: "id\taddress.street\taddress.city\tmeta.a.b\tmeta.z\ttags\torders\tat\n" +
+				"1\tMain St\tLondon\t1.5\ttrue\tx|y|z\t\"{\"\"total\"\":\"\"10.5\"\"}\"\t2026-01-02\n" +
+				"2\tNULL\tNULL\tNULL\tNULL\t\t\tNULL\n",
+		},
+		{
+			encoding: &CSVEncoding{Arrays: ArrayIndex, ArrayColumns: 2, NoHeader: true},
+			expect: `1,Main St,London,1.5,true,x,y,"{""total"":""10.5""}",,2026-01-02T03:04:05Z
+2,,,,,,,,,
+`,
+		},
+	}
+	for _, tc := range testCases {
+		w := bytes.NewBuffer(nil)
+		enc := tc.encoding.NewEncoder(w)
+		require.NoError(t, enc.Begin(info))
+		for _, row := range rows {
+			require.NoError(t, enc.Row(row))
+		}
+		require.NoError(t, enc.End())
+		assert.Equal(t, tc.expect, w.String())
+	}
+}
+
+func TestCSVEncoding_NestedLayout(t *testing.T) {
+	rows := []map[string]any{
+		{"id": int64(1), "orders": nil, "items": nil},
+		{"id": int64(2), "orders": []map[string]any{{"ref": "A"}, {"ref": "B"}}, "items": []map[string]any{{"no": int64(1), "sku": "X"}}},
+	}
+	info := EncodeInfo{
+		Properties: []string{"id", "orders", "items"},
+		Nested: []NestedInfo{
+			{Property: "orders", Array: true},
+			{Property: "items", Array: true, Properties: []string{"no", "sku"}},
+		},
+	}
+	w := bytes.NewBuffer(nil)
+	enc := (&CSVEncoding{Arrays: ArrayIndex, ArrayColumns: 2}).NewEncoder(w)
+	require.NoError(t, enc.Begin(info))
+	for _, row := range rows {
+		require.NoError(t, enc.Row(row))
+	}
+	require.NoError(t, enc.End())
+	assert.Equal(t, `id,orders.0,orders.1,items.0,items.1
+1,,,,
+2,"{""ref"":""A""}","{""ref"":""B""}","{""no"":1,""sku"":""X""}",
+`, w.String())
+
+	info = EncodeInfo{
+		Properties: []string{"id", "address"},
+		Nested:     []NestedInfo{{Property: "address", Properties: []string{"city", "geo.lat"}}},
+	}
+	w = bytes.NewBuffer(nil)
+	enc = CSV.NewEncoder(w)
+	require.NoError(t, enc.Begin(info))
+	require.NoError(t, enc.Row(map[string]any{"id": int64(1), "address": nil}))
+	require.NoError(t, enc.Row(map[string]any{"id": int64(2), "address": map[string]any{"city": "Bree", "geo": map[string]any{"lat": 1.5}}}))
+	require.NoError(t, enc.End())
+	assert.Equal(t, "id,address.city,address.geo.lat\n1,,\n2,Bree,1.5\n", w.String())
+}
+
+func TestMapper_WriteRows_NestedInfo(t *testing.T) {
+	m, err := NewMapper("id,name", Query("FROM people"),
+		NewSubQuery("orders", "SELECT ref FROM orders WHERE person_id = ?", []string{"id"}, nil, false),
+		NewObjectSubQuery("address", "SELECT city FROM addresses WHERE person_id = ?", []string{"id"}, nil, false, false),
+		NewMergeSubQuery("SELECT age FROM ages WHERE person_id = ?", []string{"id"}, nil, false))
+	require.NoError(t, err)
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer func() {
+		_ = db.Close()
+	}()
+	mock.ExpectQuery("").WillReturnRows(sqlmock.NewRows([]string{"id", "name"}))
+	enc := &testCapturingEncoding{}
+	err = m.WriteRows(ctx, bytes.NewBuffer(nil), db, nil, enc)
+	require.NoError(t, err)
+	require.NoError(t, mock.ExpectationsWereMet())
+	assert.Equal(t, []string{"id", "name", "orders", "address"}, enc.info.Properties)
+	assert.Equal(t, []NestedInfo{{Property: "orders", Array: true}, {Property: "address"}}, enc.info.Nested)
+}
+
+func TestCSVEncoding_NoRows(t *testing.T) {
+	w := bytes.NewBuffer(nil)
+	enc := CSV.NewEncoder(w)
+	require.NoError(t, enc.Begin(EncodeInfo{Properties: []string{"id", "address.city"}}))
+	require.NoError(t, enc.End())
+	assert.Equal(t, "id,address.city\n", w.String())
+}
+
+type testCsvAddress struct {
+	City   *string `sql:"city" json:"city"`
+	Street string  `json:"-"`
+}
+
+type CsvBase struct {
+	Id int64 `sql:"id" json:"id"`
+}
+
+type testCsvPerson struct {
+	CsvBase
+	Name    string          `sql:"name" json:"name"`
+	Amount  decimal.Decimal `sql:"amount" json:"amount"`
+	Address testCsvAddress
+	Tags    []string
+	private string
+}
+
+func TestStructMapper_WriteRows(t *testing.T) {
+	m, err := NewStructMapper[testCsvPerson]("id,name,amount,city", Query(`FROM people`))
+	require.NoError(t, err)
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer func() {
+		_ = db.Close()
+	}()
+	newRows := func() *sqlmock.Rows {
+		return sqlmock.NewRows([]string{"id", "name", "amount", "city"}).
+			AddRow(1, "foo", "1.50", "London").
+			AddRow(2, "bar", "2", nil)
+	}
+
+	mock.ExpectQuery("").WillReturnRows(newRows())
+	w := bytes.NewBuffer(nil)
+	err = m.WriteRows(ctx, w, db, nil, CSV)
+	require.NoError(t, err)
+	assert.Equal(t, "id,name,amount,Address.city,Tags\n1,foo,1.5,London,\n2,bar,2,,\n", w.String())
+
+	mock.ExpectQuery("").WillReturnRows(newRows())
+	w = bytes.NewBuffer(nil)
+	err = m.WriteRows(ctx, w, db, nil, &testLimiter{1})
+	require.NoError(t, err)
+	assert.Equal(t, "[{\"Address\":{\"city\":\"London\"},\"Tags\":null,\"amount\":\"1.5\",\"id\":1,\"name\":\"foo\"}\n]", w.String())
+	require.NoError(t, mock.ExpectationsWereMet())
+
+	err = m.WriteRows(ctx, w, db, nil, Accept("image/png"))
+	require.Error(t, err)
+	assert.Equal(t, "no acceptable encoding for 'image/png'", err.Error())
+}
+
+func TestStructEncodeInfo_Nested(t *testing.T) {
+	type line struct {
+		No      int `json:"no"`
+		Product struct {
+			Sku string `json:"sku"`
+		} `json:"product"`
+	}
+	type order struct {
+		Id    int64   `json:"id"`
+		Lines []*line `json:"lines"`
+		Tags  []string
+	}
+	info := structEncodeInfo(reflect.TypeOf(order{}))
+	assert.Equal(t, []string{"id", "lines", "Tags"}, info.Properties)
+	assert.Equal(t, []NestedInfo{{Property: "lines", Array: true, Properties: []string{"no", "product.sku"}}}, info.Nested)
+}
@@ -0,0 +1,261 @@
+package columbus
+
+import (
+	"context"
+	"database/sql"
+	"strconv"
+	"strings"
+)
+
+// Dialect is an option that can be passed to NewMapper, NewStructMapper (or any of the row reading methods) and
+// determines how SQL is generated for a specific database
+//
+// use one of the provided dialects - MySQL, Postgres, SQLite or SQLServer
+//
+// when a Dialect is used, queries (including sub-queries) should use '?' arg markers - which are rewritten to the
+// dialect's placeholder style (e.g. `$1`, `$2` for Postgres)
+//
+// if no Dialect is used, queries are executed as written and generated SQL (e.g. for pagination) uses `LIMIT n OFFSET m`
+// with unquoted identifiers
+type Dialect interface {
+	// Placeholders rewrites the '?' arg markers in the query to the dialect's placeholder style
+	//
+	// '?' characters within quoted strings, quoted identifiers or comments are not rewritten
+	Placeholders(query string) string
+	// QuoteIdentifier quotes an identifier (e.g. a column name)
+	QuoteIdentifier(name string) string
+	// Limit adds a row limit (and offset, if greater than zero) to the query
+	Limit(query string, limit int, offset int) string
+}
+
+var (
+	// MySQL is the Dialect for MySQL (and MariaDB) - `?` placeholders, `backtick` quoted identifiers and `LIMIT n OFFSET m`
+	MySQL Dialect = &limitDialect{quote: "`"}
+	// Postgres is the Dialect for PostgreSQL - `$n` placeholders, "double quoted" identifiers and `LIMIT n OFFSET m`
+	Postgres Dialect = &limitDialect{quote: `"`, placeholder: func(n int) string {
+		return "$" + strconv.Itoa(n)
+	}}
+	// SQLite is the Dialect for SQLite - `?` placeholders, "double quoted" identifiers and `LIMIT n OFFSET m`
+	SQLite Dialect = &limitDialect{quote: `"`}
+	// SQLServer is the Dialect for Microsoft SQL Server - `@pn` placeholders, [bracket quoted] identifiers and
+	// `OFFSET m ROWS FETCH NEXT n ROWS ONLY` (adding `ORDER BY (SELECT NULL)` if the query has no ORDER BY)
+	SQLServer Dialect = &sqlServerDialect{}
+)
+
+// defaultDialect is used when no Dialect has been specified - queries are not rewritten and identifiers are not quoted
+var defaultDialect Dialect = &limitDialect{}
+
+type limitDialect struct {
+	quote       string
+	placeholder func(n int) string
+}
+
+func (d *limitDialect) Placeholders(query string) string {
+	if d.placeholder == nil {
+		return query
+	}
+	return rewritePlaceholders(query, false, d.placeholder)
+}
+
+func (d *limitDialect) QuoteIdentifier(name string) string {
+	if d.quote == "" {
+		return name
+	}
+	return d.quote + strings.ReplaceAll(name, d.quote, d.quote+d.quote) + d.quote
+}
+
+func (d *limitDialect) Limit(query string, limit int, offset int) string {
+	if offset > 0 {
+		return query + " LIMIT " + strconv.Itoa(limit) + " OFFSET " + strconv.Itoa(offset)
+	}
+	return query + " LIMIT " + strconv.Itoa(limit)
+}
+
+type sqlServerDialect struct{}
+
+func (d *sqlServerDialect) Placeholders(query string) string {
+	return rewritePlaceholders(query, true, func(n int) string {
+		return "@p" + strconv.Itoa(n)
+	})
+}
+
+func (d *sqlServerDialect) QuoteIdentifier(name string) string {
+	return "[" + strings.ReplaceAll(name, "]", "]]") + "]"
+}
+
+func (d *sqlServerDialect) Limit(query string, limit int, offset int) string {
+	if orderByIndex(query, true) == -1 {
+		query += " ORDER BY (SELECT NULL)"
+	}
+	return query + " OFFSET " + strconv.Itoa(offset) + " ROWS FETCH NEXT " + strconv.Itoa(limit) + " ROWS ONLY"
+}
+
+// scanSql calls fn for each character of the query that is not within a quoted string, quoted identifier or comment
+//
+// depth is the parenthesis depth of the character
+func scanSql(query string, brackets bool, fn func(i int, depth int)) {
+	depth := 0
+	for i := 0; i < len(query); i++ {
+		c := query[i]
+		switch {
+		case c == '\'' || c == '"' || c == '`' || (c == '[' && brackets):
+			end := c
+			if c == '[' {
+				end = ']'
+			}
+			for i++; i < len(query) && query[i] != end; i++ {
+			}
+		case c == '-' && i+1 < len(query) && query[i+1] == '-':
+			for i += 2; i < len(query) && query[i] != '\n'; i++ {
+			}
+		case c == '/' && i+1 < len(query) && query[i+1] == '*':
+			if end := strings.Index(query[i+2:], "*/"); end == -1 {
+				i = len(query)
+			} else {
+				i += end + 3
+			}
+		case c == '(':
+			depth++
+			fn(i, depth)
+		case c == ')':
+			fn(i, depth)
+			depth--
+		default:
+			fn(i, depth)
+		}
+	}
+}
+
+// rewritePlaceholders rewrites the '?' arg markers in the query using placeholder (with the 1 based arg number)
+func rewritePlaceholders(query string, brackets bool, placeholder func(n int) string) string {
+	var sb strings.Builder
+	n, last := 0, 0
+	scanSql(query, brackets, func(i int, depth int) {
+		if query[i] == '?' {
+			n++
+			sb.WriteString(query[last:i])
+			sb.WriteString(placeholder(n))
+			last = i + 1
+		}
+	})
+	if n == 0 {
+		return query
+	}
+	sb.WriteString(query[last:])
+	return sb.String()
+}
+
+// topLevelWords returns the (upper-cased) words of the query that are not within parentheses, quotes or comments - along with their positions
+func topLevelWords(query string, brackets bool) (words []string, positions []int) {
+	start, prev := -1, -1
+	flush := func() {
+		if start != -1 {
+			words = append(words, strings.ToUpper(query[start:prev+1]))
+			positions = append(positions, start)
+			start = -1
+		}
+	}
+	scanSql(query, brackets, func(i int, depth int) {
+		if c := query[i]; depth == 0 && (c == '_' || (c >= 'A' && c <= 'Z') || (c >= 'a' && c <= 'z') || (c >= '0' && c <= '9')) {
+			if prev != i-1 {
+				flush()
+			}
+			if start == -1 {
+				start = i
+			}
+		} else {
+			flush()
+		}
+		prev = i
+	})
+	flush()
+	return words, positions
+}
+
+// orderByIndex returns the position of the last top level ORDER BY in the query (or -1 if there is none)
+func orderByIndex(query string, brackets bool) int {
+	words, positions := topLevelWords(query, brackets)
+	for i := len(words) - 2; i >= 0; i-- {
+		if words[i] == "ORDER" && words[i+1] == "BY" {
+			return positions[i]
+		}
+	}
+	return -1
+}
+
+// rowLimitIndex returns the position of a trailing top level row limiting clause (i.e. LIMIT, OFFSET or FETCH) in the
+// query - or -1 if there is none
+//
+// only the row limiting clauses after the last top level ORDER BY (if any) are considered
+func rowLimitIndex(query string, brackets bool) int {
+	words, positions := topLevelWords(query, brackets)
+	result := -1
+	for i := len(words) - 1; i >= 0; i-- {
+		switch words[i] {
+		case "LIMIT", "OFFSET", "FETCH":
+			result = positions[i]
+		case "BY":
+			if i > 0 && words[i-1] == "ORDER" {
+				return result
+			}
+		}
+	}
+	return result
+}
+
+// withoutOrderBy removes a trailing ORDER BY from the query (so that it can be used as a derived table for counting)
+//
+// the ORDER BY is not removed if it is followed by a row limiting clause (i.e. LIMIT, OFFSET or FETCH)
+func withoutOrderBy(query string) string {
+	words, positions := topLevelWords(query, true)
+	for i := len(words) - 2; i >= 0; i-- {
+		switch words[i] {
+		case "LIMIT", "OFFSET", "FETCH":
+			return query
+		case "ORDER":
+			if words[i+1] == "BY" {
+				return strings.TrimRight(query[:positions[i]], " \t\r\n")
+			}
+		}
+	}
+	return query
+}
+
+// dialectSqlInterface is a SqlInterface that rewrites the placeholders of queries according to the Dialect
+//
+// built-in sub-queries are executed with this SqlInterface - so that sub-queries use the same Dialect as the parent - but
+// user callbacks (e.g. RowPostProcessor, StructPostProcessor, Mapping.PostProcess and other SubQuery implementations) are
+// passed the unwrapped SqlInterface (see unwrapSqlInterface)
+type dialectSqlInterface struct {
+	SqlInterface
+	dialect Dialect
+}
+
+func (d *dialectSqlInterface) QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error) {
+	return d.SqlInterface.QueryContext(ctx, d.dialect.Placeholders(query), args...)
+}
+
+func (d *dialectSqlInterface) QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row {
+	return d.SqlInterface.QueryRowContext(ctx, d.dialect.Placeholders(query), args...)
+}
+
+func (d *dialectSqlInterface) ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error) {
+	return d.SqlInterface.ExecContext(ctx, d.dialect.Placeholders(query), args...)
+}
+
+// subQuerySqlInterface returns the SqlInterface to pass to a sub-query - built-in sub-queries are passed the SqlInterface
+// wrapped for the Dialect, other SubQuery implementations are passed the underlying SqlInterface
+func subQuerySqlInterface(sq SubQuery, sqli SqlInterface) SqlInterface {
+	if _, ok := sq.(internalSubQuery); ok {
+		return sqli
+	}
+	return unwrapSqlInterface(sqli)
+}
+
+// unwrapSqlInterface returns the underlying SqlInterface (if wrapped for a Dialect)
+func unwrapSqlInterface(sqli SqlInterface) SqlInterface {
+	if ds, ok := sqli.(*dialectSqlInterface); ok {
+		return ds.SqlInterface
+	}
+	return sqli
+}
@@ -0,0 +1,285 @@
+package columbus
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"regexp"
+	"testing"
+)
+
+func TestDialect_Placeholders(t *testing.T) {
+	testCases := []struct {
+		dialect Dialect
+		query   string
+		expect  string
+	}{
+		{defaultDialect, "SELECT * FROM t WHERE a = ? AND b = ?", "SELECT * FROM t WHERE a = ? AND b = ?"},
+		{MySQL, "SELECT * FROM t WHERE a = ? AND b = ?", "SELECT * FROM t WHERE a = ? AND b = ?"},
+		{SQLite, "SELECT * FROM t WHERE a = ? AND b = ?", "SELECT * FROM t WHERE a = ? AND b = ?"},
+		{Postgres, "SELECT * FROM t WHERE a = ? AND b = ?", "SELECT * FROM t WHERE a = $1 AND b = $2"},
+		{Postgres, "SELECT * FROM t WHERE a = '?' AND \"b?\" = ? -- ?\nAND c IN (?, ?) /* ? */", "SELECT * FROM t WHERE a = '?' AND \"b?\" = $1 -- ?\nAND c IN ($2, $3) /* ? */"},
+		{Postgres, "SELECT * FROM t WHERE a = 'it''s?' AND b = ?", "SELECT * FROM t WHERE a = 'it''s?' AND b = $1"},
+		{Postgres, "SELECT * FROM t WHERE a[?] = ?", "SELECT * FROM t WHERE a[$1] = $2"},
+		{Postgres, "SELECT * FROM t", "SELECT * FROM t"},
+		{SQLServer, "SELECT * FROM t WHERE [a?] = ? AND b = ?", "SELECT * FROM t WHERE [a?] = @p1 AND b = @p2"},
+		{SQLServer, "SELECT * FROM t /* unterminated ?", "SELECT * FROM t /* unterminated ?"},
+	}
+	for _, tc := range testCases {
+		assert.Equal(t, tc.expect, tc.dialect.Placeholders(tc.query))
+	}
+}
+
+func TestDialect_QuoteIdentifier(t *testing.T) {
+	assert.Equal(t, "name", defaultDialect.QuoteIdentifier("name"))
+	assert.Equal(t, "`na``me`", MySQL.QuoteIdentifier("na`me"))
+	assert.Equal(t, `"na""me"`, Postgres.QuoteIdentifier(`na"me`))
+	assert.Equal(t, `"name"`, SQLite.QuoteIdentifier("name"))
+	assert.Equal(t, "[na]]me]", SQLServer.QuoteIdentifier("na]me"))
+}
+
+func TestDialect_Limit(t *testing.T) {
+	assert.Equal(t, "SELECT * FROM t LIMIT 10", defaultDialect.Limit("SELECT * FROM t", 10, 0))
+	assert.Equal(t, "SELECT * FROM t LIMIT 10 OFFSET 20", Postgres.Limit("SELECT * FROM t", 10, 20))
+	assert.Equal(t, "SELECT * FROM t ORDER BY a OFFSET 20 ROWS FETCH NEXT 10 ROWS ONLY", SQLServer.Limit("SELECT * FROM t ORDER BY a", 10, 20))
+	assert.Equal(t, "SELECT * FROM t ORDER BY (SELECT NULL) OFFSET 0 ROWS FETCH NEXT 10 ROWS ONLY", SQLServer.Limit("SELECT * FROM t", 10, 0))
+	assert.Equal(t, "SELECT *, ROW_NUMBER() OVER (ORDER BY a) FROM t ORDER BY (SELECT NULL) OFFSET 0 ROWS FETCH NEXT 10 ROWS ONLY",
+		SQLServer.Limit("SELECT *, ROW_NUMBER() OVER (ORDER BY a) FROM t", 10, 0))
+}
+
+func TestWithoutOrderBy(t *testing.T) {
+	testCases := []struct {
+		query  string
+		expect string
+	}{
+		{"SELECT * FROM t", "SELECT * FROM t"},
+		{"SELECT * FROM t ORDER BY a DESC", "SELECT * FROM t"},
+		{"SELECT * FROM t order\n by a", "SELECT * FROM t"},
+		{"SELECT * FROM t ORDER BY a LIMIT 10", "SELECT * FROM t ORDER BY a LIMIT 10"},
+		{"SELECT * FROM t ORDER BY a OFFSET 5 ROWS", "SELECT * FROM t ORDER BY a OFFSET 5 ROWS"},
+		{"SELECT * FROM (SELECT * FROM t ORDER BY a LIMIT 1) x", "SELECT * FROM (SELECT * FROM t ORDER BY a LIMIT 1) x"},
+		{"SELECT * FROM t WHERE a = 'ORDER BY'", "SELECT * FROM t WHERE a = 'ORDER BY'"},
+		{"SELECT * FROM t WHERE border_by = 1", "SELECT * FROM t WHERE border_by = 1"},
+	}
+	for _, tc := range testCases {
+		assert.Equal(t, tc.expect, withoutOrderBy(tc.query))
+	}
+}
+
+func TestRowLimitIndex(t *testing.T) {
+	testCases := []struct {
+		query  string
+		expect int
+	}{
+		{"SELECT * FROM t", -1},
+		{"SELECT * FROM t ORDER BY a", -1},
+		{"SELECT * FROM t LIMIT 10", 16},
+		{"SELECT * FROM t ORDER BY a LIMIT 10 OFFSET 5", 27},
+		{"SELECT * FROM t ORDER BY a OFFSET 5 ROWS FETCH NEXT 10 ROWS ONLY", 27},
+		{"SELECT * FROM t LIMIT 10 ORDER BY a", -1},
+		{"SELECT * FROM (SELECT * FROM t LIMIT 1) x", -1},
+		{"SELECT * FROM t WHERE a = 'LIMIT 1'", -1},
+	}
+	for _, tc := range testCases {
+		assert.Equal(t, tc.expect, rowLimitIndex(tc.query, true), tc.query)
+	}
+}
+
+func TestMapper_Dialect(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer func() {
+		_ = db.Close()
+	}()
+	m, err := NewMapper("a,b", Query("FROM table WHERE a = ? AND b = ?"), Postgres,
+		NewSubQuery("subs", "SELECT c FROM sub WHERE a = ? AND b = ?", []string{"a", "b"}, nil, false))
+	require.NoError(t, err)
+
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT a,b FROM table WHERE a = $1 AND b = $2")).
+		WithArgs("x", "y").
+		WillReturnRows(sqlmock.NewRows([]string{"a", "b"}).AddRow("x", "y"))
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT c FROM sub WHERE a = $1 AND b = $2")).
+		WithArgs("x", "y").
+		WillReturnRows(sqlmock.NewRows([]string{"c"}).AddRow("c1"))
+	rows, err := m.Rows(ctx, db, []any{"x", "y"})
+	require.NoError(t, err)
+	require.NoError(t, mock.ExpectationsWereMet())
+	require.Len(t, rows, 1)
+	assert.Equal(t, []map[string]any{{"c": "c1"}}, rows[0]["subs"])
+
+	// per-call dialect...
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT a,b FROM table WHERE a = @p1 AND b = @p2")).
+		WithArgs("x", "y").
+		WillReturnRows(sqlmock.NewRows([]string{"a", "b"}))
+	_, err = m.Rows(ctx, db, []any{"x", "y"}, SQLServer)
+	require.NoError(t, err)
+	require.NoError(t, mock.ExpectationsWereMet())
+
+	// extended mapper keeps dialect...
+	m2, err := m.Extend(nil, nil)
+	require.NoError(t, err)
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT a,b FROM table WHERE a = $1 AND b = $2")).
+		WithArgs("x", "y").
+		WillReturnRows(sqlmock.NewRows([]string{"a", "b"}))
+	_, err = m2.FirstRow(ctx, db, []any{"x", "y"})
+	require.NoError(t, err)
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestMapper_Dialect_Keyset(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer func() {
+		_ = db.Close()
+	}()
+	m, err := NewMapper("a,b", Query("FROM table WHERE b = ?"), Postgres)
+	require.NoError(t, err)
+
+	cursor, err := (&keysetPage{sorts: []keysetSort{{property: "a", column: "a"}}}).encodeCursor(map[string]any{"a": "a1"}, keysetForward, mapKeysetValue)
+	require.NoError(t, err)
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM (SELECT a,b FROM table WHERE b = $1) _keyset WHERE ("a" > $2) ORDER BY "a" ASC LIMIT 3`)).
+		WithArgs("x", "a1").
+		WillReturnRows(sqlmock.NewRows([]string{"a", "b"}).AddRow("a2", "x"))
+	keyset := &Keyset{Sort: []string{"a"}, Size: 2, Cursor: cursor}
+	_, err = m.Rows(ctx, db, []any{"x"}, keyset)
+	require.NoError(t, err)
+	require.NoError(t, mock.ExpectationsWereMet())
+	assert.Empty(t, keyset.NextCursor)
+	assert.NotEmpty(t, keyset.PrevCursor)
+}
+
+func TestMapper_Dialect_Page(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer func() {
+		_ = db.Close()
+	}()
+	m, err := NewMapper("a,b", Query("FROM table WHERE b = ?"), SQLServer)
+	require.NoError(t, err)
+
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT COUNT(*) FROM (SELECT a,b FROM table WHERE b = @p1) _count")).
+		WithArgs("x").
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(3))
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT a,b FROM table WHERE b = @p1 ORDER BY a OFFSET 2 ROWS FETCH NEXT 2 ROWS ONLY")).
+		WithArgs("x").
+		WillReturnRows(sqlmock.NewRows([]string{"a", "b"}).AddRow("a3", "x"))
+	result, err := m.Page(ctx, db, []any{"x"}, 2, 2, AddClause("ORDER BY a"))
+	require.NoError(t, err)
+	require.NoError(t, mock.ExpectationsWereMet())
+	assert.Len(t, result.Rows, 1)
+	assert.Equal(t, int64(3), result.Total)
+}
+
+func TestStructMapper_Dialect(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer func() {
+		_ = db.Close()
+	}()
+	m, err := NewStructMapper[testStruct]("foo,bar", Query("FROM table WHERE foo = ?"), Postgres)
+	require.NoError(t, err)
+
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT foo,bar FROM table WHERE foo = $1")).
+		WithArgs("x").
+		WillReturnRows(sqlmock.NewRows([]string{"foo", "bar"}).AddRow("x", "y"))
+	rows, err := m.Rows(ctx, db, []any{"x"})
+	require.NoError(t, err)
+	require.NoError(t, mock.ExpectationsWereMet())
+	require.Len(t, rows, 1)
+
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT foo,bar FROM table WHERE foo = @p1")).
+		WithArgs("x").
+		WillReturnRows(sqlmock.NewRows([]string{"foo", "bar"}).AddRow("x", "y"))
+	_, err = m.FirstRow(ctx, db, []any{"x"}, SQLServer)
+	require.NoError(t, err)
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+type testDialectSubQuery struct{}
+
+func (sq *testDialectSubQuery) Execute(ctx context.Context, sqli SqlInterface, row map[string]any, exclusions PropertyExclusions) error {
+	if _, ok := sqli.(*sql.Tx); !ok {
+		return fmt.Errorf("unexpected SqlInterface %T", sqli)
+	}
+	return nil
+}
+
+func (sq *testDialectSubQuery) ProvidesProperty() string {
+	return ""
+}
+
+func TestMapper_Dialect_Callbacks(t *testing.T) {
+	db, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+	require.NoError(t, err)
+	defer func() {
+		_ = db.Close()
+	}()
+	m, err := NewMapper("a,b", Query("FROM table WHERE a = ?"), Postgres, &testDialectSubQuery{},
+		RowPostProcessorFunc(func(ctx context.Context, sqli SqlInterface, row map[string]any) error {
+			if _, ok := sqli.(*sql.Tx); !ok {
+				return fmt.Errorf("unexpected SqlInterface %T", sqli)
+			}
+			_, err := sqli.ExecContext(ctx, `UPDATE seen SET tags = tags WHERE tags ?| array['a']`)
+			return err
+		}))
+	require.NoError(t, err)
+
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT a,b FROM table WHERE a = $1").WithArgs("x").
+		WillReturnRows(sqlmock.NewRows([]string{"a", "b"}).AddRow("x", "y"))
+	mock.ExpectExec(`UPDATE seen SET tags = tags WHERE tags ?| array['a']`).WillReturnResult(sqlmock.NewResult(0, 1))
+	tx, err := db.Begin()
+	require.NoError(t, err)
+	rows, err := m.Rows(ctx, tx, []any{"x"})
+	require.NoError(t, err)
+	require.Len(t, rows, 1)
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+type testDialectStruct struct {
+	Foo string `sql:"foo"`
+}
+
+type testDialectPostProcessor struct {
+	sqli SqlInterface
+}
+
+func (pp *testDialectPostProcessor) PostProcess(ctx context.Context, sqli SqlInterface, row *testDialectStruct) error {
+	pp.sqli = sqli
+	return nil
+}
+
+func TestStructMapper_Dialect_PostProcessor(t *testing.T) {
+	db, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+	require.NoError(t, err)
+	defer func() {
+		_ = db.Close()
+	}()
+	m, err := NewStructMapper[testDialectStruct]("foo", Query("FROM table WHERE foo = ?"), Postgres)
+	require.NoError(t, err)
+
+	mock.ExpectQuery("SELECT foo FROM table WHERE foo = $1").WithArgs("x").
+		WillReturnRows(sqlmock.NewRows([]string{"foo"}).AddRow("x"))
+	pp := &testDialectPostProcessor{}
+	_, err = m.Rows(ctx, db, []any{"x"}, pp)
+	require.NoError(t, err)
+	require.NoError(t, mock.ExpectationsWereMet())
+	assert.Same(t, db, pp.sqli)
+}
+
+func TestSubQueryConcurrency_enabled_Dialect(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer func() {
+		_ = db.Close()
+	}()
+	c := SubQueryConcurrency{Limit: 2}
+	assert.True(t, c.enabled(&dialectSqlInterface{SqlInterface: db, dialect: Postgres}))
+	mock.ExpectBegin()
+	tx, err := db.Begin()
+	require.NoError(t, err)
+	assert.False(t, c.enabled(&dialectSqlInterface{SqlInterface: tx, dialect: Postgres}))
+}
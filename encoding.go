@@ -0,0 +1,333 @@
+package columbus
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// RowEncoding is an option that can be passed to NewMapper or any of the Mapper write methods (WriteRows, WriteFirstRow,
+// WriteExactlyOneRow or WritePage) and determines the format in which rows are written
+//
+// the built-in encodings are JsonArray (the default), NDJson, CSV, TSV, XML, XLSX, MsgPack, CBOR and Avro
+type RowEncoding interface {
+	// ContentType returns the content (media) type of the encoding - e.g. `application/json`
+	ContentType() string
+	// NewEncoder creates a RowEncoder that writes to the supplied writer
+	NewEncoder(w io.Writer) RowEncoder
+}
+
+// RowEncoder encodes rows to a writer - a RowEncoder is created (by RowEncoding.NewEncoder) for each write
+type RowEncoder interface {
+	// Begin is called before any rows are encoded
+	Begin(info EncodeInfo) error
+	// Row encodes a row
+	Row(row map[string]any) error
+	// End is called after all rows are encoded - once Begin has been called, End is always called (even if an error occurred)
+	End() error
+}
+
+// PageEncoding is an optional interface that a RowEncoding can implement to support writing pages (i.e. Mapper.WritePage)
+//
+// the built-in encodings that support pages are JsonArray, MsgPack and CBOR
+type PageEncoding interface {
+	// NewPageEncoder creates a PageEncoder that writes a page to the supplied writer
+	NewPageEncoder(w io.Writer) PageEncoder
+}
+
+// PageEncoder encodes a page of rows to a writer
+type PageEncoder interface {
+	RowEncoder
+	// Page is called, after End, with the page information (the Rows of the PageResult are not set) - Page is not called
+	// if an error occurred
+	Page(info PageResult) error
+}
+
+// EncodeInfo is the information passed to RowEncoder.Begin
+type EncodeInfo struct {
+	// Single indicates that a single row is being written (i.e. WriteFirstRow or WriteExactlyOneRow) rather than rows
+	Single bool
+	// Properties is the (non-excluded) property names in column order - followed by any properties provided by sub-queries
+	// and row post processors
+	//
+	// nested properties (i.e. mapped with a Path) are specified using dot notation - e.g. `address.city`
+	Properties []string
+	// Columns is the type information for the properties that are read from columns (or, for StructMapper, struct fields) -
+	// in the same order as Properties
+	Columns []ColumnInfo
+	// Nested is the structure of the properties whose values are objects or arrays of objects - i.e. properties provided
+	// by sub-queries or Grouping (or, for StructMapper, slice of struct fields) - in the same order as Properties
+	Nested []NestedInfo
+}
+
+// NestedInfo is the structure of a property whose value is an object or an array of objects (see EncodeInfo.Nested)
+type NestedInfo struct {
+	// Property is the property name
+	Property string
+	// Array indicates that the property value is an array of objects (otherwise the value is an object)
+	Array bool
+	// Properties is the property names of the objects (using dot notation for nested properties) - nil if the properties
+	// are not known until rows are read (e.g. the properties of sub-query rows)
+	Properties []string
+}
+
+// ColumnInfo is the type information for a property that is read from a column (see EncodeInfo.Columns)
+type ColumnInfo struct {
+	// Property is the property name (using dot notation for nested properties)
+	Property string
+	// Column is the column name (empty for StructMapper)
+	Column string
+	// DatabaseType is the database type name of the column (see sql.ColumnType.DatabaseTypeName)
+	DatabaseType string
+	// ScanType is the scan type of the column (see sql.ColumnType.ScanType) - for StructMapper, the field type
+	ScanType reflect.Type
+	// Nullable indicates whether the property value can be null (or omitted) - true if the nullability of the column is not known
+	Nullable bool
+	// Decimal indicates that the property value is a decimal.Decimal (see UseDecimals)
+	Decimal bool
+	// Precision is the decimal precision of the column (only if DecimalSize is true)
+	Precision int64
+	// Scale is the decimal scale of the column (only if DecimalSize is true)
+	Scale int64
+	// DecimalSize indicates whether the Precision and Scale of the column are known
+	DecimalSize bool
+	// Converted indicates that the property value is converted (by a Mapping Scanner, PostProcess or NullDefault - or
+	// from a JSON column) and so its type cannot be determined from the column types
+	Converted bool
+}
+
+// Accept is an option that can be passed to any of the Mapper write methods and selects the RowEncoding from an
+// HTTP Accept header value (see NegotiateEncoding)
+//
+// the encoding is selected from any RowEncoding options (passed to the write method or NewMapper) and the DefaultEncodings - if
+// no encoding is acceptable, a *NotAcceptableError is returned
+type Accept string
+
+var (
+	// JsonArray is the RowEncoding that writes rows as a JSON array (or, for a single row, a JSON object)
+	JsonArray RowEncoding = &jsonArrayEncoding{}
+	// NDJson is the RowEncoding that writes rows as newline delimited JSON (i.e. one JSON object per line)
+	//
+	// each row is flushed as it is written (if the writer is an http.Flusher - or has a `Flush() error` method, e.g. bufio.Writer) so
+	// that rows are streamed incrementally - rows are written once any sub-queries are complete (i.e., when there are batch
+	// sub-queries, as each batch is completed)
+	NDJson RowEncoding = &ndJsonEncoding{}
+	// CSV is the RowEncoding that writes rows as CSV - with a header row of property names (see CSVEncoding)
+	CSV RowEncoding = &CSVEncoding{}
+	// TSV is the RowEncoding that writes rows as TSV (tab separated values) - with a header row of property names (see CSVEncoding)
+	TSV RowEncoding = &CSVEncoding{Delimiter: '\t'}
+)
+
+// DefaultEncodings is the encodings, in order of preference, used by NegotiateEncoding (when no encodings are specified)
+var DefaultEncodings = []RowEncoding{JsonArray, NDJson, CSV, TSV, XML, XLSX, MsgPack, CBOR, Avro}
+
+// NotAcceptableError is the error returned when no RowEncoding is acceptable for an HTTP Accept header
+type NotAcceptableError struct {
+	Accept string
+}
+
+func (e *NotAcceptableError) Error() string {
+	return fmt.Sprintf("no acceptable encoding for '%s'", e.Accept)
+}
+
+// NegotiateEncoding selects a RowEncoding for an HTTP Accept header value - e.g. `text/csv, application/json;q=0.9`
+//
+// the encoding with the highest quality (q) is selected - where encodings have the same quality, the first encoding is selected
+//
+// if no encodings are specified, the DefaultEncodings are used - an empty accept selects the first encoding
+//
+// if no encoding is acceptable, a *NotAcceptableError is returned
+func NegotiateEncoding(accept string, encodings ...RowEncoding) (RowEncoding, error) {
+	if len(encodings) == 0 {
+		encodings = DefaultEncodings
+	}
+	if strings.TrimSpace(accept) == "" {
+		return encodings[0], nil
+	}
+	ranges := parseAccept(accept)
+	var result RowEncoding
+	bestQ := 0.0
+	for _, enc := range encodings {
+		if q := acceptQuality(ranges, enc.ContentType()); q > bestQ {
+			result, bestQ = enc, q
+		}
+	}
+	if result == nil {
+		return nil, &NotAcceptableError{Accept: accept}
+	}
+	return result, nil
+}
+
+type mediaRange struct {
+	mediaType string
+	q         float64
+}
+
+func parseAccept(accept string) []mediaRange {
+	result := make([]mediaRange, 0)
+	for _, s := range strings.Split(accept, ",") {
+		parts := strings.Split(s, ";")
+		mr := mediaRange{mediaType: strings.ToLower(strings.TrimSpace(parts[0])), q: 1}
+		if mr.mediaType == "" {
+			continue
+		}
+		for _, p := range parts[1:] {
+			if k, v, ok := strings.Cut(strings.TrimSpace(p), "="); ok && strings.TrimSpace(k) == "q" {
+				if q, err := strconv.ParseFloat(strings.TrimSpace(v), 64); err == nil {
+					mr.q = q
+				}
+			}
+		}
+		result = append(result, mr)
+	}
+	return result
+}
+
+// acceptQuality returns the quality for a content type - using the most specific matching media range
+func acceptQuality(ranges []mediaRange, contentType string) float64 {
+	mediaType, _, _ := strings.Cut(strings.ToLower(contentType), ";")
+	mediaType = strings.TrimSpace(mediaType)
+	mainType, _, _ := strings.Cut(mediaType, "/")
+	result := 0.0
+	specificity := -1
+	for _, mr := range ranges {
+		s := -1
+		switch mr.mediaType {
+		case mediaType:
+			s = 2
+		case mainType + "/*":
+			s = 1
+		case "*/*":
+			s = 0
+		}
+		if s > specificity {
+			result, specificity = mr.q, s
+		}
+	}
+	return result
+}
+
+type jsonArrayEncoding struct{}
+
+func (e *jsonArrayEncoding) ContentType() string {
+	return "application/json"
+}
+
+func (e *jsonArrayEncoding) NewEncoder(w io.Writer) RowEncoder {
+	return &jsonArrayEncoder{w: w, enc: json.NewEncoder(w)}
+}
+
+type jsonArrayEncoder struct {
+	w       io.Writer
+	enc     *json.Encoder
+	single  bool
+	written int
+}
+
+func (e *jsonArrayEncoder) Begin(info EncodeInfo) (err error) {
+	if e.single = info.Single; !e.single {
+		_, err = e.w.Write([]byte("["))
+	}
+	return err
+}
+
+func (e *jsonArrayEncoder) Row(row map[string]any) (err error) {
+	if e.written > 0 && !e.single {
+		_, err = e.w.Write([]byte(","))
+	}
+	if err == nil {
+		err = e.enc.Encode(row)
+		e.written++
+	}
+	return err
+}
+
+func (e *jsonArrayEncoder) End() (err error) {
+	if !e.single {
+		_, err = e.w.Write([]byte("]"))
+	}
+	return err
+}
+
+func (e *jsonArrayEncoding) NewPageEncoder(w io.Writer) PageEncoder {
+	return &jsonPageEncoder{jsonArrayEncoder{w: w, enc: json.NewEncoder(w)}}
+}
+
+type jsonPageEncoder struct {
+	jsonArrayEncoder
+}
+
+func (e *jsonPageEncoder) Begin(info EncodeInfo) (err error) {
+	if _, err = e.w.Write([]byte(`{"rows":`)); err == nil {
+		err = e.jsonArrayEncoder.Begin(info)
+	}
+	return err
+}
+
+func (e *jsonPageEncoder) Page(info PageResult) (err error) {
+	_, err = fmt.Fprintf(e.w, `,"page":%d,"size":%d,"total":%d,"pages":%d}`, info.Page, info.Size, info.Total, info.Pages)
+	return err
+}
+
+type ndJsonEncoding struct{}
+
+func (e *ndJsonEncoding) ContentType() string {
+	return "application/x-ndjson"
+}
+
+func (e *ndJsonEncoding) NewEncoder(w io.Writer) RowEncoder {
+	return &ndJsonEncoder{w: w, enc: json.NewEncoder(w)}
+}
+
+type ndJsonEncoder struct {
+	w   io.Writer
+	enc *json.Encoder
+}
+
+func (e *ndJsonEncoder) Begin(info EncodeInfo) error {
+	return nil
+}
+
+func (e *ndJsonEncoder) Row(row map[string]any) (err error) {
+	if err = e.enc.Encode(row); err == nil {
+		err = flush(e.w)
+	}
+	return err
+}
+
+func (e *ndJsonEncoder) End() error {
+	return nil
+}
+
+// flush flushes the writer - if it is an http.Flusher (or has a `Flush() error` method)
+func flush(w io.Writer) error {
+	switch f := w.(type) {
+	case interface{ Flush() error }:
+		return f.Flush()
+	case interface{ Flush() }:
+		f.Flush()
+	}
+	return nil
+}
+
+// resolveEncoding resolves the RowEncoding to use for writing rows - from the explicit (option) encoding, the default
+// encoding (of the mapper) and any Accept option
+func resolveEncoding(explicit RowEncoding, defaultEncoding RowEncoding, accept *Accept) (RowEncoding, error) {
+	if accept != nil {
+		encodings := make([]RowEncoding, 0, len(DefaultEncodings)+2)
+		for _, enc := range []RowEncoding{explicit, defaultEncoding} {
+			if enc != nil {
+				encodings = append(encodings, enc)
+			}
+		}
+		return NegotiateEncoding(string(*accept), append(encodings, DefaultEncodings...)...)
+	} else if explicit != nil {
+		return explicit, nil
+	} else if defaultEncoding != nil {
+		return defaultEncoding, nil
+	}
+	return JsonArray, nil
+}
@@ -0,0 +1,158 @@
+package columbus
+
+import (
+	"bytes"
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+func TestNegotiateEncoding(t *testing.T) {
+	testCases := []struct {
+		accept    string
+		encodings []RowEncoding
+		expect    RowEncoding
+		expectErr string
+	}{
+		{accept: "", expect: JsonArray},
+		{accept: "*/*", expect: JsonArray},
+		{accept: "text/csv", expect: CSV},
+		{accept: "text/*", expect: CSV},
+		{accept: "application/x-ndjson, application/json;q=0.9", expect: NDJson},
+		{accept: "application/json;q=0.5, text/csv", expect: CSV},
+		{accept: "text/html, */*;q=0.8", expect: JsonArray},
+		{accept: "*/*, application/json;q=0", expect: NDJson},
+		{accept: "", encodings: []RowEncoding{CSV, JsonArray}, expect: CSV},
+		{accept: "application/*", encodings: []RowEncoding{CSV}, expectErr: "no acceptable encoding for 'application/*'"},
+		{accept: "text/html", expectErr: "no acceptable encoding for 'text/html'"},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.accept, func(t *testing.T) {
+			enc, err := NegotiateEncoding(tc.accept, tc.encodings...)
+			if tc.expectErr != "" {
+				require.Error(t, err)
+				assert.Equal(t, tc.expectErr, err.Error())
+				assert.IsType(t, &NotAcceptableError{}, err)
+			} else {
+				require.NoError(t, err)
+				assert.Equal(t, tc.expect, enc)
+			}
+		})
+	}
+}
+
+func TestMapper_WriteRows_Encodings(t *testing.T) {
+	m, err := newMapper("id,name,city,amount,created", Mappings{
+		"city": {Path: []string{"address"}},
+	}, Query(`FROM table`))
+	require.NoError(t, err)
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer func() {
+		_ = db.Close()
+	}()
+	created := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	newRows := func() *sqlmock.Rows {
+		return sqlmock.NewRows([]string{"id", "name", "city", "amount", "created"}).
+			AddRow(1, "foo", "London", decimal.RequireFromString("1.50"), created).
+			AddRow(2, "bar, baz", nil, nil, created)
+	}
+
+	mock.ExpectQuery("").WillReturnRows(newRows())
+	w := bytes.NewBuffer(nil)
+	err = m.WriteRows(ctx, w, db, nil, NDJson)
+	require.NoError(t, err)
+	assert.Equal(t, `{"address":{"city":"London"},"amount":"1.5","created":"2026-01-02T03:04:05Z","id":1,"name":"foo"}
+{"address":{"city":null},"amount":null,"created":"2026-01-02T03:04:05Z","id":2,"name":"bar, baz"}
+`, w.String())
+
+	mock.ExpectQuery("").WillReturnRows(newRows())
+	w = bytes.NewBuffer(nil)
+	err = m.WriteRows(ctx, w, db, nil, Accept("text/csv"))
+	require.NoError(t, err)
+	assert.Equal(t, `id,name,address.city,amount,created
+1,foo,London,1.5,2026-01-02T03:04:05Z
+2,"bar, baz",,,2026-01-02T03:04:05Z
+`, w.String())
+
+	mock.ExpectQuery("").WillReturnRows(newRows())
+	w = bytes.NewBuffer(nil)
+	err = m.WriteFirstRow(ctx, w, db, nil, CSV, AllowedProperties{"id": nil, "name": nil})
+	require.NoError(t, err)
+	assert.Equal(t, "id,name\n1,foo\n", w.String())
+	require.NoError(t, mock.ExpectationsWereMet())
+
+	err = m.WriteRows(ctx, w, db, nil, Accept("text/html"))
+	require.Error(t, err)
+	assert.Equal(t, "no acceptable encoding for 'text/html'", err.Error())
+
+	err = m.WritePage(ctx, w, db, nil, 1, 10, NDJson)
+	require.Error(t, err)
+	assert.Equal(t, "page cannot be written using 'application/x-ndjson' encoding", err.Error())
+}
+
+func TestMapper_RowEncoding_Default(t *testing.T) {
+	m, err := newMapper("a", Query(`FROM table`), NDJson)
+	require.NoError(t, err)
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer func() {
+		_ = db.Close()
+	}()
+
+	mock.ExpectQuery("").WillReturnRows(sqlmock.NewRows([]string{"a"}).AddRow("x").AddRow("y"))
+	w := bytes.NewBuffer(nil)
+	err = m.WriteRows(ctx, w, db, nil)
+	require.NoError(t, err)
+	assert.Equal(t, "{\"a\":\"x\"}\n{\"a\":\"y\"}\n", w.String())
+
+	mock.ExpectQuery("").WillReturnRows(sqlmock.NewRows([]string{"a"}).AddRow("x"))
+	w = bytes.NewBuffer(nil)
+	err = m.WriteRows(ctx, w, db, nil, Accept("application/json"))
+	require.NoError(t, err)
+	assert.Equal(t, "[{\"a\":\"x\"}\n]", w.String())
+
+	m2, err := m.Extend(nil, nil)
+	require.NoError(t, err)
+	mock.ExpectQuery("").WillReturnRows(sqlmock.NewRows([]string{"a"}).AddRow("x"))
+	w = bytes.NewBuffer(nil)
+	err = m2.WriteExactlyOneRow(ctx, w, db, nil)
+	require.NoError(t, err)
+	assert.Equal(t, "{\"a\":\"x\"}\n", w.String())
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+type testFlushWriter struct {
+	bytes.Buffer
+	flushed []string
+}
+
+func (w *testFlushWriter) Flush() {
+	w.flushed = append(w.flushed, w.String())
+}
+
+func TestMapper_WriteRows_NDJson_Flushes(t *testing.T) {
+	m, err := newMapper("id", Query(`FROM table`),
+		NewSubQuery("subs", `SELECT sub_id FROM sub_table WHERE id = ?`, []string{"id"}, nil, false))
+	require.NoError(t, err)
+	db, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+	require.NoError(t, err)
+	defer func() {
+		_ = db.Close()
+	}()
+
+	mock.ExpectQuery("SELECT id FROM table").WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1).AddRow(2))
+	mock.ExpectQuery("SELECT sub_id FROM sub_table WHERE id = ?").WithArgs(1).WillReturnRows(sqlmock.NewRows([]string{"sub_id"}).AddRow(11))
+	mock.ExpectQuery("SELECT sub_id FROM sub_table WHERE id = ?").WithArgs(2).WillReturnRows(sqlmock.NewRows([]string{"sub_id"}))
+	w := &testFlushWriter{}
+	err = m.WriteRows(ctx, w, db, nil, NDJson)
+	require.NoError(t, err)
+	require.NoError(t, mock.ExpectationsWereMet())
+	assert.Equal(t, []string{
+		"{\"id\":1,\"subs\":[{\"sub_id\":11}]}\n",
+		"{\"id\":1,\"subs\":[{\"sub_id\":11}]}\n{\"id\":2,\"subs\":[]}\n",
+	}, w.flushed)
+}
@@ -2,6 +2,9 @@ package columbus
 
 // PropertyExcluder is an option that can be passed to Mapper.Rows, Mapper.FirstRow and Mapper.ExactlyOneRow
 // and is called during row mapping to determine whether a property is to be excluded from the final row
+//
+// properties used as sub-query args (i.e. the argColumns of NewSubQuery etc. - for sub-queries whose property is not
+// excluded) are never excluded - so that the sub-queries can be executed
 type PropertyExcluder interface {
 	// Exclude should return true if the property is to be excluded
 	Exclude(property string, path []string) bool
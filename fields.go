@@ -0,0 +1,232 @@
+package columbus
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// Fields is a sparse fieldset - a selection of the properties to be included in rows
+//
+// use ParseFields to create Fields from a comma separated list of property names - e.g. `name,address.city,orders.total`
+//
+// Fields is a PropertyExcluder and can be passed as an option to Mapper.Rows (or any of the other row reading methods) -
+// properties (including properties in Mapping.Path objects and sub-query properties) that are not selected are excluded
+//
+// when Fields is used, any column in the query select list that only feeds excluded properties is also omitted from
+// the query - a column is not omitted if it is referenced elsewhere in the query (e.g. in an ORDER BY), if it is not a plain
+// column (or aliased expression) or if it contains arg markers
+//
+// properties used as sub-query args (i.e. the argColumns of NewSubQuery etc. - for sub-queries whose property is selected)
+// and Keyset sort properties are always included (so that the sub-queries can be executed and cursors encoded)
+type Fields struct {
+	root *fieldNode
+}
+
+// fieldNode is a node in the field selection tree - a node with no children selects the entire property (and all its sub-properties)
+type fieldNode struct {
+	children map[string]*fieldNode
+}
+
+var _ PropertyExcluder = (*Fields)(nil)
+
+var fieldNameRegex = regexp.MustCompile(`^[A-Za-z0-9_$-]+$`)
+
+// InvalidFieldError is the error returned by ParseFields when a field (property name) is invalid
+type InvalidFieldError struct {
+	Field string
+}
+
+func (e *InvalidFieldError) Error() string {
+	return fmt.Sprintf("invalid field '%s'", e.Field)
+}
+
+// ParseFields parses a comma separated list of property names into Fields
+//
+// nested properties are specified using dot notation - e.g. `address.city` (selecting a property selects all its sub-properties)
+//
+// an empty (or whitespace only) string returns nil (i.e. no field selection)
+func ParseFields(s string) (*Fields, error) {
+	if strings.TrimSpace(s) == "" {
+		return nil, nil
+	}
+	result := &Fields{root: &fieldNode{children: map[string]*fieldNode{}}}
+	for _, field := range strings.Split(s, ",") {
+		field = strings.TrimSpace(field)
+		names := strings.Split(field, ".")
+		for _, name := range names {
+			if !fieldNameRegex.MatchString(name) {
+				return nil, &InvalidFieldError{Field: field}
+			}
+		}
+		node := result.root
+		for i, name := range names {
+			child, ok := node.children[name]
+			if ok && child.children == nil {
+				// already selected in its entirety...
+				break
+			} else if i == len(names)-1 {
+				node.children[name] = &fieldNode{}
+				break
+			} else if !ok {
+				child = &fieldNode{children: map[string]*fieldNode{}}
+				node.children[name] = child
+			}
+			node = child
+		}
+	}
+	return result, nil
+}
+
+// Exclude implements PropertyExcluder
+func (f *Fields) Exclude(property string, path []string) bool {
+	if f == nil || f.root == nil {
+		return false
+	}
+	node := f.root
+	for _, name := range append(path[:len(path):len(path)], property) {
+		child, ok := node.children[name]
+		if !ok {
+			return true
+		} else if child.children == nil {
+			return false
+		}
+		node = child
+	}
+	return false
+}
+
+// hasFields checks whether any of the exclusions is Fields
+func hasFields(exclusions PropertyExclusions) bool {
+	for _, exc := range exclusions {
+		switch et := exc.(type) {
+		case *Fields:
+			if et != nil {
+				return true
+			}
+		case PropertyExclusions:
+			if hasFields(et) {
+				return true
+			}
+		case *keepPropertiesExcluder:
+			if hasFields(et.exclusions) {
+				return true
+			}
+		case *subPathExcluder:
+			if hasFields(et.exclusions) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// omitExcludedColumns removes the columns from the query select list that only feed excluded properties
+//
+// keep is any additional column names that must not be removed (e.g. keyset sort columns)
+func (o *mapOptions) omitExcludedColumns(subPath []string, keep []string) {
+	start, end, ok := selectList(o.query)
+	if !ok {
+		return
+	}
+	referenced := make(map[string]bool, len(keep))
+	for _, col := range keep {
+		referenced[strings.ToUpper(col)] = true
+	}
+	for _, w := range wordRegex.FindAllString(o.query[end:], -1) {
+		referenced[strings.ToUpper(w)] = true
+	}
+	items := selectItems(o.query[start:end])
+	kept := make([]string, 0, len(items))
+	for _, item := range items {
+		if col := selectItemColumn(item); col == "" || referenced[strings.ToUpper(col)] || strings.ContainsAny(item, "?:@") || !o.columnExcluded(col, subPath) {
+			kept = append(kept, strings.TrimSpace(item))
+		}
+	}
+	if len(kept) > 0 && len(kept) < len(items) {
+		o.query = o.query[:start] + " " + strings.Join(kept, ",") + o.query[end:]
+	}
+}
+
+// columnExcluded checks whether the property for a column is excluded
+func (o *mapOptions) columnExcluded(col string, subPath []string) bool {
+	if mapping, ok := o.mappings[col]; ok {
+		name := col
+		if mapping.PropertyName != "" {
+			name = mapping.PropertyName
+		}
+		return o.exclusions.Exclude(name, append(subPath[:len(subPath):len(subPath)], mapping.Path...))
+	}
+	return o.exclusions.Exclude(col, subPath)
+}
+
+// selectList returns the start and end positions of the select list in a `SELECT ... FROM ...` query
+//
+// ok is false if the query is not a `SELECT ... FROM ...` query or the select list is qualified (e.g. `SELECT DISTINCT ...`)
+func selectList(query string) (start int, end int, ok bool) {
+	words, positions := topLevelWords(query, true)
+	if len(words) < 2 || words[0] != "SELECT" {
+		return 0, 0, false
+	}
+	switch words[1] {
+	case "DISTINCT", "ALL", "TOP":
+		return 0, 0, false
+	}
+	for i := 1; i < len(words); i++ {
+		if words[i] == "FROM" {
+			start = positions[0] + len("SELECT")
+			return start, strings.LastIndexFunc(query[:positions[i]], isNotSpace) + 1, true
+		}
+	}
+	return 0, 0, false
+}
+
+func isNotSpace(r rune) bool {
+	return r != ' ' && r != '\t' && r != '\r' && r != '\n'
+}
+
+// selectItems splits a select list into the individual (top level) items
+func selectItems(list string) []string {
+	result := make([]string, 0)
+	last := 0
+	scanSql(list, true, func(i int, depth int) {
+		if depth == 0 && list[i] == ',' {
+			result = append(result, list[last:i])
+			last = i + 1
+		}
+	})
+	return append(result, list[last:])
+}
+
+const columnSegment = "([A-Za-z_][A-Za-z0-9_]*|\"[^\"]+\"|`[^`]+`|\\[[^\\]]+\\])"
+
+var (
+	qualifiedColumnRegex = regexp.MustCompile("^" + columnSegment + "(\\." + columnSegment + ")*$")
+	aliasRegex           = regexp.MustCompile("^" + columnSegment + "$")
+	lastSegmentRegex     = regexp.MustCompile(columnSegment + "$")
+	wordRegex            = regexp.MustCompile(`[A-Za-z_][A-Za-z0-9_]*`)
+)
+
+// selectItemColumn returns the result column name for a select item (or an empty string if the name cannot be determined)
+//
+// the name can be determined for plain (or qualified) columns - e.g. `name` or `t.name` - and aliased expressions - e.g. `COUNT(*) AS total`
+func selectItemColumn(item string) (name string) {
+	item = strings.TrimSpace(item)
+	words, positions := topLevelWords(item, true)
+	if n := len(words); n > 0 && words[n-1] == "AS" {
+		name = strings.TrimSpace(item[positions[n-1]+2:])
+	} else if n > 1 && words[n-2] == "AS" {
+		name = strings.TrimSpace(item[positions[n-2]+2:])
+	} else if qualifiedColumnRegex.MatchString(item) {
+		name = lastSegmentRegex.FindString(item)
+	}
+	if !aliasRegex.MatchString(name) {
+		return ""
+	} else if name[0] == '"' || name[0] == '`' || name[0] == '[' {
+		name = name[1 : len(name)-1]
+	}
+	if !keysetColumnRegex.MatchString(name) {
+		return ""
+	}
+	return name
+}
@@ -0,0 +1,237 @@
+package columbus
+
+import (
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestParseFields(t *testing.T) {
+	f, err := ParseFields("")
+	require.NoError(t, err)
+	assert.Nil(t, f)
+	f, err = ParseFields("   ")
+	require.NoError(t, err)
+	assert.Nil(t, f)
+
+	f, err = ParseFields(" name , address.city,orders.total,address.city.code,orders")
+	require.NoError(t, err)
+	require.NotNil(t, f)
+	assert.Equal(t, 3, len(f.root.children))
+	assert.Nil(t, f.root.children["name"].children)
+	assert.Equal(t, 1, len(f.root.children["address"].children))
+	assert.Nil(t, f.root.children["address"].children["city"].children)
+	assert.Nil(t, f.root.children["orders"].children)
+
+	_, err = ParseFields("name,,id")
+	require.Error(t, err)
+	assert.Equal(t, "invalid field ''", err.Error())
+	_, err = ParseFields("address..city")
+	require.Error(t, err)
+	assert.Equal(t, "invalid field 'address..city'", err.Error())
+	_, err = ParseFields("name;DROP TABLE x")
+	require.Error(t, err)
+	assert.Equal(t, "invalid field 'name;DROP TABLE x'", err.Error())
+}
+
+func TestFields_Exclude(t *testing.T) {
+	f, err := ParseFields("name,address.city,orders")
+	require.NoError(t, err)
+	testCases := []struct {
+		property string
+		path     []string
+		expect   bool
+	}{
+		{property: "name", expect: false},
+		{property: "id", expect: true},
+		{property: "address", expect: false},
+		{property: "city", path: []string{"address"}, expect: false},
+		{property: "street", path: []string{"address"}, expect: true},
+		{property: "code", path: []string{"address", "city"}, expect: false},
+		{property: "orders", expect: false},
+		{property: "total", path: []string{"orders"}, expect: false},
+		{property: "name", path: []string{"customer"}, expect: true},
+	}
+	for _, tc := range testCases {
+		assert.Equal(t, tc.expect, f.Exclude(tc.property, tc.path), "property: %s, path: %v", tc.property, tc.path)
+	}
+	var nf *Fields
+	assert.False(t, nf.Exclude("name", nil))
+}
+
+func TestSelectItemColumn(t *testing.T) {
+	testCases := map[string]string{
+		"name":               "name",
+		" t.name ":           "name",
+		`"t"."name"`:         "name",
+		"COUNT(*) AS total":  "total",
+		"COUNT(*) total":     "",
+		"a + b":              "",
+		"LOWER(name) AS `n`": "n",
+		"*":                  "",
+		"t.*":                "",
+	}
+	for item, expect := range testCases {
+		assert.Equal(t, expect, selectItemColumn(item), "item: %s", item)
+	}
+}
+
+func TestMapper_Fields(t *testing.T) {
+	db, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+	require.NoError(t, err)
+	defer func() {
+		_ = db.Close()
+	}()
+	m, err := newMapper("id,name,street,city,COUNT(*) AS total,status", Mappings{
+		"street": {Path: []string{"address"}},
+		"city":   {Path: []string{"address"}},
+	}, Query(`FROM table WHERE status = 'ACTIVE' GROUP BY id,name,street,city`))
+	require.NoError(t, err)
+
+	mock.ExpectQuery("SELECT id,name,street,city,status FROM table WHERE status = 'ACTIVE' GROUP BY id,name,street,city").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "name", "street", "city", "status"}).AddRow(1, "foo", "Main St", "London", "ACTIVE"))
+
+	f, err := ParseFields("name,address.city")
+	require.NoError(t, err)
+	rows, err := m.Rows(ctx, db, nil, f)
+	require.NoError(t, err)
+	require.NoError(t, mock.ExpectationsWereMet())
+	require.Equal(t, 1, len(rows))
+	assert.Equal(t, map[string]any{"name": "foo", "address": map[string]any{"city": "London"}}, rows[0])
+}
+
+func TestMapper_Fields_SubQuery(t *testing.T) {
+	db, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+	require.NoError(t, err)
+	defer func() {
+		_ = db.Close()
+	}()
+	m, err := newMapper("id,name,created_at", Query(`FROM customers`),
+		NewSubQuery("orders", `SELECT order_id,total,placed_at FROM orders WHERE customer_id = ?`, []string{"id"}, nil, false),
+		NewSubQuery("notes", `SELECT note FROM notes WHERE customer_id = ?`, []string{"id"}, nil, false),
+	)
+	require.NoError(t, err)
+
+	mock.ExpectQuery("SELECT id,name FROM customers").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "name"}).AddRow(1, "foo"))
+	mock.ExpectQuery("SELECT total FROM orders WHERE customer_id = ?").WithArgs(1).
+		WillReturnRows(sqlmock.NewRows([]string{"total"}).AddRow(10))
+
+	f, err := ParseFields("id,name,orders.total")
+	require.NoError(t, err)
+	rows, err := m.Rows(ctx, db, nil, f)
+	require.NoError(t, err)
+	require.NoError(t, mock.ExpectationsWereMet())
+	require.Equal(t, 1, len(rows))
+	assert.Equal(t, map[string]any{"id": int64(1), "name": "foo", "orders": []map[string]any{{"total": int64(10)}}}, rows[0])
+}
+
+func TestMapper_Fields_KeepsReferencedColumns(t *testing.T) {
+	db, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+	require.NoError(t, err)
+	defer func() {
+		_ = db.Close()
+	}()
+	m, err := newMapper("id,name,created_at,? AS flag", Query(`FROM table`),
+		Mappings{"created_at": {PropertyName: "createdAt"}})
+	require.NoError(t, err)
+
+	mock.ExpectQuery("SELECT name,created_at,? AS flag FROM table ORDER BY created_at DESC").WithArgs(true).
+		WillReturnRows(sqlmock.NewRows([]string{"name", "created_at", "flag"}).AddRow("foo", "2026-01-01", true))
+
+	f, err := ParseFields("name")
+	require.NoError(t, err)
+	rows, err := m.Rows(ctx, db, []any{true}, f, Sort("-createdAt"))
+	require.NoError(t, err)
+	require.NoError(t, mock.ExpectationsWereMet())
+	require.Equal(t, 1, len(rows))
+	assert.Equal(t, map[string]any{"name": "foo"}, rows[0])
+}
+
+func TestMapper_Fields_KeepsRequiredProperties(t *testing.T) {
+	db, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+	require.NoError(t, err)
+	defer func() {
+		_ = db.Close()
+	}()
+	m, err := newMapper("id,name,created_at", Query(`FROM customers`),
+		NewSubQuery("orders", `SELECT total FROM orders WHERE customer_id = ?`, []string{"id"}, nil, false),
+	)
+	require.NoError(t, err)
+
+	// keyset sort property...
+	mock.ExpectQuery("SELECT * FROM (SELECT id,name FROM customers) _keyset ORDER BY id ASC LIMIT 2").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "name"}).AddRow(1, "foo").AddRow(2, "bar"))
+	f, err := ParseFields("name")
+	require.NoError(t, err)
+	keyset := &Keyset{Sort: []string{"id"}, Size: 1}
+	rows, err := m.Rows(ctx, db, nil, keyset, f)
+	require.NoError(t, err)
+	require.NoError(t, mock.ExpectationsWereMet())
+	assert.Equal(t, []map[string]any{{"id": int64(1), "name": "foo"}}, rows)
+	assert.NotEmpty(t, keyset.NextCursor)
+
+	// sub-query arg property...
+	mock.ExpectQuery("SELECT id,name FROM customers").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "name"}).AddRow(1, "foo"))
+	mock.ExpectQuery("SELECT total FROM orders WHERE customer_id = ?").WithArgs(1).
+		WillReturnRows(sqlmock.NewRows([]string{"total"}).AddRow(10))
+	f, err = ParseFields("name,orders")
+	require.NoError(t, err)
+	rows, err = m.Rows(ctx, db, nil, f)
+	require.NoError(t, err)
+	require.NoError(t, mock.ExpectationsWereMet())
+	assert.Equal(t, []map[string]any{{"id": int64(1), "name": "foo", "orders": []map[string]any{{"total": int64(10)}}}}, rows)
+}
+
+func TestMapper_Fields_KeepsNestedSubQueryArgProperties(t *testing.T) {
+	db, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+	require.NoError(t, err)
+	defer func() {
+		_ = db.Close()
+	}()
+	m, err := newMapper("id,name,created_at", Query(`FROM customers`),
+		NewSubQuery("orders", `SELECT id,total,status FROM orders WHERE customer_id = ?`, []string{"id"}, nil, false,
+			NewSubQuery("items", `SELECT sku,qty FROM items WHERE order_id = ?`, []string{"id"}, nil, false)),
+	)
+	require.NoError(t, err)
+
+	mock.ExpectQuery("SELECT id,name FROM customers").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "name"}).AddRow(1, "foo"))
+	mock.ExpectQuery("SELECT id,total FROM orders WHERE customer_id = ?").WithArgs(1).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "total"}).AddRow(100, 10))
+	mock.ExpectQuery("SELECT sku FROM items WHERE order_id = ?").WithArgs(100).
+		WillReturnRows(sqlmock.NewRows([]string{"sku"}).AddRow("X"))
+	f, err := ParseFields("name,orders.total,orders.items.sku")
+	require.NoError(t, err)
+	rows, err := m.Rows(ctx, db, nil, f)
+	require.NoError(t, err)
+	require.NoError(t, mock.ExpectationsWereMet())
+	assert.Equal(t, []map[string]any{{"id": int64(1), "name": "foo", "orders": []map[string]any{
+		{"id": int64(100), "total": int64(10), "items": []map[string]any{{"sku": "X"}}},
+	}}}, rows)
+}
+
+func TestMapper_Fields_KeepsNamedArgProperties(t *testing.T) {
+	db, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+	require.NoError(t, err)
+	defer func() {
+		_ = db.Close()
+	}()
+	m, err := newMapper("id,name,created_at", Query(`FROM customers`),
+		NewSubQuery("orders", `SELECT total FROM orders WHERE customer_id = :id`, nil, nil, false, NamedArgs{}),
+	)
+	require.NoError(t, err)
+
+	mock.ExpectQuery("SELECT id,name FROM customers").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "name"}).AddRow(1, "foo"))
+	mock.ExpectQuery("SELECT total FROM orders WHERE customer_id = ?").WithArgs(1).
+		WillReturnRows(sqlmock.NewRows([]string{"total"}).AddRow(10))
+	f, err := ParseFields("name,orders")
+	require.NoError(t, err)
+	rows, err := m.Rows(ctx, db, nil, f)
+	require.NoError(t, err)
+	require.NoError(t, mock.ExpectationsWereMet())
+	assert.Equal(t, []map[string]any{{"id": int64(1), "name": "foo", "orders": []map[string]any{{"total": int64(10)}}}}, rows)
+}
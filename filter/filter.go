@@ -0,0 +1,376 @@
+// Package filter - parses filter expressions (e.g. `status eq 'active' and age gt 30`) into parameterised sql clauses for columbus
+package filter
+
+import (
+	"fmt"
+	"github.com/go-andiamo/columbus"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// Filter is a parsed filter expression
+//
+// use Parse to create a Filter
+//
+// the grammar is:
+//
+//	expr       = and-expr { "or" and-expr }
+//	and-expr   = not-expr { "and" not-expr }
+//	not-expr   = "not" not-expr | "(" expr ")" | comparison
+//	comparison = property ( "eq" | "ne" | "gt" | "ge" | "lt" | "le" ) value
+//	           | property "in" "(" value { "," value } ")"
+//	           | property "like" string
+//	           | property "is" [ "not" ] "null"
+//	value      = string | number | "true" | "false"
+//
+// where strings are single quoted (a single quote within a string is escaped by doubling it) and property names
+// are the public property names - nested properties are specified using dot notation (e.g. `address.city`)
+//
+// keywords are case-insensitive
+type Filter struct {
+	root node
+}
+
+// Type is the type of filterable property - used to check the type of values in the filter expression
+type Type int
+
+const (
+	// Any accepts any value
+	Any Type = iota
+	// String accepts string values
+	String
+	// Number accepts number values
+	Number
+	// Bool accepts true or false
+	Bool
+	// Time accepts string values in RFC3339 format (or date only format - e.g. '2025-01-31') - the value is converted to time.Time
+	Time
+)
+
+func (t Type) String() string {
+	switch t {
+	case String:
+		return "string"
+	case Number:
+		return "number"
+	case Bool:
+		return "bool"
+	case Time:
+		return "time"
+	}
+	return "any"
+}
+
+// Property is the definition of a filterable property
+type Property struct {
+	// Column is the column expression for the property - if empty, the column is resolved from the Resolver Mappings
+	// (or, if there is no mapping for the property, the property name is used as the column)
+	Column string
+	// Type is the type of the property
+	Type Type
+}
+
+// Properties is a map of filterable properties by property name
+type Properties map[string]Property
+
+// Resolver resolves property names in a Filter to columns
+type Resolver struct {
+	// Properties is the allow-list of filterable properties
+	//
+	// if nil, any property in the Mappings can be filtered (with type Any)
+	Properties Properties
+	// Mappings is the mapper's Mappings - used to resolve property names to columns (using the Mapping PropertyName and Path)
+	//
+	// Note: filter conditions are used in a WHERE clause - so, for columns that are aliased in the select, the Property Column should be used
+	Mappings columbus.Mappings
+	// Dialect is the optional columbus.Dialect used to quote column names
+	Dialect columbus.Dialect
+}
+
+var identifierRegex = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+func (r *Resolver) resolve(property string) (column string, typ Type, err error) {
+	quote := func(name string) string {
+		if r.Dialect != nil {
+			return r.Dialect.QuoteIdentifier(name)
+		}
+		return name
+	}
+	if r.Properties != nil {
+		p, ok := r.Properties[property]
+		if !ok {
+			return "", Any, &UnknownPropertyError{Property: property}
+		} else if p.Column != "" {
+			return p.Column, p.Type, nil
+		}
+		typ = p.Type
+	}
+	if col, ok := r.Mappings.Column(property); ok {
+		return quote(col), typ, nil
+	} else if r.Properties != nil && identifierRegex.MatchString(property) {
+		return quote(property), typ, nil
+	}
+	return "", Any, &UnknownPropertyError{Property: property}
+}
+
+// Parse parses a filter expression
+//
+// an empty (or whitespace only) expression results in a Filter with no condition
+func Parse(expr string) (*Filter, error) {
+	return parse(expr, false)
+}
+
+// ParseOData parses an OData style filter expression (e.g. the value of a `$filter` query parameter)
+//
+// the grammar is the same as for Parse, with the following additions:
+//
+//	comparison = ...
+//	           | property ( "eq" | "ne" ) "null"
+//	           | ( "contains" | "startswith" | "endswith" ) "(" property "," string ")"
+//
+// and nested property paths may be specified using '/' (e.g. `address/city`)
+func ParseOData(expr string) (*Filter, error) {
+	return parse(expr, true)
+}
+
+func parse(expr string, odata bool) (*Filter, error) {
+	p := &parser{lexer: &lexer{input: expr, odata: odata}, odata: odata}
+	if err := p.next(); err != nil {
+		return nil, err
+	}
+	if p.tok.kind == tokenEOF {
+		return &Filter{}, nil
+	}
+	root, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	} else if p.tok.kind != tokenEOF {
+		return nil, p.syntaxError("unexpected '%s'", p.tok.text)
+	}
+	return &Filter{root: root}, nil
+}
+
+// Condition builds the parameterised sql condition (and args) for the filter
+//
+// returns an empty condition if the filter has no condition
+func (f *Filter) Condition(resolver Resolver) (string, []any, error) {
+	if f.root == nil {
+		return "", nil, nil
+	}
+	b := &builder{resolver: &resolver}
+	if err := f.root.build(b); err != nil {
+		return "", nil, err
+	}
+	return b.sb.String(), b.args, nil
+}
+
+// Where builds the filter as a `WHERE` clause that can be passed as an option to Mapper.Rows, StructMapper.Rows etc. (or to sub-queries)
+//
+// if the filter has no condition, the clause is empty
+func (f *Filter) Where(resolver Resolver) (columbus.AddClauseWithArgs, error) {
+	return f.clause("WHERE ", resolver)
+}
+
+// And builds the filter as an `AND` clause (i.e. for when the query already has a WHERE) that can be passed as an option
+// to Mapper.Rows, StructMapper.Rows etc. (or to sub-queries)
+//
+// if the filter has no condition, the clause is empty
+func (f *Filter) And(resolver Resolver) (columbus.AddClauseWithArgs, error) {
+	return f.clause("AND ", resolver)
+}
+
+func (f *Filter) clause(prefix string, resolver Resolver) (columbus.AddClauseWithArgs, error) {
+	cond, args, err := f.Condition(resolver)
+	if err != nil || cond == "" {
+		return columbus.AddClauseWithArgs{}, err
+	}
+	return columbus.AddClauseWithArgs{Clause: prefix + cond, Args: args}, nil
+}
+
+type builder struct {
+	resolver *Resolver
+	sb       strings.Builder
+	args     []any
+}
+
+type node interface {
+	build(b *builder) error
+}
+
+type logicalNode struct {
+	op          string
+	left, right node
+}
+
+func (n *logicalNode) build(b *builder) (err error) {
+	b.sb.WriteString("(")
+	if err = n.left.build(b); err == nil {
+		b.sb.WriteString(" " + n.op + " ")
+		if err = n.right.build(b); err == nil {
+			b.sb.WriteString(")")
+		}
+	}
+	return err
+}
+
+type notNode struct {
+	expr node
+}
+
+func (n *notNode) build(b *builder) (err error) {
+	b.sb.WriteString("NOT (")
+	if err = n.expr.build(b); err == nil {
+		b.sb.WriteString(")")
+	}
+	return err
+}
+
+var comparisonOperators = map[string]string{
+	"eq": "=",
+	"ne": "<>",
+	"gt": ">",
+	"ge": ">=",
+	"lt": "<",
+	"le": "<=",
+}
+
+type comparisonNode struct {
+	property string
+	op       string
+	values   []literal
+}
+
+func (n *comparisonNode) build(b *builder) error {
+	col, typ, err := b.resolver.resolve(n.property)
+	if err != nil {
+		return err
+	}
+	args := make([]any, len(n.values))
+	for i, v := range n.values {
+		if args[i], err = v.value(n.property, typ, n.op); err != nil {
+			return err
+		}
+	}
+	b.sb.WriteString(col)
+	switch n.op {
+	case "in":
+		b.sb.WriteString(" IN (" + strings.TrimSuffix(strings.Repeat("?,", len(args)), ",") + ")")
+	case "like":
+		b.sb.WriteString(" LIKE ?")
+	case "contains", "startswith", "endswith":
+		b.sb.WriteString(" LIKE ? ESCAPE '" + likeEscape + "'")
+		args[0] = likeFunctions[n.op](likeEscaper.Replace(args[0].(string)))
+	default:
+		b.sb.WriteString(" " + comparisonOperators[n.op] + " ?")
+	}
+	b.args = append(b.args, args...)
+	return nil
+}
+
+// likeEscape is the escape character used for LIKE patterns built from function values
+//
+// Note: '!' is used (rather than backslash) as it requires no escaping in sql string literals for any database
+const likeEscape = "!"
+
+var likeEscaper = strings.NewReplacer(likeEscape, likeEscape+likeEscape, "%", likeEscape+"%", "_", likeEscape+"_")
+
+// likeFunctions is the OData functions that are translated to LIKE patterns
+var likeFunctions = map[string]func(v string) string{
+	"contains":   func(v string) string { return "%" + v + "%" },
+	"startswith": func(v string) string { return v + "%" },
+	"endswith":   func(v string) string { return "%" + v },
+}
+
+type nullNode struct {
+	property string
+	not      bool
+}
+
+func (n *nullNode) build(b *builder) error {
+	col, _, err := b.resolver.resolve(n.property)
+	if err != nil {
+		return err
+	}
+	if n.not {
+		b.sb.WriteString(col + " IS NOT NULL")
+	} else {
+		b.sb.WriteString(col + " IS NULL")
+	}
+	return nil
+}
+
+type literalKind int
+
+const (
+	stringLiteral literalKind = iota
+	numberLiteral
+	boolLiteral
+)
+
+type literal struct {
+	kind literalKind
+	text string
+	v    any
+}
+
+// value checks the literal is compatible with the property type (and operator) - returning the arg value
+func (l literal) value(property string, typ Type, op string) (any, error) {
+	ok := false
+	_, fn := likeFunctions[op]
+	like := fn || op == "like"
+	switch typ {
+	case Any:
+		ok = !like || l.kind == stringLiteral
+	case String:
+		ok = l.kind == stringLiteral
+	case Number:
+		ok = l.kind == numberLiteral && !like
+	case Bool:
+		ok = l.kind == boolLiteral && (op == "eq" || op == "ne" || op == "in")
+	case Time:
+		if l.kind == stringLiteral && !like {
+			if t, err := time.Parse(time.RFC3339Nano, l.v.(string)); err == nil {
+				return t, nil
+			} else if t, err = time.Parse(time.DateOnly, l.v.(string)); err == nil {
+				return t, nil
+			}
+		}
+	}
+	if !ok {
+		return nil, &TypeMismatchError{Property: property, Type: typ, Operator: op, Value: l.text}
+	}
+	return l.v, nil
+}
+
+// SyntaxError is the error returned by Parse when the filter expression is invalid
+type SyntaxError struct {
+	// Position is the position (byte offset) in the expression at which the error occurred
+	Position int
+	Message  string
+}
+
+func (e *SyntaxError) Error() string {
+	return fmt.Sprintf("filter syntax error at position %d: %s", e.Position, e.Message)
+}
+
+// UnknownPropertyError is the error returned when a filter expression uses a property that cannot be filtered
+type UnknownPropertyError struct {
+	Property string
+}
+
+func (e *UnknownPropertyError) Error() string {
+	return fmt.Sprintf("unknown filter property '%s'", e.Property)
+}
+
+// TypeMismatchError is the error returned when a value in a filter expression is not compatible with the property type
+type TypeMismatchError struct {
+	Property string
+	Type     Type
+	Operator string
+	Value    string
+}
+
+func (e *TypeMismatchError) Error() string {
+	return fmt.Sprintf("filter property '%s' (%s) cannot be compared using '%s' with value %s", e.Property, e.Type, e.Operator, e.Value)
+}
@@ -0,0 +1,216 @@
+package filter
+
+import (
+	"context"
+	"errors"
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/go-andiamo/columbus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"regexp"
+	"testing"
+	"time"
+)
+
+func TestFilter_Condition(t *testing.T) {
+	mappings := columbus.Mappings{
+		"last_name": {PropertyName: "lastName"},
+		"city":      {Path: []string{"address"}},
+	}
+	testCases := []struct {
+		expr       string
+		resolver   Resolver
+		expect     string
+		expectArgs []any
+		expectErr  string
+	}{
+		{
+			expr:       "lastName eq 'Smith' and address.city ne 'London'",
+			resolver:   Resolver{Mappings: mappings},
+			expect:     "(last_name = ? AND city <> ?)",
+			expectArgs: []any{"Smith", "London"},
+		},
+		{
+			expr:       "lastName like 'S%'",
+			resolver:   Resolver{Mappings: mappings, Dialect: columbus.Postgres},
+			expect:     `"last_name" LIKE ?`,
+			expectArgs: []any{"S%"},
+		},
+		{
+			expr:      "last_name eq 'Smith'",
+			resolver:  Resolver{Mappings: mappings},
+			expectErr: "unknown filter property 'last_name'",
+		},
+		{
+			expr: "age ge 18 and name eq 'x' and active eq true",
+			resolver: Resolver{Properties: Properties{
+				"age":    {Type: Number},
+				"name":   {Column: "LOWER(name)", Type: String},
+				"active": {Type: Bool},
+			}},
+			expect:     "((age >= ? AND LOWER(name) = ?) AND active = ?)",
+			expectArgs: []any{int64(18), "x", true},
+		},
+		{
+			expr:       "lastName is not null",
+			resolver:   Resolver{Properties: Properties{"lastName": {}}, Mappings: mappings},
+			expect:     "last_name IS NOT NULL",
+			expectArgs: nil,
+		},
+		{
+			expr:      "address.city eq 'London'",
+			resolver:  Resolver{Properties: Properties{"lastName": {}}, Mappings: mappings},
+			expectErr: "unknown filter property 'address.city'",
+		},
+		{
+			expr:      "foo.bar eq 1",
+			resolver:  Resolver{Properties: Properties{"foo.bar": {}}},
+			expectErr: "unknown filter property 'foo.bar'",
+		},
+		{
+			expr:      "age eq '18'",
+			resolver:  Resolver{Properties: Properties{"age": {Type: Number}}},
+			expectErr: "filter property 'age' (number) cannot be compared using 'eq' with value '18'",
+		},
+		{
+			expr:      "age like '1%'",
+			resolver:  Resolver{Properties: Properties{"age": {Type: Number}}},
+			expectErr: "filter property 'age' (number) cannot be compared using 'like' with value '1%'",
+		},
+		{
+			expr:      "active gt false",
+			resolver:  Resolver{Properties: Properties{"active": {Type: Bool}}},
+			expectErr: "filter property 'active' (bool) cannot be compared using 'gt' with value false",
+		},
+		{
+			expr:      "name in ('a', 1)",
+			resolver:  Resolver{Properties: Properties{"name": {Type: String}}},
+			expectErr: "filter property 'name' (string) cannot be compared using 'in' with value 1",
+		},
+		{
+			expr:      "name like 1",
+			resolver:  Resolver{Properties: Properties{"name": {}}},
+			expectErr: "filter property 'name' (any) cannot be compared using 'like' with value 1",
+		},
+		{
+			expr:      "created lt 'yesterday'",
+			resolver:  Resolver{Properties: Properties{"created": {Type: Time}}},
+			expectErr: "filter property 'created' (time) cannot be compared using 'lt' with value 'yesterday'",
+		},
+		{
+			expr:      "unknown is null",
+			resolver:  Resolver{Properties: Properties{}},
+			expectErr: "unknown filter property 'unknown'",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.expr, func(t *testing.T) {
+			f, err := Parse(tc.expr)
+			require.NoError(t, err)
+			cond, args, err := f.Condition(tc.resolver)
+			if tc.expectErr != "" {
+				require.Error(t, err)
+				assert.Equal(t, tc.expectErr, err.Error())
+			} else {
+				require.NoError(t, err)
+				assert.Equal(t, tc.expect, cond)
+				assert.Equal(t, tc.expectArgs, args)
+			}
+		})
+	}
+}
+
+func TestFilter_Condition_ErrorTypes(t *testing.T) {
+	f, err := Parse("age eq 'x'")
+	require.NoError(t, err)
+	_, _, err = f.Condition(Resolver{Properties: Properties{"age": {Type: Number}}})
+	var tmErr *TypeMismatchError
+	require.True(t, errors.As(err, &tmErr))
+	assert.Equal(t, "age", tmErr.Property)
+	assert.Equal(t, Number, tmErr.Type)
+	assert.Equal(t, "eq", tmErr.Operator)
+	assert.Equal(t, "'x'", tmErr.Value)
+
+	_, _, err = f.Condition(Resolver{Properties: Properties{}})
+	var upErr *UnknownPropertyError
+	require.True(t, errors.As(err, &upErr))
+	assert.Equal(t, "age", upErr.Property)
+}
+
+func TestFilter_Condition_Time(t *testing.T) {
+	f, err := Parse("created ge '2025-01-31' and created lt '2025-02-01T10:30:00Z'")
+	require.NoError(t, err)
+	_, args, err := f.Condition(Resolver{Properties: Properties{"created": {Type: Time}}})
+	require.NoError(t, err)
+	assert.Equal(t, []any{
+		time.Date(2025, 1, 31, 0, 0, 0, 0, time.UTC),
+		time.Date(2025, 2, 1, 10, 30, 0, 0, time.UTC),
+	}, args)
+}
+
+func TestType_String(t *testing.T) {
+	assert.Equal(t, "any", Any.String())
+	assert.Equal(t, "string", String.String())
+	assert.Equal(t, "number", Number.String())
+	assert.Equal(t, "bool", Bool.String())
+	assert.Equal(t, "time", Time.String())
+}
+
+func TestFilter_WhereAnd(t *testing.T) {
+	resolver := Resolver{Properties: Properties{"status": {Type: String}}}
+	f, err := Parse("status eq 'active'")
+	require.NoError(t, err)
+	w, err := f.Where(resolver)
+	require.NoError(t, err)
+	assert.Equal(t, columbus.AddClauseWithArgs{Clause: "WHERE status = ?", Args: []any{"active"}}, w)
+	a, err := f.And(resolver)
+	require.NoError(t, err)
+	assert.Equal(t, columbus.AddClauseWithArgs{Clause: "AND status = ?", Args: []any{"active"}}, a)
+
+	f, err = Parse("")
+	require.NoError(t, err)
+	w, err = f.Where(resolver)
+	require.NoError(t, err)
+	assert.Equal(t, columbus.AddClauseWithArgs{}, w)
+
+	f, err = Parse("other eq 1")
+	require.NoError(t, err)
+	_, err = f.And(resolver)
+	require.Error(t, err)
+}
+
+func TestFilter_Mapper(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer func() {
+		_ = db.Close()
+	}()
+	m, err := columbus.NewMapper("id,status,age", columbus.Query("FROM people WHERE tenant_id = ?"))
+	require.NoError(t, err)
+	f, err := Parse("status in ('a','b') and age gt 21")
+	require.NoError(t, err)
+	clause, err := f.And(Resolver{Properties: Properties{"status": {Type: String}, "age": {Type: Number}}})
+	require.NoError(t, err)
+
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT id,status,age FROM people WHERE tenant_id = ? AND (status IN (?,?) AND age > ?) ORDER BY id ASC")).
+		WithArgs(1, "a", "b", int64(21)).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "status", "age"}).AddRow(1, "a", 30))
+	rows, err := m.Rows(context.Background(), db, []any{1}, clause, columbus.Sort("id"), columbus.SortableProperties{"id": ""})
+	require.NoError(t, err)
+	assert.Len(t, rows, 1)
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestFilter_Condition_Functions(t *testing.T) {
+	f, err := ParseOData("contains(age,'1')")
+	require.NoError(t, err)
+	_, _, err = f.Condition(Resolver{Properties: Properties{"age": {Type: Number}}})
+	require.Error(t, err)
+	assert.Equal(t, "filter property 'age' (number) cannot be compared using 'contains' with value '1'", err.Error())
+
+	f, err = ParseOData("startswith(name,1)")
+	require.NoError(t, err)
+	_, _, err = f.Condition(Resolver{Properties: Properties{"name": {}}})
+	require.Error(t, err)
+	assert.Equal(t, "filter property 'name' (any) cannot be compared using 'startswith' with value 1", err.Error())
+}
@@ -0,0 +1,287 @@
+package filter
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+type tokenKind int
+
+const (
+	tokenEOF tokenKind = iota
+	tokenIdent
+	tokenString
+	tokenNumber
+	tokenOpen
+	tokenClose
+	tokenComma
+)
+
+type token struct {
+	kind tokenKind
+	text string
+	pos  int
+}
+
+type lexer struct {
+	input string
+	pos   int
+	// odata indicates that '/' is a property path separator
+	odata bool
+}
+
+func (l *lexer) next() (token, error) {
+	for l.pos < len(l.input) && (l.input[l.pos] == ' ' || l.input[l.pos] == '\t' || l.input[l.pos] == '\r' || l.input[l.pos] == '\n') {
+		l.pos++
+	}
+	start := l.pos
+	if l.pos >= len(l.input) {
+		return token{kind: tokenEOF, pos: start}, nil
+	}
+	switch c := l.input[l.pos]; {
+	case c == '(':
+		l.pos++
+		return token{kind: tokenOpen, text: "(", pos: start}, nil
+	case c == ')':
+		l.pos++
+		return token{kind: tokenClose, text: ")", pos: start}, nil
+	case c == ',':
+		l.pos++
+		return token{kind: tokenComma, text: ",", pos: start}, nil
+	case c == '\'':
+		var sb strings.Builder
+		for l.pos++; l.pos < len(l.input); l.pos++ {
+			if l.input[l.pos] == '\'' {
+				if l.pos+1 < len(l.input) && l.input[l.pos+1] == '\'' {
+					sb.WriteByte('\'')
+					l.pos++
+				} else {
+					l.pos++
+					return token{kind: tokenString, text: sb.String(), pos: start}, nil
+				}
+			} else {
+				sb.WriteByte(l.input[l.pos])
+			}
+		}
+		return token{}, &SyntaxError{Position: start, Message: "unterminated string"}
+	case c == '-' || c == '+' || (c >= '0' && c <= '9'):
+		for l.pos++; l.pos < len(l.input) && isNumberChar(l.input[l.pos], l.input[l.pos-1]); l.pos++ {
+		}
+		return token{kind: tokenNumber, text: l.input[start:l.pos], pos: start}, nil
+	case isIdentChar(c) && !(c >= '0' && c <= '9') && c != '.':
+		for l.pos++; l.pos < len(l.input) && (isIdentChar(l.input[l.pos]) || (l.odata && l.input[l.pos] == '/')); l.pos++ {
+		}
+		return token{kind: tokenIdent, text: strings.ReplaceAll(l.input[start:l.pos], "/", "."), pos: start}, nil
+	}
+	return token{}, &SyntaxError{Position: start, Message: fmt.Sprintf("unexpected character '%c'", l.input[start])}
+}
+
+func isIdentChar(c byte) bool {
+	return c == '_' || c == '.' || (c >= 'A' && c <= 'Z') || (c >= 'a' && c <= 'z') || (c >= '0' && c <= '9')
+}
+
+func isNumberChar(c byte, prev byte) bool {
+	return (c >= '0' && c <= '9') || c == '.' || c == 'e' || c == 'E' || ((c == '-' || c == '+') && (prev == 'e' || prev == 'E'))
+}
+
+type parser struct {
+	lexer *lexer
+	tok   token
+	// odata indicates OData syntax - i.e. comparison with null and functions
+	odata bool
+}
+
+func (p *parser) next() (err error) {
+	p.tok, err = p.lexer.next()
+	return err
+}
+
+func (p *parser) syntaxError(format string, a ...any) error {
+	return &SyntaxError{Position: p.tok.pos, Message: fmt.Sprintf(format, a...)}
+}
+
+// isKeyword checks whether the current token is the keyword
+func (p *parser) isKeyword(keyword string) bool {
+	return p.tok.kind == tokenIdent && strings.EqualFold(p.tok.text, keyword)
+}
+
+func (p *parser) parseOr() (node, error) {
+	left, err := p.parseAnd()
+	for err == nil && p.isKeyword("or") {
+		var right node
+		if err = p.next(); err == nil {
+			if right, err = p.parseAnd(); err == nil {
+				left = &logicalNode{op: "OR", left: left, right: right}
+			}
+		}
+	}
+	return left, err
+}
+
+func (p *parser) parseAnd() (node, error) {
+	left, err := p.parseNot()
+	for err == nil && p.isKeyword("and") {
+		var right node
+		if err = p.next(); err == nil {
+			if right, err = p.parseNot(); err == nil {
+				left = &logicalNode{op: "AND", left: left, right: right}
+			}
+		}
+	}
+	return left, err
+}
+
+func (p *parser) parseNot() (node, error) {
+	if p.isKeyword("not") {
+		if err := p.next(); err != nil {
+			return nil, err
+		}
+		expr, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		return &notNode{expr: expr}, nil
+	} else if p.tok.kind == tokenOpen {
+		if err := p.next(); err != nil {
+			return nil, err
+		}
+		expr, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		} else if p.tok.kind != tokenClose {
+			return nil, p.syntaxError("expected ')'")
+		}
+		return expr, p.next()
+	}
+	return p.parseComparison()
+}
+
+func (p *parser) parseComparison() (node, error) {
+	if p.tok.kind != tokenIdent || isReservedWord(p.tok.text) {
+		return nil, p.syntaxError("expected property name")
+	}
+	property, propertyPos := p.tok.text, p.tok.pos
+	if err := p.next(); err != nil {
+		return nil, err
+	} else if p.odata && p.tok.kind == tokenOpen {
+		return p.parseFunction(property, propertyPos)
+	} else if p.tok.kind != tokenIdent {
+		return nil, p.syntaxError("expected operator")
+	}
+	op, opPos := strings.ToLower(p.tok.text), p.tok.pos
+	if err := p.next(); err != nil {
+		return nil, err
+	}
+	switch op {
+	case "eq", "ne":
+		if p.odata && p.isKeyword("null") {
+			return &nullNode{property: property, not: op == "ne"}, p.next()
+		}
+		fallthrough
+	case "gt", "ge", "lt", "le", "like":
+		v, err := p.parseValue()
+		if err != nil {
+			return nil, err
+		}
+		return &comparisonNode{property: property, op: op, values: []literal{v}}, nil
+	case "in":
+		if p.tok.kind != tokenOpen {
+			return nil, p.syntaxError("expected '('")
+		}
+		values := make([]literal, 0)
+		for {
+			if err := p.next(); err != nil {
+				return nil, err
+			}
+			v, err := p.parseValue()
+			if err != nil {
+				return nil, err
+			}
+			values = append(values, v)
+			if p.tok.kind == tokenClose {
+				break
+			} else if p.tok.kind != tokenComma {
+				return nil, p.syntaxError("expected ',' or ')'")
+			}
+		}
+		return &comparisonNode{property: property, op: op, values: values}, p.next()
+	case "is":
+		not := false
+		if p.isKeyword("not") {
+			not = true
+			if err := p.next(); err != nil {
+				return nil, err
+			}
+		}
+		if !p.isKeyword("null") {
+			return nil, p.syntaxError("expected 'null'")
+		}
+		return &nullNode{property: property, not: not}, p.next()
+	}
+	return nil, &SyntaxError{Position: opPos, Message: fmt.Sprintf("unknown operator '%s'", op)}
+}
+
+// parseFunction parses an OData function call - e.g. `contains(name,'x')` (the current token is the opening parenthesis)
+func (p *parser) parseFunction(name string, namePos int) (node, error) {
+	fn := strings.ToLower(name)
+	if _, ok := likeFunctions[fn]; !ok {
+		return nil, &SyntaxError{Position: namePos, Message: fmt.Sprintf("unknown function '%s'", name)}
+	}
+	if err := p.next(); err != nil {
+		return nil, err
+	} else if p.tok.kind != tokenIdent || isReservedWord(p.tok.text) {
+		return nil, p.syntaxError("expected property name")
+	}
+	property := p.tok.text
+	if err := p.next(); err != nil {
+		return nil, err
+	} else if p.tok.kind != tokenComma {
+		return nil, p.syntaxError("expected ','")
+	} else if err = p.next(); err != nil {
+		return nil, err
+	}
+	v, err := p.parseValue()
+	if err != nil {
+		return nil, err
+	} else if p.tok.kind != tokenClose {
+		return nil, p.syntaxError("expected ')'")
+	}
+	return &comparisonNode{property: property, op: fn, values: []literal{v}}, p.next()
+}
+
+// parseValue parses a literal value (and advances to the next token)
+func (p *parser) parseValue() (literal, error) {
+	result := literal{text: p.tok.text}
+	switch {
+	case p.tok.kind == tokenString:
+		result.kind = stringLiteral
+		result.v = p.tok.text
+		result.text = "'" + strings.ReplaceAll(p.tok.text, "'", "''") + "'"
+	case p.tok.kind == tokenNumber:
+		result.kind = numberLiteral
+		if i, err := strconv.ParseInt(p.tok.text, 10, 64); err == nil {
+			result.v = i
+		} else if f, err := strconv.ParseFloat(p.tok.text, 64); err == nil {
+			result.v = f
+		} else {
+			return result, p.syntaxError("invalid number '%s'", p.tok.text)
+		}
+	case p.isKeyword("true") || p.isKeyword("false"):
+		result.kind = boolLiteral
+		result.v = strings.EqualFold(p.tok.text, "true")
+	case p.isKeyword("null"):
+		return result, p.syntaxError("null cannot be compared - use 'is null' or 'is not null'")
+	default:
+		return result, p.syntaxError("expected value")
+	}
+	return result, p.next()
+}
+
+func isReservedWord(s string) bool {
+	switch strings.ToLower(s) {
+	case "and", "or", "not", "true", "false", "null":
+		return true
+	}
+	return false
+}
@@ -0,0 +1,116 @@
+package filter
+
+import (
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	testCases := []struct {
+		expr      string
+		expect    string
+		expectErr string
+	}{
+		{expr: "", expect: ""},
+		{expr: "   ", expect: ""},
+		{expr: "status eq 'active'", expect: "status = ?"},
+		{expr: "status EQ 'active' And age gt 30", expect: "(status = ? AND age > ?)"},
+		{expr: "a eq 1 or b ne 2 and c ge 3", expect: "(a = ? OR (b <> ? AND c >= ?))"},
+		{expr: "(a eq 1 or b lt 2) and c le 3", expect: "((a = ? OR b < ?) AND c <= ?)"},
+		{expr: "not a eq 1", expect: "NOT (a = ?)"},
+		{expr: "not (a eq 1 or a eq 2)", expect: "NOT ((a = ? OR a = ?))"},
+		{expr: "a in (1, 2,3)", expect: "a IN (?,?,?)"},
+		{expr: "a like 'x%'", expect: "a LIKE ?"},
+		{expr: "a is null and b is NOT null", expect: "(a IS NULL AND b IS NOT NULL)"},
+		{expr: "address.city eq 'x'", expect: "address.city = ?"},
+		{expr: "status eq", expectErr: "filter syntax error at position 9: expected value"},
+		{expr: "status", expectErr: "filter syntax error at position 6: expected operator"},
+		{expr: "status foo 1", expectErr: "filter syntax error at position 7: unknown operator 'foo'"},
+		{expr: "status eq 'active", expectErr: "filter syntax error at position 10: unterminated string"},
+		{expr: "status eq null", expectErr: "filter syntax error at position 10: null cannot be compared - use 'is null' or 'is not null'"},
+		{expr: "status is 1", expectErr: "filter syntax error at position 10: expected 'null'"},
+		{expr: "status in 1", expectErr: "filter syntax error at position 10: expected '('"},
+		{expr: "status in (1 2)", expectErr: "filter syntax error at position 13: expected ',' or ')'"},
+		{expr: "(status eq 1", expectErr: "filter syntax error at position 12: expected ')'"},
+		{expr: "status eq 1 )", expectErr: "filter syntax error at position 12: unexpected ')'"},
+		{expr: "and eq 1", expectErr: "filter syntax error at position 0: expected property name"},
+		{expr: "status eq 1 ; drop", expectErr: "filter syntax error at position 12: unexpected character ';'"},
+		{expr: "status eq 1.2.3", expectErr: "filter syntax error at position 10: invalid number '1.2.3'"},
+		{expr: "status eq 1 and", expectErr: "filter syntax error at position 15: expected property name"},
+		{expr: "status eq 1 or", expectErr: "filter syntax error at position 14: expected property name"},
+		{expr: "not", expectErr: "filter syntax error at position 3: expected property name"},
+		{expr: "status in (1,", expectErr: "filter syntax error at position 13: expected value"},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.expr, func(t *testing.T) {
+			f, err := Parse(tc.expr)
+			if tc.expectErr != "" {
+				require.Error(t, err)
+				assert.Equal(t, tc.expectErr, err.Error())
+				var sErr *SyntaxError
+				assert.True(t, errors.As(err, &sErr))
+			} else {
+				require.NoError(t, err)
+				cond, _, err := f.Condition(Resolver{Properties: Properties{
+					"status": {}, "age": {}, "a": {}, "b": {}, "c": {}, "address.city": {Column: "address.city"},
+				}})
+				require.NoError(t, err)
+				assert.Equal(t, tc.expect, cond)
+			}
+		})
+	}
+}
+
+func TestParse_Values(t *testing.T) {
+	f, err := Parse("a in ('it''s', -1, 1.5, 2e3, TRUE, false)")
+	require.NoError(t, err)
+	_, args, err := f.Condition(Resolver{Properties: Properties{"a": {}}})
+	require.NoError(t, err)
+	assert.Equal(t, []any{"it's", int64(-1), 1.5, 2000.0, true, false}, args)
+}
+
+func TestParseOData(t *testing.T) {
+	testCases := []struct {
+		expr       string
+		expect     string
+		expectArgs []any
+		expectErr  string
+	}{
+		{expr: "address/city eq 'London'", expect: "address.city = ?", expectArgs: []any{"London"}},
+		{expr: "name eq null or name ne null", expect: "(name IS NULL OR name IS NOT NULL)"},
+		{expr: "contains(name,'a%b_c!')", expect: "name LIKE ? ESCAPE '!'", expectArgs: []any{"%a!%b!_c!!%"}},
+		{expr: "StartsWith(name, 'x') and endswith(address/city,'y')", expect: "(name LIKE ? ESCAPE '!' AND address.city LIKE ? ESCAPE '!')", expectArgs: []any{"x%", "%y"}},
+		{expr: "not contains(name,'x')", expect: "NOT (name LIKE ? ESCAPE '!')", expectArgs: []any{"%x%"}},
+		{expr: "name gt null", expectErr: "filter syntax error at position 8: null cannot be compared - use 'is null' or 'is not null'"},
+		{expr: "length(name)", expectErr: "filter syntax error at position 0: unknown function 'length'"},
+		{expr: "contains('x',name)", expectErr: "filter syntax error at position 9: expected property name"},
+		{expr: "contains(name 'x')", expectErr: "filter syntax error at position 14: expected ','"},
+		{expr: "contains(name,'x'", expectErr: "filter syntax error at position 17: expected ')'"},
+		{expr: "contains(name,)", expectErr: "filter syntax error at position 14: expected value"},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.expr, func(t *testing.T) {
+			f, err := ParseOData(tc.expr)
+			if tc.expectErr != "" {
+				require.Error(t, err)
+				assert.Equal(t, tc.expectErr, err.Error())
+			} else {
+				require.NoError(t, err)
+				cond, args, err := f.Condition(Resolver{Properties: Properties{"name": {}, "address.city": {Column: "address.city"}}})
+				require.NoError(t, err)
+				assert.Equal(t, tc.expect, cond)
+				assert.Equal(t, tc.expectArgs, args)
+			}
+		})
+	}
+
+	// OData additions are not available in Parse...
+	_, err := Parse("name eq null")
+	require.Error(t, err)
+	_, err = Parse("contains(name,'x')")
+	require.Error(t, err)
+	_, err = Parse("address/city eq 'x'")
+	require.Error(t, err)
+}
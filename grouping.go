@@ -0,0 +1,466 @@
+package columbus
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Grouping is an option that can be passed to NewMapper (or any of the Mapper row reading methods) or NewStructMapper
+// and collapses flat rows (e.g. from a query that JOINs parent and child tables) into nested parents - where the
+// columns of each ColumnGroup are collected into an array of child rows
+//
+// rows with the same parent key are merged into a single parent - if Keyed is false, only consecutive rows are
+// merged (so the query should be ordered by the parent key) and each parent is completed as soon as a row with a
+// different parent key is read; if Keyed is true, rows are merged regardless of order but no parent is completed
+// until all rows have been read
+//
+// child rows are always merged by their own key within their parent - so multiple groups at the same level (which
+// result in the JOIN rows being a cross product) do not produce duplicate child rows
+//
+// any Limiter applies to the grouped parent rows (rather than the database rows) - when Keyed, all rows are still read
+// but rows for parents beyond the limit are ignored. RowLimit, Keyset and paging limit the database rows (so could
+// split the rows of a parent) and therefore cannot be used with Grouping
+type Grouping struct {
+	// Key is the column(s) that identify the parent rows
+	Key []string
+	// Groups is the child column groups
+	Groups []ColumnGroup
+	// Keyed determines whether rows are merged by parent key regardless of order (rather than only consecutive rows)
+	Keyed bool
+}
+
+// ColumnGroup is a group of columns that are collected into an array of child rows (see Grouping)
+type ColumnGroup struct {
+	// Property is the property name (for Mapper) or the field name (for StructMapper) of the child rows array
+	Property string
+	// Key is the column(s) that identify the child rows - key columns are columns of the group (unless they are also key
+	// columns of the parent)
+	//
+	// if any of the key columns are null (e.g. there was no matching row for a LEFT JOIN), no child row is added
+	Key []string
+	// Columns is the columns of the child rows (for Mapper only)
+	Columns []string
+	// Prefix determines that columns whose names start with the prefix are columns of the group
+	//
+	// for Mapper, the property name of a prefixed column is the column name without the prefix (unless there is a
+	// Mapping with a PropertyName for the column) - for StructMapper, the columns are the columns mapped by the fields
+	// of the child struct, prefixed with Prefix
+	Prefix string
+	// Table is the table qualifier (name or alias) for the derived columns of the group (for StructMapper only, where the
+	// select column list is derived - see NewStructMapper)
+	Table string
+	// Groups is any nested child column groups
+	Groups []ColumnGroup
+	// EmptyNil determines that the array property (or field) is left nil when there are no child rows
+	EmptyNil bool
+}
+
+func (g *Grouping) validate() error {
+	if len(g.Key) == 0 {
+		return errors.New("grouping must have a key")
+	}
+	return validateColumnGroups(g.Groups)
+}
+
+func validateColumnGroups(groups []ColumnGroup) error {
+	for _, cg := range groups {
+		if cg.Property == "" {
+			return errors.New("column group must have a property")
+		} else if len(cg.Key) == 0 {
+			return fmt.Errorf("column group '%s' must have a key", cg.Property)
+		} else if err := validateColumnGroups(cg.Groups); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// groupSpec is a ColumnGroup resolved for a set of columns
+type groupSpec struct {
+	*ColumnGroup
+	// index is the index of the group in groupColumns.all
+	index    int
+	parent   *groupSpec
+	children []*groupSpec
+	// keys is the indexes of the key columns
+	keys []int
+	// path is the property path of the child rows (i.e. parent group properties and the group property)
+	path []string
+	// excluded is set when the group property is excluded (Mapper only)
+	excluded bool
+}
+
+// depth returns the nesting depth of the group
+func (s *groupSpec) depth() int {
+	result := 0
+	for p := s.parent; p != nil; p = p.parent {
+		result++
+	}
+	return result
+}
+
+// groupColumns is a Grouping resolved for a set of columns
+type groupColumns struct {
+	*Grouping
+	// keys is the indexes of the parent key columns
+	keys []int
+	// groups is the top level groups and all is all the groups (by index)
+	groups []*groupSpec
+	all    []*groupSpec
+	// owners is the index of the group that each column belongs to (-1 for parent columns)
+	owners []int
+	// names is the (default) property name for each column
+	names []string
+}
+
+// resolve resolves the grouping for a set of columns - claims determines whether a column is a column of a group
+// (returning the default property name for the column)
+//
+// columns belong to the most nested group that claims them (or the first, where groups are nested at the same depth)
+func (g *Grouping) resolve(columns []string, claims func(spec *groupSpec, col string) (string, bool)) (*groupColumns, error) {
+	colIndexes := make(map[string]int, len(columns))
+	for i, col := range columns {
+		if _, ok := colIndexes[col]; !ok {
+			colIndexes[col] = i
+		}
+	}
+	keyIndexes := func(keys []string) ([]int, error) {
+		result := make([]int, len(keys))
+		for i, key := range keys {
+			idx, ok := colIndexes[key]
+			if !ok {
+				return nil, fmt.Errorf("grouping key column '%s' not present", key)
+			}
+			result[i] = idx
+		}
+		return result, nil
+	}
+	result := &groupColumns{
+		Grouping: g,
+		owners:   make([]int, len(columns)),
+		names:    append([]string{}, columns...),
+	}
+	var err error
+	if result.keys, err = keyIndexes(g.Key); err != nil {
+		return nil, err
+	}
+	var build func(groups []ColumnGroup, parent *groupSpec) ([]*groupSpec, error)
+	build = func(groups []ColumnGroup, parent *groupSpec) ([]*groupSpec, error) {
+		specs := make([]*groupSpec, len(groups))
+		for i := range groups {
+			spec := &groupSpec{
+				ColumnGroup: &groups[i],
+				index:       len(result.all),
+				parent:      parent,
+			}
+			if parent != nil {
+				spec.path = append(parent.path[:len(parent.path):len(parent.path)], spec.Property)
+			} else {
+				spec.path = []string{spec.Property}
+			}
+			result.all = append(result.all, spec)
+			var err error
+			if spec.keys, err = keyIndexes(spec.Key); err == nil {
+				spec.children, err = build(spec.Groups, spec)
+			}
+			if err != nil {
+				return nil, err
+			}
+			specs[i] = spec
+		}
+		return specs, nil
+	}
+	if result.groups, err = build(g.Groups, nil); err != nil {
+		return nil, err
+	}
+	for i, col := range columns {
+		result.owners[i] = -1
+		ownerDepth := -1
+		for _, spec := range result.all {
+			if d := spec.depth(); d > ownerDepth {
+				if name, ok := spec.claimsKey(col, g.Key); ok {
+					result.owners[i], result.names[i], ownerDepth = spec.index, name, d
+				} else if name, ok = claims(spec, col); ok {
+					result.owners[i], result.names[i], ownerDepth = spec.index, name, d
+				}
+			}
+		}
+	}
+	return result, nil
+}
+
+// claimsKey determines whether a column is a key column of the group (and not a key column of any parent)
+func (s *groupSpec) claimsKey(col string, rootKey []string) (string, bool) {
+	isKey := func(keys []string) bool {
+		for _, key := range keys {
+			if key == col {
+				return true
+			}
+		}
+		return false
+	}
+	if !isKey(s.Key) || isKey(rootKey) {
+		return "", false
+	}
+	for p := s.parent; p != nil; p = p.parent {
+		if isKey(p.Key) {
+			return "", false
+		}
+	}
+	if s.Prefix != "" && strings.HasPrefix(col, s.Prefix) {
+		return col[len(s.Prefix):], true
+	}
+	return col, true
+}
+
+// keyValues returns the key (and whether any of the key values are null) from the values of a row
+func keyValues(values []any, indexes []int) (key string, null bool) {
+	kvs := make([]any, len(indexes))
+	for i, idx := range indexes {
+		if kvs[i] = values[idx]; kvs[i] == nil {
+			null = true
+		}
+	}
+	return batchKey(kvs), null
+}
+
+// groupItem is a child row read (for a group) from a database row
+type groupItem struct {
+	item any
+	key  string
+	null bool
+}
+
+// groupNode is a grouped row (parent or child) with its child rows
+type groupNode struct {
+	item     any
+	children [][]*groupNode
+	keyed    []map[string]*groupNode
+}
+
+func newGroupNode(item any, groups int) *groupNode {
+	result := &groupNode{
+		item:     item,
+		children: make([][]*groupNode, groups),
+		keyed:    make([]map[string]*groupNode, groups),
+	}
+	for i := range result.keyed {
+		result.keyed[i] = make(map[string]*groupNode)
+	}
+	return result
+}
+
+// merge merges the child rows (of a database row) into the node
+func (n *groupNode) merge(groups []*groupSpec, items []groupItem) {
+	for i, spec := range groups {
+		gi := items[spec.index]
+		if gi.null || spec.excluded {
+			continue
+		}
+		child, ok := n.keyed[i][gi.key]
+		if !ok {
+			child = newGroupNode(gi.item, len(spec.children))
+			n.keyed[i][gi.key] = child
+			n.children[i] = append(n.children[i], child)
+		}
+		child.merge(spec.children, items)
+	}
+}
+
+// rowGrouper merges database rows into grouped parent rows
+type rowGrouper struct {
+	columns *groupColumns
+	// assign assigns the (completed) child rows of a group to a parent (or child) row
+	assign     func(item any, spec *groupSpec, children []any)
+	current    *groupNode
+	currentKey string
+	nodes      map[string]*groupNode
+	order      []*groupNode
+	// limiter limits the number of parent rows - count is the number of parent rows started and limited is set
+	// (when not Keyed) once a row for a parent beyond the limit has been read (i.e. no more rows need to be read)
+	limiter Limiter
+	count   int
+	limited bool
+}
+
+func newRowGrouper(columns *groupColumns, assign func(item any, spec *groupSpec, children []any), limiter Limiter) *rowGrouper {
+	return &rowGrouper{
+		columns: columns,
+		assign:  assign,
+		nodes:   make(map[string]*groupNode),
+		limiter: limiter,
+	}
+}
+
+// newParent checks whether a new parent row can be started (i.e. the limiter is not reached)
+func (g *rowGrouper) newParent() bool {
+	if g.limited {
+		return false
+	}
+	g.count++
+	if g.limiter != nil && g.limiter.LimitReached(g.count) {
+		g.limited = !g.columns.Keyed
+		return false
+	}
+	return true
+}
+
+// add adds a database row (the parent row and the child rows for each group) - returning any completed parent rows
+func (g *rowGrouper) add(key string, parent any, items []groupItem) (completed []any) {
+	var node *groupNode
+	if g.columns.Keyed {
+		if node = g.nodes[key]; node == nil {
+			if !g.newParent() {
+				return nil
+			}
+			node = newGroupNode(parent, len(g.columns.groups))
+			g.nodes[key] = node
+			g.order = append(g.order, node)
+		}
+	} else if g.current != nil && g.currentKey == key {
+		node = g.current
+	} else {
+		if g.current != nil {
+			completed = append(completed, g.complete(g.current, g.columns.groups))
+			g.current = nil
+		}
+		if !g.newParent() {
+			return completed
+		}
+		node = newGroupNode(parent, len(g.columns.groups))
+		g.current, g.currentKey = node, key
+	}
+	node.merge(g.columns.groups, items)
+	return completed
+}
+
+// flush completes and returns any remaining parent rows
+func (g *rowGrouper) flush() (completed []any) {
+	if g.columns.Keyed {
+		for _, node := range g.order {
+			completed = append(completed, g.complete(node, g.columns.groups))
+		}
+		g.nodes = make(map[string]*groupNode)
+		g.order = nil
+	} else if g.current != nil {
+		completed = append(completed, g.complete(g.current, g.columns.groups))
+		g.current = nil
+	}
+	return completed
+}
+
+func (g *rowGrouper) complete(node *groupNode, groups []*groupSpec) any {
+	for i, spec := range groups {
+		if spec.excluded {
+			continue
+		}
+		children := make([]any, len(node.children[i]))
+		for j, child := range node.children[i] {
+			children[j] = g.complete(child, spec.children)
+		}
+		g.assign(node.item, spec, children)
+	}
+	return node.item
+}
+
+// groupColumns resolves the grouping for the columns (using the Columns and Prefix of each group)
+func (o *mapOptions) groupColumns(cols *columnsReader, subPath []string) (*groupColumns, error) {
+	result, err := o.grouping.resolve(cols.names, func(spec *groupSpec, col string) (string, bool) {
+		for _, c := range spec.Columns {
+			if c == col {
+				return col, true
+			}
+		}
+		if spec.Prefix != "" && strings.HasPrefix(col, spec.Prefix) {
+			return col[len(spec.Prefix):], true
+		}
+		return "", false
+	})
+	if err == nil {
+		for _, spec := range result.all {
+			path := subPath
+			if spec.parent != nil {
+				path = append(subPath[:len(subPath):len(subPath)], spec.parent.path...)
+			}
+			spec.excluded = (spec.parent != nil && spec.parent.excluded) || o.exclusions.Exclude(spec.Property, path)
+		}
+	}
+	return result, err
+}
+
+// mapGroupedRow maps the current row into the parent row and the child rows for each group
+func (m *mapper) mapGroupedRow(ctx context.Context, sqli SqlInterface, rows *sql.Rows, cols *columnsReader, gc *groupColumns, opts *mapOptions) (key string, row map[string]any, items []groupItem, err error) {
+	if err = rows.Scan(cols.scanArgs...); err != nil {
+		return "", nil, nil, err
+	}
+	key, _ = keyValues(cols.values, gc.keys)
+	row = make(map[string]any, cols.count)
+	items = make([]groupItem, len(gc.all))
+	for i, spec := range gc.all {
+		items[i].key, items[i].null = keyValues(cols.values, spec.keys)
+		if items[i].null = items[i].null || spec.excluded || (spec.parent != nil && items[spec.parent.index].null); !items[i].null {
+			items[i].item = map[string]any{}
+		}
+	}
+	for i, col := range cols.names {
+		if owner := gc.owners[i]; owner == -1 {
+			err = m.mapValue(ctx, sqli, row, col, col, cols.values[i], m.subPath, opts)
+		} else if !items[owner].null {
+			path := append(m.subPath[:len(m.subPath):len(m.subPath)], gc.all[owner].path...)
+			err = m.mapValue(ctx, sqli, items[owner].item.(map[string]any), col, gc.names[i], cols.values[i], path, opts)
+		}
+		if err != nil {
+			return "", nil, nil, err
+		}
+	}
+	return key, row, items, nil
+}
+
+// assignGroupRows assigns the child rows of a group as an array property of the (map) row
+func assignGroupRows(item any, spec *groupSpec, children []any) {
+	row := item.(map[string]any)
+	if len(children) == 0 && spec.EmptyNil {
+		row[spec.Property] = nil
+		return
+	}
+	rows := make([]map[string]any, len(children))
+	for i, child := range children {
+		rows[i] = child.(map[string]any)
+	}
+	row[spec.Property] = rows
+}
+
+// keyColumns returns all the key columns of the grouping (parent and group keys)
+func (g *Grouping) keyColumns() []string {
+	result := append([]string{}, g.Key...)
+	var add func(groups []ColumnGroup)
+	add = func(groups []ColumnGroup) {
+		for _, cg := range groups {
+			result = append(result, cg.Key...)
+			add(cg.Groups)
+		}
+	}
+	add(g.Groups)
+	return result
+}
+
+// mapCompleteGroupedRow maps the first grouped row - reading rows until the first parent row is complete
+func (m *mapper) mapCompleteGroupedRow(ctx context.Context, sqli SqlInterface, rows *sql.Rows, cols *columnsReader, opts *mapOptions) (map[string]any, error) {
+	batch := opts.newBatch(ctx, sqli)
+	batch.size = 1
+	var completed []map[string]any
+	var err error
+	for err == nil && len(completed) == 0 {
+		if completed, err = m.readRow(ctx, sqli, rows, cols, opts, batch); err == nil && len(completed) == 0 && !rows.Next() {
+			completed, err = batch.flush()
+			break
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+	return completed[0], nil
+}
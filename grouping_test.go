@@ -0,0 +1,337 @@
+package columbus
+
+import (
+	"bytes"
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"io"
+	"testing"
+)
+
+func TestMapper_Grouping(t *testing.T) {
+	m, err := newMapper("p.id,p.name,a.id AS address_id,a.city AS address_city", Query(`FROM people p LEFT JOIN addresses a ON a.person_id = p.id ORDER BY p.id`),
+		Grouping{
+			Key: []string{"id"},
+			Groups: []ColumnGroup{
+				{Property: "addresses", Key: []string{"address_id"}, Prefix: "address_"},
+			},
+		})
+	require.NoError(t, err)
+
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer func() {
+		_ = db.Close()
+	}()
+	mock.ExpectQuery("").WillReturnRows(sqlmock.NewRows([]string{"id", "name", "address_id", "address_city"}).
+		AddRow(int64(1), "Bilbo", int64(10), "Hobbiton").
+		AddRow(int64(1), "Bilbo", int64(11), "Rivendell").
+		AddRow(int64(2), "Frodo", nil, nil).
+		AddRow(int64(3), "Sam", int64(12), "Bywater"))
+
+	rows, err := m.Rows(ctx, db, nil)
+	require.NoError(t, err)
+	require.NoError(t, mock.ExpectationsWereMet())
+	assert.Equal(t, []map[string]any{
+		{"id": int64(1), "name": "Bilbo", "addresses": []map[string]any{
+			{"id": int64(10), "city": "Hobbiton"},
+			{"id": int64(11), "city": "Rivendell"},
+		}},
+		{"id": int64(2), "name": "Frodo", "addresses": []map[string]any{}},
+		{"id": int64(3), "name": "Sam", "addresses": []map[string]any{
+			{"id": int64(12), "city": "Bywater"},
+		}},
+	}, rows)
+}
+
+func TestMapper_Grouping_Nested(t *testing.T) {
+	m, err := newMapper("id,order_id,order_ref,line_no,line_product,tag", Query(`FROM people`),
+		Mappings{
+			"tag": {PropertyName: "name"},
+		},
+		Grouping{
+			Key: []string{"id"},
+			Groups: []ColumnGroup{
+				{Property: "orders", Key: []string{"order_id"}, Prefix: "order_", Groups: []ColumnGroup{
+					{Property: "lines", Key: []string{"order_id", "line_no"}, Prefix: "line_"},
+				}},
+				{Property: "tags", Key: []string{"tag"}, Columns: []string{"tag"}, EmptyNil: true},
+			},
+		})
+	require.NoError(t, err)
+
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer func() {
+		_ = db.Close()
+	}()
+	// orders/lines and tags are a cross product...
+	mock.ExpectQuery("").WillReturnRows(sqlmock.NewRows([]string{"id", "order_id", "order_ref", "line_no", "line_product", "tag"}).
+		AddRow(int64(1), int64(100), "A", int64(1), "Ring", "hobbit").
+		AddRow(int64(1), int64(100), "A", int64(1), "Ring", "burglar").
+		AddRow(int64(1), int64(100), "A", int64(2), "Sword", "hobbit").
+		AddRow(int64(1), int64(100), "A", int64(2), "Sword", "burglar").
+		AddRow(int64(1), int64(101), "B", int64(1), "Mithril", "hobbit").
+		AddRow(int64(1), int64(101), "B", int64(1), "Mithril", "burglar").
+		AddRow(int64(2), int64(102), "C", nil, nil, nil))
+
+	rows, err := m.Rows(ctx, db, nil)
+	require.NoError(t, err)
+	require.NoError(t, mock.ExpectationsWereMet())
+	assert.Equal(t, []map[string]any{
+		{"id": int64(1), "orders": []map[string]any{
+			{"id": int64(100), "ref": "A", "lines": []map[string]any{
+				{"no": int64(1), "product": "Ring"},
+				{"no": int64(2), "product": "Sword"},
+			}},
+			{"id": int64(101), "ref": "B", "lines": []map[string]any{
+				{"no": int64(1), "product": "Mithril"},
+			}},
+		}, "tags": []map[string]any{
+			{"name": "hobbit"},
+			{"name": "burglar"},
+		}},
+		{"id": int64(2), "orders": []map[string]any{
+			{"id": int64(102), "ref": "C", "lines": []map[string]any{}},
+		}, "tags": nil},
+	}, rows)
+}
+
+func TestMapper_Grouping_Keyed(t *testing.T) {
+	m, err := newMapper("id,name,address_id,address_city", Query(`FROM people`))
+	require.NoError(t, err)
+	grouping := Grouping{
+		Key:    []string{"id"},
+		Groups: []ColumnGroup{{Property: "addresses", Key: []string{"address_id"}, Prefix: "address_"}},
+	}
+
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer func() {
+		_ = db.Close()
+	}()
+	newRows := func() *sqlmock.Rows {
+		return sqlmock.NewRows([]string{"id", "name", "address_id", "address_city"}).
+			AddRow(int64(1), "Bilbo", int64(10), "Hobbiton").
+			AddRow(int64(2), "Frodo", int64(11), "Bag End").
+			AddRow(int64(1), "Bilbo", int64(12), "Rivendell")
+	}
+	mock.ExpectQuery("").WillReturnRows(newRows())
+	rows, err := m.Rows(ctx, db, nil, grouping)
+	require.NoError(t, err)
+	require.Len(t, rows, 3)
+
+	grouping.Keyed = true
+	mock.ExpectQuery("").WillReturnRows(newRows())
+	rows, err = m.Rows(ctx, db, nil, grouping)
+	require.NoError(t, err)
+	require.NoError(t, mock.ExpectationsWereMet())
+	require.Len(t, rows, 2)
+	assert.Equal(t, "Bilbo", rows[0]["name"])
+	assert.Len(t, rows[0]["addresses"], 2)
+	assert.Equal(t, "Frodo", rows[1]["name"])
+	assert.Len(t, rows[1]["addresses"], 1)
+}
+
+func TestMapper_Grouping_FirstRow(t *testing.T) {
+	m, err := newMapper("id,address_id", Query(`FROM people`), Grouping{
+		Key:    []string{"id"},
+		Groups: []ColumnGroup{{Property: "addresses", Key: []string{"address_id"}, Prefix: "address_"}},
+	})
+	require.NoError(t, err)
+
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer func() {
+		_ = db.Close()
+	}()
+	mock.ExpectQuery("").WillReturnRows(sqlmock.NewRows([]string{"id", "address_id"}).
+		AddRow(int64(1), int64(10)).AddRow(int64(1), int64(11)).AddRow(int64(2), int64(12)))
+	row, err := m.FirstRow(ctx, db, nil)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]any{"id": int64(1), "addresses": []map[string]any{{"id": int64(10)}, {"id": int64(11)}}}, row)
+
+	mock.ExpectQuery("").WillReturnRows(sqlmock.NewRows([]string{"id", "address_id"}).
+		AddRow(int64(1), int64(10)).AddRow(int64(1), int64(11)))
+	row, err = m.ExactlyOneRow(ctx, db, nil)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]any{"id": int64(1), "addresses": []map[string]any{{"id": int64(10)}, {"id": int64(11)}}}, row)
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestMapper_Grouping_WriteRows(t *testing.T) {
+	m, err := newMapper("id,address_id,address_city", Query(`FROM people`), Grouping{
+		Key:    []string{"id"},
+		Groups: []ColumnGroup{{Property: "addresses", Key: []string{"address_id"}, Prefix: "address_"}},
+	})
+	require.NoError(t, err)
+
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer func() {
+		_ = db.Close()
+	}()
+	newRows := func() *sqlmock.Rows {
+		return sqlmock.NewRows([]string{"id", "address_id", "address_city"}).
+			AddRow(int64(1), int64(10), "Hobbiton").AddRow(int64(1), int64(11), "Rivendell").AddRow(int64(2), int64(12), "Bywater")
+	}
+	mock.ExpectQuery("").WillReturnRows(newRows())
+	var buf bytes.Buffer
+	err = m.WriteRows(ctx, &buf, db, nil)
+	require.NoError(t, err)
+	assert.JSONEq(t, `[{"id":1,"addresses":[{"id":10,"city":"Hobbiton"},{"id":11,"city":"Rivendell"}]},{"id":2,"addresses":[{"id":12,"city":"Bywater"}]}]`, buf.String())
+
+	mock.ExpectQuery("").WillReturnRows(newRows())
+	enc := &testCapturingEncoding{}
+	err = m.WriteRows(ctx, &buf, db, nil, enc)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"id", "addresses"}, enc.info.Properties)
+	assert.Equal(t, []NestedInfo{{Property: "addresses", Array: true, Properties: []string{"id", "city"}}}, enc.info.Nested)
+
+	// excluded group and excluded group property...
+	mock.ExpectQuery("").WillReturnRows(newRows())
+	buf.Reset()
+	err = m.WriteRows(ctx, &buf, db, nil, func(property string, path []string) bool {
+		return property == "city" && len(path) == 1 && path[0] == "addresses"
+	})
+	require.NoError(t, err)
+	assert.JSONEq(t, `[{"id":1,"addresses":[{"id":10},{"id":11}]},{"id":2,"addresses":[{"id":12}]}]`, buf.String())
+	mock.ExpectQuery("").WillReturnRows(newRows())
+	buf.Reset()
+	err = m.WriteRows(ctx, &buf, db, nil, func(property string, path []string) bool {
+		return property == "addresses"
+	})
+	require.NoError(t, err)
+	assert.JSONEq(t, `[{"id":1},{"id":2}]`, buf.String())
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestMapper_Grouping_Iterate(t *testing.T) {
+	m, err := newMapper("id,address_id", Query(`FROM people`), Grouping{
+		Key:    []string{"id"},
+		Groups: []ColumnGroup{{Property: "addresses", Key: []string{"address_id"}, Prefix: "address_"}},
+	})
+	require.NoError(t, err)
+
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer func() {
+		_ = db.Close()
+	}()
+	newRows := func() *sqlmock.Rows {
+		return sqlmock.NewRows([]string{"id", "address_id"}).
+			AddRow(int64(1), int64(10)).AddRow(int64(1), int64(11)).AddRow(int64(2), int64(12))
+	}
+	mock.ExpectQuery("").WillReturnRows(newRows())
+	counts := make([]int, 0)
+	err = m.Iterate(ctx, db, nil, func(row map[string]any) (bool, error) {
+		counts = append(counts, len(row["addresses"].([]map[string]any)))
+		return true, nil
+	})
+	require.NoError(t, err)
+	assert.Equal(t, []int{2, 1}, counts)
+
+	mock.ExpectQuery("").WillReturnRows(newRows())
+	counts = counts[:0]
+	for row, err := range m.ErrIterator(ctx, db, nil) {
+		require.NoError(t, err)
+		counts = append(counts, len(row["addresses"].([]map[string]any)))
+	}
+	assert.Equal(t, []int{2, 1}, counts)
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestMapper_Grouping_Errors(t *testing.T) {
+	_, err := newMapper("id", Query(`FROM people`), Grouping{})
+	require.Error(t, err)
+	assert.Equal(t, "grouping must have a key", err.Error())
+	_, err = newMapper("id", Query(`FROM people`), Grouping{Key: []string{"id"}, Groups: []ColumnGroup{{Key: []string{"x"}}}})
+	require.Error(t, err)
+	assert.Equal(t, "column group must have a property", err.Error())
+	_, err = newMapper("id", Query(`FROM people`), Grouping{Key: []string{"id"}, Groups: []ColumnGroup{{Property: "x"}}})
+	require.Error(t, err)
+	assert.Equal(t, "column group 'x' must have a key", err.Error())
+
+	m, err := newMapper("id", Query(`FROM people`), Grouping{Key: []string{"id"}, Groups: []ColumnGroup{{Property: "x", Key: []string{"x_id"}}}})
+	require.NoError(t, err)
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer func() {
+		_ = db.Close()
+	}()
+	mock.ExpectQuery("").WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(int64(1)))
+	_, err = m.Rows(ctx, db, nil)
+	require.Error(t, err)
+	assert.Equal(t, "grouping key column 'x_id' not present", err.Error())
+
+	_, err = m.Rows(ctx, db, nil, &Keyset{Sort: []string{"id"}, Size: 1})
+	require.Error(t, err)
+	assert.Equal(t, "keyset cannot be used with grouping", err.Error())
+	_, err = m.Rows(ctx, db, nil, RowLimit{Limit: 1})
+	require.Error(t, err)
+	assert.Equal(t, "row limit cannot be used with grouping", err.Error())
+	_, err = m.Page(ctx, db, nil, 1, 10)
+	require.Error(t, err)
+	assert.Equal(t, "page cannot be used with grouping", err.Error())
+}
+
+func TestMapper_Grouping_Limiter(t *testing.T) {
+	newRows := func() *sqlmock.Rows {
+		return sqlmock.NewRows([]string{"id", "address_id"}).
+			AddRow(int64(1), int64(10)).
+			AddRow(int64(1), int64(11)).
+			AddRow(int64(2), int64(12)).
+			AddRow(int64(2), int64(13)).
+			AddRow(int64(1), int64(14)).
+			AddRow(int64(3), int64(15))
+	}
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer func() {
+		_ = db.Close()
+	}()
+	grouping := Grouping{Key: []string{"id"}, Groups: []ColumnGroup{{Property: "addresses", Key: []string{"address_id"}, Prefix: "address_"}}}
+	m, err := newMapper("id,address_id", Query(`FROM people`), grouping)
+	require.NoError(t, err)
+
+	mock.ExpectQuery("").WillReturnRows(newRows())
+	rows, err := m.Rows(ctx, db, nil, &testLimiter{limit: 2})
+	require.NoError(t, err)
+	assert.Equal(t, []map[string]any{
+		{"id": int64(1), "addresses": []map[string]any{{"id": int64(10)}, {"id": int64(11)}}},
+		{"id": int64(2), "addresses": []map[string]any{{"id": int64(12)}, {"id": int64(13)}}},
+	}, rows)
+
+	grouping.Keyed = true
+	mock.ExpectQuery("").WillReturnRows(newRows())
+	rows, err = m.Rows(ctx, db, nil, &testLimiter{limit: 2}, grouping)
+	require.NoError(t, err)
+	assert.Equal(t, []map[string]any{
+		{"id": int64(1), "addresses": []map[string]any{{"id": int64(10)}, {"id": int64(11)}, {"id": int64(14)}}},
+		{"id": int64(2), "addresses": []map[string]any{{"id": int64(12)}, {"id": int64(13)}}},
+	}, rows)
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+type testCapturingEncoding struct {
+	info EncodeInfo
+}
+
+func (e *testCapturingEncoding) ContentType() string {
+	return "application/json"
+}
+
+func (e *testCapturingEncoding) NewEncoder(w io.Writer) RowEncoder {
+	return &testCapturingEncoder{encoding: e, RowEncoder: JsonArray.NewEncoder(w)}
+}
+
+type testCapturingEncoder struct {
+	RowEncoder
+	encoding *testCapturingEncoding
+}
+
+func (e *testCapturingEncoder) Begin(info EncodeInfo) error {
+	e.encoding.info = info
+	return e.RowEncoder.Begin(info)
+}
@@ -0,0 +1,173 @@
+// Package handler - builds net/http handlers that serve columbus mappers as JSON endpoints
+package handler
+
+import (
+	"encoding/json"
+	"github.com/go-andiamo/columbus"
+	"net/http"
+)
+
+// Config is the configuration used when building a handler
+type Config struct {
+	// Args is the request parameters that are converted to query args (in order)
+	Args []Param
+	// Options is an optional func that returns additional options for the request - e.g. using odata.Options or columbus.ParseFields
+	//
+	// any error returned is written as a problem response (see StatusCode)
+	Options func(r *http.Request) ([]any, error)
+	// StatusCode is an optional func that determines the http status code for an error
+	//
+	// if the func returns false (or is nil), the default status code for the error is used (see StatusCode)
+	StatusCode func(err error) (int, bool)
+	// Encodings is the row encodings that can be selected, using the request Accept header, by List and Item handlers
+	// (see columbus.NegotiateEncoding)
+	//
+	// if empty, the columbus.DefaultEncodings are used
+	Encodings []columbus.RowEncoding
+}
+
+// List creates an http.Handler that writes all rows (using Mapper.WriteRows)
+//
+// the rows are written using the row encoding selected by the request Accept header (see Config.Encodings) - if no encoding is
+// acceptable, a 406 (Not Acceptable) problem response is written
+func List(m columbus.Mapper, sqli columbus.SqlInterface, config Config) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		enc, args, options, err := config.resolveEncoded(r)
+		if err == nil {
+			rw := newResponseWriter(w, enc.ContentType())
+			if err = m.WriteRows(r.Context(), rw, sqli, args, options...); err != nil && rw.written {
+				// the response has already started - so it cannot be replaced with a problem response...
+				return
+			}
+		}
+		if err != nil {
+			config.writeProblem(w, err)
+		}
+	})
+}
+
+// Item creates an http.Handler that writes exactly one row (using Mapper.WriteExactlyOneRow)
+//
+// the row is written using the row encoding selected by the request Accept header (see Config.Encodings) - if no encoding is
+// acceptable, a 406 (Not Acceptable) problem response is written
+//
+// if there is no row, a 404 (Not Found) problem response is written
+func Item(m columbus.Mapper, sqli columbus.SqlInterface, config Config) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		enc, args, options, err := config.resolveEncoded(r)
+		if err == nil {
+			rw := newResponseWriter(w, enc.ContentType())
+			if err = m.WriteExactlyOneRow(r.Context(), rw, sqli, args, options...); err != nil && rw.written {
+				return
+			}
+		}
+		if err != nil {
+			config.writeProblem(w, err)
+		}
+	})
+}
+
+// StructList creates an http.Handler that writes all rows of a StructMapper (using StructMapper.WriteRows)
+//
+// the rows are written using the row encoding selected by the request Accept header (see Config.Encodings) - if no encoding is
+// acceptable, a 406 (Not Acceptable) problem response is written
+func StructList[T any](m columbus.StructMapper[T], sqli columbus.SqlInterface, config Config) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		enc, args, options, err := config.resolveEncoded(r)
+		if err == nil {
+			rw := newResponseWriter(w, enc.ContentType())
+			if err = m.WriteRows(r.Context(), rw, sqli, args, options...); err != nil && rw.written {
+				return
+			}
+		}
+		if err != nil {
+			config.writeProblem(w, err)
+		}
+	})
+}
+
+// StructItem creates an http.Handler that writes exactly one row of a StructMapper as a JSON object
+//
+// if there is no row, a 404 (Not Found) problem response is written
+func StructItem[T any](m columbus.StructMapper[T], sqli columbus.SqlInterface, config Config) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		args, options, err := config.resolve(r)
+		if err == nil {
+			var row T
+			if row, err = m.ExactlyOneRow(r.Context(), sqli, args, options...); err == nil {
+				writeJson(w, http.StatusOK, contentTypeJson, row)
+				return
+			}
+		}
+		config.writeProblem(w, err)
+	})
+}
+
+// resolve resolves the args and options for the request
+func (c Config) resolve(r *http.Request) (args []any, options []any, err error) {
+	if args, err = resolveArgs(r, c.Args); err == nil && c.Options != nil {
+		options, err = c.Options(r)
+	}
+	return args, options, err
+}
+
+// resolveEncoded resolves the row encoding (from the Accept header), args and options for the request
+func (c Config) resolveEncoded(r *http.Request) (enc columbus.RowEncoding, args []any, options []any, err error) {
+	if enc, err = columbus.NegotiateEncoding(r.Header.Get("Accept"), c.Encodings...); err == nil {
+		if args, options, err = c.resolve(r); err == nil {
+			options = append(options, enc)
+		}
+	}
+	return enc, args, options, err
+}
+
+const (
+	contentTypeJson    = "application/json"
+	contentTypeProblem = "application/problem+json"
+)
+
+func writeJson(w http.ResponseWriter, status int, contentType string, v any) {
+	w.Header().Set("Content-Type", contentType)
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+// responseWriter defers writing the response header (and content type) until the first write - so that, if an error
+// occurs before anything is written, a problem response can be written instead
+type responseWriter struct {
+	http.ResponseWriter
+	contentType string
+	written     bool
+}
+
+func newResponseWriter(w http.ResponseWriter, contentType string) *responseWriter {
+	return &responseWriter{
+		ResponseWriter: w,
+		contentType:    contentType,
+	}
+}
+
+var _ http.Flusher = (*responseWriter)(nil)
+
+// Flush implements http.Flusher - so that streamed rows (e.g. columbus.NDJson) are sent to the client incrementally
+//
+// nothing is flushed until the response has started (i.e. something has been written)
+func (w *responseWriter) Flush() {
+	if w.written {
+		_ = http.NewResponseController(w.ResponseWriter).Flush()
+	}
+}
+
+// Unwrap returns the underlying http.ResponseWriter (for use by http.ResponseController)
+func (w *responseWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+func (w *responseWriter) Write(p []byte) (int, error) {
+	if !w.written {
+		w.written = true
+		w.Header().Set("Content-Type", w.contentType)
+		w.WriteHeader(http.StatusOK)
+	}
+	return w.ResponseWriter.Write(p)
+}
@@ -0,0 +1,282 @@
+package handler
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/go-andiamo/columbus"
+	"github.com/go-andiamo/columbus/odata"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestList(t *testing.T) {
+	db, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+	require.NoError(t, err)
+	defer func() {
+		_ = db.Close()
+	}()
+	m := columbus.MustNewMapper("id,name", columbus.Query(`FROM people WHERE status = ?`))
+	h := List(m, db, Config{
+		Args: []Param{{Name: "status", In: QueryParam, Default: "ACTIVE"}},
+		Options: func(r *http.Request) ([]any, error) {
+			return odata.Options(r.URL.Query(), odata.Config{Sortable: columbus.SortableProperties{"name": ""}})
+		},
+	})
+
+	mock.ExpectQuery("SELECT id,name FROM people WHERE status = ? ORDER BY name DESC").WithArgs("ACTIVE").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "name"}).AddRow(1, "foo").AddRow(2, "bar"))
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/people?$orderby=name+desc", nil))
+	require.NoError(t, mock.ExpectationsWereMet())
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "application/json", w.Header().Get("Content-Type"))
+	assert.JSONEq(t, `[{"id":1,"name":"foo"},{"id":2,"name":"bar"}]`, w.Body.String())
+
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/people?$orderby=age", nil))
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Equal(t, "application/problem+json", w.Header().Get("Content-Type"))
+	assert.JSONEq(t, `{"type":"about:blank","title":"Bad Request","status":400,"detail":"unknown sort property 'age'"}`, w.Body.String())
+
+	mock.ExpectQuery("SELECT id,name FROM people WHERE status = ?").WithArgs("X").WillReturnError(errors.New("fooey"))
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/people?status=X", nil))
+	require.NoError(t, mock.ExpectationsWereMet())
+	assert.Equal(t, http.StatusInternalServerError, w.Code)
+	assert.JSONEq(t, `{"type":"about:blank","title":"Internal Server Error","status":500}`, w.Body.String())
+}
+
+func TestItem(t *testing.T) {
+	db, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+	require.NoError(t, err)
+	defer func() {
+		_ = db.Close()
+	}()
+	m := columbus.MustNewMapper("id,name", columbus.Query(`FROM people WHERE id = ?`))
+	mux := http.NewServeMux()
+	mux.Handle("GET /people/{id}", Item(m, db, Config{
+		Args: []Param{{Name: "id", Required: true, Convert: Int}},
+	}))
+
+	mock.ExpectQuery("SELECT id,name FROM people WHERE id = ?").WithArgs(int64(1)).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "name"}).AddRow(1, "foo"))
+	w := httptest.NewRecorder()
+	mux.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/people/1", nil))
+	require.NoError(t, mock.ExpectationsWereMet())
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "application/json", w.Header().Get("Content-Type"))
+	assert.JSONEq(t, `{"id":1,"name":"foo"}`, w.Body.String())
+
+	mock.ExpectQuery("SELECT id,name FROM people WHERE id = ?").WithArgs(int64(2)).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "name"}))
+	w = httptest.NewRecorder()
+	mux.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/people/2", nil))
+	require.NoError(t, mock.ExpectationsWereMet())
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	assert.Equal(t, "application/problem+json", w.Header().Get("Content-Type"))
+	assert.JSONEq(t, `{"type":"about:blank","title":"Not Found","status":404,"detail":"sql: no rows in result set"}`, w.Body.String())
+
+	w = httptest.NewRecorder()
+	mux.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/people/x", nil))
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.JSONEq(t, `{"type":"about:blank","title":"Bad Request","status":400,"detail":"invalid path parameter 'id': invalid value 'x'"}`, w.Body.String())
+}
+
+type testPerson struct {
+	Id   int64  `sql:"id" json:"id"`
+	Name string `sql:"name" json:"name"`
+}
+
+func TestStructList(t *testing.T) {
+	db, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+	require.NoError(t, err)
+	defer func() {
+		_ = db.Close()
+	}()
+	m := columbus.MustNewStructMapper[testPerson]("id,name", columbus.Query(`FROM people`))
+	h := StructList(m, db, Config{})
+
+	mock.ExpectQuery("SELECT id,name FROM people").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "name"}).AddRow(1, "foo"))
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/people", nil))
+	require.NoError(t, mock.ExpectationsWereMet())
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, `[{"id":1,"name":"foo"}]`, w.Body.String())
+
+	mock.ExpectQuery("SELECT id,name FROM people").WillReturnRows(sqlmock.NewRows([]string{"id", "name"}))
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/people", nil))
+	require.NoError(t, mock.ExpectationsWereMet())
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, `[]`, w.Body.String())
+}
+
+type testStatusError struct{}
+
+func (e *testStatusError) Error() string {
+	return "gone away"
+}
+
+func (e *testStatusError) StatusCode() int {
+	return http.StatusGone
+}
+
+type testErrorTranslator struct{}
+
+func (et *testErrorTranslator) Translate(err error) error {
+	if errors.Is(err, sql.ErrNoRows) {
+		return &testStatusError{}
+	}
+	return err
+}
+
+func TestStructItem(t *testing.T) {
+	db, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+	require.NoError(t, err)
+	defer func() {
+		_ = db.Close()
+	}()
+	m := columbus.MustNewStructMapper[testPerson]("id,name", columbus.Query(`FROM people WHERE id = ?`),
+		&testErrorTranslator{})
+	h := StructItem(m, db, Config{
+		Args: []Param{{Name: "X-Id", In: Header, Required: true}},
+	})
+
+	mock.ExpectQuery("SELECT id,name FROM people WHERE id = ?").WithArgs("1").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "name"}).AddRow(1, "foo"))
+	r := httptest.NewRequest(http.MethodGet, "/person", nil)
+	r.Header.Set("X-Id", "1")
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	require.NoError(t, mock.ExpectationsWereMet())
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, `{"id":1,"name":"foo"}`, w.Body.String())
+
+	mock.ExpectQuery("SELECT id,name FROM people WHERE id = ?").WithArgs("2").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "name"}))
+	r = httptest.NewRequest(http.MethodGet, "/person", nil)
+	r.Header.Set("X-Id", "2")
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	require.NoError(t, mock.ExpectationsWereMet())
+	assert.Equal(t, http.StatusGone, w.Code)
+	assert.JSONEq(t, `{"type":"about:blank","title":"Gone","status":410,"detail":"gone away"}`, w.Body.String())
+
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/person", nil))
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.JSONEq(t, `{"type":"about:blank","title":"Bad Request","status":400,"detail":"invalid header parameter 'X-Id': is required"}`, w.Body.String())
+}
+
+func TestConfig_StatusCode(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer func() {
+		_ = db.Close()
+	}()
+	m := columbus.MustNewMapper("id", columbus.Query(`FROM people`))
+	h := List(m, db, Config{
+		StatusCode: func(err error) (int, bool) {
+			return http.StatusServiceUnavailable, err.Error() == "busy"
+		},
+	})
+	mock.ExpectQuery("").WillReturnError(errors.New("busy"))
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/people", nil))
+	require.NoError(t, mock.ExpectationsWereMet())
+	assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+}
+
+func TestList_Accept(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer func() {
+		_ = db.Close()
+	}()
+	m := columbus.MustNewMapper("id,name", columbus.Query(`FROM people`))
+	h := List(m, db, Config{})
+
+	mock.ExpectQuery("").WillReturnRows(sqlmock.NewRows([]string{"id", "name"}).AddRow(1, "foo").AddRow(2, "bar"))
+	r := httptest.NewRequest(http.MethodGet, "/people", nil)
+	r.Header.Set("Accept", "text/csv, application/json;q=0.9")
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	require.NoError(t, mock.ExpectationsWereMet())
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "text/csv", w.Header().Get("Content-Type"))
+	assert.Equal(t, "id,name\n1,foo\n2,bar\n", w.Body.String())
+
+	r = httptest.NewRequest(http.MethodGet, "/people", nil)
+	r.Header.Set("Accept", "text/html")
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	assert.Equal(t, http.StatusNotAcceptable, w.Code)
+	assert.JSONEq(t, `{"type":"about:blank","title":"Not Acceptable","status":406,"detail":"no acceptable encoding for 'text/html'"}`, w.Body.String())
+}
+
+func TestList_NDJson_Flushed(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer func() {
+		_ = db.Close()
+	}()
+	m := columbus.MustNewMapper("id", columbus.Query(`FROM people`))
+	h := List(m, db, Config{Encodings: []columbus.RowEncoding{columbus.JsonArray, columbus.NDJson}})
+
+	mock.ExpectQuery("").WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1).AddRow(2))
+	r := httptest.NewRequest(http.MethodGet, "/people", nil)
+	r.Header.Set("Accept", "application/x-ndjson")
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	require.NoError(t, mock.ExpectationsWereMet())
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "application/x-ndjson", w.Header().Get("Content-Type"))
+	assert.True(t, w.Flushed)
+	assert.Equal(t, "{\"id\":1}\n{\"id\":2}\n", w.Body.String())
+}
+
+func TestList_BadRequestOptions(t *testing.T) {
+	db, _, err := sqlmock.New()
+	require.NoError(t, err)
+	defer func() {
+		_ = db.Close()
+	}()
+	m := columbus.MustNewMapper("id,name", columbus.Query(`FROM people`))
+	h := List(m, db, Config{
+		Options: func(r *http.Request) ([]any, error) {
+			fields, err := columbus.ParseFields(r.URL.Query().Get("fields"))
+			if err != nil {
+				return nil, err
+			}
+			return []any{fields, &columbus.Keyset{Sort: []string{"id"}, Size: 10, Cursor: r.URL.Query().Get("cursor")}}, nil
+		},
+	})
+
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/people?fields=name,bad+field", nil))
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.JSONEq(t, `{"type":"about:blank","title":"Bad Request","status":400,"detail":"invalid field 'bad field'"}`, w.Body.String())
+
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/people?cursor=not-a-cursor", nil))
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.JSONEq(t, `{"type":"about:blank","title":"Bad Request","status":400,"detail":"invalid keyset cursor"}`, w.Body.String())
+}
+
+func TestStatusCode_NamedArgError(t *testing.T) {
+	db, _, err := sqlmock.New()
+	require.NoError(t, err)
+	defer func() {
+		_ = db.Close()
+	}()
+	m := columbus.MustNewMapper("id,name", columbus.Query(`FROM people WHERE status = :status`))
+	_, err = m.Rows(context.Background(), db, []any{columbus.NamedArgs{"state": "x"}})
+	require.Error(t, err)
+	assert.Equal(t, http.StatusInternalServerError, StatusCode(err))
+}
@@ -0,0 +1,108 @@
+package handler
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+)
+
+// Source is the source of a request parameter
+type Source int
+
+const (
+	// Path is a path parameter - obtained using http.Request.PathValue (i.e. a wildcard in the http.ServeMux pattern)
+	Path Source = iota
+	// QueryParam is a url query parameter
+	QueryParam
+	// Header is a request header
+	Header
+)
+
+func (s Source) String() string {
+	switch s {
+	case QueryParam:
+		return "query"
+	case Header:
+		return "header"
+	}
+	return "path"
+}
+
+// Param is the declaration of a request parameter that is converted to a query arg
+type Param struct {
+	// Name is the name of the path wildcard, query parameter or header
+	Name string
+	// In is the source of the parameter
+	In Source
+	// Required indicates that the parameter must be present (and non-empty)
+	Required bool
+	// Default is the arg value used when the parameter is not present (or empty) and is not Required
+	Default any
+	// Convert is an optional func that converts the parameter value to the arg value (e.g. Int or Bool)
+	//
+	// if nil, the arg value is the parameter string value
+	Convert func(value string) (any, error)
+}
+
+// ParamError is the error returned when a request parameter is missing or invalid
+//
+// a ParamError results in a 400 (Bad Request) problem response
+type ParamError struct {
+	// Name is the parameter name
+	Name string
+	// In is the source of the parameter
+	In Source
+	// Message is the error message
+	Message string
+	// Err is the underlying error (e.g. from Param.Convert) - may be nil
+	Err error
+}
+
+func (e *ParamError) Error() string {
+	return fmt.Sprintf("invalid %s parameter '%s': %s", e.In, e.Name, e.Message)
+}
+
+func (e *ParamError) Unwrap() error {
+	return e.Err
+}
+
+// Int is a Param.Convert func that converts the parameter value to an int64
+func Int(value string) (any, error) {
+	return strconv.ParseInt(value, 10, 64)
+}
+
+// Bool is a Param.Convert func that converts the parameter value to a bool
+func Bool(value string) (any, error) {
+	return strconv.ParseBool(value)
+}
+
+// resolveArgs resolves the query args from the request parameters
+func resolveArgs(r *http.Request, params []Param) ([]any, error) {
+	result := make([]any, 0, len(params))
+	for _, p := range params {
+		var value string
+		switch p.In {
+		case QueryParam:
+			value = r.URL.Query().Get(p.Name)
+		case Header:
+			value = r.Header.Get(p.Name)
+		default:
+			value = r.PathValue(p.Name)
+		}
+		if value == "" {
+			if p.Required {
+				return nil, &ParamError{Name: p.Name, In: p.In, Message: "is required"}
+			}
+			result = append(result, p.Default)
+		} else if p.Convert != nil {
+			if v, err := p.Convert(value); err != nil {
+				return nil, &ParamError{Name: p.Name, In: p.In, Message: fmt.Sprintf("invalid value '%s'", value), Err: err}
+			} else {
+				result = append(result, v)
+			}
+		} else {
+			result = append(result, value)
+		}
+	}
+	return result, nil
+}
@@ -0,0 +1,90 @@
+package handler
+
+import (
+	"database/sql"
+	"errors"
+	"github.com/go-andiamo/columbus"
+	"github.com/go-andiamo/columbus/filter"
+	"github.com/go-andiamo/columbus/odata"
+	"net/http"
+)
+
+// Problem is the problem details (RFC 9457) body written, as `application/problem+json`, for errors
+type Problem struct {
+	Type   string `json:"type"`
+	Title  string `json:"title"`
+	Status int    `json:"status"`
+	// Detail is the error message - it is omitted for 5xx (server error) status codes, so that internal errors are not exposed
+	Detail string `json:"detail,omitempty"`
+}
+
+// StatusCoder is an interface that errors can implement to determine the http status code of the problem response
+//
+// is particularly useful for errors returned by a columbus.ErrorTranslator
+type StatusCoder interface {
+	StatusCode() int
+}
+
+// StatusCode returns the default http status code for an error
+//
+// the status code is determined as follows:
+//
+//   - an error implementing StatusCoder - the status code returned by StatusCoder.StatusCode
+//   - sql.ErrNoRows - 404 (Not Found)
+//   - *columbus.NotAcceptableError - 406 (Not Acceptable)
+//   - *ParamError, *odata.Error, *columbus.UnknownSortPropertyError, *columbus.InvalidFieldError, *columbus.InvalidCursorError
+//     or any filter error - 400 (Bad Request)
+//   - any other error (including *columbus.NamedArgError) - 500 (Internal Server Error)
+func StatusCode(err error) int {
+	var sc StatusCoder
+	if errors.As(err, &sc) {
+		return sc.StatusCode()
+	} else if errors.Is(err, sql.ErrNoRows) {
+		return http.StatusNotFound
+	}
+	var notAcceptableErr *columbus.NotAcceptableError
+	if errors.As(err, &notAcceptableErr) {
+		return http.StatusNotAcceptable
+	}
+	var paramErr *ParamError
+	var odataErr *odata.Error
+	var sortErr *columbus.UnknownSortPropertyError
+	var fieldErr *columbus.InvalidFieldError
+	var cursorErr *columbus.InvalidCursorError
+	var syntaxErr *filter.SyntaxError
+	var propertyErr *filter.UnknownPropertyError
+	var typeErr *filter.TypeMismatchError
+	if errors.As(err, &paramErr) || errors.As(err, &odataErr) || errors.As(err, &sortErr) ||
+		errors.As(err, &fieldErr) || errors.As(err, &cursorErr) || errors.As(err, &syntaxErr) ||
+		errors.As(err, &propertyErr) || errors.As(err, &typeErr) {
+		return http.StatusBadRequest
+	}
+	return http.StatusInternalServerError
+}
+
+// NewProblem creates the Problem for an error and status code
+func NewProblem(err error, status int) Problem {
+	result := Problem{
+		Type:   "about:blank",
+		Title:  http.StatusText(status),
+		Status: status,
+	}
+	if status < http.StatusInternalServerError {
+		result.Detail = err.Error()
+	}
+	return result
+}
+
+func (c Config) statusCode(err error) int {
+	if c.StatusCode != nil {
+		if status, ok := c.StatusCode(err); ok {
+			return status
+		}
+	}
+	return StatusCode(err)
+}
+
+func (c Config) writeProblem(w http.ResponseWriter, err error) {
+	status := c.statusCode(err)
+	writeJson(w, status, contentTypeProblem, NewProblem(err, status))
+}
@@ -0,0 +1,297 @@
+package columbus
+
+import (
+	"database/sql/driver"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/shopspring/decimal"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Keyset is an option that can be passed to Mapper.Rows, Mapper.WriteRows, StructMapper.Rows or StructMapper.WriteRows
+// and reads a page of rows using keyset (cursor) pagination - passing a Keyset to any other method returns an error
+//
+// The Keyset must be passed as a pointer (i.e. *Keyset) - after the rows have been read, the NextCursor and PrevCursor are
+// set and can be passed as the Cursor on a subsequent call to read the next (or previous) page
+//
+// The query (including any AddClause) is wrapped as a derived table, so sort properties must be columns of the result
+type Keyset struct {
+	// Sort is the property names to sort by (in order) - a property name prefixed with '-' sorts descending
+	//
+	// for Mapper, property names are resolved to columns using the Mappings (PropertyName) - for StructMapper, the sort
+	// properties are column names
+	//
+	// the sort properties should be non-null and, collectively, unique (e.g. the last sort property being an id)
+	Sort []string
+	// Size is the page size
+	Size int
+	// Cursor is the cursor of the page to be read - an empty cursor reads the first page
+	Cursor string
+	// NextCursor is set, after reading, to the cursor of the next page (empty if there is no next page)
+	NextCursor string
+	// PrevCursor is set, after reading, to the cursor of the previous page (empty if there is no previous page)
+	PrevCursor string
+}
+
+// InvalidCursorError is the error returned when the Keyset Cursor is invalid (i.e. malformed, tampered with or for
+// different sort properties)
+type InvalidCursorError struct{}
+
+func (e *InvalidCursorError) Error() string {
+	return "invalid keyset cursor"
+}
+
+// errKeysetUnsupported is returned when a Keyset is passed to a method other than Rows or WriteRows
+var errKeysetUnsupported = errors.New("keyset can only be used with Rows or WriteRows")
+
+var keysetColumnRegex = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+type keysetSort struct {
+	property string
+	column   string
+	desc     bool
+}
+
+// keysetPage is the state of a Keyset for a single read
+type keysetPage struct {
+	keyset   *Keyset
+	sorts    []keysetSort
+	backward bool
+	cursor   bool
+	limiter  *keysetLimiter
+}
+
+type keysetCursor struct {
+	Direction string      `json:"d"`
+	Keys      [][2]string `json:"k"`
+}
+
+const (
+	keysetForward  = "n"
+	keysetBackward = "p"
+)
+
+// newKeysetPage resolves the keyset sort properties (using resolve to find the column for a property) and decodes the cursor
+func newKeysetPage(keyset *Keyset, resolve func(property string) string) (*keysetPage, []any, error) {
+	if keyset.Size <= 0 {
+		return nil, nil, errors.New("keyset size must be greater than zero")
+	}
+	if len(keyset.Sort) == 0 {
+		return nil, nil, errors.New("keyset must have at least one sort property")
+	}
+	result := &keysetPage{
+		keyset: keyset,
+		sorts:  make([]keysetSort, 0, len(keyset.Sort)),
+	}
+	for _, s := range keyset.Sort {
+		ks := keysetSort{property: strings.TrimPrefix(s, "-"), desc: strings.HasPrefix(s, "-")}
+		if ks.column = resolve(ks.property); !keysetColumnRegex.MatchString(ks.column) {
+			return nil, nil, fmt.Errorf("invalid keyset sort property '%s'", ks.property)
+		}
+		result.sorts = append(result.sorts, ks)
+	}
+	var values []any
+	if keyset.Cursor != "" {
+		var err error
+		if values, result.backward, err = decodeKeysetCursor(keyset.Cursor); err != nil {
+			return nil, nil, err
+		} else if len(values) != len(result.sorts) {
+			return nil, nil, &InvalidCursorError{}
+		}
+		result.cursor = true
+	}
+	return result, values, nil
+}
+
+// wrapQuery wraps the query with the keyset predicate, order and limit - returning the wrapped query and the additional args
+func (kp *keysetPage) wrapQuery(query string, values []any, dialect Dialect) (string, []any) {
+	var sb strings.Builder
+	var args []any
+	sb.WriteString("SELECT * FROM (" + query + ") _keyset")
+	if len(values) > 0 {
+		sb.WriteString(" WHERE ")
+		for i := range kp.sorts {
+			if i > 0 {
+				sb.WriteString(" OR ")
+			}
+			sb.WriteString("(")
+			for j := 0; j < i; j++ {
+				sb.WriteString(dialect.QuoteIdentifier(kp.sorts[j].column) + " = ? AND ")
+				args = append(args, values[j])
+			}
+			if kp.sorts[i].desc != kp.backward {
+				sb.WriteString(dialect.QuoteIdentifier(kp.sorts[i].column) + " < ?)")
+			} else {
+				sb.WriteString(dialect.QuoteIdentifier(kp.sorts[i].column) + " > ?)")
+			}
+			args = append(args, values[i])
+		}
+	}
+	// one more row than the page size is read (to detect whether there are further rows) - for a backward page, the rows
+	// are read in reverse order (and are reversed after reading)
+	kp.limiter = &keysetLimiter{limit: kp.keyset.Size}
+	sb.WriteString(" ORDER BY " + kp.orderBy(kp.backward, dialect))
+	return dialect.Limit(sb.String(), kp.keyset.Size+1, 0), args
+}
+
+// reversed returns whether the rows are read in reverse order (i.e. a backward page) - and must be reversed after reading
+func (kp *keysetPage) reversed() bool {
+	return kp != nil && kp.backward
+}
+
+func (kp *keysetPage) orderBy(reverse bool, dialect Dialect) string {
+	parts := make([]string, len(kp.sorts))
+	for i, s := range kp.sorts {
+		if s.desc != reverse {
+			parts[i] = dialect.QuoteIdentifier(s.column) + " DESC"
+		} else {
+			parts[i] = dialect.QuoteIdentifier(s.column) + " ASC"
+		}
+	}
+	return strings.Join(parts, ",")
+}
+
+// complete sets the next and previous cursors of the Keyset from the first and last rows read
+//
+// value is used to obtain the value of a sort property from a row
+func (kp *keysetPage) complete(count int, first, last any, value func(row any, sort keysetSort) (any, error)) (err error) {
+	kp.keyset.NextCursor, kp.keyset.PrevCursor = "", ""
+	if count == 0 {
+		return nil
+	}
+	hasNext, hasPrev := kp.cursor, kp.cursor
+	if kp.backward {
+		hasPrev = kp.limiter.reached
+	} else {
+		hasNext = kp.limiter.reached
+	}
+	if hasNext {
+		if kp.keyset.NextCursor, err = kp.encodeCursor(last, keysetForward, value); err != nil {
+			return err
+		}
+	}
+	if hasPrev {
+		kp.keyset.PrevCursor, err = kp.encodeCursor(first, keysetBackward, value)
+	}
+	return err
+}
+
+func (kp *keysetPage) encodeCursor(row any, direction string, value func(row any, sort keysetSort) (any, error)) (string, error) {
+	c := keysetCursor{Direction: direction, Keys: make([][2]string, len(kp.sorts))}
+	for i, s := range kp.sorts {
+		v, err := value(row, s)
+		if err != nil {
+			return "", err
+		}
+		if c.Keys[i], err = encodeKeysetValue(v); err != nil {
+			return "", err
+		}
+	}
+	data, err := json.Marshal(c)
+	if err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(data), nil
+}
+
+func decodeKeysetCursor(cursor string) (values []any, backward bool, err error) {
+	var data []byte
+	if data, err = base64.RawURLEncoding.DecodeString(cursor); err == nil {
+		c := keysetCursor{}
+		if err = json.Unmarshal(data, &c); err == nil && (c.Direction == keysetForward || c.Direction == keysetBackward) {
+			values = make([]any, len(c.Keys))
+			for i := 0; err == nil && i < len(c.Keys); i++ {
+				values[i], err = decodeKeysetValue(c.Keys[i])
+			}
+			if err == nil {
+				return values, c.Direction == keysetBackward, nil
+			}
+		}
+	}
+	return nil, false, &InvalidCursorError{}
+}
+
+// encodeKeysetValue encodes a cursor value with a type tag - so that the value is restored to the same type
+func encodeKeysetValue(v any) ([2]string, error) {
+	switch vt := v.(type) {
+	case nil:
+		return [2]string{"n", ""}, nil
+	case string:
+		return [2]string{"s", vt}, nil
+	case []byte:
+		return [2]string{"x", base64.StdEncoding.EncodeToString(vt)}, nil
+	case bool:
+		return [2]string{"b", strconv.FormatBool(vt)}, nil
+	case time.Time:
+		return [2]string{"t", vt.Format(time.RFC3339Nano)}, nil
+	case decimal.Decimal:
+		return [2]string{"d", vt.String()}, nil
+	case driver.Valuer:
+		dv, err := vt.Value()
+		if err != nil {
+			return [2]string{}, err
+		}
+		return encodeKeysetValue(dv)
+	}
+	rv := reflect.ValueOf(v)
+	switch rv.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return [2]string{"i", strconv.FormatInt(rv.Int(), 10)}, nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return [2]string{"i", strconv.FormatUint(rv.Uint(), 10)}, nil
+	case reflect.Float32, reflect.Float64:
+		return [2]string{"f", strconv.FormatFloat(rv.Float(), 'g', -1, 64)}, nil
+	case reflect.String:
+		return [2]string{"s", rv.String()}, nil
+	case reflect.Ptr:
+		if rv.IsNil() {
+			return [2]string{"n", ""}, nil
+		}
+		return encodeKeysetValue(rv.Elem().Interface())
+	}
+	return [2]string{}, fmt.Errorf("unsupported keyset value type %T", v)
+}
+
+func decodeKeysetValue(kv [2]string) (any, error) {
+	switch kv[0] {
+	case "n":
+		return nil, nil
+	case "s":
+		return kv[1], nil
+	case "x":
+		return base64.StdEncoding.DecodeString(kv[1])
+	case "b":
+		return strconv.ParseBool(kv[1])
+	case "t":
+		return time.Parse(time.RFC3339Nano, kv[1])
+	case "d":
+		return decimal.NewFromString(kv[1])
+	case "i":
+		if i, err := strconv.ParseInt(kv[1], 10, 64); err == nil {
+			return i, nil
+		}
+		return strconv.ParseUint(kv[1], 10, 64)
+	case "f":
+		return strconv.ParseFloat(kv[1], 64)
+	}
+	return nil, fmt.Errorf("unknown keyset value type '%s'", kv[0])
+}
+
+// keysetLimiter limits the rows read to the page size - and records whether there were more rows
+type keysetLimiter struct {
+	limit   int
+	reached bool
+}
+
+func (l *keysetLimiter) LimitReached(rowCount int) bool {
+	if rowCount > l.limit {
+		l.reached = true
+	}
+	return l.reached
+}
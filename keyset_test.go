@@ -0,0 +1,355 @@
+package columbus
+
+import (
+	"bytes"
+	"fmt"
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"math"
+	"testing"
+	"time"
+)
+
+func TestKeysetValues_RoundTrip(t *testing.T) {
+	now := time.Date(2025, 7, 22, 10, 11, 12, 13, time.UTC)
+	str := "ptr"
+	var nilPtr *string
+	testCases := []struct {
+		value  any
+		expect any
+	}{
+		{nil, nil},
+		{"abc", "abc"},
+		{[]byte("abc"), []byte("abc")},
+		{true, true},
+		{now, now},
+		{decimal.RequireFromString("1.23"), decimal.RequireFromString("1.23")},
+		{int64(1234567890123456789), int64(1234567890123456789)},
+		{int32(16), int64(16)},
+		{uint16(16), int64(16)},
+		{uint64(math.MaxUint64), uint64(math.MaxUint64)},
+		{1.5, 1.5},
+		{&str, "ptr"},
+		{nilPtr, nil},
+	}
+	for _, tc := range testCases {
+		kv, err := encodeKeysetValue(tc.value)
+		require.NoError(t, err)
+		v, err := decodeKeysetValue(kv)
+		require.NoError(t, err)
+		assert.Equal(t, tc.expect, v)
+	}
+	_, err := encodeKeysetValue(struct{}{})
+	require.Error(t, err)
+	_, err = decodeKeysetValue([2]string{"?", ""})
+	require.Error(t, err)
+}
+
+func TestDecodeKeysetCursor_Errors(t *testing.T) {
+	_, _, err := decodeKeysetCursor("not base64!")
+	require.Error(t, err)
+	require.Equal(t, "invalid keyset cursor", err.Error())
+	_, _, err = decodeKeysetCursor("bm90IGpzb24")
+	require.Error(t, err)
+	_, _, err = decodeKeysetCursor("eyJkIjoieCIsImsiOltdfQ")
+	require.Error(t, err)
+}
+
+func TestNewKeysetPage_Errors(t *testing.T) {
+	resolve := func(property string) string {
+		return property
+	}
+	_, _, err := newKeysetPage(&Keyset{Sort: []string{"id"}}, resolve)
+	require.Error(t, err)
+	require.Equal(t, "keyset size must be greater than zero", err.Error())
+	_, _, err = newKeysetPage(&Keyset{Size: 10}, resolve)
+	require.Error(t, err)
+	require.Equal(t, "keyset must have at least one sort property", err.Error())
+	_, _, err = newKeysetPage(&Keyset{Size: 10, Sort: []string{"id; DROP TABLE people"}}, resolve)
+	require.Error(t, err)
+	require.Equal(t, "invalid keyset sort property 'id; DROP TABLE people'", err.Error())
+	_, _, err = newKeysetPage(&Keyset{Size: 10, Sort: []string{"id"}, Cursor: "rubbish"}, resolve)
+	require.Error(t, err)
+	require.Equal(t, "invalid keyset cursor", err.Error())
+
+	kp, _, err := newKeysetPage(&Keyset{Size: 10, Sort: []string{"id"}}, resolve)
+	require.NoError(t, err)
+	cursor, err := kp.encodeCursor(map[string]any{"id": 1}, keysetForward, mapKeysetValue)
+	require.NoError(t, err)
+	_, _, err = newKeysetPage(&Keyset{Size: 10, Sort: []string{"name", "id"}, Cursor: cursor}, resolve)
+	require.Error(t, err)
+	require.Equal(t, "invalid keyset cursor", err.Error())
+}
+
+func TestKeysetPage_wrapQuery(t *testing.T) {
+	resolve := func(property string) string {
+		return property
+	}
+	kp, values, err := newKeysetPage(&Keyset{Size: 10, Sort: []string{"-created_at", "id"}}, resolve)
+	require.NoError(t, err)
+	q, args := kp.wrapQuery("SELECT * FROM people", values, defaultDialect)
+	require.Equal(t, "SELECT * FROM (SELECT * FROM people) _keyset ORDER BY created_at DESC,id ASC LIMIT 11", q)
+	require.Empty(t, args)
+
+	kp.backward = true
+	q, args = kp.wrapQuery("SELECT * FROM people", []any{"x", 1}, defaultDialect)
+	require.Equal(t, "SELECT * FROM (SELECT * FROM people) _keyset WHERE (created_at > ?) OR (created_at = ? AND id < ?) ORDER BY created_at ASC,id DESC LIMIT 11", q)
+	require.Equal(t, []any{"x", "x", 1}, args)
+
+	kp.backward = false
+	q, args = kp.wrapQuery("SELECT * FROM people", []any{"x", 1}, defaultDialect)
+	require.Equal(t, "SELECT * FROM (SELECT * FROM people) _keyset WHERE (created_at < ?) OR (created_at = ? AND id > ?) ORDER BY created_at DESC,id ASC LIMIT 11", q)
+	require.Equal(t, []any{"x", "x", 1}, args)
+}
+
+func TestMapper_Rows_Keyset(t *testing.T) {
+	m, err := newMapper("person_id,name", Query(`FROM people`), Mappings{"person_id": {PropertyName: "id"}})
+	require.NoError(t, err)
+
+	db, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+	require.NoError(t, err)
+	defer func() {
+		_ = db.Close()
+	}()
+	mock.ExpectQuery("SELECT * FROM (SELECT person_id,name FROM people WHERE tenant = ?) _keyset ORDER BY name ASC,person_id ASC LIMIT 3").
+		WithArgs("t1").
+		WillReturnRows(sqlmock.NewRows([]string{"person_id", "name"}).AddRow(int64(1), "a").AddRow(int64(2), "b").AddRow(int64(3), "c"))
+
+	keyset := &Keyset{Sort: []string{"name", "id"}, Size: 2}
+	rows, err := m.Rows(ctx, db, []any{"t1"}, Query(`FROM people`), AddClause(`WHERE tenant = ?`), keyset)
+	require.NoError(t, err)
+	require.NoError(t, mock.ExpectationsWereMet())
+	require.Len(t, rows, 2)
+	require.NotEmpty(t, keyset.NextCursor)
+	require.Empty(t, keyset.PrevCursor)
+
+	// next page...
+	mock.ExpectQuery("SELECT * FROM (SELECT person_id,name FROM people WHERE tenant = ?) _keyset WHERE (name > ?) OR (name = ? AND person_id > ?) ORDER BY name ASC,person_id ASC LIMIT 3").
+		WithArgs("t1", "b", "b", int64(2)).
+		WillReturnRows(sqlmock.NewRows([]string{"person_id", "name"}).AddRow(int64(3), "c"))
+	keyset.Cursor = keyset.NextCursor
+	rows, err = m.Rows(ctx, db, []any{"t1"}, AddClause(`WHERE tenant = ?`), keyset)
+	require.NoError(t, err)
+	require.NoError(t, mock.ExpectationsWereMet())
+	require.Len(t, rows, 1)
+	require.Empty(t, keyset.NextCursor)
+	require.NotEmpty(t, keyset.PrevCursor)
+
+	// previous page (rows are read in reverse order)...
+	prevCursor := keyset.PrevCursor
+	mock.ExpectQuery("SELECT * FROM (SELECT person_id,name FROM people) _keyset WHERE (name < ?) OR (name = ? AND person_id < ?) ORDER BY name DESC,person_id DESC LIMIT 3").
+		WithArgs("c", "c", int64(3)).
+		WillReturnRows(sqlmock.NewRows([]string{"person_id", "name"}).AddRow(int64(2), "b").AddRow(int64(1), "a"))
+	keyset.Cursor = prevCursor
+	rows, err = m.Rows(ctx, db, nil, keyset)
+	require.NoError(t, err)
+	require.NoError(t, mock.ExpectationsWereMet())
+	require.Len(t, rows, 2)
+	require.Equal(t, int64(1), rows[0]["id"])
+	require.Equal(t, int64(2), rows[1]["id"])
+	require.NotEmpty(t, keyset.NextCursor)
+	// page starts at the first row - so no previous page...
+	require.Empty(t, keyset.PrevCursor)
+
+	// previous page with more rows...
+	mock.ExpectQuery("SELECT * FROM (SELECT person_id,name FROM people) _keyset WHERE (name < ?) OR (name = ? AND person_id < ?) ORDER BY name DESC,person_id DESC LIMIT 3").
+		WithArgs("c", "c", int64(3)).
+		WillReturnRows(sqlmock.NewRows([]string{"person_id", "name"}).AddRow(int64(2), "b").AddRow(int64(1), "a").AddRow(int64(0), "_"))
+	keyset.Cursor = prevCursor
+	rows, err = m.Rows(ctx, db, nil, keyset)
+	require.NoError(t, err)
+	require.NoError(t, mock.ExpectationsWereMet())
+	require.Len(t, rows, 2)
+	require.Equal(t, int64(1), rows[0]["id"])
+	require.NotEmpty(t, keyset.NextCursor)
+	require.NotEmpty(t, keyset.PrevCursor)
+	values, backward, err := decodeKeysetCursor(keyset.PrevCursor)
+	require.NoError(t, err)
+	require.True(t, backward)
+	require.Equal(t, []any{"a", int64(1)}, values)
+
+	// no rows...
+	mock.ExpectQuery("SELECT * FROM (SELECT person_id,name FROM people) _keyset ORDER BY name ASC,person_id ASC LIMIT 3").
+		WillReturnRows(sqlmock.NewRows([]string{"person_id", "name"}))
+	keyset.Cursor = ""
+	rows, err = m.Rows(ctx, db, nil, keyset)
+	require.NoError(t, err)
+	require.Len(t, rows, 0)
+	require.Empty(t, keyset.NextCursor)
+	require.Empty(t, keyset.PrevCursor)
+}
+
+func TestMapper_Rows_Keyset_Errors(t *testing.T) {
+	m, err := newMapper("id,name", Query(`FROM people`))
+	require.NoError(t, err)
+
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer func() {
+		_ = db.Close()
+	}()
+	_, err = m.Rows(ctx, db, nil, &Keyset{Sort: []string{"name"}})
+	require.Error(t, err)
+
+	mock.ExpectQuery("").WillReturnRows(sqlmock.NewRows([]string{"id", "name"}).AddRow(int64(1), "a").AddRow(int64(2), "b"))
+	_, err = m.Rows(ctx, db, nil, &Keyset{Sort: []string{"name"}, Size: 1}, AllowedProperties{"id": nil})
+	require.Error(t, err)
+	require.Equal(t, "keyset sort property 'name' not present in row", err.Error())
+}
+
+func TestMapper_Keyset_UnsupportedMethods(t *testing.T) {
+	m, err := newMapper("id,name", Query(`FROM people`))
+	require.NoError(t, err)
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer func() {
+		_ = db.Close()
+	}()
+	keyset := &Keyset{Sort: []string{"id"}, Size: 2}
+	const msg = "keyset can only be used with Rows or WriteRows"
+
+	_, err = m.FirstRow(ctx, db, nil, keyset)
+	require.EqualError(t, err, msg)
+	_, err = m.ExactlyOneRow(ctx, db, nil, keyset)
+	require.EqualError(t, err, msg)
+	err = m.WriteFirstRow(ctx, &bytes.Buffer{}, db, nil, keyset)
+	require.EqualError(t, err, msg)
+	err = m.WriteExactlyOneRow(ctx, &bytes.Buffer{}, db, nil, keyset)
+	require.EqualError(t, err, msg)
+	err = m.Iterate(ctx, db, nil, func(row map[string]any) (bool, error) {
+		return true, nil
+	}, keyset)
+	require.EqualError(t, err, msg)
+	for range m.Iterator(ctx, db, nil, keyset) {
+		t.Fatal("unexpected row")
+	}
+	for _, err = range m.ErrIterator(ctx, db, nil, keyset) {
+		require.EqualError(t, err, msg)
+	}
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestStructMapper_Keyset_UnsupportedMethods(t *testing.T) {
+	type person struct {
+		Id   int64  `sql:"id"`
+		Name string `sql:"name"`
+	}
+	m, err := NewStructMapper[person]("id,name", Query(`FROM people`))
+	require.NoError(t, err)
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer func() {
+		_ = db.Close()
+	}()
+	keyset := &Keyset{Sort: []string{"id"}, Size: 2}
+	const msg = "keyset can only be used with Rows or WriteRows"
+
+	_, err = m.FirstRow(ctx, db, nil, keyset)
+	require.EqualError(t, err, msg)
+	_, err = m.ExactlyOneRow(ctx, db, nil, keyset)
+	require.EqualError(t, err, msg)
+	err = m.Iterate(ctx, db, nil, func(row person) (bool, error) {
+		return true, nil
+	}, keyset)
+	require.EqualError(t, err, msg)
+	for range m.Iterator(ctx, db, nil, keyset) {
+		t.Fatal("unexpected row")
+	}
+	for _, err = range m.ErrIterator(ctx, db, nil, keyset) {
+		require.EqualError(t, err, msg)
+	}
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestMapper_WriteRows_Keyset(t *testing.T) {
+	m, err := newMapper("id,name", Query(`FROM people`))
+	require.NoError(t, err)
+
+	db, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+	require.NoError(t, err)
+	defer func() {
+		_ = db.Close()
+	}()
+	mock.ExpectQuery("SELECT * FROM (SELECT id,name FROM people) _keyset ORDER BY id DESC LIMIT 2").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "name"}).AddRow(int64(2), "b").AddRow(int64(1), "a"))
+
+	keyset := &Keyset{Sort: []string{"-id"}, Size: 1}
+	var buffer bytes.Buffer
+	err = m.WriteRows(ctx, &buffer, db, nil, keyset)
+	require.NoError(t, err)
+	require.NoError(t, mock.ExpectationsWereMet())
+	require.Equal(t, `[{"id":2,"name":"b"}`+"\n"+`]`, buffer.String())
+	require.NotEmpty(t, keyset.NextCursor)
+	values, backward, err := decodeKeysetCursor(keyset.NextCursor)
+	require.NoError(t, err)
+	require.False(t, backward)
+	require.Equal(t, []any{int64(2)}, values)
+
+	// previous page is written in order...
+	mock.ExpectQuery("SELECT * FROM (SELECT id,name FROM people) _keyset WHERE (id > ?) ORDER BY id ASC LIMIT 3").
+		WithArgs(int64(1)).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "name"}).AddRow(int64(2), "b").AddRow(int64(3), "c"))
+	keyset = &Keyset{Sort: []string{"-id"}, Size: 2, Cursor: mustEncodeTestCursor(t, int64(1))}
+	buffer.Reset()
+	err = m.WriteRows(ctx, &buffer, db, nil, keyset)
+	require.NoError(t, err)
+	require.NoError(t, mock.ExpectationsWereMet())
+	require.Equal(t, `[{"id":3,"name":"c"}`+"\n"+`,{"id":2,"name":"b"}`+"\n"+`]`, buffer.String())
+	require.NotEmpty(t, keyset.NextCursor)
+	require.Empty(t, keyset.PrevCursor)
+}
+
+func mustEncodeTestCursor(t *testing.T, values ...any) string {
+	kp := &keysetPage{sorts: make([]keysetSort, len(values))}
+	row := make(map[string]any, len(values))
+	for i, v := range values {
+		kp.sorts[i].property = fmt.Sprintf("p%d", i)
+		row[kp.sorts[i].property] = v
+	}
+	cursor, err := kp.encodeCursor(row, keysetBackward, mapKeysetValue)
+	require.NoError(t, err)
+	return cursor
+}
+
+func TestStructMapper_Rows_Keyset(t *testing.T) {
+	db, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+	require.NoError(t, err)
+	defer func() {
+		_ = db.Close()
+	}()
+	sm, err := NewStructMapper[testStruct](`foo,bar`,
+		Query("FROM table"),
+		UseTagName("db"),
+	)
+	require.NoError(t, err)
+	mock.ExpectQuery("SELECT * FROM (SELECT foo,bar FROM table) _keyset ORDER BY foo ASC LIMIT 2").
+		WillReturnRows(sqlmock.NewRows([]string{"foo", "bar"}).AddRow("foo1", "bar1").AddRow("foo2", "bar2"))
+
+	keyset := &Keyset{Sort: []string{"foo"}, Size: 1}
+	rows, err := sm.Rows(ctx, db, nil, keyset)
+	require.NoError(t, err)
+	require.NoError(t, mock.ExpectationsWereMet())
+	require.Len(t, rows, 1)
+	values, _, err := decodeKeysetCursor(keyset.NextCursor)
+	require.NoError(t, err)
+	require.Equal(t, []any{"foo1"}, values)
+
+	mock.ExpectQuery("SELECT * FROM (SELECT foo,bar FROM table) _keyset WHERE (foo > ?) ORDER BY foo ASC LIMIT 2").
+		WithArgs("foo1").
+		WillReturnRows(sqlmock.NewRows([]string{"foo", "bar"}).AddRow("foo2", "bar2"))
+	keyset.Cursor = keyset.NextCursor
+	rows, err = sm.Rows(ctx, db, nil, keyset)
+	require.NoError(t, err)
+	require.NoError(t, mock.ExpectationsWereMet())
+	require.Len(t, rows, 1)
+	require.Empty(t, keyset.NextCursor)
+	require.NotEmpty(t, keyset.PrevCursor)
+
+	mock.ExpectQuery("SELECT * FROM (SELECT foo,bar FROM table) _keyset ORDER BY baz ASC LIMIT 2").
+		WillReturnRows(sqlmock.NewRows([]string{"foo", "bar"}).AddRow("foo1", "bar1").AddRow("foo2", "bar2"))
+	_, err = sm.Rows(ctx, db, nil, &Keyset{Sort: []string{"baz"}, Size: 1})
+	require.Error(t, err)
+	require.Equal(t, "keyset sort property 'baz' not mapped to field", err.Error())
+}
@@ -3,10 +3,11 @@ package columbus
 import (
 	"context"
 	"database/sql"
-	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
+	"iter"
+	"slices"
 	"strings"
 	"sync"
 )
@@ -15,46 +16,83 @@ import (
 type Mapper interface {
 	// Rows reads all rows and maps them into a slice of `map[string]any`
 	//
-	// options can be any of Query, AddClause, StructPostProcessor[T], ErrorTranslator or Limiter
+	// options can be any of Query, AddClause, AddClauseWithArgs, Mappings, PropertyExclusions, PropertyExcluder (e.g. *Fields), RowPostProcessor,
+	// SubQuery, ErrorTranslator, Limiter, BatchSize, SubQueryConcurrency, Dialect, Sort, SortableProperties, *Keyset, RowLimit or Grouping
 	Rows(ctx context.Context, sqli SqlInterface, args []any, options ...any) ([]map[string]any, error)
 	// FirstRow reads just the first row and maps it into a `map[string]any`
 	//
 	// if there are no rows, returns nil
 	//
-	// options can be any of Query, AddClause, StructPostProcessor[T], ErrorTranslator or Limiter (ignored)
+	// options can be any of Query, AddClause, AddClauseWithArgs, Mappings, PropertyExclusions, PropertyExcluder (e.g. *Fields), RowPostProcessor,
+	// SubQuery, ErrorTranslator, BatchSize, SubQueryConcurrency, Dialect, Sort, SortableProperties, Grouping or Limiter (ignored)
 	FirstRow(ctx context.Context, sqli SqlInterface, args []any, options ...any) (map[string]any, error)
 	// ExactlyOneRow reads exactly one row and maps it into a `map[string]any`
 	//
 	// if there are no rows, returns error sql.ErrNoRows
 	//
-	// options can be any of Query, AddClause, StructPostProcessor[T], ErrorTranslator or Limiter (ignored)
+	// options can be any of Query, AddClause, AddClauseWithArgs, Mappings, PropertyExclusions, PropertyExcluder (e.g. *Fields), RowPostProcessor,
+	// SubQuery, ErrorTranslator, BatchSize, SubQueryConcurrency, Dialect, Sort, SortableProperties, Grouping or Limiter (ignored)
 	ExactlyOneRow(ctx context.Context, sqli SqlInterface, args []any, options ...any) (map[string]any, error)
-	// WriteRows reads all rows and writes them as JSON to the supplied writer
+	// WriteRows reads all rows and writes them to the supplied writer - as a JSON array, unless a RowEncoding (or Accept) option is passed
 	//
-	// options can be any of Query, AddClause, StructPostProcessor[T], ErrorTranslator or Limiter
+	// options can be any of Query, AddClause, AddClauseWithArgs, Mappings, PropertyExclusions, PropertyExcluder (e.g. *Fields), RowPostProcessor,
+	// SubQuery, ErrorTranslator, Limiter, BatchSize, SubQueryConcurrency, Dialect, Sort, SortableProperties, *Keyset, RowLimit, Grouping,
+	// RowEncoding or Accept
 	WriteRows(ctx context.Context, writer io.Writer, sqli SqlInterface, args []any, options ...any) error
-	// WriteFirstRow reads just the first row and writes it as JSON to the supplied writer
+	// Page reads a page of rows (page numbers start at 1) - returning the rows along with the total count and page metadata
+	//
+	// the total count is obtained by executing a count query using the same query (i.e. `SELECT COUNT(*) FROM (<query>) _count`)
+	// or, if the PageCountWindow option is passed, using a `COUNT(*) OVER()` window function
+	//
+	// options can be any of Query, AddClause, AddClauseWithArgs, Mappings, PropertyExclusions, PropertyExcluder (e.g. *Fields), RowPostProcessor,
+	// SubQuery, ErrorTranslator, Limiter, BatchSize, SubQueryConcurrency, Dialect, Sort, SortableProperties or PageCount
+	Page(ctx context.Context, sqli SqlInterface, args []any, page int, size int, options ...any) (*PageResult, error)
+	// WritePage reads a page of rows (page numbers start at 1) and writes them to the supplied writer - along with the page metadata
+	//
+	// the JSON written is an object with properties "rows", "page", "size", "total" and "pages" (see PageResult)
+	//
+	// only a RowEncoding that is a PageEncoding (i.e. JsonArray, MsgPack or CBOR) can be used to write a page
+	//
+	// options can be any of Query, AddClause, AddClauseWithArgs, Mappings, PropertyExclusions, PropertyExcluder (e.g. *Fields), RowPostProcessor,
+	// SubQuery, ErrorTranslator, Limiter, BatchSize, SubQueryConcurrency, Dialect, Sort, SortableProperties, PageCount,
+	// RowEncoding or Accept
+	WritePage(ctx context.Context, writer io.Writer, sqli SqlInterface, args []any, page int, size int, options ...any) error
+	// WriteFirstRow reads just the first row and writes it to the supplied writer - as a JSON object, unless a RowEncoding (or Accept) option is passed
 	//
 	// if there are no rows, nothing is written to the writer
 	//
-	// options can be any of Query, AddClause, StructPostProcessor[T], ErrorTranslator or Limiter (ignored)
+	// options can be any of Query, AddClause, AddClauseWithArgs, Mappings, PropertyExclusions, PropertyExcluder (e.g. *Fields), RowPostProcessor,
+	// SubQuery, ErrorTranslator, BatchSize, SubQueryConcurrency, Dialect, Sort, SortableProperties, Grouping,
+	// Limiter (ignored), RowEncoding or Accept
 	WriteFirstRow(ctx context.Context, writer io.Writer, sqli SqlInterface, args []any, options ...any) error
-	// WriteExactlyOneRow reads exactly one row and writes it as JSON to the supplied writer
+	// WriteExactlyOneRow reads exactly one row and writes it to the supplied writer - as a JSON object, unless a RowEncoding (or Accept) option is passed
 	//
 	// if there are no rows, returns error sql.ErrNoRows (and nothing is written to the writer)
 	//
-	// options can be any of Query, AddClause, StructPostProcessor[T], ErrorTranslator or Limiter (ignored)
+	// options can be any of Query, AddClause, AddClauseWithArgs, Mappings, PropertyExclusions, PropertyExcluder (e.g. *Fields), RowPostProcessor,
+	// SubQuery, ErrorTranslator, BatchSize, SubQueryConcurrency, Dialect, Sort, SortableProperties, Grouping,
+	// Limiter (ignored), RowEncoding or Accept
 	WriteExactlyOneRow(ctx context.Context, writer io.Writer, sqli SqlInterface, args []any, options ...any) error
 	// Iterate iterates over the rows and calls the supplied handler with each row
 	//
 	// iteration stops at the end of rows - or an error is encountered - or the supplied handler returns false for `cont` (continue)
 	//
-	// options can be any of Query, AddClause, StructPostProcessor[T], ErrorTranslator or Limiter (ignored)
+	// options can be any of Query, AddClause, AddClauseWithArgs, Mappings, PropertyExclusions, PropertyExcluder (e.g. *Fields), RowPostProcessor,
+	// SubQuery, ErrorTranslator, BatchSize, SubQueryConcurrency, Dialect, Sort, SortableProperties, RowLimit, Grouping or Limiter (ignored)
 	Iterate(ctx context.Context, sqli SqlInterface, args []any, handler func(row map[string]any) (cont bool, err error), options ...any) error
 	// Iterator return an iterator that can be ranged over
 	//
-	// options can be any of Query, AddClause, StructPostProcessor[T], ErrorTranslator or Limiter
+	// options can be any of Query, AddClause, AddClauseWithArgs, Mappings, PropertyExclusions, PropertyExcluder (e.g. *Fields), RowPostProcessor,
+	// SubQuery, ErrorTranslator, Limiter, BatchSize, SubQueryConcurrency, Dialect, Sort, SortableProperties, RowLimit or Grouping
 	Iterator(ctx context.Context, sqli SqlInterface, args []any, options ...any) func(func(int, map[string]any) bool)
+	// ErrIterator returns an iterator that can be ranged over - yielding each row and any error
+	//
+	// the query is executed when the iterator is ranged over and iteration stops after an error is yielded (or when
+	// the range loop is exited) - errors are translated with any ErrorTranslator
+	//
+	// options can be any of Query, AddClause, AddClauseWithArgs, Mappings, PropertyExclusions, PropertyExcluder (e.g. *Fields), RowPostProcessor,
+	// SubQuery, ErrorTranslator, Limiter, BatchSize, SubQueryConcurrency, Dialect, Sort, SortableProperties, RowLimit or Grouping
+	ErrIterator(ctx context.Context, sqli SqlInterface, args []any, options ...any) iter.Seq2[map[string]any, error]
 	// Extend creates a new Mapper adding the specified columns, mappings and options
 	Extend(addColumns []string, mappings Mappings, options ...any) (Mapper, error)
 }
@@ -64,16 +102,25 @@ type Mapper interface {
 // by default, Mapper will convert float/numeric/decimal columns to decimal.Decimal
 type UseDecimals bool
 
+// BatchSize is an option that determines how many rows are collected before batch sub-queries (see NewBatchSubQuery) are executed
+//
+// a BatchSize of zero (or less) means all rows are collected before batch sub-queries are executed
+//
+// by default, Mapper uses a batch size of 100 - the option can be passed to NewMapper or any of the row reading methods
+type BatchSize int
+
+const defaultBatchSize = 100
+
 // NewMapper creates a new row mapper
 //
-// options can be any of: Mappings, Query, RowPostProcessor, SubQuery or UseDecimals
+// options can be any of: Mappings, Query, RowPostProcessor, SubQuery, UseDecimals, BatchSize, SubQueryConcurrency, ColumnsCacheSize, Dialect, SortableProperties, RowEncoding or Grouping
 func NewMapper[T string | []string](columns T, options ...any) (Mapper, error) {
 	return newMapper(columns, options...)
 }
 
 // MustNewMapper is the same as NewMapper, except it panics on error
 //
-// options can be any of: Mappings, Query, RowPostProcessor, SubQuery or UseDecimals
+// options can be any of: Mappings, Query, RowPostProcessor, SubQuery, UseDecimals, BatchSize, SubQueryConcurrency, ColumnsCacheSize, Dialect, SortableProperties, RowEncoding or Grouping
 func MustNewMapper[T string | []string](columns T, options ...any) Mapper {
 	m, err := NewMapper[T](columns, options...)
 	if err != nil {
@@ -87,6 +134,7 @@ func newMapper(cols any, options ...any) (*mapper, error) {
 		mappings:        Mappings{},
 		errorTranslator: defaultErrorTranslator,
 		useDecimals:     true,
+		batchSize:       defaultBatchSize,
 	}
 	switch ct := cols.(type) {
 	case string:
@@ -103,13 +151,20 @@ func newMapper(cols any, options ...any) (*mapper, error) {
 type mapper struct {
 	mutex             sync.RWMutex
 	cols              string
-	columnsInfo       *columnsInfo
+	columnsCache      *columnsCache
+	columnsCacheSize  int
 	mappings          Mappings
 	rowPostProcessors []RowPostProcessor
 	rowSubQueries     []SubQuery
 	defaultQuery      *Query
 	useDecimals       bool
 	errorTranslator   ErrorTranslator
+	batchSize         int
+	concurrency       SubQueryConcurrency
+	dialect           Dialect
+	sortable          SortableProperties
+	encoding          RowEncoding
+	grouping          *Grouping
 	// subQuery is set by parent sub-query
 	subQuery internalSubQuery
 	subPath  []string
@@ -118,66 +173,90 @@ type mapper struct {
 var _ Mapper = (*mapper)(nil)
 
 func (m *mapper) Rows(ctx context.Context, sqli SqlInterface, args []any, options ...any) (result []map[string]any, err error) {
-	query, mappings, postProcesses, subQueries, exclusions, limiter, errTranslator, err := m.rowMapOptions(options...)
+	opts, err := m.rowMapOptions(options...)
 	if err != nil {
 		return nil, err
 	}
-	rows, err := sqli.QueryContext(ctx, query, args...)
+	return m.rows(ctx, sqli, args, opts)
+}
+
+func (m *mapper) rows(ctx context.Context, sqli SqlInterface, args []any, opts *mapOptions) (result []map[string]any, err error) {
+	sqli = opts.sqlInterface(sqli)
+	rows, err := opts.queryContext(ctx, sqli, args)
 	if err != nil {
-		return nil, translateError(err, errTranslator)
+		return nil, translateError(err, opts.errorTranslator)
 	}
 	defer func() {
 		_ = rows.Close()
 	}()
 	var colsReader *columnsReader
-	if colsReader, err = m.mapColumns(rows, mappings); err == nil {
+	if colsReader, err = m.mapColumns(rows, opts); err == nil {
 		result = make([]map[string]any, 0)
-		var row map[string]any
+		batch := opts.newBatch(ctx, sqli)
+		var completed []map[string]any
 		rowCount := 0
-		for rows.Next() {
+		for err == nil && rows.Next() {
 			rowCount++
-			if limiter.LimitReached(rowCount) {
+			if batch.limitReached(rowCount) {
 				break
 			}
-			if row, err = m.mapRow(ctx, sqli, rows, colsReader, mappings, postProcesses, subQueries, exclusions); err == nil {
-				result = append(result, row)
-			} else {
-				return nil, translateError(err, errTranslator)
+			if completed, err = m.readRow(ctx, sqli, rows, colsReader, opts, batch); err == nil {
+				result = append(result, completed...)
+			}
+		}
+		if err == nil {
+			if completed, err = batch.flush(); err == nil {
+				result = append(result, completed...)
+			}
+		}
+		if err == nil && opts.keyset.reversed() {
+			slices.Reverse(result)
+		}
+		if err == nil && opts.keyset != nil {
+			var firstRow, lastRow map[string]any
+			if len(result) > 0 {
+				firstRow, lastRow = result[0], result[len(result)-1]
 			}
+			err = opts.keyset.complete(len(result), firstRow, lastRow, mapKeysetValue)
+		}
+		if err != nil {
+			return nil, translateError(err, opts.errorTranslator)
 		}
 	}
-	return result, translateError(err, errTranslator)
+	return result, translateError(err, opts.errorTranslator)
 }
 
 func (m *mapper) FirstRow(ctx context.Context, sqli SqlInterface, args []any, options ...any) (result map[string]any, err error) {
-	query, mappings, postProcesses, subQueries, exclusions, _, errTranslator, err := m.rowMapOptions(options...)
+	opts, err := m.rowMapOptionsNoKeyset(options...)
 	if err != nil {
 		return nil, err
 	}
-	rows, err := sqli.QueryContext(ctx, query, args...)
+	sqli = opts.sqlInterface(sqli)
+	rows, err := opts.queryContext(ctx, sqli, args)
 	if err != nil {
-		return nil, translateError(err, errTranslator)
+		return nil, translateError(err, opts.errorTranslator)
 	}
 	defer func() {
 		_ = rows.Close()
 	}()
 	if rows.Next() {
 		var colsReader *columnsReader
-		if colsReader, err = m.mapColumns(rows, mappings); err == nil {
-			result, err = m.mapRow(ctx, sqli, rows, colsReader, mappings, postProcesses, subQueries, exclusions)
+		if colsReader, err = m.mapColumns(rows, opts); err == nil {
+			result, err = m.mapCompleteRow(ctx, sqli, rows, colsReader, opts)
 		}
 	}
-	return result, translateError(err, errTranslator)
+	return result, translateError(err, opts.errorTranslator)
 }
 
 func (m *mapper) ExactlyOneRow(ctx context.Context, sqli SqlInterface, args []any, options ...any) (result map[string]any, err error) {
-	query, mappings, postProcesses, subQueries, exclusions, _, errTranslator, err := m.rowMapOptions(options...)
+	opts, err := m.rowMapOptionsNoKeyset(options...)
 	if err != nil {
 		return nil, err
 	}
-	rows, err := sqli.QueryContext(ctx, query, args...)
+	sqli = opts.sqlInterface(sqli)
+	rows, err := opts.queryContext(ctx, sqli, args)
 	if err != nil {
-		return nil, translateError(err, errTranslator)
+		return nil, translateError(err, opts.errorTranslator)
 	}
 	defer func() {
 		_ = rows.Close()
@@ -185,85 +264,220 @@ func (m *mapper) ExactlyOneRow(ctx context.Context, sqli SqlInterface, args []an
 	err = sql.ErrNoRows
 	if rows.Next() {
 		var colsReader *columnsReader
-		if colsReader, err = m.mapColumns(rows, mappings); err == nil {
-			result, err = m.mapRow(ctx, sqli, rows, colsReader, mappings, postProcesses, subQueries, exclusions)
+		if colsReader, err = m.mapColumns(rows, opts); err == nil {
+			result, err = m.mapCompleteRow(ctx, sqli, rows, colsReader, opts)
 		}
 	}
-	return result, translateError(err, errTranslator)
+	return result, translateError(err, opts.errorTranslator)
 }
 
 func (m *mapper) WriteRows(ctx context.Context, writer io.Writer, sqli SqlInterface, args []any, options ...any) (err error) {
-	query, mappings, postProcesses, subQueries, exclusions, limiter, errTranslator, err := m.rowMapOptions(options...)
+	opts, err := m.rowMapOptions(options...)
 	if err != nil {
 		return err
 	}
-	rows, err := sqli.QueryContext(ctx, query, args...)
+	return m.writeRows(ctx, opts.encoding.NewEncoder(writer), sqli, args, opts)
+}
+
+func (m *mapper) writeRows(ctx context.Context, enc RowEncoder, sqli SqlInterface, args []any, opts *mapOptions) (err error) {
+	sqli = opts.sqlInterface(sqli)
+	rows, err := opts.queryContext(ctx, sqli, args)
 	if err != nil {
-		return translateError(err, errTranslator)
+		return translateError(err, opts.errorTranslator)
 	}
 	defer func() {
 		_ = rows.Close()
 	}()
 	var colsReader *columnsReader
-	if colsReader, err = m.mapColumns(rows, mappings); err == nil {
-		var row map[string]any
-		if _, err = writer.Write([]byte("[")); err == nil {
-			jw := json.NewEncoder(writer)
-			first := true
+	if colsReader, err = m.mapColumns(rows, opts); err == nil {
+		if err = enc.Begin(m.encodeInfo(colsReader, opts, false)); err == nil {
+			var firstRow, lastRow map[string]any
+			written := 0
+			var reversed []map[string]any
+			emit := func(completed []map[string]any) (err error) {
+				for i := 0; err == nil && i < len(completed); i++ {
+					if written == 0 {
+						firstRow = completed[i]
+					}
+					err = enc.Row(completed[i])
+					lastRow = completed[i]
+					written++
+				}
+				return err
+			}
+			write := func(completed []map[string]any) error {
+				if opts.keyset.reversed() {
+					reversed = append(reversed, completed...)
+					return nil
+				}
+				return emit(completed)
+			}
+			batch := opts.newBatch(ctx, sqli)
+			var completed []map[string]any
 			rowCount := 0
-			for rows.Next() && err == nil {
+			for err == nil && rows.Next() {
 				rowCount++
-				if limiter.LimitReached(rowCount) {
+				if batch.limitReached(rowCount) {
 					break
 				}
-				if row, err = m.mapRow(ctx, sqli, rows, colsReader, mappings, postProcesses, subQueries, exclusions); err == nil {
-					if !first {
-						_, err = writer.Write([]byte(","))
-					}
-					if err == nil {
-						err = jw.Encode(row)
-						first = false
-					}
+				if completed, err = m.readRow(ctx, sqli, rows, colsReader, opts, batch); err == nil {
+					err = write(completed)
 				}
 			}
+			if err == nil {
+				if completed, err = batch.flush(); err == nil {
+					err = write(completed)
+				}
+			}
+			if err == nil && len(reversed) > 0 {
+				slices.Reverse(reversed)
+				err = emit(reversed)
+			}
+			if err == nil && opts.keyset != nil {
+				err = opts.keyset.complete(written, firstRow, lastRow, mapKeysetValue)
+			}
+			if eErr := enc.End(); err == nil {
+				err = eErr
+			}
+		}
+	}
+	return translateError(err, opts.errorTranslator)
+}
+
+// writeRow writes a single row using the encoding
+func (m *mapper) writeRow(writer io.Writer, cols *columnsReader, opts *mapOptions, row map[string]any) (err error) {
+	enc := opts.encoding.NewEncoder(writer)
+	if err = enc.Begin(m.encodeInfo(cols, opts, true)); err == nil {
+		err = enc.Row(row)
+		if eErr := enc.End(); err == nil {
+			err = eErr
+		}
+	}
+	return err
+}
+
+// encodeInfo builds the EncodeInfo - the property names are resolved from the columns (using the mappings) and the
+// sub-queries and row post processors
+func (m *mapper) encodeInfo(cols *columnsReader, opts *mapOptions, single bool) EncodeInfo {
+	result := EncodeInfo{
+		Single:     single,
+		Properties: make([]string, 0, cols.count),
+		Columns:    make([]ColumnInfo, 0, cols.count),
+	}
+	var grouped *groupColumns
+	if opts.grouping != nil {
+		grouped, _ = opts.groupColumns(cols, m.subPath)
+	}
+	for i, name := range cols.names {
+		if (grouped != nil && grouped.owners[i] != -1) || (opts.pageWindow && name == pageTotalProperty) {
+			continue
+		}
+		if property, mapping, ok := opts.columnProperty(name, name, m.subPath); ok {
+			result.Properties = append(result.Properties, property)
+			result.Columns = append(result.Columns, cols.info.columnInfo(i, property, mapping))
+		}
+	}
+	if grouped != nil {
+		for _, spec := range grouped.groups {
+			if !spec.excluded {
+				result.Properties = append(result.Properties, spec.Property)
+				result.Nested = append(result.Nested, NestedInfo{
+					Property:   spec.Property,
+					Array:      true,
+					Properties: opts.groupProperties(cols, grouped, spec, m.subPath),
+				})
+			}
+		}
+	}
+	for _, sq := range opts.subQueries {
+		if sq != nil && sq.ProvidesProperty() != "" && !opts.exclusions.Exclude(sq.ProvidesProperty(), opts.subPath) {
+			result.Properties = append(result.Properties, sq.ProvidesProperty())
+			switch sq.(type) {
+			case *sliceSubQuery, *sliceBatchSubQuery:
+				result.Nested = append(result.Nested, NestedInfo{Property: sq.ProvidesProperty(), Array: true})
+			case *objectSubQuery, *exactObjectSubQuery, *objectBatchSubQuery:
+				result.Nested = append(result.Nested, NestedInfo{Property: sq.ProvidesProperty()})
+			}
+		}
+	}
+	for _, rp := range opts.postProcesses {
+		if rp != nil && rp.ProvidesProperty() != "" && !opts.exclusions.Exclude(rp.ProvidesProperty(), opts.subPath) {
+			result.Properties = append(result.Properties, rp.ProvidesProperty())
+		}
+	}
+	return result
+}
+
+// columnProperty resolves the (dot notation) property name for a column - using the mappings - ok is false if the property is excluded
+func (o *mapOptions) columnProperty(col string, name string, path []string) (property string, mapping *Mapping, ok bool) {
+	var mappingPath []string
+	if mp, found := o.mappings[col]; found {
+		mapping = &mp
+		if mp.PropertyName != "" {
+			name = mp.PropertyName
+		}
+		mappingPath = mp.Path
+	}
+	if o.exclusions.Exclude(name, append(path[:len(path):len(path)], mappingPath...)) {
+		return "", nil, false
+	}
+	return strings.Join(append(mappingPath[:len(mappingPath):len(mappingPath)], name), "."), mapping, true
+}
+
+// groupProperties resolves the (non-excluded) property names of the child rows of a group - the properties of the group
+// columns followed by the properties of any nested groups
+func (o *mapOptions) groupProperties(cols *columnsReader, gc *groupColumns, spec *groupSpec, subPath []string) []string {
+	result := make([]string, 0)
+	path := append(subPath[:len(subPath):len(subPath)], spec.path...)
+	for i, col := range cols.names {
+		if gc.owners[i] == spec.index {
+			if property, _, ok := o.columnProperty(col, gc.names[i], path); ok {
+				result = append(result, property)
+			}
 		}
-		_, err = writer.Write([]byte("]"))
 	}
-	return translateError(err, errTranslator)
+	for _, child := range spec.children {
+		if !child.excluded {
+			result = append(result, child.Property)
+		}
+	}
+	return result
 }
 
 func (m *mapper) WriteFirstRow(ctx context.Context, writer io.Writer, sqli SqlInterface, args []any, options ...any) (err error) {
-	query, mappings, postProcesses, subQueries, exclusions, _, errTranslator, err := m.rowMapOptions(options...)
+	opts, err := m.rowMapOptionsNoKeyset(options...)
 	if err != nil {
 		return err
 	}
-	rows, err := sqli.QueryContext(ctx, query, args...)
+	sqli = opts.sqlInterface(sqli)
+	rows, err := opts.queryContext(ctx, sqli, args)
 	if err != nil {
-		return translateError(err, errTranslator)
+		return translateError(err, opts.errorTranslator)
 	}
 	defer func() {
 		_ = rows.Close()
 	}()
 	if rows.Next() {
 		var colsReader *columnsReader
-		if colsReader, err = m.mapColumns(rows, mappings); err == nil {
+		if colsReader, err = m.mapColumns(rows, opts); err == nil {
 			var row map[string]any
-			if row, err = m.mapRow(ctx, sqli, rows, colsReader, mappings, postProcesses, subQueries, exclusions); err == nil {
-				err = json.NewEncoder(writer).Encode(row)
+			if row, err = m.mapCompleteRow(ctx, sqli, rows, colsReader, opts); err == nil {
+				err = m.writeRow(writer, colsReader, opts, row)
 			}
 		}
 	}
-	return translateError(err, errTranslator)
+	return translateError(err, opts.errorTranslator)
 }
 
 func (m *mapper) WriteExactlyOneRow(ctx context.Context, writer io.Writer, sqli SqlInterface, args []any, options ...any) (err error) {
-	query, mappings, postProcesses, subQueries, exclusions, _, errTranslator, err := m.rowMapOptions(options...)
+	opts, err := m.rowMapOptionsNoKeyset(options...)
 	if err != nil {
 		return err
 	}
-	rows, err := sqli.QueryContext(ctx, query, args...)
+	sqli = opts.sqlInterface(sqli)
+	rows, err := opts.queryContext(ctx, sqli, args)
 	if err != nil {
-		return translateError(err, errTranslator)
+		return translateError(err, opts.errorTranslator)
 	}
 	defer func() {
 		_ = rows.Close()
@@ -271,74 +485,158 @@ func (m *mapper) WriteExactlyOneRow(ctx context.Context, writer io.Writer, sqli
 	err = sql.ErrNoRows
 	if rows.Next() {
 		var colsReader *columnsReader
-		if colsReader, err = m.mapColumns(rows, mappings); err == nil {
+		if colsReader, err = m.mapColumns(rows, opts); err == nil {
 			var row map[string]any
-			if row, err = m.mapRow(ctx, sqli, rows, colsReader, mappings, postProcesses, subQueries, exclusions); err == nil {
-				err = json.NewEncoder(writer).Encode(row)
+			if row, err = m.mapCompleteRow(ctx, sqli, rows, colsReader, opts); err == nil {
+				err = m.writeRow(writer, colsReader, opts, row)
 			}
 		}
 	}
-	return translateError(err, errTranslator)
+	return translateError(err, opts.errorTranslator)
 }
 
 func (m *mapper) Iterate(ctx context.Context, sqli SqlInterface, args []any, handler func(row map[string]any) (cont bool, err error), options ...any) (err error) {
-	query, mappings, postProcesses, subQueries, exclusions, _, errTranslator, err := m.rowMapOptions(options...)
+	opts, err := m.rowMapOptionsNoKeyset(options...)
 	if err != nil {
 		return err
 	}
-	rows, err := sqli.QueryContext(ctx, query, args...)
+	sqli = opts.sqlInterface(sqli)
+	rows, err := opts.queryContext(ctx, sqli, args)
 	if err != nil {
-		return translateError(err, errTranslator)
+		return translateError(err, opts.errorTranslator)
 	}
 	defer func() {
 		_ = rows.Close()
 	}()
 	var colsReader *columnsReader
-	if colsReader, err = m.mapColumns(rows, mappings); err == nil {
-		var row map[string]any
+	if colsReader, err = m.mapColumns(rows, opts); err == nil {
 		cont := true
+		handle := func(completed []map[string]any) (err error) {
+			for i := 0; cont && err == nil && i < len(completed); i++ {
+				cont, err = handler(completed[i])
+			}
+			return err
+		}
+		batch := opts.newBatch(ctx, sqli)
+		var completed []map[string]any
 		for cont && err == nil && rows.Next() {
-			if row, err = m.mapRow(ctx, sqli, rows, colsReader, mappings, postProcesses, subQueries, exclusions); err == nil {
-				cont, err = handler(row)
+			if completed, err = m.readRow(ctx, sqli, rows, colsReader, opts, batch); err == nil {
+				err = handle(completed)
+			}
+		}
+		if cont && err == nil {
+			if completed, err = batch.flush(); err == nil {
+				err = handle(completed)
 			}
 		}
 	}
-	return translateError(err, errTranslator)
+	return translateError(err, opts.errorTranslator)
 }
 
 func (m *mapper) Iterator(ctx context.Context, sqli SqlInterface, args []any, options ...any) func(func(int, map[string]any) bool) {
-	query, mappings, postProcesses, subQueries, exclusions, limiter, errTranslator, err := m.rowMapOptions(options...)
+	opts, err := m.rowMapOptionsNoKeyset(options...)
 	if err == nil {
 		i := 0
 		var rows *sql.Rows
-		if rows, err = sqli.QueryContext(ctx, query, args...); err == nil {
+		sqli = opts.sqlInterface(sqli)
+		if rows, err = opts.queryContext(ctx, sqli, args); err == nil {
 			return func(yield func(int, map[string]any) bool) {
 				var colsReader *columnsReader
-				if colsReader, err = m.mapColumns(rows, mappings); err == nil {
-					var row map[string]any
-					for err == nil && rows.Next() {
-						if limiter.LimitReached(i + 1) {
+				if colsReader, err = m.mapColumns(rows, opts); err == nil {
+					cont := true
+					yieldAll := func(completed []map[string]any) {
+						for j := 0; cont && j < len(completed); j++ {
+							cont = yield(i, completed[j])
+							i++
+						}
+					}
+					batch := opts.newBatch(ctx, sqli)
+					var completed []map[string]any
+					rowCount := 0
+					for cont && err == nil && rows.Next() {
+						rowCount++
+						if batch.limitReached(rowCount) {
 							break
 						}
-						if row, err = m.mapRow(ctx, sqli, rows, colsReader, mappings, postProcesses, subQueries, exclusions); err == nil {
-							yield(i, row)
-						} else {
-							err = translateError(err, errTranslator)
+						if completed, err = m.readRow(ctx, sqli, rows, colsReader, opts, batch); err == nil {
+							yieldAll(completed)
+						}
+						if err != nil {
+							err = translateError(err, opts.errorTranslator)
+						}
+					}
+					if cont && err == nil {
+						if completed, err = batch.flush(); err == nil {
+							yieldAll(completed)
 						}
-						i++
 					}
 				}
 				_ = rows.Close()
 				if err != nil {
-					_ = translateError(err, errTranslator)
+					_ = translateError(err, opts.errorTranslator)
 				}
 			}
 		}
 	}
-	_ = translateError(err, errTranslator)
+	_ = translateError(err, opts.errorTranslator)
 	return func(func(int, map[string]any) bool) {}
 }
 
+func (m *mapper) ErrIterator(ctx context.Context, sqli SqlInterface, args []any, options ...any) iter.Seq2[map[string]any, error] {
+	return func(yield func(map[string]any, error) bool) {
+		opts, err := m.rowMapOptionsNoKeyset(options...)
+		if err != nil {
+			yield(nil, err)
+			return
+		}
+		sqli := opts.sqlInterface(sqli)
+		rows, err := opts.queryContext(ctx, sqli, args)
+		if err != nil {
+			yield(nil, translateError(err, opts.errorTranslator))
+			return
+		}
+		defer func() {
+			_ = rows.Close()
+		}()
+		var colsReader *columnsReader
+		if colsReader, err = m.mapColumns(rows, opts); err != nil {
+			yield(nil, translateError(err, opts.errorTranslator))
+			return
+		}
+		yieldAll := func(completed []map[string]any) bool {
+			for _, row := range completed {
+				if !yield(row, nil) {
+					return false
+				}
+			}
+			return true
+		}
+		batch := opts.newBatch(ctx, sqli)
+		var completed []map[string]any
+		rowCount := 0
+		for rows.Next() {
+			rowCount++
+			if batch.limitReached(rowCount) {
+				break
+			}
+			if completed, err = m.readRow(ctx, sqli, rows, colsReader, opts, batch); err != nil {
+				yield(nil, translateError(err, opts.errorTranslator))
+				return
+			} else if !yieldAll(completed) {
+				return
+			}
+		}
+		if err = rows.Err(); err == nil {
+			completed, err = batch.flush()
+		}
+		if err != nil {
+			yield(nil, translateError(err, opts.errorTranslator))
+			return
+		}
+		yieldAll(completed)
+	}
+}
+
 func (m *mapper) Extend(addColumns []string, mappings Mappings, options ...any) (Mapper, error) {
 	result := &mapper{
 		mappings:          m.copyMappings(),
@@ -347,6 +645,13 @@ func (m *mapper) Extend(addColumns []string, mappings Mappings, options ...any)
 		rowSubQueries:     append([]SubQuery{}, m.rowSubQueries...),
 		defaultQuery:      m.defaultQuery,
 		useDecimals:       m.useDecimals,
+		batchSize:         m.batchSize,
+		concurrency:       m.concurrency,
+		columnsCacheSize:  m.columnsCacheSize,
+		dialect:           m.dialect,
+		sortable:          m.sortable,
+		encoding:          m.encoding,
+		grouping:          m.grouping,
 	}
 	if len(addColumns) != 0 {
 		if result.cols != "" {
@@ -364,67 +669,221 @@ func (m *mapper) Extend(addColumns []string, mappings Mappings, options ...any)
 	return result, nil
 }
 
-func (m *mapper) rowMapOptions(options ...any) (query string, mappings Mappings, postProcesses []RowPostProcessor, subQueries []SubQuery, exclusions PropertyExclusions, limiter Limiter, errorTranslator ErrorTranslator, err error) {
-	mappings = m.mappings
+type mapOptions struct {
+	queryOptions
+	mappings        Mappings
+	postProcesses   []RowPostProcessor
+	subQueries      []SubQuery
+	exclusions      PropertyExclusions
+	limiter         Limiter
+	errorTranslator ErrorTranslator
+	batchSize       int
+	concurrency     SubQueryConcurrency
+	encoding        RowEncoding
+	grouping        *Grouping
+	// subPath is the property path of the rows (for sub-query mappers) - used when checking sub-query and row post processor exclusions
+	subPath []string
+	// pageWindow is set when the page total is captured from the window function column - so the column is not encoded
+	pageWindow bool
+	// scannersOverridden is set when Mappings options override column Scanner(s) - so column information cannot be cached
+	scannersOverridden bool
+}
+
+func (m *mapper) rowMapOptions(options ...any) (opts *mapOptions, err error) {
+	opts = &mapOptions{
+		mappings:        m.mappings,
+		exclusions:      make([]PropertyExcluder, 0),
+		subQueries:      append([]SubQuery{}, m.rowSubQueries...),
+		postProcesses:   append([]RowPostProcessor{}, m.rowPostProcessors...),
+		limiter:         defaultLimiter,
+		errorTranslator: m.errorTranslator,
+		batchSize:       m.batchSize,
+		concurrency:     m.concurrency,
+		grouping:        m.grouping,
+		subPath:         m.subPath,
+	}
+	opts.dialect = m.dialect
 	mappingsCopied := false
-	exclusions = make([]PropertyExcluder, 0)
 	querySet := false
-	subQueries = append(subQueries, m.rowSubQueries...)
-	postProcesses = append(postProcesses, m.rowPostProcessors...)
-	limiter = defaultLimiter
-	errorTranslator = m.errorTranslator
+	var keyset *Keyset
+	var rowLimit *RowLimit
+	var sort Sort
+	var accept *Accept
+	sortable := m.sortable
 	if m.defaultQuery != nil {
 		querySet = true
-		query = string(*m.defaultQuery)
+		opts.query = string(*m.defaultQuery)
 	} else if m.subQuery != nil {
 		querySet = true
-		query = m.subQuery.getQuery()
+		opts.query = m.subQuery.getQuery()
 	}
 	for _, o := range options {
 		if o != nil {
 			switch option := o.(type) {
 			case Query:
 				querySet = true
-				query = "SELECT " + m.cols + " " + string(option)
+				opts.query = "SELECT " + m.cols + " " + string(option)
+				opts.argInserts = nil
+			case rawQuery:
+				querySet = true
+				opts.query = string(option)
+				opts.argInserts = nil
 			case AddClause:
 				if !querySet {
-					err = errors.New("add clause must have a query set")
-					return
+					return opts, errors.New("add clause must have a query set")
+				}
+				opts.addClause(string(option), nil)
+			case AddClauseWithArgs:
+				if !querySet {
+					return opts, errors.New("add clause must have a query set")
 				}
-				query += " " + string(option)
+				opts.addClause(option.Clause, option.Args)
 			case Mappings:
 				if !mappingsCopied {
 					mappingsCopied = true
-					mappings = m.copyMappings()
+					opts.mappings = m.copyMappings()
 				}
 				for k, v := range option {
-					mappings[k] = v
+					if existing, ok := opts.mappings[k]; v.Scanner != nil || (ok && existing.Scanner != nil) {
+						opts.scannersOverridden = true
+					}
+					opts.mappings[k] = v
 				}
 			case PropertyExclusions:
-				exclusions = append(exclusions, option...)
+				opts.exclusions = append(opts.exclusions, option...)
 			case PropertyExcluder:
-				exclusions = append(exclusions, option)
+				opts.exclusions = append(opts.exclusions, option)
 			case RowPostProcessor:
-				postProcesses = append(postProcesses, option)
+				opts.postProcesses = append(opts.postProcesses, option)
 			case SubQuery:
-				subQueries = append(subQueries, option)
+				opts.subQueries = append(opts.subQueries, option)
 			case Limiter:
-				limiter = option
+				opts.limiter = option
 			case ErrorTranslator:
-				errorTranslator = option
+				opts.errorTranslator = option
+			case BatchSize:
+				opts.batchSize = int(option)
+			case SubQueryConcurrency:
+				opts.concurrency = option
+			case *Keyset:
+				keyset = option
+			case Dialect:
+				opts.dialect = option
+			case Sort:
+				sort = option
+			case SortableProperties:
+				sortable = option
+			case RowLimit:
+				rowLimit = &option
+			case RowEncoding:
+				opts.encoding = option
+			case Accept:
+				accept = &option
+			case Grouping:
+				if err = option.validate(); err != nil {
+					return opts, err
+				}
+				opts.grouping = &option
 			default:
 				if excf, ok := o.(func(string, []string) bool); ok {
-					exclusions = append(exclusions, ConditionalExclude(excf))
+					opts.exclusions = append(opts.exclusions, ConditionalExclude(excf))
 				} else {
-					return "", nil, nil, nil, nil, nil, nil, fmt.Errorf("unknown option type: %T", o)
+					return opts, fmt.Errorf("unknown option type: %T", o)
 				}
 			}
 		}
 	}
 	if !querySet {
-		err = errors.New("no default query")
+		return opts, errors.New("no default query")
 	}
-	return query, mappings, postProcesses, subQueries, exclusions, limiter, errorTranslator, err
+	if opts.encoding, err = resolveEncoding(opts.encoding, m.encoding, accept); err != nil {
+		return opts, err
+	}
+	if sort != "" {
+		if err = opts.applySort(sort, sortable, opts.mappings); err != nil {
+			return opts, err
+		}
+	}
+	fields := hasFields(opts.exclusions)
+	if len(opts.exclusions) > 0 {
+		var fieldsKeyset *Keyset
+		if fields {
+			fieldsKeyset = keyset
+		}
+		if keep := opts.requiredProperties(fieldsKeyset); len(keep) > 0 {
+			opts.exclusions = PropertyExclusions{&keepPropertiesExcluder{keys: keep, path: m.subPath, exclusions: opts.exclusions}}
+		}
+	}
+	if fields {
+		var keep []string
+		if opts.grouping != nil {
+			keep = opts.grouping.keyColumns()
+		}
+		opts.omitExcludedColumns(m.subPath, keep)
+	}
+	if opts.grouping != nil {
+		if keyset != nil {
+			return opts, errors.New("keyset cannot be used with grouping")
+		} else if rowLimit != nil {
+			return opts, errors.New("row limit cannot be used with grouping")
+		}
+	}
+	if rowLimit != nil {
+		if keyset != nil {
+			return opts, errors.New("row limit cannot be used with keyset")
+		} else if err = opts.applyRowLimit(rowLimit); err != nil {
+			return opts, err
+		}
+	}
+	if keyset != nil {
+		opts.limiter, err = opts.applyKeyset(keyset, func(property string) string {
+			return propertyColumn(opts.mappings, property)
+		}, opts.limiter)
+	}
+	return opts, err
+}
+
+// rowMapOptionsNoKeyset resolves the row mapping options for methods that do not support keyset pagination (i.e. methods
+// other than Rows and WriteRows)
+func (m *mapper) rowMapOptionsNoKeyset(options ...any) (*mapOptions, error) {
+	opts, err := m.rowMapOptions(options...)
+	if err == nil && opts.keyset != nil {
+		err = errKeysetUnsupported
+	}
+	return opts, err
+}
+
+// requiredProperties returns the properties (at the sub path) that must not be excluded - i.e. the arg properties of sub-queries
+// that are executed and, for Fields, the keyset sort properties (so that cursors can be encoded)
+func (o *mapOptions) requiredProperties(keyset *Keyset) (result []string) {
+	if keyset != nil {
+		for _, s := range keyset.Sort {
+			result = append(result, strings.TrimPrefix(s, "-"))
+		}
+	}
+	for _, sq := range o.subQueries {
+		if isq, ok := sq.(internalSubQuery); ok && (sq.ProvidesProperty() == "" || !o.exclusions.Exclude(sq.ProvidesProperty(), o.subPath)) {
+			result = append(result, isq.getArgProperties()...)
+		}
+	}
+	return result
+}
+
+// propertyColumn resolves a (top level) property name to the column name using mappings
+func propertyColumn(mappings Mappings, property string) string {
+	for col, mp := range mappings {
+		if mp.PropertyName == property && len(mp.Path) == 0 {
+			return col
+		}
+	}
+	return property
+}
+
+func mapKeysetValue(row any, sort keysetSort) (any, error) {
+	if v, ok := row.(map[string]any)[sort.property]; ok {
+		return v, nil
+	}
+	return nil, fmt.Errorf("keyset sort property '%s' not present in row", sort.property)
 }
 
 func (m *mapper) copyMappings() Mappings {
@@ -453,6 +912,23 @@ func (m *mapper) addOptions(options ...any) error {
 				m.defaultQuery = &qStr
 			case UseDecimals:
 				m.useDecimals = bool(option)
+			case BatchSize:
+				m.batchSize = int(option)
+			case SubQueryConcurrency:
+				m.concurrency = option
+			case ColumnsCacheSize:
+				m.columnsCacheSize = int(option)
+			case Dialect:
+				m.dialect = option
+			case SortableProperties:
+				m.sortable = option
+			case RowEncoding:
+				m.encoding = option
+			case Grouping:
+				if err := option.validate(); err != nil {
+					return err
+				}
+				m.grouping = &option
 			case ErrorTranslator:
 				m.errorTranslator = option
 			case Mappings:
@@ -467,86 +943,257 @@ func (m *mapper) addOptions(options ...any) error {
 	return nil
 }
 
-func (m *mapper) mapColumns(rows *sql.Rows, mappings Mappings) (cr *columnsReader, err error) {
-	m.mutex.RLock()
-	if m.columnsInfo != nil {
-		m.mutex.RUnlock()
-		return m.columnsInfo.reader(), nil
+// mapColumns returns a reader for the columns of the rows
+//
+// column information is cached by result shape - unless the options override any column Scanner(s)
+func (m *mapper) mapColumns(rows *sql.Rows, opts *mapOptions) (cr *columnsReader, err error) {
+	var cts []*sql.ColumnType
+	if cts, err = rows.ColumnTypes(); err != nil {
+		return nil, err
 	}
-	m.mutex.RUnlock()
+	if opts.scannersOverridden {
+		return newColumnsInfoFromTypes(cts, m.useDecimals, opts.mappings).reader(), nil
+	}
+	key := columnsShapeKey(cts)
 	m.mutex.Lock()
 	defer m.mutex.Unlock()
-	m.columnsInfo, err = newColumnsInfo(rows, m.useDecimals, mappings)
-	return m.columnsInfo.reader(), err
+	if m.columnsCache == nil {
+		m.columnsCache = newColumnsCache(m.columnsCacheSize)
+	}
+	info, ok := m.columnsCache.get(key)
+	if !ok {
+		info = newColumnsInfoFromTypes(cts, m.useDecimals, m.mappings)
+		m.columnsCache.put(key, info)
+	}
+	return info.reader(), nil
+}
+
+func (m *mapper) mapCompleteRow(ctx context.Context, sqli SqlInterface, rows *sql.Rows, cols *columnsReader, opts *mapOptions) (row map[string]any, err error) {
+	if opts.grouping != nil {
+		return m.mapCompleteGroupedRow(ctx, sqli, rows, cols, opts)
+	}
+	if row, err = m.mapRow(ctx, sqli, rows, cols, opts); err == nil {
+		if err = opts.completeRows(ctx, sqli, []map[string]any{row}); err != nil {
+			row = nil
+		}
+	}
+	return row, err
+}
+
+// readRow maps the current row and adds it to the batch - returning any completed rows
+//
+// when grouping, the row is merged into its parent row (see Grouping) and any completed parent rows are added to the batch
+func (m *mapper) readRow(ctx context.Context, sqli SqlInterface, rows *sql.Rows, cols *columnsReader, opts *mapOptions, batch *rowBatch) (completed []map[string]any, err error) {
+	if opts.grouping == nil {
+		var row map[string]any
+		if row, err = m.mapRow(ctx, sqli, rows, cols, opts); err == nil {
+			completed, err = batch.add(row)
+		}
+		return completed, err
+	}
+	if batch.grouper == nil {
+		var gc *groupColumns
+		if gc, err = opts.groupColumns(cols, m.subPath); err != nil {
+			return nil, err
+		}
+		batch.grouper = newRowGrouper(gc, assignGroupRows, opts.limiter)
+	}
+	key, row, items, err := m.mapGroupedRow(ctx, sqli, rows, cols, batch.grouper.columns, opts)
+	if err != nil {
+		return nil, err
+	}
+	for _, parent := range batch.grouper.add(key, row, items) {
+		var added []map[string]any
+		if added, err = batch.add(parent.(map[string]any)); err != nil {
+			return nil, err
+		}
+		completed = append(completed, added...)
+	}
+	return completed, nil
 }
 
-func (m *mapper) mapRow(ctx context.Context, sqli SqlInterface, rows *sql.Rows, cols *columnsReader, mappings Mappings, postProcesses []RowPostProcessor, subQueries []SubQuery, exclusions PropertyExclusions) (row map[string]any, err error) {
+// mapRow maps the current row from columns - sub-queries and row post processors are not run (see mapOptions.completeRows)
+func (m *mapper) mapRow(ctx context.Context, sqli SqlInterface, rows *sql.Rows, cols *columnsReader, opts *mapOptions) (row map[string]any, err error) {
 	if err = rows.Scan(cols.scanArgs...); err == nil {
 		row = make(map[string]any, cols.count)
 		for i, name := range cols.names {
-			value := cols.values[i]
-			useObject := row
-			var mapping *Mapping
-			excluded := false
-			if mp, ok := mappings[name]; ok {
-				mapping = &mp
-				if value == nil {
-					if mapping.OmitNull {
-						continue
-					} else if mapping.NullDefault != nil {
-						value = mapping.NullDefault
+			if err = m.mapValue(ctx, sqli, row, name, name, cols.values[i], m.subPath, opts); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return row, err
+}
+
+// mapValue maps a column value into the row (using any mapping for the column) - name is the default property name
+// and path is the property path of the row (used for exclusions)
+func (m *mapper) mapValue(ctx context.Context, sqli SqlInterface, row map[string]any, col string, name string, value any, path []string, opts *mapOptions) error {
+	useObject := row
+	var mapping *Mapping
+	excluded := false
+	if mp, ok := opts.mappings[col]; ok {
+		mapping = &mp
+		if value == nil {
+			if mapping.OmitNull {
+				return nil
+			} else if mapping.NullDefault != nil {
+				value = mapping.NullDefault
+			}
+		}
+		if mapping.PropertyName != "" {
+			name = mapping.PropertyName
+		}
+		if excluded = opts.exclusions.Exclude(name, append(path[:len(path):len(path)], mapping.Path...)); !excluded {
+			for _, path := range mapping.Path {
+				found := false
+				if existing, ok := useObject[path]; ok {
+					if obj, ok := existing.(map[string]any); ok {
+						found = true
+						useObject = obj
 					}
 				}
-				if mapping.PropertyName != "" {
-					name = mapping.PropertyName
-				}
-				if excluded = exclusions.Exclude(name, append(m.subPath, mapping.Path...)); !excluded {
-					for _, path := range mapping.Path {
-						found := false
-						if existing, ok := useObject[path]; ok {
-							if obj, ok := existing.(map[string]any); ok {
-								found = true
-								useObject = obj
-							}
-						}
-						if !found {
-							obj := map[string]any{}
-							useObject[path] = obj
-							useObject = obj
+				if !found {
+					obj := map[string]any{}
+					useObject[path] = obj
+					useObject = obj
 
-						}
-					}
 				}
-			} else {
-				excluded = exclusions.Exclude(name, m.subPath)
-			}
-			if !excluded {
-				useObject[name] = value
-				if mapping != nil {
-					if mapping.PostProcess != nil {
-						if replace, replaceValue, err := mapping.PostProcess(ctx, sqli, row, value); err != nil {
-							return nil, err
-						} else if replace {
-							useObject[name] = replaceValue
-						}
-					}
+			}
+		}
+	} else {
+		excluded = opts.exclusions.Exclude(name, path)
+	}
+	if !excluded {
+		useObject[name] = value
+		if mapping != nil {
+			if mapping.PostProcess != nil {
+				if replace, replaceValue, err := mapping.PostProcess(ctx, unwrapSqlInterface(sqli), row, value); err != nil {
+					return err
+				} else if replace {
+					useObject[name] = replaceValue
 				}
 			}
 		}
-		for _, sq := range subQueries {
-			if sq != nil && (sq.ProvidesProperty() == "" || !exclusions.Exclude(sq.ProvidesProperty(), nil)) {
-				if err = sq.Execute(ctx, sqli, row, exclusions); err != nil {
-					return nil, err
+	}
+	return nil
+}
+
+// completeRows runs the sub-queries and row post processors for the mapped rows
+//
+// batch sub-queries are executed once for all the rows, other sub-queries are executed for each row
+// (concurrently, if SubQueryConcurrency is enabled)
+func (o *mapOptions) completeRows(ctx context.Context, sqli SqlInterface, rows []map[string]any) (err error) {
+	if o.concurrency.enabled(sqli) {
+		err = o.executeSubQueriesConcurrently(ctx, sqli, rows)
+	} else {
+		err = o.executeSubQueries(ctx, sqli, rows, o.subQueries)
+	}
+	if err != nil {
+		return err
+	}
+	for _, row := range rows {
+		for _, rp := range o.postProcesses {
+			if rp != nil && (rp.ProvidesProperty() == "" || !o.exclusions.Exclude(rp.ProvidesProperty(), o.subPath)) {
+				if err = rp.PostProcess(ctx, unwrapSqlInterface(sqli), row); err != nil {
+					return err
 				}
 			}
 		}
-		for _, rp := range postProcesses {
-			if rp != nil && (rp.ProvidesProperty() == "" || !exclusions.Exclude(rp.ProvidesProperty(), nil)) {
-				if err = rp.PostProcess(ctx, sqli, row); err != nil {
-					return nil, err
+	}
+	return nil
+}
+
+func (o *mapOptions) executeSubQueries(ctx context.Context, sqli SqlInterface, rows []map[string]any, subQueries []SubQuery) (err error) {
+	for _, sq := range subQueries {
+		if sq != nil && (sq.ProvidesProperty() == "" || !o.exclusions.Exclude(sq.ProvidesProperty(), o.subPath)) {
+			if bsq, ok := sq.(BatchSubQuery); ok {
+				err = bsq.ExecuteBatch(ctx, subQuerySqlInterface(sq, sqli), rows, o.subQueryExclusions())
+			} else {
+				for i := 0; err == nil && i < len(rows); i++ {
+					err = sq.Execute(ctx, subQuerySqlInterface(sq, sqli), rows[i], o.subQueryExclusions())
 				}
 			}
+			if err != nil {
+				return err
+			}
 		}
 	}
-	return row, err
+	return nil
+}
+
+func (o *mapOptions) newBatch(ctx context.Context, sqli SqlInterface) *rowBatch {
+	size := 1
+	for _, sq := range o.subQueries {
+		if _, ok := sq.(BatchSubQuery); ok {
+			size = o.batchSize
+			break
+		}
+	}
+	return &rowBatch{
+		ctx:  ctx,
+		sqli: sqli,
+		opts: o,
+		size: size,
+	}
+}
+
+// rowBatch collects mapped rows so that batch sub-queries can be executed once for many rows
+//
+// when there are no batch sub-queries, the batch size is 1 (i.e. each row is completed as it is added)
+type rowBatch struct {
+	ctx  context.Context
+	sqli SqlInterface
+	opts *mapOptions
+	size int
+	rows []map[string]any
+	// grouper is set when grouping (see mapper.readRow)
+	grouper *rowGrouper
+}
+
+// add adds a mapped row to the batch - returning the completed rows if the batch is full
+func (b *rowBatch) add(row map[string]any) ([]map[string]any, error) {
+	b.rows = append(b.rows, row)
+	if b.size > 0 && len(b.rows) >= b.size {
+		return b.complete()
+	}
+	return nil, nil
+}
+
+// limitReached checks whether the Limiter is reached for the database row count - when grouping, the Limiter applies to
+// the grouped parent rows instead (see rowGrouper)
+func (b *rowBatch) limitReached(rowCount int) bool {
+	if b.grouper != nil {
+		return b.grouper.limited
+	}
+	return b.opts.limiter.LimitReached(rowCount)
+}
+
+// flush completes and returns any rows remaining in the batch (including any remaining grouped parent rows)
+func (b *rowBatch) flush() (completed []map[string]any, err error) {
+	if b.grouper != nil {
+		for _, parent := range b.grouper.flush() {
+			var added []map[string]any
+			if added, err = b.add(parent.(map[string]any)); err != nil {
+				return nil, err
+			}
+			completed = append(completed, added...)
+		}
+	}
+	var added []map[string]any
+	if added, err = b.complete(); err != nil {
+		return nil, err
+	}
+	return append(completed, added...), nil
+}
+
+// complete completes the rows in the batch
+func (b *rowBatch) complete() (completed []map[string]any, err error) {
+	if len(b.rows) > 0 {
+		completed = b.rows
+		b.rows = nil
+		if err = b.opts.completeRows(b.ctx, b.sqli, completed); err != nil {
+			completed = nil
+		}
+	}
+	return completed, err
 }
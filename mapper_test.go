@@ -5,9 +5,11 @@ import (
 	"context"
 	"database/sql"
 	"errors"
+	"fmt"
 	"github.com/DATA-DOG/go-sqlmock"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	"regexp"
 	"testing"
 )
 
@@ -96,34 +98,34 @@ func TestMapper_rowMapOptions_query(t *testing.T) {
 	m, err := newMapper("a,b,c")
 	require.NoError(t, err)
 	require.Nil(t, m.defaultQuery)
-	_, _, _, _, _, _, _, err = m.rowMapOptions()
+	_, err = m.rowMapOptions()
 	require.Error(t, err)
 	require.Equal(t, "no default query", err.Error())
 
 	m, err = newMapper("a,b,c", Query(`FROM table WHERE id = ?`))
 	require.NoError(t, err)
 	require.NotNil(t, m.defaultQuery)
-	q, _, _, _, _, _, _, err := m.rowMapOptions()
+	opts, err := m.rowMapOptions()
 	require.NoError(t, err)
-	require.Equal(t, "SELECT a,b,c FROM table WHERE id = ?", q)
+	require.Equal(t, "SELECT a,b,c FROM table WHERE id = ?", opts.query)
 
 	useQuery := Query(`FROM other_table WHERE other_id = ?`)
-	q, _, _, _, _, _, _, err = m.rowMapOptions(useQuery)
+	opts, err = m.rowMapOptions(useQuery)
 	require.NoError(t, err)
-	require.Equal(t, "SELECT a,b,c FROM other_table WHERE other_id = ?", q)
+	require.Equal(t, "SELECT a,b,c FROM other_table WHERE other_id = ?", opts.query)
 
 	addClause := AddClause(`ORDER BY id`)
-	q, _, _, _, _, _, _, err = m.rowMapOptions(addClause)
+	opts, err = m.rowMapOptions(addClause)
 	require.NoError(t, err)
-	require.Equal(t, "SELECT a,b,c FROM table WHERE id = ? ORDER BY id", q)
+	require.Equal(t, "SELECT a,b,c FROM table WHERE id = ? ORDER BY id", opts.query)
 
-	q, _, _, _, _, _, _, err = m.rowMapOptions(useQuery, addClause)
+	opts, err = m.rowMapOptions(useQuery, addClause)
 	require.NoError(t, err)
-	require.Equal(t, "SELECT a,b,c FROM other_table WHERE other_id = ? ORDER BY id", q)
+	require.Equal(t, "SELECT a,b,c FROM other_table WHERE other_id = ? ORDER BY id", opts.query)
 
 	m, err = newMapper("a,b,c")
 	require.NoError(t, err)
-	_, _, _, _, _, _, _, err = m.rowMapOptions(addClause)
+	_, err = m.rowMapOptions(addClause)
 	require.Error(t, err)
 	require.Equal(t, "add clause must have a query set", err.Error())
 }
@@ -136,108 +138,126 @@ func TestMapper_rowMapOptions_mappings(t *testing.T) {
 	}, Query(`FROM table WHERE id = ?`))
 	require.NoError(t, err)
 	require.NotNil(t, m.defaultQuery)
-	_, mappings, _, _, _, _, _, err := m.rowMapOptions()
+	opts, err := m.rowMapOptions()
 	require.NoError(t, err)
-	require.Equal(t, 1, len(mappings))
+	require.Equal(t, 1, len(opts.mappings))
 
-	_, mappings, _, _, _, _, _, err = m.rowMapOptions(Mappings{
+	opts, err = m.rowMapOptions(Mappings{
 		"b": Mapping{},
 	})
 	require.NoError(t, err)
-	require.Equal(t, 2, len(mappings))
+	require.Equal(t, 2, len(opts.mappings))
 
-	_, mappings, _, _, _, _, _, err = m.rowMapOptions(Mappings{"a": Mapping{}}, Mappings{"b": Mapping{}})
+	opts, err = m.rowMapOptions(Mappings{"a": Mapping{}}, Mappings{"b": Mapping{}})
 	require.NoError(t, err)
-	require.Equal(t, 2, len(mappings))
+	require.Equal(t, 2, len(opts.mappings))
 }
 
 func TestMapper_rowMapOptions_postProcesses(t *testing.T) {
 	m, err := newMapper("a,b,c", Query(`FROM table WHERE id = ?`))
 	require.NoError(t, err)
 	require.NotNil(t, m.defaultQuery)
-	_, _, postProcesses, _, _, _, _, err := m.rowMapOptions()
+	opts, err := m.rowMapOptions()
 	require.NoError(t, err)
-	require.Empty(t, postProcesses)
+	require.Empty(t, opts.postProcesses)
 
-	_, _, postProcesses, _, _, _, _, err = m.rowMapOptions(&dummyRowPostProcessor{})
+	opts, err = m.rowMapOptions(&dummyRowPostProcessor{})
 	require.NoError(t, err)
-	require.Equal(t, 1, len(postProcesses))
+	require.Equal(t, 1, len(opts.postProcesses))
 
-	_, _, postProcesses, _, _, _, _, err = m.rowMapOptions(&dummyRowPostProcessor{}, &dummyRowPostProcessor{})
+	opts, err = m.rowMapOptions(&dummyRowPostProcessor{}, &dummyRowPostProcessor{})
 	require.NoError(t, err)
-	require.Equal(t, 2, len(postProcesses))
+	require.Equal(t, 2, len(opts.postProcesses))
 }
 
 func TestMapper_rowMapOptions_subQueries(t *testing.T) {
 	m, err := newMapper("a,b,c", Query(`FROM table WHERE id = ?`))
 	require.NoError(t, err)
 	require.NotNil(t, m.defaultQuery)
-	_, _, _, subQueries, _, _, _, err := m.rowMapOptions()
+	opts, err := m.rowMapOptions()
 	require.NoError(t, err)
-	require.Empty(t, subQueries)
+	require.Empty(t, opts.subQueries)
 
 	sq1 := NewSubQuery("", "", nil, nil, false)
 	sq2 := NewObjectSubQuery("", "", nil, nil, false, true)
-	_, _, _, subQueries, _, _, _, err = m.rowMapOptions(sq1, sq2)
+	opts, err = m.rowMapOptions(sq1, sq2)
 	require.NoError(t, err)
-	require.Equal(t, 2, len(subQueries))
+	require.Equal(t, 2, len(opts.subQueries))
 }
 
 func TestMapper_rowMapOptions_excludeProperties(t *testing.T) {
 	m, err := newMapper("a,b,c", Query(`FROM table WHERE id = ?`))
 	require.NoError(t, err)
 	require.NotNil(t, m.defaultQuery)
-	_, _, _, _, exclusions, _, _, err := m.rowMapOptions()
+	opts, err := m.rowMapOptions()
 	require.NoError(t, err)
-	require.Empty(t, exclusions)
+	require.Empty(t, opts.exclusions)
 
-	_, _, _, _, exclusions, _, _, err = m.rowMapOptions(AllowedProperties{"a": nil})
+	opts, err = m.rowMapOptions(AllowedProperties{"a": nil})
 	require.NoError(t, err)
-	require.Equal(t, 1, len(exclusions))
+	require.Equal(t, 1, len(opts.exclusions))
 
-	_, _, _, _, exclusions, _, _, err = m.rowMapOptions(AllowedProperties{"a": nil}, AllowedProperties{"b": nil})
+	opts, err = m.rowMapOptions(AllowedProperties{"a": nil}, AllowedProperties{"b": nil})
 	require.NoError(t, err)
-	require.Equal(t, 2, len(exclusions))
+	require.Equal(t, 2, len(opts.exclusions))
 
-	_, _, _, _, exclusions, _, _, err = m.rowMapOptions(PropertyExclusions{AllowedProperties{"a": nil}, AllowedProperties{"b": nil}})
+	opts, err = m.rowMapOptions(PropertyExclusions{AllowedProperties{"a": nil}, AllowedProperties{"b": nil}})
 	require.NoError(t, err)
-	require.Equal(t, 2, len(exclusions))
+	require.Equal(t, 2, len(opts.exclusions))
 
 	excfn := func(property string, path []string) bool { return false }
-	_, _, _, _, exclusions, _, _, err = m.rowMapOptions(excfn)
+	opts, err = m.rowMapOptions(excfn)
 	require.NoError(t, err)
-	require.Equal(t, 1, len(exclusions))
+	require.Equal(t, 1, len(opts.exclusions))
 }
 
 func TestMapper_rowMapOptions_limiter(t *testing.T) {
 	m, err := newMapper("a,b,c", Query(`FROM table`))
 	require.NoError(t, err)
-	_, _, _, _, _, limiter, _, err := m.rowMapOptions()
+	opts, err := m.rowMapOptions()
 	require.NoError(t, err)
-	require.NotNil(t, limiter)
-	require.IsType(t, &nullLimiter{}, limiter)
+	require.NotNil(t, opts.limiter)
+	require.IsType(t, &nullLimiter{}, opts.limiter)
 
 	opt := &testLimiter{2}
-	_, _, _, _, _, limiter, _, err = m.rowMapOptions(opt)
+	opts, err = m.rowMapOptions(opt)
 	require.NoError(t, err)
-	require.NotNil(t, limiter)
-	require.IsType(t, &testLimiter{}, limiter)
+	require.NotNil(t, opts.limiter)
+	require.IsType(t, &testLimiter{}, opts.limiter)
 }
 
 func TestMapper_rowMapOptions_errorTranslator(t *testing.T) {
 	m, err := newMapper("a,b,c", Query(`FROM table`))
 	require.NoError(t, err)
 	require.NotNil(t, m.defaultQuery)
-	_, _, _, _, _, _, translator, err := m.rowMapOptions()
+	opts, err := m.rowMapOptions()
 	require.NoError(t, err)
-	require.NotNil(t, translator)
-	require.Equal(t, defaultErrorTranslator, translator)
+	require.NotNil(t, opts.errorTranslator)
+	require.Equal(t, defaultErrorTranslator, opts.errorTranslator)
 
 	et := &testErrorTranslator{}
-	_, _, _, _, _, _, translator, err = m.rowMapOptions(et)
+	opts, err = m.rowMapOptions(et)
 	require.NoError(t, err)
-	require.NotNil(t, translator)
-	require.Equal(t, et, translator)
+	require.NotNil(t, opts.errorTranslator)
+	require.Equal(t, et, opts.errorTranslator)
+}
+
+func TestMapper_rowMapOptions_batchSize(t *testing.T) {
+	m, err := newMapper("a,b,c", Query(`FROM table`))
+	require.NoError(t, err)
+	opts, err := m.rowMapOptions()
+	require.NoError(t, err)
+	require.Equal(t, defaultBatchSize, opts.batchSize)
+
+	opts, err = m.rowMapOptions(BatchSize(10))
+	require.NoError(t, err)
+	require.Equal(t, 10, opts.batchSize)
+
+	m, err = newMapper("a,b,c", Query(`FROM table`), BatchSize(0))
+	require.NoError(t, err)
+	opts, err = m.rowMapOptions()
+	require.NoError(t, err)
+	require.Equal(t, 0, opts.batchSize)
 }
 
 func TestMapper_Rows(t *testing.T) {
@@ -258,6 +278,69 @@ func TestMapper_Rows(t *testing.T) {
 	require.Len(t, rows, 2)
 }
 
+func TestMapper_Rows_AddClauseWithArgs(t *testing.T) {
+	m, err := newMapper("a", Query(`FROM table WHERE x = ?`))
+	require.NoError(t, err)
+
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer func() {
+		_ = db.Close()
+	}()
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT a FROM table WHERE x = ? AND y = ? AND z IN (?,?) LIMIT ?")).
+		WithArgs(1, 2, 3, 4, 10).
+		WillReturnRows(sqlmock.NewRows([]string{"a"}).AddRow("a value"))
+
+	rows, err := m.Rows(ctx, db, []any{1, 10},
+		AddClauseWithArgs{Clause: "AND y = ?", Args: []any{2}},
+		AddClauseWithArgs{Clause: "AND z IN (?)", Args: []any{[]int{3, 4}}},
+		AddClause("LIMIT ?"))
+	require.NoError(t, err)
+	require.NoError(t, mock.ExpectationsWereMet())
+	require.Len(t, rows, 1)
+
+	// empty clause is ignored...
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT a FROM table WHERE x = ?")).
+		WithArgs(1).
+		WillReturnRows(sqlmock.NewRows([]string{"a"}))
+	_, err = m.Rows(ctx, db, []any{1}, AddClauseWithArgs{})
+	require.NoError(t, err)
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestMapper_Rows_RowLimit(t *testing.T) {
+	m, err := newMapper("a", Query(`FROM table`))
+	require.NoError(t, err)
+
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer func() {
+		_ = db.Close()
+	}()
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT a FROM table ORDER BY a ASC LIMIT 10 OFFSET 20")).
+		WillReturnRows(sqlmock.NewRows([]string{"a"}).AddRow("a value"))
+	rows, err := m.Rows(ctx, db, nil, RowLimit{Limit: 10, Offset: 20}, Sort("a"), SortableProperties{"a": ""})
+	require.NoError(t, err)
+	require.NoError(t, mock.ExpectationsWereMet())
+	require.Len(t, rows, 1)
+
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT a FROM table ORDER BY (SELECT NULL) OFFSET 0 ROWS FETCH NEXT 5 ROWS ONLY")).
+		WillReturnRows(sqlmock.NewRows([]string{"a"}))
+	_, err = m.Rows(ctx, db, nil, RowLimit{Limit: 5}, SQLServer)
+	require.NoError(t, err)
+	require.NoError(t, mock.ExpectationsWereMet())
+
+	_, err = m.Rows(ctx, db, nil, RowLimit{Limit: -1})
+	require.Error(t, err)
+	require.Equal(t, "row limit and offset must not be negative", err.Error())
+	_, err = m.Rows(ctx, db, nil, RowLimit{Limit: 1}, &Keyset{Sort: []string{"a"}, Size: 1})
+	require.Error(t, err)
+	require.Equal(t, "row limit cannot be used with keyset", err.Error())
+	_, err = m.Page(ctx, db, nil, 1, 10, RowLimit{Limit: 1})
+	require.Error(t, err)
+	require.Equal(t, "page cannot be used with row limit", err.Error())
+}
+
 func TestMapper_Rows_Limited(t *testing.T) {
 	m, err := newMapper("a", Query(`FROM table`))
 	require.NoError(t, err)
@@ -1228,6 +1311,246 @@ func TestMapper_Iterator_WithLimiter(t *testing.T) {
 	require.Equal(t, []int{0}, indices)
 }
 
+func TestMapper_Iterator_Break(t *testing.T) {
+	mapped := 0
+	m, err := newMapper("a", Mappings{
+		"a": {
+			PostProcess: func(ctx context.Context, sqli SqlInterface, row map[string]any, value any) (bool, any, error) {
+				mapped++
+				return false, nil, nil
+			},
+		},
+	}, Query(`FROM table`))
+	require.NoError(t, err)
+
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer func() {
+		_ = db.Close()
+	}()
+	mock.ExpectQuery("").WillReturnRows(sqlmock.NewRows([]string{"a"}).AddRow("a value").AddRow("a value 2").AddRow("a value 3"))
+	items := make([]map[string]any, 0)
+	for _, item := range m.Iterator(context.Background(), db, nil) {
+		items = append(items, item)
+		break
+	}
+	require.Len(t, items, 1)
+	require.Equal(t, 1, mapped)
+}
+
+func TestMapper_ErrIterator(t *testing.T) {
+	m, err := newMapper("a", Query(`FROM table`))
+	require.NoError(t, err)
+
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer func() {
+		_ = db.Close()
+	}()
+	mock.ExpectQuery("").WillReturnRows(sqlmock.NewRows([]string{"a"}).AddRow("a value").AddRow("a value 2"))
+	items := make([]map[string]any, 0)
+	for item, err := range m.ErrIterator(context.Background(), db, nil) {
+		require.NoError(t, err)
+		items = append(items, item)
+	}
+	require.NoError(t, mock.ExpectationsWereMet())
+	require.Len(t, items, 2)
+	require.Equal(t, "a value", items[0]["a"])
+	require.Equal(t, "a value 2", items[1]["a"])
+}
+
+func TestMapper_ErrIterator_Break(t *testing.T) {
+	mapped := 0
+	m, err := newMapper("a", Mappings{
+		"a": {
+			PostProcess: func(ctx context.Context, sqli SqlInterface, row map[string]any, value any) (bool, any, error) {
+				mapped++
+				return false, nil, nil
+			},
+		},
+	}, Query(`FROM table`))
+	require.NoError(t, err)
+
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer func() {
+		_ = db.Close()
+	}()
+	mock.ExpectQuery("").WillReturnRows(sqlmock.NewRows([]string{"a"}).AddRow("a value").AddRow("a value 2").AddRow("a value 3")).RowsWillBeClosed()
+	items := make([]map[string]any, 0)
+	for item, err := range m.ErrIterator(context.Background(), db, nil) {
+		require.NoError(t, err)
+		items = append(items, item)
+		break
+	}
+	require.NoError(t, mock.ExpectationsWereMet())
+	require.Len(t, items, 1)
+	require.Equal(t, 1, mapped)
+}
+
+func TestMapper_ErrIterator_WithLimiter(t *testing.T) {
+	m, err := newMapper("a", Query(`FROM table`))
+	require.NoError(t, err)
+
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer func() {
+		_ = db.Close()
+	}()
+	mock.ExpectQuery("").WillReturnRows(sqlmock.NewRows([]string{"a"}).AddRow("a value").AddRow("a value 2"))
+	items := make([]map[string]any, 0)
+	for item, err := range m.ErrIterator(context.Background(), db, nil, &testLimiter{limit: 1}) {
+		require.NoError(t, err)
+		items = append(items, item)
+	}
+	require.Len(t, items, 1)
+}
+
+type testWrappingErrorTranslator struct{}
+
+func (t *testWrappingErrorTranslator) Translate(err error) error {
+	return fmt.Errorf("translated: %w", err)
+}
+
+func TestMapper_ErrIterator_Errors(t *testing.T) {
+	m, err := newMapper("a", Mappings{
+		"a": {
+			PostProcess: func(ctx context.Context, sqli SqlInterface, row map[string]any, value any) (bool, any, error) {
+				if value == "a value 2" {
+					return false, nil, errors.New("fooey")
+				}
+				return false, nil, nil
+			},
+		},
+	}, Query(`FROM table`))
+	require.NoError(t, err)
+
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer func() {
+		_ = db.Close()
+	}()
+	collect := func(options ...any) (items []map[string]any, errs []error) {
+		for item, err := range m.ErrIterator(context.Background(), db, nil, options...) {
+			if err != nil {
+				errs = append(errs, err)
+			} else {
+				items = append(items, item)
+			}
+		}
+		return
+	}
+
+	items, errs := collect("not a valid option")
+	require.Len(t, items, 0)
+	require.Len(t, errs, 1)
+	require.Equal(t, "unknown option type: string", errs[0].Error())
+
+	mock.ExpectQuery("").WillReturnError(errors.New("query error"))
+	et := &testWrappingErrorTranslator{}
+	items, errs = collect(et)
+	require.Len(t, items, 0)
+	require.Len(t, errs, 1)
+	require.Equal(t, "translated: query error", errs[0].Error())
+
+	mock.ExpectQuery("").WillReturnRows(sqlmock.NewRows([]string{"a"}).AddRow("a value").AddRow("a value 2").AddRow("a value 3"))
+	items, errs = collect(et)
+	require.Len(t, items, 1)
+	require.Len(t, errs, 1)
+	require.Equal(t, "translated: fooey", errs[0].Error())
+
+	mock.ExpectQuery("").WillReturnRows(sqlmock.NewRows([]string{"a"}).AddRow("a value").AddRow("a value 3").RowError(1, errors.New("row error")))
+	items, errs = collect(et)
+	require.Len(t, items, 1)
+	require.Len(t, errs, 1)
+	require.Equal(t, "translated: row error", errs[0].Error())
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestMapper_ErrIterator_SubQueryErrors(t *testing.T) {
+	m, err := newMapper("a",
+		Query(`FROM table`),
+		NewSubQuery("foo", `SELECT b FROM sub_table WHERE a = ?`, []string{"a"}, nil, false),
+	)
+	require.NoError(t, err)
+
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer func() {
+		_ = db.Close()
+	}()
+	mock.ExpectQuery("SELECT a FROM table").WillReturnRows(sqlmock.NewRows([]string{"a"}).AddRow("a value"))
+	mock.ExpectQuery("SELECT b FROM sub_table WHERE a = ?").WithArgs("a value").WillReturnError(errors.New("fooey"))
+
+	count := 0
+	for item, err := range m.ErrIterator(context.Background(), db, nil) {
+		count++
+		require.Nil(t, item)
+		require.Error(t, err)
+		require.Equal(t, "fooey", err.Error())
+	}
+	require.Equal(t, 1, count)
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestMapper_ColumnsCache_QueryShapes(t *testing.T) {
+	m, err := newMapper("a", Query(`FROM table`), ColumnsCacheSize(2))
+	require.NoError(t, err)
+
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer func() {
+		_ = db.Close()
+	}()
+	mock.ExpectQuery("").WillReturnRows(sqlmock.NewRows([]string{"a"}).AddRow("a value"))
+	mock.ExpectQuery("").WillReturnRows(sqlmock.NewRows([]string{"a", "b", "c"}).AddRow("a value", "b value", "c value"))
+	mock.ExpectQuery("").WillReturnRows(sqlmock.NewRows([]string{"b"}).AddRow("b value"))
+	mock.ExpectQuery("").WillReturnRows(sqlmock.NewRows([]string{"a"}).AddRow("a value 2"))
+
+	row, err := m.FirstRow(ctx, db, nil)
+	require.NoError(t, err)
+	require.Equal(t, map[string]any{"a": "a value"}, row)
+	row, err = m.FirstRow(ctx, db, nil, Query(`, b, c FROM table`))
+	require.NoError(t, err)
+	require.Equal(t, map[string]any{"a": "a value", "b": "b value", "c": "c value"}, row)
+	row, err = m.FirstRow(ctx, db, nil, rawQuery(`SELECT b FROM table`))
+	require.NoError(t, err)
+	require.Equal(t, map[string]any{"b": "b value"}, row)
+	require.Equal(t, 2, m.columnsCache.len())
+	row, err = m.FirstRow(ctx, db, nil)
+	require.NoError(t, err)
+	require.Equal(t, map[string]any{"a": "a value 2"}, row)
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestMapper_ColumnsCache_MappingsScanner(t *testing.T) {
+	m, err := newMapper("a", Query(`FROM table`))
+	require.NoError(t, err)
+
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer func() {
+		_ = db.Close()
+	}()
+	mock.ExpectQuery("").WillReturnRows(sqlmock.NewRows([]string{"a"}).AddRow("a value"))
+	mock.ExpectQuery("").WillReturnRows(sqlmock.NewRows([]string{"a"}).AddRow("a value"))
+	mock.ExpectQuery("").WillReturnRows(sqlmock.NewRows([]string{"a"}).AddRow("a value"))
+
+	row, err := m.FirstRow(ctx, db, nil)
+	require.NoError(t, err)
+	require.Equal(t, "a value", row["a"])
+	row, err = m.FirstRow(ctx, db, nil, Mappings{"a": {Scanner: func(src any) (any, error) {
+		return "scanned", nil
+	}}})
+	require.NoError(t, err)
+	require.Equal(t, "scanned", row["a"])
+	row, err = m.FirstRow(ctx, db, nil)
+	require.NoError(t, err)
+	require.Equal(t, "a value", row["a"])
+	require.Equal(t, 1, m.columnsCache.len())
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
 func TestMapper_Extend(t *testing.T) {
 	m, err := NewMapper("a",
 		Mappings{"a": {Path: []string{"sub_obj"}}},
@@ -1,6 +1,9 @@
 package columbus
 
-import "context"
+import (
+	"context"
+	"strings"
+)
 
 // PostProcess is an optional function used on a Mapping
 //
@@ -26,3 +29,22 @@ type Mapping struct {
 
 // Mappings is a map of Mapping by column name
 type Mappings map[string]Mapping
+
+// Column returns the column name for a property name (i.e. the Mapping PropertyName, or the column name if no PropertyName)
+//
+// nested properties (i.e. mapped with a Path) are specified using dot notation - e.g. `address.city`
+func (m Mappings) Column(property string) (string, bool) {
+	for col, mp := range m {
+		name := mp.PropertyName
+		if name == "" {
+			name = col
+		}
+		if len(mp.Path) > 0 {
+			name = strings.Join(mp.Path, ".") + "." + name
+		}
+		if name == property {
+			return col, true
+		}
+	}
+	return "", false
+}
@@ -0,0 +1,420 @@
+package columbus
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"github.com/shopspring/decimal"
+	"io"
+	"math"
+	"slices"
+	"time"
+)
+
+// MsgPackEncoding is a RowEncoding that writes rows as MessagePack - rows are written as a stream of MessagePack maps (i.e.
+// one map per row, with no enclosing array) and a single row is written as a map
+//
+// values are encoded as follows:
+//
+//   - decimal.Decimal - as a string
+//   - time.Time - as a timestamp (extension type -1)
+//   - []byte - as binary
+//   - nested objects and arrays - as maps and arrays
+//
+// rows are streamed (each row is written, and the writer flushed, as it is encoded)
+//
+// when writing a page, the page is written as a single map (with "rows", "page", "size", "total" and "pages" entries) - the
+// rows of the page are held in memory until the page is complete
+//
+// see MsgPackDecoder for decoding
+type MsgPackEncoding struct{}
+
+var (
+	_ RowEncoding  = (*MsgPackEncoding)(nil)
+	_ PageEncoding = (*MsgPackEncoding)(nil)
+)
+
+// MsgPack is the RowEncoding that writes rows as MessagePack (see MsgPackEncoding)
+var MsgPack RowEncoding = &MsgPackEncoding{}
+
+func (e *MsgPackEncoding) ContentType() string {
+	return "application/msgpack"
+}
+
+func (e *MsgPackEncoding) NewEncoder(w io.Writer) RowEncoder {
+	return &msgPackEncoder{w: w}
+}
+
+func (e *MsgPackEncoding) NewPageEncoder(w io.Writer) PageEncoder {
+	return &msgPackPageEncoder{w: w}
+}
+
+type msgPackEncoder struct {
+	w   io.Writer
+	buf []byte
+}
+
+func (e *msgPackEncoder) Begin(info EncodeInfo) error {
+	return nil
+}
+
+func (e *msgPackEncoder) Row(row map[string]any) (err error) {
+	if e.buf, err = appendMsgPack(e.buf[:0], row); err == nil {
+		if _, err = e.w.Write(e.buf); err == nil {
+			err = flush(e.w)
+		}
+	}
+	return err
+}
+
+func (e *msgPackEncoder) End() error {
+	return nil
+}
+
+type msgPackPageEncoder struct {
+	w     io.Writer
+	rows  []byte
+	count int
+}
+
+func (e *msgPackPageEncoder) Begin(info EncodeInfo) error {
+	return nil
+}
+
+func (e *msgPackPageEncoder) Row(row map[string]any) (err error) {
+	if e.rows, err = appendMsgPack(e.rows, row); err == nil {
+		e.count++
+	}
+	return err
+}
+
+func (e *msgPackPageEncoder) End() error {
+	return nil
+}
+
+func (e *msgPackPageEncoder) Page(info PageResult) (err error) {
+	buf := appendMsgPackHeader(nil, 0x80, 0xde, 5)
+	buf = appendMsgPackStr(buf, "rows")
+	buf = append(appendMsgPackHeader(buf, 0x90, 0xdc, e.count), e.rows...)
+	buf = appendMsgPackInt(appendMsgPackStr(buf, "page"), int64(info.Page))
+	buf = appendMsgPackInt(appendMsgPackStr(buf, "size"), int64(info.Size))
+	buf = appendMsgPackInt(appendMsgPackStr(buf, "total"), info.Total)
+	buf = appendMsgPackInt(appendMsgPackStr(buf, "pages"), info.Pages)
+	_, err = e.w.Write(buf)
+	return err
+}
+
+func appendMsgPack(b []byte, v any) (_ []byte, err error) {
+	switch vt := v.(type) {
+	case nil:
+		return append(b, 0xc0), nil
+	case bool:
+		if vt {
+			return append(b, 0xc3), nil
+		}
+		return append(b, 0xc2), nil
+	case string:
+		return appendMsgPackStr(b, vt), nil
+	case []byte:
+		return append(appendMsgPackBinHeader(b, len(vt)), vt...), nil
+	case int:
+		return appendMsgPackInt(b, int64(vt)), nil
+	case int64:
+		return appendMsgPackInt(b, vt), nil
+	case int32:
+		return appendMsgPackInt(b, int64(vt)), nil
+	case uint64:
+		return appendMsgPackUint(b, vt), nil
+	case float64:
+		return binary.BigEndian.AppendUint64(append(b, 0xcb), math.Float64bits(vt)), nil
+	case float32:
+		return binary.BigEndian.AppendUint32(append(b, 0xca), math.Float32bits(vt)), nil
+	case decimal.Decimal:
+		return appendMsgPackStr(b, vt.String()), nil
+	case time.Time:
+		return appendMsgPackTime(b, vt), nil
+	case map[string]any:
+		keys := make([]string, 0, len(vt))
+		for k := range vt {
+			keys = append(keys, k)
+		}
+		slices.Sort(keys)
+		b = appendMsgPackHeader(b, 0x80, 0xde, len(vt))
+		for _, k := range keys {
+			if b, err = appendMsgPack(appendMsgPackStr(b, k), vt[k]); err != nil {
+				return nil, err
+			}
+		}
+		return b, nil
+	case []map[string]any:
+		b = appendMsgPackHeader(b, 0x90, 0xdc, len(vt))
+		for _, item := range vt {
+			if b, err = appendMsgPack(b, item); err != nil {
+				return nil, err
+			}
+		}
+		return b, nil
+	case []any:
+		b = appendMsgPackHeader(b, 0x90, 0xdc, len(vt))
+		for _, item := range vt {
+			if b, err = appendMsgPack(b, item); err != nil {
+				return nil, err
+			}
+		}
+		return b, nil
+	}
+	pv, err := plainValue(v)
+	if err != nil {
+		return nil, err
+	}
+	return appendMsgPack(b, pv)
+}
+
+// appendMsgPackHeader appends an array or map header - fix is the fix type (0x90 or 0x80) and code16 is the 16-bit type (0xdc or 0xde)
+// (the 32-bit type is code16 + 1)
+func appendMsgPackHeader(b []byte, fix byte, code16 byte, n int) []byte {
+	switch {
+	case n < 16:
+		return append(b, fix|byte(n))
+	case n <= math.MaxUint16:
+		return binary.BigEndian.AppendUint16(append(b, code16), uint16(n))
+	}
+	return binary.BigEndian.AppendUint32(append(b, code16+1), uint32(n))
+}
+
+func appendMsgPackStr(b []byte, s string) []byte {
+	switch n := len(s); {
+	case n < 32:
+		b = append(b, 0xa0|byte(n))
+	case n <= math.MaxUint8:
+		b = append(b, 0xd9, byte(n))
+	case n <= math.MaxUint16:
+		b = binary.BigEndian.AppendUint16(append(b, 0xda), uint16(n))
+	default:
+		b = binary.BigEndian.AppendUint32(append(b, 0xdb), uint32(n))
+	}
+	return append(b, s...)
+}
+
+func appendMsgPackBinHeader(b []byte, n int) []byte {
+	switch {
+	case n <= math.MaxUint8:
+		return append(b, 0xc4, byte(n))
+	case n <= math.MaxUint16:
+		return binary.BigEndian.AppendUint16(append(b, 0xc5), uint16(n))
+	}
+	return binary.BigEndian.AppendUint32(append(b, 0xc6), uint32(n))
+}
+
+func appendMsgPackInt(b []byte, i int64) []byte {
+	switch {
+	case i >= 0:
+		return appendMsgPackUint(b, uint64(i))
+	case i >= -32:
+		return append(b, byte(i))
+	case i >= math.MinInt8:
+		return append(b, 0xd0, byte(i))
+	case i >= math.MinInt16:
+		return binary.BigEndian.AppendUint16(append(b, 0xd1), uint16(i))
+	case i >= math.MinInt32:
+		return binary.BigEndian.AppendUint32(append(b, 0xd2), uint32(i))
+	}
+	return binary.BigEndian.AppendUint64(append(b, 0xd3), uint64(i))
+}
+
+func appendMsgPackUint(b []byte, u uint64) []byte {
+	switch {
+	case u <= 0x7f:
+		return append(b, byte(u))
+	case u <= math.MaxUint8:
+		return append(b, 0xcc, byte(u))
+	case u <= math.MaxUint16:
+		return binary.BigEndian.AppendUint16(append(b, 0xcd), uint16(u))
+	case u <= math.MaxUint32:
+		return binary.BigEndian.AppendUint32(append(b, 0xce), uint32(u))
+	}
+	return binary.BigEndian.AppendUint64(append(b, 0xcf), u)
+}
+
+// appendMsgPackTime appends a timestamp (extension type -1) - using the smallest of the timestamp 32, 64 or 96 formats
+func appendMsgPackTime(b []byte, t time.Time) []byte {
+	secs, nsec := t.Unix(), uint64(t.Nanosecond())
+	if secs>>34 == 0 {
+		data := nsec<<34 | uint64(secs)
+		if data>>32 == 0 {
+			return binary.BigEndian.AppendUint32(append(b, 0xd6, 0xff), uint32(data))
+		}
+		return binary.BigEndian.AppendUint64(append(b, 0xd7, 0xff), data)
+	}
+	b = binary.BigEndian.AppendUint32(append(b, 0xc7, 12, 0xff), uint32(nsec))
+	return binary.BigEndian.AppendUint64(b, uint64(secs))
+}
+
+// MsgPackDecoder decodes MessagePack values (e.g. as written by the MsgPack encoding) from a reader
+//
+// decoded values are: nil, bool, string, []byte, int64 (or uint64 if the value overflows an int64), float64, time.Time (for
+// timestamps - in UTC), map[string]any and []any
+type MsgPackDecoder struct {
+	r binaryReader
+}
+
+// NewMsgPackDecoder creates a new MsgPackDecoder that reads from the supplied reader
+func NewMsgPackDecoder(r io.Reader) *MsgPackDecoder {
+	return &MsgPackDecoder{r: newBinaryReader(r)}
+}
+
+// Decode decodes the next value - io.EOF is returned when there are no more values
+func (d *MsgPackDecoder) Decode() (any, error) {
+	if _, err := d.r.r.Peek(1); err != nil {
+		return nil, err
+	}
+	return d.decode()
+}
+
+func (d *MsgPackDecoder) decode() (any, error) {
+	code, err := d.r.readByte()
+	if err != nil {
+		return nil, err
+	}
+	switch {
+	case code <= 0x7f:
+		return int64(code), nil
+	case code >= 0xe0:
+		return int64(int8(code)), nil
+	case code&0xf0 == 0x80:
+		return d.decodeMap(uint64(code & 0x0f))
+	case code&0xf0 == 0x90:
+		return d.decodeArray(uint64(code & 0x0f))
+	case code&0xe0 == 0xa0:
+		return d.decodeStr(uint64(code & 0x1f))
+	}
+	switch code {
+	case 0xc0:
+		return nil, nil
+	case 0xc2:
+		return false, nil
+	case 0xc3:
+		return true, nil
+	case 0xc4, 0xc5, 0xc6:
+		n, err := d.r.readUint(1 << (code - 0xc4))
+		if err != nil {
+			return nil, err
+		}
+		return d.r.readBytes(n)
+	case 0xc7, 0xc8, 0xc9:
+		n, err := d.r.readUint(1 << (code - 0xc7))
+		if err != nil {
+			return nil, err
+		}
+		return d.decodeExt(n)
+	case 0xca:
+		u, err := d.r.readUint(4)
+		return float64(math.Float32frombits(uint32(u))), err
+	case 0xcb:
+		u, err := d.r.readUint(8)
+		return math.Float64frombits(u), err
+	case 0xcc, 0xcd, 0xce, 0xcf:
+		u, err := d.r.readUint(1 << (code - 0xcc))
+		return decodeInt(u), err
+	case 0xd0:
+		u, err := d.r.readUint(1)
+		return int64(int8(u)), err
+	case 0xd1:
+		u, err := d.r.readUint(2)
+		return int64(int16(u)), err
+	case 0xd2:
+		u, err := d.r.readUint(4)
+		return int64(int32(u)), err
+	case 0xd3:
+		u, err := d.r.readUint(8)
+		return int64(u), err
+	case 0xd4, 0xd5, 0xd6, 0xd7, 0xd8:
+		return d.decodeExt(1 << (code - 0xd4))
+	case 0xd9, 0xda, 0xdb:
+		n, err := d.r.readUint(1 << (code - 0xd9))
+		if err != nil {
+			return nil, err
+		}
+		return d.decodeStr(n)
+	case 0xdc, 0xdd:
+		n, err := d.r.readUint(2 << (code - 0xdc))
+		if err != nil {
+			return nil, err
+		}
+		return d.decodeArray(n)
+	case 0xde, 0xdf:
+		n, err := d.r.readUint(2 << (code - 0xde))
+		if err != nil {
+			return nil, err
+		}
+		return d.decodeMap(n)
+	}
+	return nil, fmt.Errorf("invalid msgpack code 0x%02x", code)
+}
+
+func (d *MsgPackDecoder) decodeStr(n uint64) (any, error) {
+	data, err := d.r.readBytes(n)
+	return string(data), err
+}
+
+func (d *MsgPackDecoder) decodeArray(n uint64) (any, error) {
+	c, err := decodeLength(n)
+	if err != nil {
+		return nil, err
+	}
+	result := make([]any, 0, c)
+	for i := uint64(0); i < n; i++ {
+		v, err := d.decode()
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, v)
+	}
+	return result, nil
+}
+
+func (d *MsgPackDecoder) decodeMap(n uint64) (any, error) {
+	c, err := decodeLength(n)
+	if err != nil {
+		return nil, err
+	}
+	result := make(map[string]any, c)
+	for i := uint64(0); i < n; i++ {
+		k, err := d.decode()
+		if err != nil {
+			return nil, err
+		}
+		key, ok := k.(string)
+		if !ok {
+			return nil, fmt.Errorf("unsupported msgpack map key type %T", k)
+		}
+		if result[key], err = d.decode(); err != nil {
+			return nil, err
+		}
+	}
+	return result, nil
+}
+
+// decodeExt decodes an extension (of data length n) - only the timestamp extension type (-1) is supported
+func (d *MsgPackDecoder) decodeExt(n uint64) (any, error) {
+	typ, err := d.r.readByte()
+	if err != nil {
+		return nil, err
+	}
+	data, err := d.r.readBytes(n)
+	if err != nil {
+		return nil, err
+	} else if int8(typ) != -1 {
+		return nil, fmt.Errorf("unsupported msgpack extension type %d", int8(typ))
+	}
+	switch n {
+	case 4:
+		return time.Unix(int64(binary.BigEndian.Uint32(data)), 0).UTC(), nil
+	case 8:
+		u := binary.BigEndian.Uint64(data)
+		return time.Unix(int64(u&0x3ffffffff), int64(u>>34)).UTC(), nil
+	case 12:
+		return time.Unix(int64(binary.BigEndian.Uint64(data[4:])), int64(binary.BigEndian.Uint32(data))).UTC(), nil
+	}
+	return nil, errors.New("invalid msgpack timestamp length")
+}
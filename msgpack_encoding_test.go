@@ -0,0 +1,162 @@
+package columbus
+
+import (
+	"bytes"
+	"encoding/hex"
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"io"
+	"math"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestAppendMsgPack(t *testing.T) {
+	testCases := []struct {
+		value  any
+		expect string
+	}{
+		{value: nil, expect: "c0"},
+		{value: true, expect: "c3"},
+		{value: false, expect: "c2"},
+		{value: 1, expect: "01"},
+		{value: 127, expect: "7f"},
+		{value: 128, expect: "cc80"},
+		{value: 256, expect: "cd0100"},
+		{value: 65536, expect: "ce00010000"},
+		{value: int64(math.MaxUint32) + 1, expect: "cf0000000100000000"},
+		{value: -1, expect: "ff"},
+		{value: -32, expect: "e0"},
+		{value: -33, expect: "d0df"},
+		{value: -129, expect: "d1ff7f"},
+		{value: -32769, expect: "d2ffff7fff"},
+		{value: int64(math.MinInt32) - 1, expect: "d3ffffffff7fffffff"},
+		{value: uint64(math.MaxUint64), expect: "cfffffffffffffffff"},
+		{value: int8(-2), expect: "fe"},
+		{value: uint16(300), expect: "cd012c"},
+		{value: 1.5, expect: "cb3ff8000000000000"},
+		{value: float32(1.5), expect: "ca3fc00000"},
+		{value: "a", expect: "a161"},
+		{value: strings.Repeat("x", 32), expect: "d920" + strings.Repeat("78", 32)},
+		{value: []byte{1, 2}, expect: "c4020102"},
+		{value: decimal.RequireFromString("1.50"), expect: "a3312e35"},
+		{value: time.Unix(0, 0), expect: "d6ff00000000"},
+		{value: time.Unix(1, 1), expect: "d7ff0000000400000001"},
+		{value: time.Unix(-1, 0), expect: "c70cff00000000ffffffffffffffff"},
+		{value: map[string]any{"b": 2, "a": 1}, expect: "82a16101a16202"},
+		{value: []any{1, "a"}, expect: "9201a161"},
+		{value: []map[string]any{{}}, expect: "9180"},
+		{value: []string{"a"}, expect: "91a161"},
+		{value: map[string]int{"a": 1}, expect: "81a16101"},
+		{value: &struct {
+			A string `json:"a"`
+		}{A: "x"}, expect: "81a161a178"},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.expect, func(t *testing.T) {
+			b, err := appendMsgPack(nil, tc.value)
+			require.NoError(t, err)
+			assert.Equal(t, tc.expect, hex.EncodeToString(b))
+		})
+	}
+}
+
+func TestMsgPackDecoder(t *testing.T) {
+	created := time.Date(2026, 1, 2, 3, 4, 5, 6, time.UTC)
+	values := []any{
+		nil, true, false, int64(1), int64(-33), int64(65536), uint64(math.MaxUint64), int64(math.MinInt64), 1.5, "a",
+		strings.Repeat("x", 300), []byte{1, 2}, created, time.Unix(0, 0).UTC(), time.Unix(-1, 0).UTC(),
+		map[string]any{"a": []any{int64(1), map[string]any{}}},
+	}
+	var b []byte
+	for _, v := range values {
+		var err error
+		b, err = appendMsgPack(b, v)
+		require.NoError(t, err)
+	}
+	d := NewMsgPackDecoder(bytes.NewReader(b))
+	for _, expect := range values {
+		v, err := d.Decode()
+		require.NoError(t, err)
+		assert.Equal(t, expect, v)
+	}
+	_, err := d.Decode()
+	assert.Equal(t, io.EOF, err)
+
+	_, err = NewMsgPackDecoder(bytes.NewReader([]byte{0x92, 0x01})).Decode()
+	assert.Equal(t, io.ErrUnexpectedEOF, err)
+	_, err = NewMsgPackDecoder(bytes.NewReader([]byte{0xc1})).Decode()
+	assert.Equal(t, "invalid msgpack code 0xc1", err.Error())
+	_, err = NewMsgPackDecoder(bytes.NewReader([]byte{0xd4, 0x01, 0x00})).Decode()
+	assert.Equal(t, "unsupported msgpack extension type 1", err.Error())
+	_, err = NewMsgPackDecoder(bytes.NewReader([]byte{0x81, 0x01, 0x01})).Decode()
+	assert.Equal(t, "unsupported msgpack map key type int64", err.Error())
+}
+
+func TestMapper_Write_MsgPack(t *testing.T) {
+	m, err := newMapper("id,name,data", Query(`FROM table`),
+		NewSubQuery("subs", `SELECT sub_id FROM sub_table WHERE id = ?`, []string{"id"}, nil, false))
+	require.NoError(t, err)
+	db, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+	require.NoError(t, err)
+	defer func() {
+		_ = db.Close()
+	}()
+	newRows := func() *sqlmock.Rows {
+		return sqlmock.NewRows([]string{"id", "name", "data"}).
+			AddRow(1, "foo", []byte{1}).
+			AddRow(2, nil, nil)
+	}
+	expectSubs := func() {
+		mock.ExpectQuery("SELECT sub_id FROM sub_table WHERE id = ?").WithArgs(1).WillReturnRows(sqlmock.NewRows([]string{"sub_id"}).AddRow(11))
+		mock.ExpectQuery("SELECT sub_id FROM sub_table WHERE id = ?").WithArgs(2).WillReturnRows(sqlmock.NewRows([]string{"sub_id"}))
+	}
+	row1 := map[string]any{"id": int64(1), "name": "foo", "data": []byte{1}, "subs": []any{map[string]any{"sub_id": int64(11)}}}
+	row2 := map[string]any{"id": int64(2), "name": nil, "data": nil, "subs": []any{}}
+
+	mock.ExpectQuery("SELECT id,name,data FROM table").WillReturnRows(newRows())
+	expectSubs()
+	w := &testFlushWriter{}
+	err = m.WriteRows(ctx, w, db, nil, Accept("application/msgpack"))
+	require.NoError(t, err)
+	require.NoError(t, mock.ExpectationsWereMet())
+	assert.Len(t, w.flushed, 2)
+	d := NewMsgPackDecoder(&w.Buffer)
+	v, err := d.Decode()
+	require.NoError(t, err)
+	assert.Equal(t, row1, v)
+	v, err = d.Decode()
+	require.NoError(t, err)
+	assert.Equal(t, row2, v)
+	_, err = d.Decode()
+	assert.Equal(t, io.EOF, err)
+
+	mock.ExpectQuery("SELECT id,name,data FROM table").WillReturnRows(newRows())
+	mock.ExpectQuery("SELECT sub_id FROM sub_table WHERE id = ?").WithArgs(1).WillReturnRows(sqlmock.NewRows([]string{"sub_id"}).AddRow(11))
+	buf := bytes.NewBuffer(nil)
+	err = m.WriteFirstRow(ctx, buf, db, nil, MsgPack)
+	require.NoError(t, err)
+	v, err = NewMsgPackDecoder(buf).Decode()
+	require.NoError(t, err)
+	assert.Equal(t, row1, v)
+
+	mock.ExpectQuery("SELECT COUNT(*) FROM (SELECT id,name,data FROM table) _count").WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(12))
+	mock.ExpectQuery("SELECT id,name,data FROM table LIMIT 10 OFFSET 10").WillReturnRows(newRows())
+	expectSubs()
+	buf = bytes.NewBuffer(nil)
+	err = m.WritePage(ctx, buf, db, nil, 2, 10, MsgPack)
+	require.NoError(t, err)
+	require.NoError(t, mock.ExpectationsWereMet())
+	v, err = NewMsgPackDecoder(buf).Decode()
+	require.NoError(t, err)
+	assert.Equal(t, map[string]any{
+		"rows":  []any{row1, row2},
+		"page":  int64(2),
+		"size":  int64(10),
+		"total": int64(12),
+		"pages": int64(2),
+	}, v)
+}
@@ -0,0 +1,203 @@
+package columbus
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"fmt"
+	"reflect"
+	"slices"
+	"strings"
+	"time"
+)
+
+// NamedArgs is a map of named arg values
+//
+// when NamedArgs is passed as the only arg to a row reading method (e.g. Mapper.Rows), named placeholders in the
+// query (e.g. `:tenant_id` or `@status`) are rewritten to positional '?' arg markers and bound to the named values
+//
+// named args can also be supplied as a single `map[string]any`, a single struct (or pointer to struct) - where field
+// names are the `sql` tag name or the field name - or as all sql.NamedArg (see sql.Named)
+//
+// an error is returned if a named placeholder in the query is not supplied - or, for maps and sql.NamedArg, if a supplied
+// name is not used in the query
+//
+// sub-queries (with no argColumns) bind named placeholders to the parent row properties when NamedArgs is passed as a
+// sub-query option (the values of the NamedArgs option are used for names that are not row properties) - e.g.
+//
+//	NewSubQuery("orders", "SELECT * FROM orders WHERE customer_id = :id", nil, nil, false, NamedArgs{})
+//
+// Note: any '?' arg markers in the query (e.g. from Keyset) are bound to the additional positional args
+type NamedArgs map[string]any
+
+// NamedArgError is the error returned when a named placeholder in the query is not supplied (or, if Unused is true,
+// when a supplied named arg is not used in the query)
+type NamedArgError struct {
+	Name   string
+	Unused bool
+}
+
+func (e *NamedArgError) Error() string {
+	if e.Unused {
+		return fmt.Sprintf("named arg '%s' not used", e.Name)
+	}
+	return fmt.Sprintf("named arg '%s' not supplied", e.Name)
+}
+
+// namedValues is the source of values for named placeholders
+type namedValues struct {
+	get func(name string) (any, bool)
+	// names is the supplied names - checked for unused names (nil if unused names are not checked)
+	names []string
+}
+
+// namedArgsOf determines whether the args are named args - returning the named values if so
+func namedArgsOf(args []any) (*namedValues, bool) {
+	if len(args) == 1 {
+		switch at := args[0].(type) {
+		case NamedArgs:
+			return mapNamedValues(at), true
+		case map[string]any:
+			return mapNamedValues(at), true
+		case *namedValues:
+			return at, true
+		case sql.NamedArg:
+			return mapNamedValues(map[string]any{at.Name: at.Value}), true
+		case driver.Valuer, time.Time:
+			return nil, false
+		}
+		if rv := reflect.ValueOf(args[0]); rv.Kind() == reflect.Struct || (rv.Kind() == reflect.Ptr && !rv.IsNil() && rv.Elem().Kind() == reflect.Struct) {
+			return structNamedValues(reflect.Indirect(rv)), true
+		}
+	}
+	if len(args) > 0 {
+		m := make(map[string]any, len(args))
+		for _, arg := range args {
+			if na, ok := arg.(sql.NamedArg); ok {
+				m[na.Name] = na.Value
+			} else {
+				return nil, false
+			}
+		}
+		return mapNamedValues(m), true
+	}
+	return nil, false
+}
+
+func mapNamedValues(m map[string]any) *namedValues {
+	names := make([]string, 0, len(m))
+	for k := range m {
+		names = append(names, k)
+	}
+	slices.Sort(names)
+	return &namedValues{
+		get: func(name string) (v any, ok bool) {
+			v, ok = m[name]
+			return
+		},
+		names: names,
+	}
+}
+
+func structNamedValues(rv reflect.Value) *namedValues {
+	return &namedValues{
+		get: func(name string) (any, bool) {
+			rt := rv.Type()
+			for i := 0; i < rt.NumField(); i++ {
+				if fld := rt.Field(i); fld.IsExported() {
+					fn := fld.Name
+					if tag, _, _ := strings.Cut(fld.Tag.Get(sqlTag), ","); tag != "" {
+						fn = tag
+					}
+					if fn == name && fn != "-" {
+						return rv.Field(i).Interface(), true
+					}
+				}
+			}
+			return nil, false
+		},
+	}
+}
+
+// rowNamedValues provides named values from a row (used for sub-queries) - falling back to the values of named (for
+// names that are not row properties) - unused names are not checked
+func rowNamedValues(row map[string]any, named NamedArgs) *namedValues {
+	return &namedValues{
+		get: func(name string) (v any, ok bool) {
+			if v, ok = row[name]; !ok {
+				v, ok = named[name]
+			}
+			return
+		},
+	}
+}
+
+// bind rewrites the named placeholders in the query to '?' arg markers - returning the rewritten query and positional args
+//
+// any '?' arg markers already in the query are bound to the positional args
+func (nv *namedValues) bind(query string, positional []any) (string, []any, error) {
+	var sb strings.Builder
+	args := make([]any, 0, len(positional))
+	used := make(map[string]bool)
+	last, skip, pos := 0, -1, 0
+	var err error
+	scanSql(query, false, func(i int, depth int) {
+		if i < skip || err != nil {
+			return
+		}
+		if c := query[i]; c == '?' {
+			if pos < len(positional) {
+				args = append(args, positional[pos])
+			}
+			pos++
+		} else if (c == ':' || c == '@') && i+1 < len(query) && isNameStart(query[i+1]) && (i == 0 || !isNamePrecede(query[i-1])) {
+			end := i + 2
+			for ; end < len(query) && isNameChar(query[end]); end++ {
+			}
+			name := query[i+1 : end]
+			if v, ok := nv.get(name); ok {
+				used[name] = true
+				args = append(args, v)
+				sb.WriteString(query[last:i])
+				sb.WriteString("?")
+				last, skip = end, end
+			} else {
+				err = &NamedArgError{Name: name}
+			}
+		}
+	})
+	if err != nil {
+		return "", nil, err
+	}
+	for _, name := range nv.names {
+		if !used[name] {
+			return "", nil, &NamedArgError{Name: name, Unused: true}
+		}
+	}
+	sb.WriteString(query[last:])
+	return sb.String(), args, nil
+}
+
+// placeholderNames returns the names of the named placeholders in a query
+func placeholderNames(query string) (result []string) {
+	nv := &namedValues{
+		get: func(name string) (any, bool) {
+			result = append(result, name)
+			return nil, true
+		},
+	}
+	_, _, _ = nv.bind(query, nil)
+	return result
+}
+
+func isNameStart(c byte) bool {
+	return c == '_' || (c >= 'A' && c <= 'Z') || (c >= 'a' && c <= 'z')
+}
+
+func isNameChar(c byte) bool {
+	return isNameStart(c) || (c >= '0' && c <= '9')
+}
+
+// isNamePrecede checks whether a character preceding a ':' or '@' means it is not a named placeholder (e.g. `::` casts or `@@` variables)
+func isNamePrecede(c byte) bool {
+	return c == ':' || c == '@' || isNameChar(c)
+}
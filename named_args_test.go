@@ -0,0 +1,202 @@
+package columbus
+
+import (
+	"database/sql"
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"regexp"
+	"testing"
+	"time"
+)
+
+func TestNamedArgsOf(t *testing.T) {
+	type args struct {
+		TenantId int `sql:"tenant_id"`
+		Status   string
+		Ignored  string `sql:"-"`
+		private  string
+	}
+	testCases := []struct {
+		args   []any
+		expect bool
+	}{
+		{nil, false},
+		{[]any{}, false},
+		{[]any{1}, false},
+		{[]any{"a", "b"}, false},
+		{[]any{time.Now()}, false},
+		{[]any{decimal.NewFromInt(1)}, false},
+		{[]any{sql.NullString{}}, false},
+		{[]any{(*args)(nil)}, false},
+		{[]any{NamedArgs{}}, true},
+		{[]any{map[string]any{}}, true},
+		{[]any{args{}}, true},
+		{[]any{&args{}}, true},
+		{[]any{sql.Named("a", 1), sql.Named("b", 2)}, true},
+		{[]any{sql.Named("a", 1), 2}, false},
+	}
+	for _, tc := range testCases {
+		_, ok := namedArgsOf(tc.args)
+		assert.Equal(t, tc.expect, ok)
+	}
+
+	nv, _ := namedArgsOf([]any{args{TenantId: 1, Status: "active", Ignored: "x", private: "y"}})
+	v, ok := nv.get("tenant_id")
+	assert.True(t, ok)
+	assert.Equal(t, 1, v)
+	v, ok = nv.get("Status")
+	assert.True(t, ok)
+	assert.Equal(t, "active", v)
+	_, ok = nv.get("TenantId")
+	assert.False(t, ok)
+	_, ok = nv.get("Ignored")
+	assert.False(t, ok)
+	_, ok = nv.get("private")
+	assert.False(t, ok)
+}
+
+func TestNamedValues_bind(t *testing.T) {
+	nv := mapNamedValues(map[string]any{"tenant_id": 1, "status": "active"})
+	q, args, err := nv.bind("SELECT * FROM t WHERE tenant_id = :tenant_id AND (status = @status OR :tenant_id = 0)", nil)
+	require.NoError(t, err)
+	assert.Equal(t, "SELECT * FROM t WHERE tenant_id = ? AND (status = ? OR ? = 0)", q)
+	assert.Equal(t, []any{1, "active", 1}, args)
+
+	q, args, err = nv.bind("SELECT a::text, @@version, 'x:y @z', \"a:b\" FROM t -- :c\nWHERE tenant_id = :tenant_id AND status = :status AND x > ?", []any{"k"})
+	require.NoError(t, err)
+	assert.Equal(t, "SELECT a::text, @@version, 'x:y @z', \"a:b\" FROM t -- :c\nWHERE tenant_id = ? AND status = ? AND x > ?", q)
+	assert.Equal(t, []any{1, "active", "k"}, args)
+
+	_, _, err = nv.bind("SELECT * FROM t WHERE tenant_id = :tenant_id AND status = :status AND x = :other", nil)
+	require.Error(t, err)
+	assert.Equal(t, "named arg 'other' not supplied", err.Error())
+
+	_, _, err = nv.bind("SELECT * FROM t WHERE tenant_id = :tenant_id", nil)
+	require.Error(t, err)
+	assert.Equal(t, "named arg 'status' not used", err.Error())
+
+	// row named values don't check unused...
+	nv = rowNamedValues(map[string]any{"id": 1, "other": 2}, NamedArgs{"id": 0, "kind": "x"})
+	q, args, err = nv.bind("SELECT * FROM t WHERE parent_id = :id AND kind = :kind", nil)
+	require.NoError(t, err)
+	assert.Equal(t, "SELECT * FROM t WHERE parent_id = ? AND kind = ?", q)
+	assert.Equal(t, []any{1, "x"}, args)
+}
+
+func TestMapper_NamedArgs(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer func() {
+		_ = db.Close()
+	}()
+	m, err := NewMapper("id,name", Query("FROM parents WHERE tenant_id = :tenant_id"),
+		NewSubQuery("children", "SELECT name FROM children WHERE parent_id = :id", nil, nil, false, NamedArgs{}))
+	require.NoError(t, err)
+
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT id,name FROM parents WHERE tenant_id = ? AND status = ?")).
+		WithArgs(1, "active").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "name"}).AddRow(10, "p1"))
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT name FROM children WHERE parent_id = ?")).
+		WithArgs(10).
+		WillReturnRows(sqlmock.NewRows([]string{"name"}).AddRow("c1"))
+	rows, err := m.Rows(ctx, db, []any{NamedArgs{"tenant_id": 1, "status": "active"}}, AddClause("AND status = @status"))
+	require.NoError(t, err)
+	require.NoError(t, mock.ExpectationsWereMet())
+	require.Len(t, rows, 1)
+	assert.Equal(t, []map[string]any{{"name": "c1"}}, rows[0]["children"])
+
+	// with dialect...
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT id,name FROM parents WHERE tenant_id = $1")).
+		WithArgs(1).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "name"}).AddRow(10, "p1"))
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT name FROM children WHERE parent_id = $1")).
+		WithArgs(10).
+		WillReturnRows(sqlmock.NewRows([]string{"name"}))
+	_, err = m.FirstRow(ctx, db, []any{sql.Named("tenant_id", 1)}, Postgres)
+	require.NoError(t, err)
+	require.NoError(t, mock.ExpectationsWereMet())
+
+	// missing and unused...
+	_, err = m.Rows(ctx, db, []any{NamedArgs{"other": 1}})
+	require.Error(t, err)
+	assert.Equal(t, "named arg 'tenant_id' not supplied", err.Error())
+	_, err = m.Rows(ctx, db, []any{NamedArgs{"tenant_id": 1, "other": 1}})
+	require.Error(t, err)
+	assert.Equal(t, "named arg 'other' not used", err.Error())
+}
+
+func TestMapper_NamedArgs_Page(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer func() {
+		_ = db.Close()
+	}()
+	m, err := NewMapper("id,name", Query("FROM parents WHERE tenant_id = :tenant_id"))
+	require.NoError(t, err)
+
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT COUNT(*) FROM (SELECT id,name FROM parents WHERE tenant_id = ?) _count")).
+		WithArgs(1).
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(1))
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT id,name FROM parents WHERE tenant_id = ? LIMIT 10")).
+		WithArgs(1).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "name"}).AddRow(10, "p1"))
+	result, err := m.Page(ctx, db, []any{map[string]any{"tenant_id": 1}}, 1, 10)
+	require.NoError(t, err)
+	require.NoError(t, mock.ExpectationsWereMet())
+	assert.Len(t, result.Rows, 1)
+
+	_, err = m.Page(ctx, db, []any{NamedArgs{}}, 1, 10)
+	require.Error(t, err)
+	assert.Equal(t, "named arg 'tenant_id' not supplied", err.Error())
+}
+
+func TestStructMapper_NamedArgs(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer func() {
+		_ = db.Close()
+	}()
+	m, err := NewStructMapper[testStruct]("foo,bar", Query("FROM table WHERE foo = :foo"))
+	require.NoError(t, err)
+
+	type filter struct {
+		Foo string `sql:"foo"`
+	}
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT foo,bar FROM table WHERE foo = ?")).
+		WithArgs("x").
+		WillReturnRows(sqlmock.NewRows([]string{"foo", "bar"}).AddRow("x", "y"))
+	rows, err := m.Rows(ctx, db, []any{&filter{Foo: "x"}})
+	require.NoError(t, err)
+	require.NoError(t, mock.ExpectationsWereMet())
+	require.Len(t, rows, 1)
+}
+
+func TestMapper_SubQuery_NamedArgs(t *testing.T) {
+	db, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+	require.NoError(t, err)
+	defer func() {
+		_ = db.Close()
+	}()
+	m, err := newMapper("id,name", Query("FROM customers"),
+		NewSubQuery("orders", "SELECT total FROM orders WHERE customer_id = :id AND status = :status", nil, nil, false, NamedArgs{"status": "open"}),
+		NewSubQuery("ranked", "SELECT @rank := @rank + 1 AS r FROM ranks", nil, nil, false),
+	)
+	require.NoError(t, err)
+
+	mock.ExpectQuery("SELECT id,name FROM customers").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "name"}).AddRow(1, "foo"))
+	mock.ExpectQuery("SELECT total FROM orders WHERE customer_id = ? AND status = ?").WithArgs(1, "open").
+		WillReturnRows(sqlmock.NewRows([]string{"total"}).AddRow(10))
+	// not opted in - so named placeholders are not bound...
+	mock.ExpectQuery("SELECT @rank := @rank + 1 AS r FROM ranks").WithoutArgs().
+		WillReturnRows(sqlmock.NewRows([]string{"r"}).AddRow(1))
+	rows, err := m.Rows(ctx, db, nil)
+	require.NoError(t, err)
+	require.NoError(t, mock.ExpectationsWereMet())
+	assert.Equal(t, []map[string]any{{"id": int64(1), "name": "foo",
+		"orders": []map[string]any{{"total": int64(10)}},
+		"ranked": []map[string]any{{"r": int64(1)}},
+	}}, rows)
+}
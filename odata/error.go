@@ -0,0 +1,29 @@
+package odata
+
+import "fmt"
+
+// Error is the error returned by Options when a query option is invalid
+type Error struct {
+	// Option is the query option name (e.g. `$filter`)
+	Option string
+	// Message is the error message
+	Message string
+	// Err is the underlying error (e.g. a *filter.SyntaxError) - may be nil
+	Err error
+}
+
+func newError(option string, message string) error {
+	return &Error{Option: option, Message: message}
+}
+
+func wrapError(option string, err error) error {
+	return &Error{Option: option, Message: err.Error(), Err: err}
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("invalid query option '%s': %s", e.Option, e.Message)
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
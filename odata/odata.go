@@ -0,0 +1,195 @@
+// Package odata - converts OData style system query options ($select, $filter, $orderby, $top, $skip and $expand) into columbus options
+package odata
+
+import (
+	"fmt"
+	"github.com/go-andiamo/columbus"
+	"github.com/go-andiamo/columbus/filter"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Config is the configuration used by Options to convert query options
+type Config struct {
+	// Filter is the filter.Resolver used to resolve $filter property names to columns
+	Filter filter.Resolver
+	// HasWhere indicates that the query already has a WHERE clause - so the $filter is added as `AND ...` (rather than `WHERE ...`)
+	HasWhere bool
+	// Sortable is the allow-list of properties that can be used in $orderby (see columbus.SortableProperties)
+	//
+	// if nil, the SortableProperties of the Mapper (or its Mappings) are used
+	Sortable columbus.SortableProperties
+	// Expandable is the property names of sub-queries that are only executed when the property is requested using $expand
+	//
+	// the sub-queries themselves must be passed to the Mapper (e.g. as options to NewMapper) - an expandable sub-query
+	// that is not requested is excluded by its SubQuery.ProvidesProperty
+	Expandable []string
+	// MaxTop is the maximum number of rows that can be requested using $top (if $top exceeds MaxTop, MaxTop is used)
+	//
+	// if greater than zero, MaxTop is also used as the row limit when no $top is specified
+	MaxTop int
+}
+
+const (
+	optionSelect  = "$select"
+	optionFilter  = "$filter"
+	optionOrderBy = "$orderby"
+	optionTop     = "$top"
+	optionSkip    = "$skip"
+	optionExpand  = "$expand"
+)
+
+// Options converts OData style system query options (from url query values) into options that can be passed
+// to columbus Mapper.Rows, Mapper.WriteRows etc.
+//
+// the supported query options are:
+//
+//   - `$select` - a comma separated list of properties to include in the rows (nested properties can be specified using '/' - e.g. `address/city`)
+//   - `$filter` - a filter expression (see filter.ParseOData) - converted to a columbus.AddClauseWithArgs
+//   - `$orderby` - a comma separated list of properties, each optionally followed by `asc` or `desc` - converted to a columbus.Sort
+//   - `$top` and `$skip` - converted to a columbus.RowLimit
+//   - `$expand` - a comma separated list of Config.Expandable properties to include
+//
+// query values not starting with '$' are ignored - any other '$' query option results in an error
+//
+// any error returned is an *Error
+func Options(values url.Values, config Config) ([]any, error) {
+	result := make([]any, 0)
+	for name, vs := range values {
+		if !strings.HasPrefix(name, "$") {
+			continue
+		}
+		switch name {
+		case optionSelect, optionFilter, optionOrderBy, optionTop, optionSkip, optionExpand:
+			if len(vs) > 1 {
+				return nil, newError(name, "specified more than once")
+			}
+		default:
+			return nil, newError(name, "unsupported query option")
+		}
+	}
+	excluder, err := newExcluder(values, config.Expandable)
+	if err != nil {
+		return nil, err
+	} else if excluder != nil {
+		result = append(result, excluder)
+	}
+	if clause, err := filterClause(values.Get(optionFilter), config); err != nil {
+		return nil, err
+	} else if clause.Clause != "" {
+		result = append(result, clause)
+	}
+	if sort, err := orderBy(values.Get(optionOrderBy)); err != nil {
+		return nil, err
+	} else if sort != "" {
+		result = append(result, sort)
+		if config.Sortable != nil {
+			result = append(result, config.Sortable)
+		}
+	}
+	if rowLimit, err := topSkip(values, config.MaxTop); err != nil {
+		return nil, err
+	} else if rowLimit != nil {
+		result = append(result, *rowLimit)
+	}
+	return result, nil
+}
+
+var propertyRegex = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*(\.[A-Za-z_][A-Za-z0-9_]*)*$`)
+
+// propertyList parses a comma separated list of property names (converting any '/' path separators to '.')
+func propertyList(option string, value string) ([]string, error) {
+	result := make([]string, 0)
+	for _, s := range strings.Split(value, ",") {
+		if s = strings.ReplaceAll(strings.TrimSpace(s), "/", "."); s == "" {
+			return nil, newError(option, "empty property name")
+		} else if s != "*" && !propertyRegex.MatchString(s) {
+			return nil, newError(option, fmt.Sprintf("invalid property name '%s'", s))
+		}
+		result = append(result, s)
+	}
+	return result, nil
+}
+
+func filterClause(value string, config Config) (columbus.AddClauseWithArgs, error) {
+	f, err := filter.ParseOData(value)
+	if err != nil {
+		return columbus.AddClauseWithArgs{}, wrapError(optionFilter, err)
+	}
+	var clause columbus.AddClauseWithArgs
+	if config.HasWhere {
+		clause, err = f.And(config.Filter)
+	} else {
+		clause, err = f.Where(config.Filter)
+	}
+	if err != nil {
+		return clause, wrapError(optionFilter, err)
+	}
+	return clause, nil
+}
+
+// orderBy converts the $orderby to a columbus.Sort
+func orderBy(value string) (columbus.Sort, error) {
+	if strings.TrimSpace(value) == "" {
+		return "", nil
+	}
+	parts := make([]string, 0)
+	for _, s := range strings.Split(value, ",") {
+		fields := strings.Fields(s)
+		if len(fields) == 0 {
+			return "", newError(optionOrderBy, "empty property name")
+		} else if len(fields) > 2 {
+			return "", newError(optionOrderBy, fmt.Sprintf("invalid order '%s'", strings.TrimSpace(s)))
+		}
+		property := strings.ReplaceAll(fields[0], "/", ".")
+		if !propertyRegex.MatchString(property) {
+			return "", newError(optionOrderBy, fmt.Sprintf("invalid property name '%s'", property))
+		}
+		if len(fields) == 2 {
+			switch strings.ToLower(fields[1]) {
+			case "asc":
+			case "desc":
+				property = "-" + property
+			default:
+				return "", newError(optionOrderBy, fmt.Sprintf("invalid direction '%s'", fields[1]))
+			}
+		}
+		parts = append(parts, property)
+	}
+	return columbus.Sort(strings.Join(parts, ",")), nil
+}
+
+// topSkip converts the $top and $skip to a columbus.RowLimit (nil if no row limit)
+func topSkip(values url.Values, maxTop int) (*columbus.RowLimit, error) {
+	top, hasTop, err := nonNegativeInt(values, optionTop)
+	if err != nil {
+		return nil, err
+	}
+	skip, hasSkip, err := nonNegativeInt(values, optionSkip)
+	if err != nil {
+		return nil, err
+	}
+	if maxTop > 0 && (!hasTop || top > maxTop) {
+		top, hasTop = maxTop, true
+	}
+	if !hasTop {
+		if hasSkip {
+			return nil, newError(optionSkip, "cannot be used without $top")
+		}
+		return nil, nil
+	}
+	return &columbus.RowLimit{Limit: top, Offset: skip}, nil
+}
+
+func nonNegativeInt(values url.Values, option string) (int, bool, error) {
+	if !values.Has(option) {
+		return 0, false, nil
+	}
+	i, err := strconv.Atoi(strings.TrimSpace(values.Get(option)))
+	if err != nil || i < 0 {
+		return 0, false, newError(option, "must be a non-negative integer")
+	}
+	return i, true, nil
+}
@@ -0,0 +1,152 @@
+package odata
+
+import (
+	"context"
+	"errors"
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/go-andiamo/columbus"
+	"github.com/go-andiamo/columbus/filter"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"net/url"
+	"regexp"
+	"testing"
+)
+
+func TestOptions(t *testing.T) {
+	config := Config{
+		Filter:   filter.Resolver{Properties: filter.Properties{"name": {Type: filter.String}, "age": {Type: filter.Number}}},
+		Sortable: columbus.SortableProperties{"name": "", "age": ""},
+		MaxTop:   100,
+	}
+	values, err := url.ParseQuery("$filter=name eq 'x' and age gt 21&$orderby=name desc,age&$top=10&$skip=20&other=ignored")
+	require.NoError(t, err)
+	opts, err := Options(values, config)
+	require.NoError(t, err)
+	assert.Equal(t, []any{
+		columbus.AddClauseWithArgs{Clause: "WHERE (name = ? AND age > ?)", Args: []any{"x", int64(21)}},
+		columbus.Sort("-name,age"),
+		config.Sortable,
+		columbus.RowLimit{Limit: 10, Offset: 20},
+	}, opts)
+
+	config.HasWhere = true
+	opts, err = Options(url.Values{"$filter": {"age ge 18"}}, config)
+	require.NoError(t, err)
+	assert.Equal(t, []any{
+		columbus.AddClauseWithArgs{Clause: "AND age >= ?", Args: []any{int64(18)}},
+		columbus.RowLimit{Limit: 100},
+	}, opts)
+
+	opts, err = Options(url.Values{"$top": {"1000"}, "$orderby": {"address/city"}}, Config{MaxTop: 100})
+	require.NoError(t, err)
+	assert.Equal(t, []any{
+		columbus.Sort("address.city"),
+		columbus.RowLimit{Limit: 100},
+	}, opts)
+
+	opts, err = Options(url.Values{}, Config{})
+	require.NoError(t, err)
+	assert.Empty(t, opts)
+}
+
+func TestOptions_Errors(t *testing.T) {
+	config := Config{
+		Filter:     filter.Resolver{Properties: filter.Properties{"name": {Type: filter.String}}},
+		Expandable: []string{"children"},
+	}
+	testCases := []struct {
+		query     string
+		expectErr string
+	}{
+		{query: "$count=true", expectErr: "invalid query option '$count': unsupported query option"},
+		{query: "$top=1&$top=2", expectErr: "invalid query option '$top': specified more than once"},
+		{query: "$top=x", expectErr: "invalid query option '$top': must be a non-negative integer"},
+		{query: "$top=-1", expectErr: "invalid query option '$top': must be a non-negative integer"},
+		{query: "$top=1&$skip=-1", expectErr: "invalid query option '$skip': must be a non-negative integer"},
+		{query: "$skip=10", expectErr: "invalid query option '$skip': cannot be used without $top"},
+		{query: "$filter=name eq", expectErr: "invalid query option '$filter': filter syntax error at position 7: expected value"},
+		{query: "$filter=age eq 1", expectErr: "invalid query option '$filter': unknown filter property 'age'"},
+		{query: "$orderby=name,", expectErr: "invalid query option '$orderby': empty property name"},
+		{query: "$orderby=name up", expectErr: "invalid query option '$orderby': invalid direction 'up'"},
+		{query: "$orderby=name desc nulls", expectErr: "invalid query option '$orderby': invalid order 'name desc nulls'"},
+		{query: "$orderby=na-me", expectErr: "invalid query option '$orderby': invalid property name 'na-me'"},
+		{query: "$select=name,,age", expectErr: "invalid query option '$select': empty property name"},
+		{query: "$select=name+drop", expectErr: "invalid query option '$select': invalid property name 'name drop'"},
+		{query: "$expand=parent", expectErr: "invalid query option '$expand': property 'parent' cannot be expanded"},
+		{query: "$expand=children($select=name)", expectErr: "invalid query option '$expand': invalid property name 'children($select=name)'"},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.query, func(t *testing.T) {
+			values, err := url.ParseQuery(tc.query)
+			require.NoError(t, err)
+			_, err = Options(values, config)
+			require.Error(t, err)
+			assert.Equal(t, tc.expectErr, err.Error())
+			var oErr *Error
+			assert.True(t, errors.As(err, &oErr))
+		})
+	}
+
+	_, err := Options(url.Values{"$filter": {"name eq 1"}}, config)
+	var tmErr *filter.TypeMismatchError
+	assert.True(t, errors.As(err, &tmErr))
+}
+
+func TestOptions_Mapper(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer func() {
+		_ = db.Close()
+	}()
+	m, err := columbus.NewMapper("id,name,city", columbus.Query("FROM people"),
+		columbus.Mappings{"city": {Path: []string{"address"}}},
+		columbus.NewSubQuery("children", "SELECT name FROM children WHERE parent_id = ?", []string{"id"}, nil, false))
+	require.NoError(t, err)
+	config := Config{
+		Filter:     filter.Resolver{Properties: filter.Properties{"name": {Type: filter.String}}},
+		Expandable: []string{"children"},
+	}
+
+	values, err := url.ParseQuery("$select=name,address&$filter=startswith(name,'J')&$orderby=address/city desc&$top=5")
+	require.NoError(t, err)
+	opts, err := Options(values, config)
+	require.NoError(t, err)
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT id,name,city FROM people WHERE name LIKE ? ESCAPE '!' ORDER BY city DESC LIMIT 5")).
+		WithArgs("J%").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "name", "city"}).AddRow(1, "Jane", "London"))
+	rows, err := m.Rows(context.Background(), db, nil, opts...)
+	require.NoError(t, err)
+	require.NoError(t, mock.ExpectationsWereMet())
+	assert.Equal(t, []map[string]any{{"name": "Jane", "address": map[string]any{"city": "London"}}}, rows)
+
+	// expanded...
+	values, err = url.ParseQuery("$expand=children&$skip=5&$top=5")
+	require.NoError(t, err)
+	opts, err = Options(values, config)
+	require.NoError(t, err)
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT id,name,city FROM people LIMIT 5 OFFSET 5")).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "name", "city"}).AddRow(1, "Jane", "London"))
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT name FROM children WHERE parent_id = ?")).
+		WithArgs(1).
+		WillReturnRows(sqlmock.NewRows([]string{"name"}).AddRow("Joe"))
+	rows, err = m.Rows(context.Background(), db, nil, opts...)
+	require.NoError(t, err)
+	require.NoError(t, mock.ExpectationsWereMet())
+	assert.Equal(t, []map[string]any{{"id": int64(1), "name": "Jane", "address": map[string]any{"city": "London"}, "children": []map[string]any{{"name": "Joe"}}}}, rows)
+
+	// selected and expanded (sub-query arg property is kept)...
+	values, err = url.ParseQuery("$select=name,children&$expand=children")
+	require.NoError(t, err)
+	opts, err = Options(values, config)
+	require.NoError(t, err)
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT id,name,city FROM people")).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "name", "city"}).AddRow(1, "Jane", "London"))
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT name FROM children WHERE parent_id = ?")).
+		WithArgs(1).
+		WillReturnRows(sqlmock.NewRows([]string{"name"}).AddRow("Joe"))
+	rows, err = m.Rows(context.Background(), db, nil, opts...)
+	require.NoError(t, err)
+	require.NoError(t, mock.ExpectationsWereMet())
+	assert.Equal(t, []map[string]any{{"id": int64(1), "name": "Jane", "children": []map[string]any{{"name": "Joe"}}}}, rows)
+}
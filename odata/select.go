@@ -0,0 +1,94 @@
+package odata
+
+import (
+	"fmt"
+	"github.com/go-andiamo/columbus"
+	"net/url"
+	"strings"
+)
+
+// excluder is the columbus.PropertyExcluder for $select and $expand
+type excluder struct {
+	// selected is the selected property names (nil if all properties are selected)
+	selected map[string]struct{}
+	// expandable is the properties that are only included when expanded
+	expandable map[string]struct{}
+	// expanded is the expanded properties
+	expanded map[string]struct{}
+}
+
+var _ columbus.PropertyExcluder = (*excluder)(nil)
+
+// newExcluder creates the excluder for $select and $expand (returns nil if no properties are to be excluded)
+func newExcluder(values url.Values, expandable []string) (*excluder, error) {
+	result := &excluder{
+		expandable: make(map[string]struct{}, len(expandable)),
+		expanded:   map[string]struct{}{},
+	}
+	for _, p := range expandable {
+		result.expandable[p] = struct{}{}
+	}
+	if vs, ok := values[optionSelect]; ok && len(vs) > 0 {
+		properties, err := propertyList(optionSelect, vs[0])
+		if err != nil {
+			return nil, err
+		}
+		result.selected = make(map[string]struct{}, len(properties))
+		for _, p := range properties {
+			if p == "*" {
+				result.selected = nil
+				break
+			}
+			result.selected[p] = struct{}{}
+		}
+	}
+	if vs, ok := values[optionExpand]; ok && len(vs) > 0 {
+		properties, err := propertyList(optionExpand, vs[0])
+		if err != nil {
+			return nil, err
+		}
+		for _, p := range properties {
+			if p == "*" {
+				for ep := range result.expandable {
+					result.expanded[ep] = struct{}{}
+				}
+			} else if _, ok := result.expandable[p]; ok {
+				result.expanded[p] = struct{}{}
+			} else {
+				return nil, newError(optionExpand, fmt.Sprintf("property '%s' cannot be expanded", p))
+			}
+		}
+	}
+	if result.selected == nil && len(result.expandable) == len(result.expanded) {
+		return nil, nil
+	}
+	return result, nil
+}
+
+// Exclude implements columbus.PropertyExcluder
+//
+// expandable properties (and any properties within them) are included only if expanded - other properties are included
+// if the property, or any of its parent paths, is selected
+func (e *excluder) Exclude(property string, path []string) bool {
+	name := property
+	if len(path) > 0 {
+		name = strings.Join(path, ".") + "." + property
+	}
+	top, _, _ := strings.Cut(name, ".")
+	if _, ok := e.expandable[top]; ok {
+		_, expanded := e.expanded[top]
+		return !expanded
+	} else if e.selected == nil {
+		return false
+	}
+	for {
+		if _, ok := e.selected[name]; ok {
+			return false
+		}
+		i := strings.LastIndexByte(name, '.')
+		if i == -1 {
+			return true
+		}
+		name = name[:i]
+	}
+}
@@ -0,0 +1,77 @@
+package odata
+
+import (
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestExcluder(t *testing.T) {
+	testCases := []struct {
+		query      string
+		expandable []string
+		expectNil  bool
+		included   []string
+		excluded   []string
+	}{
+		{query: "", expectNil: true},
+		{query: "$select=*", expectNil: true},
+		{query: "$expand=children", expandable: []string{"children"}, expectNil: true},
+		{query: "$expand=*", expandable: []string{"children", "parent"}, expectNil: true},
+		{
+			query:      "",
+			expandable: []string{"children"},
+			included:   []string{"id", "address.city"},
+			excluded:   []string{"children", "children.name"},
+		},
+		{
+			query:    "$select=id,address/city",
+			included: []string{"id", "address.city"},
+			excluded: []string{"name", "address.street", "address"},
+		},
+		{
+			query:    "$select=address",
+			included: []string{"address", "address.city", "address.geo.lat"},
+			excluded: []string{"id"},
+		},
+		{
+			query:      "$select=id&$expand=children",
+			expandable: []string{"children", "parent"},
+			included:   []string{"id", "children", "children.name"},
+			excluded:   []string{"name", "parent", "parent.id"},
+		},
+		{
+			query:      "$select=*&$expand=parent",
+			expandable: []string{"children", "parent"},
+			included:   []string{"id", "parent", "parent.id"},
+			excluded:   []string{"children"},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.query, func(t *testing.T) {
+			values, err := url.ParseQuery(tc.query)
+			require.NoError(t, err)
+			exc, err := newExcluder(values, tc.expandable)
+			require.NoError(t, err)
+			if tc.expectNil {
+				require.Nil(t, exc)
+				return
+			}
+			require.NotNil(t, exc)
+			split := func(name string) (string, []string) {
+				parts := strings.Split(name, ".")
+				return parts[len(parts)-1], parts[:len(parts)-1]
+			}
+			for _, name := range tc.included {
+				property, path := split(name)
+				assert.False(t, exc.Exclude(property, path), "expected %q included", name)
+			}
+			for _, name := range tc.excluded {
+				property, path := split(name)
+				assert.True(t, exc.Exclude(property, path), "expected %q excluded", name)
+			}
+		})
+	}
+}
@@ -0,0 +1,196 @@
+package columbus
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/shopspring/decimal"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// PageResult is the result of Mapper.Page - the rows of the page along with the page metadata
+type PageResult struct {
+	// Rows is the rows of the page
+	Rows []map[string]any `json:"rows"`
+	// Page is the page number (starting at 1)
+	Page int `json:"page"`
+	// Size is the page size
+	Size int `json:"size"`
+	// Total is the total number of rows (across all pages)
+	Total int64 `json:"total"`
+	// Pages is the total number of pages
+	Pages int64 `json:"pages"`
+}
+
+// PageCount is an option that can be passed to Mapper.Page or Mapper.WritePage and determines how the total count is obtained
+type PageCount int
+
+const (
+	// PageCountQuery obtains the total count by executing a count query - i.e. `SELECT COUNT(*) FROM (<query>) _count` (the default)
+	PageCountQuery PageCount = iota
+	// PageCountWindow obtains the total count using a `COUNT(*) OVER()` window function added to the page query
+	//
+	// the database must support window functions - if the page is empty (e.g. past the last page), a count query is used
+	//
+	// Note: the window function can only be added when the query is `SELECT cols ...` (i.e. a Query option or default query) -
+	// otherwise a count query is used
+	PageCountWindow
+)
+
+const pageTotalProperty = "_total"
+
+// pageOptions separates the PageCount option from the row mapping options
+func pageOptions(options []any) (mode PageCount, rowOptions []any) {
+	rowOptions = make([]any, 0, len(options))
+	for _, o := range options {
+		if pc, ok := o.(PageCount); ok {
+			mode = pc
+		} else {
+			rowOptions = append(rowOptions, o)
+		}
+	}
+	return mode, rowOptions
+}
+
+func (m *mapper) Page(ctx context.Context, sqli SqlInterface, args []any, page int, size int, options ...any) (*PageResult, error) {
+	opts, total, err := m.pageMapOptions(ctx, sqli, args, page, size, options, false)
+	if err != nil {
+		return nil, err
+	}
+	sqli = opts.sqlInterface(sqli)
+	result := &PageResult{Page: page, Size: size}
+	if result.Rows, err = m.rows(ctx, sqli, args, opts); err != nil {
+		return nil, err
+	}
+	if err = total.resolve(ctx, sqli, len(result.Rows), opts.errorTranslator); err != nil {
+		return nil, err
+	}
+	result.setTotal(total.total)
+	return result, nil
+}
+
+func (m *mapper) WritePage(ctx context.Context, writer io.Writer, sqli SqlInterface, args []any, page int, size int, options ...any) (err error) {
+	opts, total, err := m.pageMapOptions(ctx, sqli, args, page, size, options, true)
+	if err != nil {
+		return err
+	}
+	sqli = opts.sqlInterface(sqli)
+	enc := opts.encoding.(PageEncoding).NewPageEncoder(writer)
+	rowCount := 0
+	opts.postProcesses = append(opts.postProcesses, RowPostProcessorFunc(func(ctx context.Context, sqli SqlInterface, row map[string]any) error {
+		rowCount++
+		return nil
+	}))
+	if err = m.writeRows(ctx, enc, sqli, args, opts); err == nil {
+		if err = total.resolve(ctx, sqli, rowCount, opts.errorTranslator); err == nil {
+			meta := PageResult{Page: page, Size: size}
+			meta.setTotal(total.total)
+			err = enc.Page(meta)
+		}
+	}
+	return err
+}
+
+func (r *PageResult) setTotal(total int64) {
+	r.Total = total
+	r.Pages = (total + int64(r.Size) - 1) / int64(r.Size)
+}
+
+// pageMapOptions resolves the row mapping options for a page - adding the limit/offset and obtaining the total count
+// (or adding the window function to obtain the total count)
+//
+// write indicates that the page is to be written (which requires an encoding that is a PageEncoding)
+func (m *mapper) pageMapOptions(ctx context.Context, sqli SqlInterface, args []any, page int, size int, options []any, write bool) (opts *mapOptions, total *pageTotal, err error) {
+	if page < 1 {
+		return nil, nil, errors.New("page must be greater than zero")
+	} else if size < 1 {
+		return nil, nil, errors.New("page size must be greater than zero")
+	}
+	mode, rowOptions := pageOptions(options)
+	if opts, err = m.rowMapOptions(rowOptions...); err != nil {
+		return nil, nil, err
+	} else if opts.keyset != nil {
+		return nil, nil, errors.New("page cannot be used with keyset")
+	} else if opts.limited {
+		return nil, nil, errors.New("page cannot be used with row limit")
+	} else if opts.grouping != nil {
+		return nil, nil, errors.New("page cannot be used with grouping")
+	} else if _, ok := opts.encoding.(PageEncoding); write && !ok {
+		return nil, nil, fmt.Errorf("page cannot be written using '%s' encoding", opts.encoding.ContentType())
+	}
+	sqli = opts.sqlInterface(sqli)
+	total = &pageTotal{}
+	if total.query, total.args, err = opts.bindArgs("SELECT COUNT(*) FROM ("+withoutOrderBy(opts.query)+") _count", args); err != nil {
+		return nil, nil, err
+	}
+	selectCols := "SELECT " + m.cols + " "
+	if mode == PageCountWindow && strings.HasPrefix(opts.query, selectCols) {
+		total.window = true
+		opts.pageWindow = true
+		opts.query = "SELECT " + m.cols + ",COUNT(*) OVER() AS " + pageTotalProperty + " " + opts.query[len(selectCols):]
+		opts.exclusions = PropertyExclusions{&keepPropertiesExcluder{keys: []string{pageTotalProperty}, path: m.subPath, exclusions: opts.exclusions}}
+		opts.postProcesses = append([]RowPostProcessor{RowPostProcessorFunc(total.capture)}, opts.postProcesses...)
+	} else if err = total.count(ctx, sqli, opts.errorTranslator); err != nil {
+		return nil, nil, err
+	}
+	opts.query = opts.getDialect().Limit(opts.query, size, (page-1)*size)
+	return opts, total, nil
+}
+
+// pageTotal obtains the total count for a page - either using a count query or captured from the window function column
+type pageTotal struct {
+	query    string
+	args     []any
+	window   bool
+	captured bool
+	total    int64
+}
+
+func (t *pageTotal) count(ctx context.Context, sqli SqlInterface, errorTranslator ErrorTranslator) error {
+	if err := sqli.QueryRowContext(ctx, t.query, t.args...).Scan(&t.total); err != nil {
+		return translateError(err, errorTranslator)
+	}
+	return nil
+}
+
+// capture is a row post processor that captures the total count (and removes the window function column from the row)
+func (t *pageTotal) capture(ctx context.Context, sqli SqlInterface, row map[string]any) (err error) {
+	if v, ok := row[pageTotalProperty]; ok {
+		delete(row, pageTotalProperty)
+		if !t.captured {
+			t.captured = true
+			t.total, err = toInt64(v)
+		}
+	}
+	return err
+}
+
+// resolve ensures the total count is obtained - when using the window function and no rows were read, the count query is used
+func (t *pageTotal) resolve(ctx context.Context, sqli SqlInterface, rowCount int, errorTranslator ErrorTranslator) error {
+	if t.window && !t.captured && rowCount == 0 {
+		return t.count(ctx, sqli, errorTranslator)
+	}
+	return nil
+}
+
+func toInt64(v any) (int64, error) {
+	switch vt := v.(type) {
+	case int64:
+		return vt, nil
+	case int:
+		return int64(vt), nil
+	case int32:
+		return int64(vt), nil
+	case float64:
+		return int64(vt), nil
+	case decimal.Decimal:
+		return vt.IntPart(), nil
+	case []byte:
+		return strconv.ParseInt(string(vt), 10, 64)
+	case string:
+		return strconv.ParseInt(vt, 10, 64)
+	}
+	return 0, fmt.Errorf("unsupported total count type %T", v)
+}
@@ -0,0 +1,220 @@
+package columbus
+
+import (
+	"bytes"
+	"errors"
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"io"
+	"regexp"
+	"testing"
+)
+
+func TestMapper_Page(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer func() {
+		_ = db.Close()
+	}()
+	m, err := NewMapper("a,b", Query("FROM table WHERE c = ?"))
+	require.NoError(t, err)
+
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT COUNT(*) FROM (SELECT a,b FROM table WHERE c = ?) _count")).
+		WithArgs("x").
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(5))
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT a,b FROM table WHERE c = ? ORDER BY a LIMIT 2 OFFSET 2")).
+		WithArgs("x").
+		WillReturnRows(sqlmock.NewRows([]string{"a", "b"}).AddRow("a3", "b3").AddRow("a4", "b4"))
+	result, err := m.Page(ctx, db, []any{"x"}, 2, 2, AddClause("ORDER BY a"))
+	require.NoError(t, err)
+	require.NoError(t, mock.ExpectationsWereMet())
+	require.Len(t, result.Rows, 2)
+	assert.Equal(t, "a3", result.Rows[0]["a"])
+	assert.Equal(t, 2, result.Page)
+	assert.Equal(t, 2, result.Size)
+	assert.Equal(t, int64(5), result.Total)
+	assert.Equal(t, int64(3), result.Pages)
+}
+
+func TestMapper_Page_Window(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer func() {
+		_ = db.Close()
+	}()
+	m, err := NewMapper("a,b", Query("FROM table"))
+	require.NoError(t, err)
+
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT a,b,COUNT(*) OVER() AS _total FROM table LIMIT 2")).
+		WillReturnRows(sqlmock.NewRows([]string{"a", "b", "_total"}).AddRow("a1", "b1", 3).AddRow("a2", "b2", 3))
+	result, err := m.Page(ctx, db, nil, 1, 2, PageCountWindow, AllowedProperties{"a": nil})
+	require.NoError(t, err)
+	require.NoError(t, mock.ExpectationsWereMet())
+	require.Len(t, result.Rows, 2)
+	assert.Equal(t, map[string]any{"a": "a1"}, result.Rows[0])
+	assert.Equal(t, map[string]any{"a": "a2"}, result.Rows[1])
+	assert.Equal(t, int64(3), result.Total)
+	assert.Equal(t, int64(2), result.Pages)
+}
+
+func TestMapper_Page_WindowEmptyPage(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer func() {
+		_ = db.Close()
+	}()
+	m, err := NewMapper("a,b", Query("FROM table"))
+	require.NoError(t, err)
+
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT a,b,COUNT(*) OVER() AS _total FROM table LIMIT 2 OFFSET 8")).
+		WillReturnRows(sqlmock.NewRows([]string{"a", "b", "_total"}))
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT COUNT(*) FROM (SELECT a,b FROM table) _count")).
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(3))
+	result, err := m.Page(ctx, db, nil, 5, 2, PageCountWindow)
+	require.NoError(t, err)
+	require.NoError(t, mock.ExpectationsWereMet())
+	require.Empty(t, result.Rows)
+	assert.Equal(t, int64(3), result.Total)
+	assert.Equal(t, int64(2), result.Pages)
+}
+
+func TestMapper_Page_Errors(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer func() {
+		_ = db.Close()
+	}()
+	m, err := NewMapper("a,b", Query("FROM table"))
+	require.NoError(t, err)
+
+	_, err = m.Page(ctx, db, nil, 0, 2)
+	require.Error(t, err)
+	assert.Equal(t, "page must be greater than zero", err.Error())
+	_, err = m.Page(ctx, db, nil, 1, 0)
+	require.Error(t, err)
+	assert.Equal(t, "page size must be greater than zero", err.Error())
+	_, err = m.Page(ctx, db, nil, 1, 2, &Keyset{Sort: []string{"a"}, Size: 2})
+	require.Error(t, err)
+	assert.Equal(t, "page cannot be used with keyset", err.Error())
+	_, err = m.Page(ctx, db, nil, 1, 2, "unknown")
+	require.Error(t, err)
+
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT COUNT(*) FROM (SELECT a,b FROM table) _count")).
+		WillReturnError(errors.New("fooey"))
+	_, err = m.Page(ctx, db, nil, 1, 2, &testWrappingErrorTranslator{})
+	require.Error(t, err)
+	assert.Equal(t, "translated: fooey", err.Error())
+
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT COUNT(*) FROM (SELECT a,b FROM table) _count")).
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(3))
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT a,b FROM table LIMIT 2")).
+		WillReturnError(errors.New("fooey"))
+	_, err = m.Page(ctx, db, nil, 1, 2)
+	require.Error(t, err)
+	assert.Equal(t, "fooey", err.Error())
+
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT a,b,COUNT(*) OVER() AS _total FROM table LIMIT 2")).
+		WillReturnRows(sqlmock.NewRows([]string{"a", "b", "_total"}).AddRow("a1", "b1", "x"))
+	_, err = m.Page(ctx, db, nil, 1, 2, PageCountWindow)
+	require.Error(t, err)
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestMapper_WritePage(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer func() {
+		_ = db.Close()
+	}()
+	m, err := NewMapper("a,b", Query("FROM table"))
+	require.NoError(t, err)
+
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT COUNT(*) FROM (SELECT a,b FROM table) _count")).
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(3))
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT a,b FROM table LIMIT 2 OFFSET 2")).
+		WillReturnRows(sqlmock.NewRows([]string{"a", "b"}).AddRow("a3", "b3"))
+	var buffer bytes.Buffer
+	err = m.WritePage(ctx, &buffer, db, nil, 2, 2)
+	require.NoError(t, err)
+	require.NoError(t, mock.ExpectationsWereMet())
+	assert.JSONEq(t, `{"rows":[{"a":"a3","b":"b3"}],"page":2,"size":2,"total":3,"pages":2}`, buffer.String())
+
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT a,b,COUNT(*) OVER() AS _total FROM table LIMIT 2")).
+		WillReturnRows(sqlmock.NewRows([]string{"a", "b", "_total"}).AddRow("a1", "b1", 3).AddRow("a2", "b2", 3))
+	buffer.Reset()
+	err = m.WritePage(ctx, &buffer, db, nil, 1, 2, PageCountWindow)
+	require.NoError(t, err)
+	require.NoError(t, mock.ExpectationsWereMet())
+	assert.JSONEq(t, `{"rows":[{"a":"a1","b":"b1"},{"a":"a2","b":"b2"}],"page":1,"size":2,"total":3,"pages":2}`, buffer.String())
+
+	err = m.WritePage(ctx, &buffer, db, nil, 0, 2)
+	require.Error(t, err)
+}
+
+func TestMapper_WritePage_WindowEncodeInfo(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer func() {
+		_ = db.Close()
+	}()
+	m, err := NewMapper("a,b", Query("FROM table"))
+	require.NoError(t, err)
+
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT a,b,COUNT(*) OVER() AS _total FROM table LIMIT 2")).
+		WillReturnRows(sqlmock.NewRows([]string{"a", "b", "_total"}).AddRow("a1", "b1", 3))
+	var buffer bytes.Buffer
+	enc := &testCapturingPageEncoding{}
+	err = m.WritePage(ctx, &buffer, db, nil, 1, 2, PageCountWindow, enc)
+	require.NoError(t, err)
+	require.NoError(t, mock.ExpectationsWereMet())
+	assert.Equal(t, []string{"a", "b"}, enc.info.Properties)
+	require.Len(t, enc.info.Columns, 2)
+	assert.JSONEq(t, `{"rows":[{"a":"a1","b":"b1"}],"page":1,"size":2,"total":3,"pages":2}`, buffer.String())
+}
+
+type testCapturingPageEncoding struct {
+	testCapturingEncoding
+}
+
+func (e *testCapturingPageEncoding) NewPageEncoder(w io.Writer) PageEncoder {
+	return &testCapturingPageEncoder{encoding: e, PageEncoder: JsonArray.(PageEncoding).NewPageEncoder(w)}
+}
+
+type testCapturingPageEncoder struct {
+	PageEncoder
+	encoding *testCapturingPageEncoding
+}
+
+func (e *testCapturingPageEncoder) Begin(info EncodeInfo) error {
+	e.encoding.info = info
+	return e.PageEncoder.Begin(info)
+}
+
+func TestToInt64(t *testing.T) {
+	testCases := []struct {
+		value     any
+		expect    int64
+		expectErr bool
+	}{
+		{int64(1), 1, false},
+		{2, 2, false},
+		{int32(3), 3, false},
+		{4.0, 4, false},
+		{decimal.NewFromInt(5), 5, false},
+		{[]byte("6"), 6, false},
+		{"7", 7, false},
+		{"x", 0, true},
+		{true, 0, true},
+	}
+	for _, tc := range testCases {
+		v, err := toInt64(tc.value)
+		if tc.expectErr {
+			require.Error(t, err)
+		} else {
+			require.NoError(t, err)
+			assert.Equal(t, tc.expect, v)
+		}
+	}
+}
@@ -1,8 +1,179 @@
 package columbus
 
+import (
+	"context"
+	"database/sql"
+	"errors"
+)
+
 // Query represents the sql query used by Mapper (or Mapper.Rows etc.)
 // it should exclude the 'SELECT cols' - as Mapper already knows the columns to be mapped
+//
+// slice args (e.g. `[]int64` or `[]string`) within an IN list are expanded - so that `WHERE id IN (?)` is rewritten with
+// an arg marker for each item (an empty slice is rewritten as `IN (NULL)` - which matches no rows) - slice args elsewhere
+// (e.g. `WHERE id = ANY(?)`) are passed to the driver as is
 type Query string
 
 // AddClause is a sql clause that can be added when using Mapper.Rows, Mapper.FirstRow or Mapper.ExactlyOneRow
 type AddClause string
+
+// AddClauseWithArgs is a sql clause (with args) that can be added when using Mapper.Rows, StructMapper.Rows etc. (the same as AddClause)
+//
+// the Args are bound to the '?' arg markers in the Clause - and are inserted amongst the args passed to the row reading
+// method according to the position of the clause in the query
+type AddClauseWithArgs struct {
+	Clause string
+	Args   []any
+}
+
+// RowLimit is an option that can be passed to Mapper.Rows (or any of the other row reading methods) and adds a row limit
+// (and offset) to the query - e.g. `LIMIT 10 OFFSET 20` (the actual clause is determined by the Dialect)
+//
+// the row limit is added after the query, any AddClause and any Sort - and cannot be used with Keyset, Mapper.Page or Mapper.WritePage
+type RowLimit struct {
+	// Limit is the maximum number of rows
+	Limit int
+	// Offset is the number of rows to skip
+	Offset int
+}
+
+// rawQuery is an internal option used to specify the complete query (i.e. including the 'SELECT cols')
+type rawQuery string
+
+// queryOptions is the query (and any additional args) resolved from options
+type queryOptions struct {
+	query string
+	// argInserts is any additional args (inserted amongst the args passed to the row reading method)
+	argInserts []argInsert
+	// keyset is set when the query is wrapped for keyset pagination
+	keyset *keysetPage
+	// dialect is the Dialect (nil if no Dialect specified)
+	dialect Dialect
+	// limited is set when a RowLimit has been added to the query
+	limited bool
+}
+
+// getDialect returns the Dialect to use for generating SQL
+func (o *queryOptions) getDialect() Dialect {
+	if o.dialect == nil {
+		return defaultDialect
+	}
+	return o.dialect
+}
+
+// sqlInterface returns the SqlInterface to use for executing queries - i.e. rewriting placeholders if a Dialect is specified
+func (o *queryOptions) sqlInterface(sqli SqlInterface) SqlInterface {
+	if o.dialect == nil {
+		return sqli
+	} else if ds, ok := sqli.(*dialectSqlInterface); ok && ds.dialect == o.dialect {
+		return sqli
+	}
+	return &dialectSqlInterface{SqlInterface: unwrapSqlInterface(sqli), dialect: o.dialect}
+}
+
+// argInsert is additional args to be inserted at a specific arg marker position
+type argInsert struct {
+	at   int
+	args []any
+}
+
+// addClause adds a clause (and any args for the clause) to the query
+func (o *queryOptions) addClause(clause string, args []any) {
+	at := countArgMarkers(o.query)
+	o.query += " " + clause
+	o.insertArgs(at, args)
+}
+
+// insertArgs records additional args to be inserted at the arg marker position
+func (o *queryOptions) insertArgs(at int, args []any) {
+	if len(args) > 0 {
+		o.argInserts = append(o.argInserts, argInsert{at: at, args: args})
+	}
+}
+
+// queryArgs merges the args passed to the row reading method with any additional args
+func (o *queryOptions) queryArgs(args []any) []any {
+	if len(o.argInserts) == 0 {
+		return args
+	}
+	result := make([]any, 0, len(args)+len(o.argInserts))
+	n := 0
+	for _, ins := range o.argInserts {
+		if take := ins.at - len(result); take > 0 {
+			take = min(take, len(args)-n)
+			result = append(result, args[n:n+take]...)
+			n += take
+		}
+		result = append(result, ins.args...)
+	}
+	return append(result, args[n:]...)
+}
+
+// additionalArgs returns just the additional args (i.e. when named args are used, the only '?' arg markers are for the additional args)
+func (o *queryOptions) additionalArgs() []any {
+	result := make([]any, 0, len(o.argInserts))
+	for _, ins := range o.argInserts {
+		result = append(result, ins.args...)
+	}
+	return result
+}
+
+func countArgMarkers(query string) (count int) {
+	scanSql(query, false, func(i int, depth int) {
+		if query[i] == '?' {
+			count++
+		}
+	})
+	return count
+}
+
+// bindArgs binds the args (and any additional args) for the query - rewriting named placeholders if the args are named args
+// and expanding any slice args within IN lists
+func (o *queryOptions) bindArgs(query string, args []any) (string, []any, error) {
+	if nv, ok := namedArgsOf(args); ok {
+		q, qArgs, err := nv.bind(query, o.additionalArgs())
+		if err != nil {
+			return "", nil, err
+		}
+		q, qArgs = expandSliceArgs(q, qArgs)
+		return q, qArgs, nil
+	}
+	q, qArgs := expandSliceArgs(query, o.queryArgs(args))
+	return q, qArgs, nil
+}
+
+// queryContext executes the query with the args
+func (o *queryOptions) queryContext(ctx context.Context, sqli SqlInterface, args []any) (*sql.Rows, error) {
+	query, qArgs, err := o.bindArgs(o.query, args)
+	if err != nil {
+		return nil, err
+	}
+	return sqli.QueryContext(ctx, query, qArgs...)
+}
+
+// applyRowLimit adds the row limit to the query
+func (o *queryOptions) applyRowLimit(rowLimit *RowLimit) error {
+	if rowLimit.Limit < 0 || rowLimit.Offset < 0 {
+		return errors.New("row limit and offset must not be negative")
+	}
+	o.limited = true
+	o.query = o.getDialect().Limit(o.query, rowLimit.Limit, rowLimit.Offset)
+	return nil
+}
+
+// applyKeyset wraps the query for keyset pagination - returning the Limiter to use
+func (o *queryOptions) applyKeyset(keyset *Keyset, resolve func(property string) string, limiter Limiter) (Limiter, error) {
+	kp, values, err := newKeysetPage(keyset, resolve)
+	if err != nil {
+		return limiter, err
+	}
+	var args []any
+	o.keyset = kp
+	at := countArgMarkers(o.query)
+	o.query, args = kp.wrapQuery(o.query, values, o.getDialect())
+	o.insertArgs(at, args)
+	if kp.limiter != nil {
+		return kp.limiter, nil
+	}
+	return limiter, nil
+}
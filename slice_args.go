@@ -0,0 +1,88 @@
+package columbus
+
+import (
+	"database/sql/driver"
+	"reflect"
+	"strings"
+)
+
+// expandSliceArgs expands slice args - so that a single '?' arg marker for a slice arg within an IN list (e.g. `WHERE id IN (?)`)
+// is rewritten to an arg marker for each item of the slice (e.g. `WHERE id IN (?,?,?)`)
+//
+// an empty slice arg is rewritten as `NULL` (i.e. `WHERE id IN (NULL)` - which matches no rows)
+//
+// slice args for arg markers that are not within an IN list (e.g. `WHERE id = ANY(?)`), []byte args and args that implement
+// driver.Valuer (e.g. explicit array types) are not expanded - and are passed to the driver as is
+func expandSliceArgs(query string, args []any) (string, []any) {
+	expand := false
+	for _, arg := range args {
+		if isExpandableSlice(arg) {
+			expand = true
+			break
+		}
+	}
+	if !expand {
+		return query, args
+	}
+	var sb strings.Builder
+	result := make([]any, 0, len(args))
+	last, n := 0, 0
+	// inList is, for each parenthesis depth, whether the parentheses are an IN list
+	inList := make([]bool, 0)
+	scanSql(query, false, func(i int, depth int) {
+		switch query[i] {
+		case '(':
+			inList = append(inList, isInList(query, i))
+		case ')':
+			if len(inList) > 0 {
+				inList = inList[:len(inList)-1]
+			}
+		case '?':
+			if n < len(args) {
+				if arg := args[n]; depth > 0 && depth <= len(inList) && inList[depth-1] && isExpandableSlice(arg) {
+					sb.WriteString(query[last:i])
+					last = i + 1
+					rv := reflect.ValueOf(arg)
+					if rv.Len() == 0 {
+						sb.WriteString("NULL")
+					}
+					for j := 0; j < rv.Len(); j++ {
+						if j > 0 {
+							sb.WriteString(",")
+						}
+						sb.WriteString("?")
+						result = append(result, rv.Index(j).Interface())
+					}
+				} else {
+					result = append(result, arg)
+				}
+			}
+			n++
+		}
+	})
+	if n < len(args) {
+		// more args than arg markers - leave the surplus for the driver to report
+		result = append(result, args[n:]...)
+	}
+	sb.WriteString(query[last:])
+	return sb.String(), result
+}
+
+// isInList determines whether the opening parenthesis at position i is an IN list (i.e. is preceded by the word IN)
+func isInList(query string, i int) bool {
+	end := i
+	for end > 0 && (query[end-1] == ' ' || query[end-1] == '\t' || query[end-1] == '\n' || query[end-1] == '\r') {
+		end--
+	}
+	return end >= 2 && strings.EqualFold(query[end-2:end], "IN") && (end == 2 || !isNameChar(query[end-3]))
+}
+
+func isExpandableSlice(arg any) bool {
+	if arg == nil {
+		return false
+	} else if _, ok := arg.(driver.Valuer); ok {
+		return false
+	}
+	rt := reflect.TypeOf(arg)
+	return rt.Kind() == reflect.Slice && rt.Elem().Kind() != reflect.Uint8
+}
@@ -0,0 +1,86 @@
+package columbus
+
+import (
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"regexp"
+	"testing"
+)
+
+func TestExpandSliceArgs(t *testing.T) {
+	type ids []int64
+	testCases := []struct {
+		query       string
+		args        []any
+		expectQuery string
+		expectArgs  []any
+	}{
+		{"SELECT * FROM t WHERE a = ?", []any{1}, "SELECT * FROM t WHERE a = ?", []any{1}},
+		{"SELECT * FROM t WHERE a = ?", []any{[]byte("x")}, "SELECT * FROM t WHERE a = ?", []any{[]byte("x")}},
+		{"SELECT * FROM t WHERE id IN (?)", []any{[]int64{1, 2, 3}}, "SELECT * FROM t WHERE id IN (?,?,?)", []any{int64(1), int64(2), int64(3)}},
+		{"SELECT * FROM t WHERE id IN (?)", []any{ids{1}}, "SELECT * FROM t WHERE id IN (?)", []any{int64(1)}},
+		{"SELECT * FROM t WHERE a = ? AND id IN (?) AND b = ?", []any{"a", []string{"x", "y"}, "b"}, "SELECT * FROM t WHERE a = ? AND id IN (?,?) AND b = ?", []any{"a", "x", "y", "b"}},
+		{"SELECT * FROM t WHERE id IN (?) AND c = '?'", []any{[]string{}}, "SELECT * FROM t WHERE id IN (NULL) AND c = '?'", []any{}},
+		{"SELECT * FROM t WHERE id IN (?)", []any{[]int{1, 2}, "surplus"}, "SELECT * FROM t WHERE id IN (?,?)", []any{1, 2, "surplus"}},
+		{"SELECT * FROM t WHERE id not in(?)", []any{[]int{1, 2}}, "SELECT * FROM t WHERE id not in(?,?)", []any{1, 2}},
+		{"SELECT * FROM t WHERE id IN\n  (?)", []any{[]int{1, 2}}, "SELECT * FROM t WHERE id IN\n  (?,?)", []any{1, 2}},
+		// slices not within an IN list are left alone...
+		{"SELECT * FROM t WHERE id = ANY(?)", []any{[]int64{1, 2}}, "SELECT * FROM t WHERE id = ANY(?)", []any{[]int64{1, 2}}},
+		{"SELECT * FROM t WHERE tags = ?", []any{[]string{"x"}}, "SELECT * FROM t WHERE tags = ?", []any{[]string{"x"}}},
+		{"SELECT * FROM t WHERE COIN(?)", []any{[]int{1, 2}}, "SELECT * FROM t WHERE COIN(?)", []any{[]int{1, 2}}},
+		{"SELECT * FROM t WHERE id IN (SELECT id FROM u WHERE v = ANY(?)) AND w IN (?)", []any{[]int{1}, []int{2, 3}},
+			"SELECT * FROM t WHERE id IN (SELECT id FROM u WHERE v = ANY(?)) AND w IN (?,?)", []any{[]int{1}, 2, 3}},
+	}
+	for _, tc := range testCases {
+		q, args := expandSliceArgs(tc.query, tc.args)
+		assert.Equal(t, tc.expectQuery, q)
+		assert.Equal(t, tc.expectArgs, args)
+	}
+}
+
+func TestMapper_SliceArgs(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer func() {
+		_ = db.Close()
+	}()
+	m, err := NewMapper("id,name", Query("FROM parents WHERE id IN (?)"), Postgres)
+	require.NoError(t, err)
+
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT id,name FROM parents WHERE id IN ($1,$2) AND name = $3")).
+		WithArgs(1, 2, "p1").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "name"}).AddRow(1, "p1"))
+	rows, err := m.Rows(ctx, db, []any{[]int{1, 2}, "p1"}, AddClause("AND name = ?"))
+	require.NoError(t, err)
+	require.NoError(t, mock.ExpectationsWereMet())
+	require.Len(t, rows, 1)
+
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT COUNT(*) FROM (SELECT id,name FROM parents WHERE id IN ($1,$2,$3)) _count")).
+		WithArgs("a", "b", "c").
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(0))
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT id,name FROM parents WHERE id IN ($1,$2,$3) LIMIT 10")).
+		WithArgs("a", "b", "c").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "name"}))
+	_, err = m.Page(ctx, db, []any{[]string{"a", "b", "c"}}, 1, 10)
+	require.NoError(t, err)
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestMapper_SliceArgs_Named(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer func() {
+		_ = db.Close()
+	}()
+	m, err := NewMapper("id,name", Query("FROM parents WHERE id IN (:ids) AND tenant_id = :tenant_id"))
+	require.NoError(t, err)
+
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT id,name FROM parents WHERE id IN (NULL) AND tenant_id = ?")).
+		WithArgs(1).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "name"}))
+	rows, err := m.Rows(ctx, db, []any{NamedArgs{"ids": []int64{}, "tenant_id": 1}})
+	require.NoError(t, err)
+	require.NoError(t, mock.ExpectationsWereMet())
+	require.Empty(t, rows)
+}
@@ -0,0 +1,96 @@
+package columbus
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Sort is an option that can be passed to Mapper.Rows (or any of the other row reading methods) and adds an
+// ORDER BY clause to the query from a comma separated list of property names - e.g. `-lastName,createdAt`
+//
+// a property name prefixed with '-' sorts descending (a '+' prefix, or no prefix, sorts ascending) - nested properties
+// (i.e. mapped with a Path) are specified using dot notation - e.g. `address.city`
+//
+// property names are resolved to columns using SortableProperties (if specified) or the Mappings (PropertyName and Path) - if a property
+// cannot be resolved, an *UnknownSortPropertyError is returned
+//
+// the ORDER BY is added after the query and any AddClause (if the query already has an ORDER BY, the sort is appended to it) -
+// but before any trailing row limiting clause (i.e. LIMIT, OFFSET or FETCH)
+type Sort string
+
+// SortableProperties is an option that can be passed to NewMapper (or any of the row reading methods) and is the allow-list
+// of property names that can be used by Sort
+//
+// the map key is the property name and the value is the column expression to sort by - if the value is an empty string,
+// the column is resolved from the Mappings (or, if there is no mapping for the property, the property name is used as the column)
+//
+// if no SortableProperties is specified, only properties in the Mappings can be used by Sort
+type SortableProperties map[string]string
+
+// UnknownSortPropertyError is the error returned when a Sort specifies a property that cannot be sorted by
+type UnknownSortPropertyError struct {
+	Property string
+}
+
+func (e *UnknownSortPropertyError) Error() string {
+	return fmt.Sprintf("unknown sort property '%s'", e.Property)
+}
+
+// sortClause builds the ORDER BY clause (without the 'ORDER BY') for the sort
+func sortClause(sort Sort, sortable SortableProperties, mappings Mappings, dialect Dialect) (string, error) {
+	parts := make([]string, 0)
+	for _, s := range strings.Split(string(sort), ",") {
+		if s = strings.TrimSpace(s); s == "" {
+			continue
+		}
+		direction := " ASC"
+		if strings.HasPrefix(s, "-") {
+			direction = " DESC"
+			s = s[1:]
+		} else {
+			s = strings.TrimPrefix(s, "+")
+		}
+		col, ok := sortColumn(s, sortable, mappings, dialect)
+		if !ok {
+			return "", &UnknownSortPropertyError{Property: s}
+		}
+		parts = append(parts, col+direction)
+	}
+	return strings.Join(parts, ","), nil
+}
+
+func sortColumn(property string, sortable SortableProperties, mappings Mappings, dialect Dialect) (string, bool) {
+	if sortable != nil {
+		expr, ok := sortable[property]
+		if !ok {
+			return "", false
+		} else if expr != "" {
+			return expr, true
+		}
+	}
+	if col, ok := mappings.Column(property); ok {
+		return dialect.QuoteIdentifier(col), true
+	}
+	if sortable != nil && keysetColumnRegex.MatchString(property) {
+		return dialect.QuoteIdentifier(property), true
+	}
+	return "", false
+}
+
+// applySort adds the sort to the query
+func (o *queryOptions) applySort(sort Sort, sortable SortableProperties, mappings Mappings) error {
+	clause, err := sortClause(sort, sortable, mappings, o.getDialect())
+	if err != nil || clause == "" {
+		return err
+	}
+	query, limit := o.query, ""
+	if i := rowLimitIndex(query, true); i != -1 {
+		query, limit = strings.TrimRight(query[:i], " \t\r\n"), " "+query[i:]
+	}
+	if orderByIndex(query, true) == -1 {
+		o.query = query + " ORDER BY " + clause + limit
+	} else {
+		o.query = query + "," + clause + limit
+	}
+	return nil
+}
@@ -0,0 +1,176 @@
+package columbus
+
+import (
+	"errors"
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"regexp"
+	"testing"
+)
+
+func TestSortClause(t *testing.T) {
+	mappings := Mappings{
+		"last_name":  {PropertyName: "lastName"},
+		"created_at": {PropertyName: "createdAt"},
+		"city":       {Path: []string{"address"}},
+	}
+	testCases := []struct {
+		sort      Sort
+		sortable  SortableProperties
+		dialect   Dialect
+		expect    string
+		expectErr string
+	}{
+		{
+			sort:   "-lastName,createdAt",
+			expect: "last_name DESC,created_at ASC",
+		},
+		{
+			sort:   " +lastName , ,address.city ",
+			expect: "last_name ASC,city ASC",
+		},
+		{
+			sort:    "-lastName",
+			dialect: Postgres,
+			expect:  `"last_name" DESC`,
+		},
+		{
+			sort:      "city",
+			expectErr: "unknown sort property 'city'",
+		},
+		{
+			sort:      "last_name",
+			expectErr: "unknown sort property 'last_name'",
+		},
+		{
+			sort:      "lastName;DROP TABLE x",
+			expectErr: "unknown sort property 'lastName;DROP TABLE x'",
+		},
+		{
+			sort:     "lastName,id,name",
+			sortable: SortableProperties{"lastName": "", "id": "", "name": "LOWER(name)"},
+			expect:   "last_name ASC,id ASC,LOWER(name) ASC",
+		},
+		{
+			sort:      "createdAt",
+			sortable:  SortableProperties{"lastName": ""},
+			expectErr: "unknown sort property 'createdAt'",
+		},
+		{
+			sort:      "bad-name",
+			sortable:  SortableProperties{"bad-name": ""},
+			expectErr: "unknown sort property 'bad-name'",
+		},
+		{
+			sort:   "",
+			expect: "",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(string(tc.sort), func(t *testing.T) {
+			dialect := tc.dialect
+			if dialect == nil {
+				dialect = defaultDialect
+			}
+			clause, err := sortClause(tc.sort, tc.sortable, mappings, dialect)
+			if tc.expectErr != "" {
+				require.Error(t, err)
+				assert.Equal(t, tc.expectErr, err.Error())
+				var usErr *UnknownSortPropertyError
+				assert.True(t, errors.As(err, &usErr))
+			} else {
+				require.NoError(t, err)
+				assert.Equal(t, tc.expect, clause)
+			}
+		})
+	}
+}
+
+func TestMapper_Sort(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer func() {
+		_ = db.Close()
+	}()
+	m, err := NewMapper("last_name,created_at", Query("FROM people WHERE tenant_id = ?"), Mappings{
+		"last_name":  {PropertyName: "lastName"},
+		"created_at": {PropertyName: "createdAt"},
+	})
+	require.NoError(t, err)
+
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT last_name,created_at FROM people WHERE tenant_id = ? AND active = 1 ORDER BY last_name DESC,created_at ASC")).
+		WithArgs(1).
+		WillReturnRows(sqlmock.NewRows([]string{"last_name", "created_at"}))
+	_, err = m.Rows(ctx, db, []any{1}, Sort("-lastName,createdAt"), AddClause("AND active = 1"))
+	require.NoError(t, err)
+	require.NoError(t, mock.ExpectationsWereMet())
+
+	// appends to existing ORDER BY...
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT last_name,created_at FROM people WHERE tenant_id = ? ORDER BY tenant_id,last_name ASC")).
+		WithArgs(1).
+		WillReturnRows(sqlmock.NewRows([]string{"last_name", "created_at"}))
+	_, err = m.Rows(ctx, db, []any{1}, AddClause("ORDER BY tenant_id"), Sort("lastName"))
+	require.NoError(t, err)
+	require.NoError(t, mock.ExpectationsWereMet())
+
+	// inserted before a trailing row limiting clause...
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT last_name,created_at FROM people WHERE tenant_id = ? ORDER BY tenant_id,last_name ASC LIMIT 10")).
+		WithArgs(1).
+		WillReturnRows(sqlmock.NewRows([]string{"last_name", "created_at"}))
+	_, err = m.Rows(ctx, db, []any{1}, AddClause("ORDER BY tenant_id LIMIT 10"), Sort("lastName"))
+	require.NoError(t, err)
+	require.NoError(t, mock.ExpectationsWereMet())
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT last_name,created_at FROM people WHERE tenant_id = ? ORDER BY last_name ASC LIMIT 10 OFFSET 5")).
+		WithArgs(1).
+		WillReturnRows(sqlmock.NewRows([]string{"last_name", "created_at"}))
+	_, err = m.Rows(ctx, db, []any{1}, AddClause("LIMIT 10 OFFSET 5"), Sort("lastName"))
+	require.NoError(t, err)
+	require.NoError(t, mock.ExpectationsWereMet())
+
+	// page count query drops the sort...
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT COUNT(*) FROM (SELECT last_name,created_at FROM people WHERE tenant_id = ?) _count")).
+		WithArgs(1).
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(0))
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT last_name,created_at FROM people WHERE tenant_id = ? ORDER BY created_at DESC LIMIT 10")).
+		WithArgs(1).
+		WillReturnRows(sqlmock.NewRows([]string{"last_name", "created_at"}))
+	_, err = m.Page(ctx, db, []any{1}, 1, 10, Sort("-createdAt"))
+	require.NoError(t, err)
+	require.NoError(t, mock.ExpectationsWereMet())
+
+	_, err = m.Rows(ctx, db, []any{1}, Sort("unknown"))
+	require.Error(t, err)
+	var usErr *UnknownSortPropertyError
+	require.True(t, errors.As(err, &usErr))
+	assert.Equal(t, "unknown", usErr.Property)
+}
+
+func TestMapper_SortableProperties(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer func() {
+		_ = db.Close()
+	}()
+	m, err := NewMapper("id,name", Query("FROM people"), SortableProperties{"id": "", "name": "LOWER(name)"})
+	require.NoError(t, err)
+
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT id,name FROM people ORDER BY LOWER(name) DESC,id ASC")).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "name"}))
+	_, err = m.Rows(ctx, db, nil, Sort("-name,id"))
+	require.NoError(t, err)
+	require.NoError(t, mock.ExpectationsWereMet())
+
+	// per-call sortable overrides...
+	_, err = m.Rows(ctx, db, nil, Sort("name"), SortableProperties{"id": ""})
+	require.Error(t, err)
+
+	// extended mapper keeps sortable...
+	m2, err := m.Extend(nil, nil)
+	require.NoError(t, err)
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT id,name FROM people ORDER BY id ASC")).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "name"}))
+	_, err = m2.Rows(ctx, db, nil, Sort("id"))
+	require.NoError(t, err)
+	require.NoError(t, mock.ExpectationsWereMet())
+}
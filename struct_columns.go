@@ -0,0 +1,131 @@
+package columbus
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+)
+
+// columnTag is a parsed field column tag - the column name followed by any comma separated options, e.g.
+//
+//	`sql:"name,table=p"`
+//
+// the prefix option (on nested struct fields) prefixes the column names of all descendant fields, e.g.
+//
+//	Billing Address `sql:",prefix=billing_"`
+//
+// the expr option consumes the remainder of the tag (so that expressions may contain commas) and must be the last option
+type columnTag struct {
+	name   string
+	table  string
+	expr   string
+	prefix string
+}
+
+func parseColumnTag(tag string) (result columnTag) {
+	name, opts, _ := strings.Cut(tag, ",")
+	result.name = strings.TrimSpace(name)
+	for opts = strings.TrimLeft(opts, " "); opts != ""; opts = strings.TrimLeft(opts, " ") {
+		if expr, ok := strings.CutPrefix(opts, "expr="); ok {
+			result.expr = strings.TrimSpace(expr)
+			break
+		}
+		var opt string
+		opt, opts, _ = strings.Cut(opts, ",")
+		if k, v, ok := strings.Cut(opt, "="); ok {
+			switch strings.TrimSpace(k) {
+			case "table":
+				result.table = strings.TrimSpace(v)
+			case "prefix":
+				result.prefix = strings.TrimSpace(v)
+			}
+		}
+	}
+	return result
+}
+
+// deriveColumns derives the select column list from the mapped fields of the struct (and, when grouping, the mapped
+// fields of the child structs)
+func (m *structMapper[T]) deriveColumns() (string, error) {
+	rt := reflect.TypeOf((*T)(nil)).Elem()
+	cols := appendSelectColumns(nil, m.fieldColumnNamers, m.useTagName, rt, nil, "", m.skipFields, m.tableAlias, "")
+	if m.grouping != nil {
+		cols = appendGroupSelectColumns(cols, m.fieldColumnNamers, m.useTagName, rt, m.grouping.Groups)
+	}
+	if len(cols) == 0 {
+		return "", errors.New("no mapped fields to derive columns from")
+	}
+	return strings.Join(cols, ","), nil
+}
+
+// appendGroupSelectColumns appends the select columns for the child structs of the column groups (and any nested
+// column groups) - child columns are aliased with the group Prefix
+func appendGroupSelectColumns(cols []string, namers []FieldColumnNamer, tagName string, rt reflect.Type, groups []ColumnGroup) []string {
+	for _, cg := range groups {
+		f, _ := rt.FieldByName(cg.Property)
+		elemType := f.Type.Elem()
+		if elemType.Kind() == reflect.Ptr {
+			elemType = elemType.Elem()
+		}
+		childSkip := make(map[string]bool, len(cg.Groups))
+		for _, child := range cg.Groups {
+			if cf, ok := elemType.FieldByName(child.Property); ok {
+				childSkip[indexKey(cf.Index)] = true
+			}
+		}
+		cols = appendSelectColumns(cols, namers, tagName, elemType, nil, "", childSkip, cg.Table, cg.Prefix)
+		cols = appendGroupSelectColumns(cols, namers, tagName, elemType, cg.Groups)
+	}
+	return cols
+}
+
+// appendSelectColumns appends the select columns for the mapped fields of a struct type (visiting the fields in the
+// same way as buildFieldMapRecursive) - prefix is the column name prefix for the fields and alias is the group Prefix
+func appendSelectColumns(cols []string, namers []FieldColumnNamer, tagName string, rt reflect.Type, parentIndex []int, prefix string, skip map[string]bool, table string, alias string) []string {
+	for i := 0; i < rt.NumField(); i++ {
+		f := rt.Field(i)
+		if !f.IsExported() {
+			continue
+		}
+		index := append([]int{}, parentIndex...)
+		index = append(index, f.Index...)
+		if skip[indexKey(index)] {
+			continue
+		} else if f.Type.Kind() == reflect.Struct && !isScannable(f.Type) {
+			cols = appendSelectColumns(cols, namers, tagName, f.Type, index, prefix+columnPrefix(namers, f), skip, table, alias)
+			continue
+		}
+		useColName := ""
+		named := false
+		for _, namer := range namers {
+			if useColName, named = namer.ColumnName(rt, f); named {
+				break
+			}
+		}
+		if !named || useColName == "-" || useColName == "" {
+			continue
+		}
+		cols = append(cols, selectColumn(prefix+useColName, f.Tag.Get(tagName), table, alias))
+	}
+	return cols
+}
+
+// selectColumn returns the select column for a mapped column name - qualified by the tag table option (or the default
+// table), or the tag expr option, and aliased with the group Prefix
+func selectColumn(name string, tag string, table string, alias string) string {
+	ct := parseColumnTag(tag)
+	if ct.expr != "" {
+		return ct.expr + " AS " + alias + name
+	}
+	result := name
+	if ct.table != "" {
+		table = ct.table
+	}
+	if table != "" {
+		result = table + "." + name
+	}
+	if alias != "" {
+		result += " AS " + alias + name
+	}
+	return result
+}
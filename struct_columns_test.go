@@ -0,0 +1,158 @@
+package columbus
+
+import (
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+type testDerivedAudit struct {
+	Created string `sql:"created"`
+}
+
+type testDerivedPerson struct {
+	Id       int64  `sql:"id"`
+	Name     string `sql:"name,table=n"`
+	FullName string `sql:"full_name,expr=CONCAT(p.first_name, ' ', p.last_name)"`
+	Ignored  string `sql:"-"`
+	Audit    testDerivedAudit
+	internal string
+}
+
+func TestNewStructMapper_DerivedColumns(t *testing.T) {
+	sm, err := NewStructMapper[testDerivedPerson]("", Query("FROM people p"))
+	require.NoError(t, err)
+	raw := sm.(*structMapper[testDerivedPerson])
+	assert.Equal(t, "id,n.name,CONCAT(p.first_name, ' ', p.last_name) AS full_name,created", raw.cols)
+	assert.Equal(t, Query("SELECT id,n.name,CONCAT(p.first_name, ' ', p.last_name) AS full_name,created FROM people p"), *raw.defaultQuery)
+
+	sm, err = NewStructMapper[testDerivedPerson]("", TableAlias("p"), Query("FROM people p"))
+	require.NoError(t, err)
+	raw = sm.(*structMapper[testDerivedPerson])
+	assert.Equal(t, "p.id,n.name,CONCAT(p.first_name, ' ', p.last_name) AS full_name,p.created", raw.cols)
+
+	db, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+	require.NoError(t, err)
+	defer func() {
+		_ = db.Close()
+	}()
+	mock.ExpectQuery("SELECT p.id,n.name,CONCAT(p.first_name, ' ', p.last_name) AS full_name,p.created FROM people p WHERE p.id = ?").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "name", "full_name", "created"}).AddRow(int64(1), "Bilbo", "Bilbo Baggins", "today"))
+	row, err := sm.ExactlyOneRow(ctx, db, []any{1}, AddClause("WHERE p.id = ?"))
+	require.NoError(t, err)
+	assert.Equal(t, testDerivedPerson{Id: 1, Name: "Bilbo", FullName: "Bilbo Baggins", Audit: testDerivedAudit{Created: "today"}}, row)
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestNewStructMapper_DerivedColumns_Grouping(t *testing.T) {
+	grouping := testGroupingPersonGrouping
+	grouping.Groups = []ColumnGroup{
+		{Property: "Orders", Key: []string{"order_id"}, Prefix: "order_", Table: "o", Groups: []ColumnGroup{
+			{Property: "Lines", Key: []string{"order_id", "line_no"}, Prefix: "line_", Table: "l"},
+		}},
+	}
+	sm, err := NewStructMapper[testGroupingPerson]("", TableAlias("p"), grouping,
+		Query("FROM people p LEFT JOIN orders o ON o.person_id = p.id LEFT JOIN lines l ON l.order_id = o.id ORDER BY p.id"))
+	require.NoError(t, err)
+	raw := sm.(*structMapper[testGroupingPerson])
+	assert.Equal(t, "p.id,p.name,o.id AS order_id,o.ref AS order_ref,l.no AS line_no,l.product AS line_product", raw.cols)
+}
+
+func TestNewStructMapper_DerivedColumns_Errors(t *testing.T) {
+	type noFields struct {
+		Ignored string `sql:"-"`
+	}
+	_, err := NewStructMapper[noFields]("")
+	require.Error(t, err)
+	assert.Equal(t, "no mapped fields to derive columns from", err.Error())
+}
+
+func TestParseColumnTag(t *testing.T) {
+	testCases := []struct {
+		tag    string
+		expect columnTag
+	}{
+		{tag: "", expect: columnTag{}},
+		{tag: "-", expect: columnTag{name: "-"}},
+		{tag: "name", expect: columnTag{name: "name"}},
+		{tag: "name,table=p", expect: columnTag{name: "name", table: "p"}},
+		{tag: "name, table=p, unknown=x", expect: columnTag{name: "name", table: "p"}},
+		{tag: "name,expr=COALESCE(a,b)", expect: columnTag{name: "name", expr: "COALESCE(a,b)"}},
+		{tag: "name,table=p,expr=COALESCE(a, b),table=x", expect: columnTag{name: "name", table: "p", expr: "COALESCE(a, b),table=x"}},
+		{tag: ",table=p", expect: columnTag{table: "p"}},
+		{tag: ",prefix=billing_", expect: columnTag{prefix: "billing_"}},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.tag, func(t *testing.T) {
+			assert.Equal(t, tc.expect, parseColumnTag(tc.tag))
+		})
+	}
+}
+
+type testPrefixAddress struct {
+	Street string `sql:"street"`
+	City   string `sql:"city,table=a"`
+}
+
+type testPrefixCustomer struct {
+	Id       int64             `sql:"id"`
+	Billing  testPrefixAddress `sql:",prefix=billing_"`
+	Shipping struct {
+		Address testPrefixAddress `sql:",prefix=addr_"`
+	} `sql:",prefix=shipping_"`
+}
+
+func TestStructMapper_ColumnPrefix(t *testing.T) {
+	sm, err := NewStructMapper[testPrefixCustomer]("", Query("FROM customers"), ErrorOnUnknownColumns(true), ErrorOnUnMappedColumns(true))
+	require.NoError(t, err)
+	raw := sm.(*structMapper[testPrefixCustomer])
+	assert.Equal(t, "id,billing_street,a.billing_city,shipping_addr_street,a.shipping_addr_city", raw.cols)
+
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer func() {
+		_ = db.Close()
+	}()
+	mock.ExpectQuery("").WillReturnRows(sqlmock.NewRows([]string{"id", "billing_street", "billing_city", "shipping_addr_street", "shipping_addr_city"}).
+		AddRow(int64(1), "Bagshot Row", "Hobbiton", "Bree Road", "Bree"))
+	row, err := sm.FirstRow(ctx, db, nil)
+	require.NoError(t, err)
+	require.NotNil(t, row)
+	assert.Equal(t, "Bagshot Row", row.Billing.Street)
+	assert.Equal(t, "Hobbiton", row.Billing.City)
+	assert.Equal(t, "Bree Road", row.Shipping.Address.Street)
+	assert.Equal(t, "Bree", row.Shipping.Address.City)
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestStructMapper_ColumnPrefix_Errors(t *testing.T) {
+	type unprefixed struct {
+		Billing  testPrefixAddress
+		Shipping testPrefixAddress
+	}
+	_, err := NewStructMapper[unprefixed]("street")
+	require.Error(t, err)
+	assert.Equal(t, `duplicate column mapping "street"`, err.Error())
+
+	type samePrefix struct {
+		Street  string            `sql:"billing_street"`
+		Billing testPrefixAddress `sql:",prefix=billing_"`
+	}
+	_, err = NewStructMapper[samePrefix]("billing_street")
+	require.Error(t, err)
+	assert.Equal(t, `duplicate column mapping "billing_street"`, err.Error())
+
+	sm, err := NewStructMapper[testPrefixCustomer]("id,billing_street", Query("FROM customers"), ErrorOnUnknownColumns(true))
+	require.NoError(t, err)
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer func() {
+		_ = db.Close()
+	}()
+	mock.ExpectQuery("").WillReturnRows(sqlmock.NewRows([]string{"id", "billing_street"}).AddRow(int64(1), "Bagshot Row"))
+	_, err = sm.Rows(ctx, db, nil)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), `"billing_city"`)
+	assert.NotContains(t, err.Error(), `"city"`)
+}
@@ -0,0 +1,186 @@
+package columbus
+
+import (
+	"encoding"
+	"encoding/json"
+	"github.com/shopspring/decimal"
+	"reflect"
+	"strings"
+)
+
+var (
+	jsonMarshalerType = reflect.TypeOf((*json.Marshaler)(nil)).Elem()
+	textMarshalerType = reflect.TypeOf((*encoding.TextMarshaler)(nil)).Elem()
+	decimalType       = reflect.TypeOf(decimal.Decimal{})
+)
+
+// structEncodeInfo returns the EncodeInfo for a struct type (see structColumns) - slice of struct fields are the Nested
+// (array) properties
+func structEncodeInfo(rt reflect.Type) EncodeInfo {
+	result := EncodeInfo{Columns: structColumns(rt, nil, false, nil)}
+	result.Properties = make([]string, len(result.Columns))
+	for i, col := range result.Columns {
+		result.Properties[i] = col.Property
+		if col.ScanType.Kind() != reflect.Slice {
+			continue
+		}
+		et := col.ScanType.Elem()
+		for et.Kind() == reflect.Ptr {
+			et = et.Elem()
+		}
+		if isEncodeObject(et) {
+			nested := NestedInfo{Property: col.Property, Array: true, Properties: make([]string, 0)}
+			for _, child := range structColumns(et, nil, false, nil) {
+				nested.Properties = append(nested.Properties, child.Property)
+			}
+			result.Nested = append(result.Nested, nested)
+		}
+	}
+	return result
+}
+
+// structColumns returns the properties (in field order) of a struct type for encoding
+//
+// property names are the `json` tag names of the fields (or the field name if there is no json tag) - the properties of
+// nested structs are specified using dot notation (embedded structs without a json tag name are flattened)
+//
+// properties are nullable if the field (or any of its parent structs) is a pointer - or is a slice, map or interface
+func structColumns(rt reflect.Type, path []string, nullable bool, result []ColumnInfo) []ColumnInfo {
+	for i := 0; i < rt.NumField(); i++ {
+		f := rt.Field(i)
+		name, embedded, ok := encodeFieldName(f)
+		if !ok {
+			continue
+		}
+		ft := f.Type
+		ptr := false
+		for ft.Kind() == reflect.Ptr {
+			ft = ft.Elem()
+			ptr = true
+		}
+		if isEncodeObject(ft) {
+			if embedded {
+				result = structColumns(ft, path, nullable || ptr, result)
+			} else {
+				result = structColumns(ft, append(path[:len(path):len(path)], name), nullable || ptr, result)
+			}
+			continue
+		}
+		switch ft.Kind() {
+		case reflect.Slice, reflect.Map, reflect.Interface:
+			ptr = true
+		}
+		result = append(result, ColumnInfo{
+			Property: strings.Join(append(path[:len(path):len(path)], name), "."),
+			ScanType: ft,
+			Nullable: nullable || ptr,
+			Decimal:  ft == decimalType,
+		})
+	}
+	return result
+}
+
+// structRow converts a struct to a map[string]any for encoding (see structColumns for property names)
+//
+// values that are json.Marshaler or encoding.TextMarshaler (e.g. time.Time or decimal.Decimal) are not converted
+func structRow(rv reflect.Value) map[string]any {
+	result := make(map[string]any, rv.NumField())
+	addStructProperties(rv, result)
+	return result
+}
+
+func addStructProperties(rv reflect.Value, result map[string]any) {
+	rt := rv.Type()
+	for i := 0; i < rt.NumField(); i++ {
+		f := rt.Field(i)
+		name, embedded, ok := encodeFieldName(f)
+		if !ok {
+			continue
+		}
+		fv := rv.Field(i)
+		if embedded {
+			for fv.Kind() == reflect.Ptr && !fv.IsNil() {
+				fv = fv.Elem()
+			}
+			if fv.Kind() == reflect.Struct && isEncodeObject(fv.Type()) {
+				addStructProperties(fv, result)
+				continue
+			} else if fv.Kind() == reflect.Ptr && isEncodeObject(fv.Type().Elem()) {
+				continue
+			}
+		}
+		result[name] = encodeValue(fv)
+	}
+}
+
+func encodeValue(rv reflect.Value) any {
+	for rv.Kind() == reflect.Ptr || rv.Kind() == reflect.Interface {
+		if rv.IsNil() {
+			return nil
+		}
+		rv = rv.Elem()
+	}
+	if !rv.IsValid() {
+		return nil
+	}
+	switch rv.Kind() {
+	case reflect.Struct:
+		if isEncodeObject(rv.Type()) {
+			return structRow(rv)
+		}
+	case reflect.Slice:
+		if rv.IsNil() {
+			return nil
+		} else if rv.Type().Elem().Kind() == reflect.Uint8 {
+			return rv.Interface()
+		}
+		fallthrough
+	case reflect.Array:
+		items := make([]any, rv.Len())
+		for i := range items {
+			items[i] = encodeValue(rv.Index(i))
+		}
+		return items
+	case reflect.Map:
+		if rv.IsNil() {
+			return nil
+		} else if rv.Type().Key().Kind() == reflect.String && !isMarshaler(rv.Type()) {
+			obj := make(map[string]any, rv.Len())
+			for iter := rv.MapRange(); iter.Next(); {
+				obj[iter.Key().String()] = encodeValue(iter.Value())
+			}
+			return obj
+		}
+	}
+	return rv.Interface()
+}
+
+// encodeFieldName returns the property name for a struct field (ok is false if the field is not encoded)
+//
+// as with encoding/json, the exported fields of unexported embedded structs are encoded
+func encodeFieldName(f reflect.StructField) (name string, embedded bool, ok bool) {
+	tag, _, _ := strings.Cut(f.Tag.Get("json"), ",")
+	if tag == "-" {
+		return "", false, false
+	} else if f.Anonymous && tag == "" {
+		if !f.IsExported() && f.Type.Kind() != reflect.Struct {
+			return "", false, false
+		}
+		return f.Name, true, true
+	} else if !f.IsExported() {
+		return "", false, false
+	} else if tag != "" {
+		return tag, false, true
+	}
+	return f.Name, false, true
+}
+
+// isEncodeObject determines whether a type is encoded as an object (i.e. a struct that is not a json.Marshaler or encoding.TextMarshaler)
+func isEncodeObject(t reflect.Type) bool {
+	return t.Kind() == reflect.Struct && !isMarshaler(t)
+}
+
+func isMarshaler(t reflect.Type) bool {
+	return t.Implements(jsonMarshalerType) || t.Implements(textMarshalerType) ||
+		reflect.PointerTo(t).Implements(jsonMarshalerType) || reflect.PointerTo(t).Implements(textMarshalerType)
+}
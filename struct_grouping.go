@@ -0,0 +1,135 @@
+package columbus
+
+import (
+	"database/sql"
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// structGroupField is a ColumnGroup resolved to the slice field that the child structs are assigned to
+type structGroupField struct {
+	// index is the index of the field (in the parent struct)
+	index []int
+	// elemType is the child struct type and ptrs is whether the slice is of pointers to the child struct
+	elemType reflect.Type
+	ptrs     bool
+	// columns is the field accessors of the child struct (by column name, without Prefix)
+	columns map[string]func(any) any
+}
+
+// resolveStructGroupFields resolves the slice fields for the column groups (and any nested column groups) - the returned
+// fields are in the same order as groupColumns.all
+//
+// the top level group fields are added to skip (so that they are not mapped to columns)
+func resolveStructGroupFields(namers []FieldColumnNamer, rt reflect.Type, groups []ColumnGroup, skip map[string]bool) ([]*structGroupField, error) {
+	result := make([]*structGroupField, 0)
+	var resolve func(rt reflect.Type, groups []ColumnGroup, skip map[string]bool) error
+	resolve = func(rt reflect.Type, groups []ColumnGroup, skip map[string]bool) error {
+		for _, cg := range groups {
+			f, ok := rt.FieldByName(cg.Property)
+			if !ok || !f.IsExported() {
+				return fmt.Errorf("grouping field '%s' does not exist", cg.Property)
+			}
+			elemType := f.Type
+			if elemType.Kind() == reflect.Slice {
+				elemType = elemType.Elem()
+			}
+			ptrs := elemType.Kind() == reflect.Ptr
+			if ptrs {
+				elemType = elemType.Elem()
+			}
+			if f.Type.Kind() != reflect.Slice || elemType.Kind() != reflect.Struct || isScannable(elemType) {
+				return fmt.Errorf("grouping field '%s' must be a slice of structs", cg.Property)
+			} else if skip[indexKey(f.Index)] {
+				return fmt.Errorf("grouping field '%s' is populated by a struct sub-query", cg.Property)
+			}
+			skip[indexKey(f.Index)] = true
+			gf := &structGroupField{
+				index:    f.Index,
+				elemType: elemType,
+				ptrs:     ptrs,
+				columns:  make(map[string]func(any) any),
+			}
+			result = append(result, gf)
+			childSkip := make(map[string]bool)
+			if err := resolve(elemType, cg.Groups, childSkip); err != nil {
+				return err
+			}
+			if err := buildFieldMapRecursive(namers, elemType, nil, "", gf.columns, nil, childSkip); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	return result, resolve(rt, groups, skip)
+}
+
+// resolveGroupColumns resolves the grouping for the columns - a column is a column of a group if it is mapped by a
+// field of the child struct (with the group Prefix)
+func (m *structMapper[T]) resolveGroupColumns(columns []string) (*groupColumns, error) {
+	return m.grouping.resolve(columns, func(spec *groupSpec, col string) (string, bool) {
+		if strings.HasPrefix(col, spec.Prefix) {
+			if _, ok := m.groupFields[spec.index].columns[col[len(spec.Prefix):]]; ok {
+				return col[len(spec.Prefix):], true
+			}
+		}
+		return "", false
+	})
+}
+
+// readGroupedRow reads the current row into a new T and the child structs for each group
+//
+// the row is scanned twice - first to read the key values (so that the columns of groups with null keys, e.g. for
+// an unmatched LEFT JOIN, are not scanned into the child struct fields) and then into the fields
+func (m *structMapper[T]) readGroupedRow(rows *sql.Rows, fieldPtrs func(*T) []any) (key string, item *T, items []groupItem, err error) {
+	gc := m.groupColumns
+	values := make([]any, len(gc.owners))
+	ptrs := make([]any, len(values))
+	for i := range values {
+		ptrs[i] = &values[i]
+	}
+	if err = rows.Scan(ptrs...); err != nil {
+		return "", nil, nil, err
+	}
+	key, _ = keyValues(values, gc.keys)
+	items = make([]groupItem, len(gc.all))
+	for i, spec := range gc.all {
+		items[i].key, items[i].null = keyValues(values, spec.keys)
+		if items[i].null = items[i].null || (spec.parent != nil && items[spec.parent.index].null); !items[i].null {
+			items[i].item = reflect.New(m.groupFields[i].elemType).Interface()
+		}
+	}
+	item = new(T)
+	ptrs = fieldPtrs(item)
+	for i, owner := range gc.owners {
+		if owner != -1 && !items[owner].null {
+			if acc, ok := m.groupFields[owner].columns[gc.names[i]]; ok {
+				ptrs[i] = acc(items[owner].item)
+			}
+		}
+	}
+	if err = rows.Scan(ptrs...); err != nil {
+		return "", nil, nil, err
+	}
+	return key, item, items, nil
+}
+
+// assignGroupRows assigns the child structs of a group to the slice field of the parent (or child) struct
+func (m *structMapper[T]) assignGroupRows(item any, spec *groupSpec, children []any) {
+	gf := m.groupFields[spec.index]
+	fv := reflect.ValueOf(item).Elem().FieldByIndex(gf.index)
+	if len(children) == 0 && spec.EmptyNil {
+		fv.SetZero()
+		return
+	}
+	slice := reflect.MakeSlice(fv.Type(), 0, len(children))
+	for _, child := range children {
+		cv := reflect.ValueOf(child)
+		if !gf.ptrs {
+			cv = cv.Elem()
+		}
+		slice = reflect.Append(slice, cv)
+	}
+	fv.Set(slice)
+}
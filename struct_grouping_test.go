@@ -0,0 +1,139 @@
+package columbus
+
+import (
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+type testGroupingLine struct {
+	No      int64  `sql:"no"`
+	Product string `sql:"product"`
+}
+
+type testGroupingOrder struct {
+	Id    int64               `sql:"id"`
+	Ref   string              `sql:"ref"`
+	Lines []*testGroupingLine `json:"lines"`
+}
+
+type testGroupingPerson struct {
+	Id     int64               `sql:"id"`
+	Name   string              `sql:"name"`
+	Orders []testGroupingOrder `json:"orders"`
+}
+
+var testGroupingPersonGrouping = Grouping{
+	Key: []string{"id"},
+	Groups: []ColumnGroup{
+		{Property: "Orders", Key: []string{"order_id"}, Prefix: "order_", Groups: []ColumnGroup{
+			{Property: "Lines", Key: []string{"order_id", "line_no"}, Prefix: "line_"},
+		}},
+	},
+}
+
+func TestStructMapper_Grouping(t *testing.T) {
+	sm, err := NewStructMapper[testGroupingPerson]("p.id,p.name,o.id AS order_id,o.ref AS order_ref,l.no AS line_no,l.product AS line_product",
+		Query("FROM people p LEFT JOIN orders o ON o.person_id = p.id LEFT JOIN lines l ON l.order_id = o.id ORDER BY p.id"),
+		testGroupingPersonGrouping,
+		ErrorOnUnMappedColumns(true),
+	)
+	require.NoError(t, err)
+
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer func() {
+		_ = db.Close()
+	}()
+	mock.ExpectQuery("").WillReturnRows(sqlmock.NewRows([]string{"id", "name", "order_id", "order_ref", "line_no", "line_product"}).
+		AddRow(int64(1), "Bilbo", int64(100), "A", int64(1), "Ring").
+		AddRow(int64(1), "Bilbo", int64(100), "A", int64(2), "Sword").
+		AddRow(int64(1), "Bilbo", int64(101), "B", nil, nil).
+		AddRow(int64(2), "Frodo", nil, nil, nil, nil))
+
+	rows, err := sm.Rows(ctx, db, nil)
+	require.NoError(t, err)
+	require.NoError(t, mock.ExpectationsWereMet())
+	assert.Equal(t, []testGroupingPerson{
+		{Id: 1, Name: "Bilbo", Orders: []testGroupingOrder{
+			{Id: 100, Ref: "A", Lines: []*testGroupingLine{{No: 1, Product: "Ring"}, {No: 2, Product: "Sword"}}},
+			{Id: 101, Ref: "B", Lines: []*testGroupingLine{}},
+		}},
+		{Id: 2, Name: "Frodo", Orders: []testGroupingOrder{}},
+	}, rows)
+}
+
+func TestStructMapper_Grouping_FirstRow_Keyed(t *testing.T) {
+	grouping := testGroupingPersonGrouping
+	grouping.Keyed = true
+	grouping.Groups = []ColumnGroup{{Property: "Orders", Key: []string{"order_id"}, Prefix: "order_", EmptyNil: true}}
+	sm, err := NewStructMapper[testGroupingPerson]("id,name,order_id,order_ref", Query("FROM people"), grouping)
+	require.NoError(t, err)
+
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer func() {
+		_ = db.Close()
+	}()
+	newRows := func() *sqlmock.Rows {
+		return sqlmock.NewRows([]string{"id", "name", "order_id", "order_ref"}).
+			AddRow(int64(1), "Bilbo", int64(100), "A").
+			AddRow(int64(2), "Frodo", nil, nil).
+			AddRow(int64(1), "Bilbo", int64(101), "B")
+	}
+	mock.ExpectQuery("").WillReturnRows(newRows())
+	row, err := sm.FirstRow(ctx, db, nil)
+	require.NoError(t, err)
+	require.NotNil(t, row)
+	assert.Equal(t, testGroupingPerson{Id: 1, Name: "Bilbo", Orders: []testGroupingOrder{{Id: 100, Ref: "A"}, {Id: 101, Ref: "B"}}}, *row)
+
+	mock.ExpectQuery("").WillReturnRows(newRows())
+	names := make([]string, 0)
+	for item, err := range sm.ErrIterator(ctx, db, nil) {
+		require.NoError(t, err)
+		names = append(names, item.Name)
+		if item.Name == "Frodo" {
+			assert.Nil(t, item.Orders)
+		}
+	}
+	assert.Equal(t, []string{"Bilbo", "Frodo"}, names)
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestStructMapper_Grouping_Errors(t *testing.T) {
+	_, err := NewStructMapper[testGroupingPerson]("id", Grouping{Key: []string{"id"}, Groups: []ColumnGroup{{Property: "Unknown", Key: []string{"x"}}}})
+	require.Error(t, err)
+	assert.Equal(t, "grouping field 'Unknown' does not exist", err.Error())
+
+	_, err = NewStructMapper[testGroupingPerson]("id", Grouping{Key: []string{"id"}, Groups: []ColumnGroup{{Property: "Name", Key: []string{"x"}}}})
+	require.Error(t, err)
+	assert.Equal(t, "grouping field 'Name' must be a slice of structs", err.Error())
+
+	_, err = NewStructMapper[testSubQueryPerson]("id", Grouping{Key: []string{"id"}, Groups: []ColumnGroup{{Property: "Addresses", Key: []string{"x"}}}})
+	require.Error(t, err)
+	assert.Equal(t, "grouping field 'Addresses' is populated by a struct sub-query", err.Error())
+
+	_, err = NewStructMapper[testGroupingPerson]("id", Grouping{})
+	require.Error(t, err)
+	assert.Equal(t, "grouping must have a key", err.Error())
+
+	sm, err := NewStructMapper[testGroupingPerson]("id", Query("FROM people"), testGroupingPersonGrouping)
+	require.NoError(t, err)
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer func() {
+		_ = db.Close()
+	}()
+	mock.ExpectQuery("").WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(int64(1)))
+	_, err = sm.Rows(ctx, db, nil)
+	require.Error(t, err)
+	assert.Equal(t, "grouping key column 'order_id' not present", err.Error())
+
+	_, err = sm.Rows(ctx, db, nil, &Keyset{Sort: []string{"id"}, Size: 1})
+	require.Error(t, err)
+	assert.Equal(t, "keyset cannot be used with grouping", err.Error())
+	_, err = sm.Rows(ctx, db, nil, RowLimit{Limit: 1})
+	require.Error(t, err)
+	assert.Equal(t, "row limit cannot be used with grouping", err.Error())
+}
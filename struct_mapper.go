@@ -5,7 +5,10 @@ import (
 	"database/sql"
 	"errors"
 	"fmt"
+	"io"
+	"iter"
 	"reflect"
+	"slices"
 	"strings"
 	"sync"
 )
@@ -39,6 +42,10 @@ type ErrorOnUnknownColumns bool
 // and determines whether an error is raised when there are columns that are not mapped to fields
 type ErrorOnUnMappedColumns bool
 
+// TableAlias is a type that can be passed as an option to NewStructMapper
+// and is the table qualifier (name or alias) for derived columns (see NewStructMapper) whose tags do not specify a table
+type TableAlias string
+
 // StructPostProcessor is an interface that can be passed as an option to NewStructMapper (or
 // any of the row reading methods - StructMapper.Rows, StructMapper.Iterate, StructMapper.FirstRow, StructMapper.ExactlyOneRow, etc.)
 //
@@ -52,29 +59,49 @@ type StructPostProcessor[T any] interface {
 type StructMapper[T any] interface {
 	// Rows reads all rows and maps them into a slice of `T`
 	//
-	// options can be any of Query, AddClause, StructPostProcessor[T], ErrorTranslator or Limiter
+	// options can be any of Query, AddClause, AddClauseWithArgs, StructPostProcessor[T], PropertyExclusions, PropertyExcluder (e.g. *Fields),
+	// ErrorTranslator, Limiter, BatchSize, Dialect, *Keyset or RowLimit
 	Rows(ctx context.Context, db SqlInterface, args []any, options ...any) ([]T, error)
+	// WriteRows reads all rows and writes them to the supplied writer - as a JSON array, unless a RowEncoding (or Accept) option is passed
+	//
+	// for encoding, each row is converted to a `map[string]any` using the `json` tag names of the fields (nested structs become objects)
+	//
+	// options can be any of Query, AddClause, AddClauseWithArgs, StructPostProcessor[T], PropertyExclusions, PropertyExcluder (e.g. *Fields),
+	// ErrorTranslator, Limiter, BatchSize, Dialect, *Keyset, RowLimit, RowEncoding or Accept
+	WriteRows(ctx context.Context, writer io.Writer, db SqlInterface, args []any, options ...any) error
 	// Iterate iterates over the rows and calls the supplied handler with each row
 	//
 	// iteration stops at the end of rows - or an error is encountered - or the supplied handler returns false for `cont` (continue)
 	//
-	// options can be any of Query, AddClause, StructPostProcessor[T], ErrorTranslator or Limiter (ignored)
+	// options can be any of Query, AddClause, AddClauseWithArgs, StructPostProcessor[T], PropertyExclusions, PropertyExcluder (e.g. *Fields),
+	// ErrorTranslator, BatchSize, Dialect, RowLimit or Limiter (ignored)
 	Iterate(ctx context.Context, db SqlInterface, args []any, handler func(row T) (cont bool, err error), options ...any) error
 	// Iterator return an iterator that can be ranged over
 	//
-	// options can be any of Query, AddClause, StructPostProcessor[T], ErrorTranslator or Limiter
+	// options can be any of Query, AddClause, AddClauseWithArgs, StructPostProcessor[T], PropertyExclusions, PropertyExcluder (e.g. *Fields),
+	// ErrorTranslator, Limiter, BatchSize, Dialect or RowLimit
 	Iterator(ctx context.Context, db SqlInterface, args []any, options ...any) func(func(int, T) bool)
+	// ErrIterator returns an iterator that can be ranged over - yielding each row and any error
+	//
+	// the query is executed when the iterator is ranged over and iteration stops after an error is yielded (or when
+	// the range loop is exited) - errors are translated with any ErrorTranslator
+	//
+	// options can be any of Query, AddClause, AddClauseWithArgs, StructPostProcessor[T], PropertyExclusions, PropertyExcluder (e.g. *Fields),
+	// ErrorTranslator, Limiter, BatchSize, Dialect or RowLimit
+	ErrIterator(ctx context.Context, db SqlInterface, args []any, options ...any) iter.Seq2[T, error]
 	// FirstRow reads just the first row and maps it into a `T`
 	//
 	// if there are no rows, returns nil
 	//
-	// options can be any of Query, AddClause, StructPostProcessor[T], ErrorTranslator or Limiter (ignored)
+	// options can be any of Query, AddClause, AddClauseWithArgs, StructPostProcessor[T], PropertyExclusions, PropertyExcluder (e.g. *Fields),
+	// ErrorTranslator, BatchSize, Dialect or Limiter (ignored)
 	FirstRow(ctx context.Context, sqli SqlInterface, args []any, options ...any) (*T, error)
 	// ExactlyOneRow reads exactly one row and maps it into a `T`
 	//
 	// if there are no rows, returns error sql.ErrNoRows
 	//
-	// options can be any of Query, AddClause, StructPostProcessor[T], ErrorTranslator or Limiter (ignored)
+	// options can be any of Query, AddClause, AddClauseWithArgs, StructPostProcessor[T], PropertyExclusions, PropertyExcluder (e.g. *Fields),
+	// ErrorTranslator, BatchSize, Dialect or Limiter (ignored)
 	ExactlyOneRow(ctx context.Context, sqli SqlInterface, args []any, options ...any) (T, error)
 }
 
@@ -84,16 +111,51 @@ type structMapper[T any] struct {
 	mu                     sync.RWMutex
 	mapped                 bool
 	fieldMappers           func(*T) []any
+	columnAccessors        map[string]func(any) any
 	errorOnUnknownColumns  bool
 	errorOnUnMappedColumns bool
 	mapError               error
 	postProcessors         []StructPostProcessor[T]
 	useTagName             string
+	tableAlias             string
 	fieldColumnNamers      []FieldColumnNamer
 	errorTranslator        ErrorTranslator
+	dialect                Dialect
+	encoding               RowEncoding
+	batchSize              int
+	subQueryOptions        []*structSubQuery
+	subQueries             []*structSubQueryField
+	grouping               *Grouping
+	groupFields            []*structGroupField
+	groupColumns           *groupColumns
+	// skipFields is the fields (by index key) that are not mapped to columns - i.e. populated by struct sub-queries or grouping
+	skipFields map[string]bool
 }
 
 // NewStructMapper creates a new struct mapper for reading structs from database rows
+//
+// nested slice, struct and pointer fields can be populated by struct sub-queries - declared by tag (see StructSubQueryTag)
+// or by passing StructSubQuery options (the BatchSize option sets the default batch size for batch struct sub-queries)
+//
+// slice fields can also be populated from the rows of a single (JOIN) query by passing a Grouping option - where the
+// Property of each ColumnGroup is the name of the slice field
+//
+// if cols is empty, the select column list is derived from the mapped fields (in field order) - tags may specify
+// a table qualifier and/or an expression for the column, e.g.
+//
+//	Name     string `sql:"name,table=p"`
+//	FullName string `sql:"full_name,expr=CONCAT(p.first_name,' ',p.last_name)"`
+//
+// selects "p.name" and "CONCAT(p.first_name,' ',p.last_name) AS full_name" (the expr option must be the last tag option).
+//
+// nested struct fields may specify a column name prefix for all descendant fields - so that the same struct type can be
+// used more than once, e.g.
+//
+//	Billing  Address `sql:",prefix=billing_"`
+//	Shipping Address `sql:",prefix=shipping_"`
+//
+// The TableAlias option sets the table qualifier for columns whose tags do not specify one. When grouping, the columns
+// of the child structs are also derived - qualified by the ColumnGroup Table and aliased with the ColumnGroup Prefix
 func NewStructMapper[T any](cols string, options ...any) (StructMapper[T], error) {
 	var zero T
 	if reflect.TypeOf(zero).Kind() != reflect.Struct {
@@ -102,6 +164,7 @@ func NewStructMapper[T any](cols string, options ...any) (StructMapper[T], error
 	return (&structMapper[T]{
 		cols:            cols,
 		errorTranslator: defaultErrorTranslator,
+		batchSize:       defaultBatchSize,
 	}).processInitialOptions(options)
 }
 
@@ -115,155 +178,309 @@ func MustNewStructMapper[T any](cols string, options ...any) StructMapper[T] {
 }
 
 func (m *structMapper[T]) Rows(ctx context.Context, db SqlInterface, args []any, options ...any) (result []T, err error) {
-	query, postProcessors, limiter, errTranslator, err := m.rowMapOptions(options)
+	opts, err := m.rowMapOptions(options)
 	if err == nil {
 		var rows *sql.Rows
-		if rows, err = db.QueryContext(ctx, query, args...); err == nil {
+		db = opts.sqlInterface(db)
+		if rows, err = opts.queryContext(ctx, db, args); err == nil {
 			defer func() {
 				_ = rows.Close()
 			}()
 			var fieldPtrs func(*T) []any
 			if fieldPtrs, err = m.getFieldMappers(rows); err == nil {
+				batch := opts.newBatch(ctx, db)
+				add := func(completed []*T) {
+					for _, item := range completed {
+						result = append(result, *item)
+					}
+				}
+				var completed []*T
 				rowCount := 0
 				for err == nil && rows.Next() {
 					rowCount++
-					if limiter.LimitReached(rowCount) {
+					if batch.limitReached(rowCount) {
 						break
 					}
-					var item T
-					if err = rows.Scan(fieldPtrs(&item)...); err == nil {
-						for _, pp := range postProcessors {
-							if err = pp.PostProcess(ctx, db, &item); err != nil {
-								return nil, translateError(err, errTranslator)
-							}
-						}
-						result = append(result, item)
+					if completed, err = m.readRow(rows, fieldPtrs, batch); err != nil {
+						return nil, translateError(err, opts.errorTranslator)
 					}
+					add(completed)
 				}
 				if err == nil {
 					err = rows.Err()
 				}
+				if err == nil {
+					if completed, err = batch.flush(); err != nil {
+						return nil, translateError(err, opts.errorTranslator)
+					}
+					add(completed)
+				}
+				if err == nil && opts.keyset.reversed() {
+					slices.Reverse(result)
+				}
+				if err == nil && opts.keyset != nil {
+					var firstRow, lastRow *T
+					if len(result) > 0 {
+						firstRow, lastRow = &result[0], &result[len(result)-1]
+					}
+					err = opts.keyset.complete(len(result), firstRow, lastRow, m.keysetValue)
+				}
 			}
 		}
 	}
-	return result, translateError(err, errTranslator)
+	return result, translateError(err, opts.errorTranslator)
+}
+
+func (m *structMapper[T]) WriteRows(ctx context.Context, writer io.Writer, db SqlInterface, args []any, options ...any) (err error) {
+	opts, err := m.rowMapOptions(options)
+	if err == nil {
+		var rows *sql.Rows
+		db = opts.sqlInterface(db)
+		if rows, err = opts.queryContext(ctx, db, args); err == nil {
+			defer func() {
+				_ = rows.Close()
+			}()
+			var fieldPtrs func(*T) []any
+			if fieldPtrs, err = m.getFieldMappers(rows); err == nil {
+				enc := opts.encoding.NewEncoder(writer)
+				if err = enc.Begin(structEncodeInfo(reflect.TypeOf((*T)(nil)).Elem())); err == nil {
+					var firstRow, lastRow *T
+					rowCount := 0
+					var reversed []*T
+					emit := func(completed []*T) (err error) {
+						for i := 0; err == nil && i < len(completed); i++ {
+							if err = enc.Row(structRow(reflect.ValueOf(completed[i]).Elem())); firstRow == nil {
+								firstRow = completed[i]
+							}
+							lastRow = completed[i]
+							rowCount++
+						}
+						return err
+					}
+					write := func(completed []*T) error {
+						if opts.keyset.reversed() {
+							reversed = append(reversed, completed...)
+							return nil
+						}
+						return emit(completed)
+					}
+					batch := opts.newBatch(ctx, db)
+					var completed []*T
+					readCount := 0
+					for err == nil && rows.Next() {
+						if readCount++; batch.limitReached(readCount) {
+							break
+						}
+						if completed, err = m.readRow(rows, fieldPtrs, batch); err == nil {
+							err = write(completed)
+						}
+					}
+					if err == nil {
+						err = rows.Err()
+					}
+					if err == nil {
+						if completed, err = batch.flush(); err == nil {
+							err = write(completed)
+						}
+					}
+					if err == nil && len(reversed) > 0 {
+						slices.Reverse(reversed)
+						err = emit(reversed)
+					}
+					if err == nil && opts.keyset != nil {
+						err = opts.keyset.complete(rowCount, firstRow, lastRow, m.keysetValue)
+					}
+					if eErr := enc.End(); err == nil {
+						err = eErr
+					}
+				}
+			}
+		}
+	}
+	return translateError(err, opts.errorTranslator)
 }
 
 func (m *structMapper[T]) Iterate(ctx context.Context, db SqlInterface, args []any, handler func(row T) (cont bool, err error), options ...any) (err error) {
-	query, postProcessors, _, errTranslator, err := m.rowMapOptions(options)
+	opts, err := m.rowMapOptionsNoKeyset(options)
 	if err == nil {
 		var rows *sql.Rows
-		if rows, err = db.QueryContext(ctx, query, args...); err == nil {
+		db = opts.sqlInterface(db)
+		if rows, err = opts.queryContext(ctx, db, args); err == nil {
 			defer func() {
 				_ = rows.Close()
 			}()
 			var fieldPtrs func(*T) []any
 			if fieldPtrs, err = m.getFieldMappers(rows); err == nil {
 				cont := true
+				handle := func(completed []*T) (err error) {
+					for i := 0; cont && err == nil && i < len(completed); i++ {
+						cont, err = handler(*completed[i])
+					}
+					return err
+				}
+				batch := opts.newBatch(ctx, db)
+				var completed []*T
 				for cont && err == nil && rows.Next() {
-					var item T
-					if err = rows.Scan(fieldPtrs(&item)...); err == nil {
-						for _, pp := range postProcessors {
-							if err = pp.PostProcess(ctx, db, &item); err != nil {
-								return translateError(err, errTranslator)
-							}
-						}
-						cont, err = handler(item)
+					if completed, err = m.readRow(rows, fieldPtrs, batch); err == nil {
+						err = handle(completed)
 					}
 				}
 				if err == nil {
 					err = rows.Err()
 				}
+				if err == nil && cont {
+					if completed, err = batch.flush(); err == nil {
+						err = handle(completed)
+					}
+				}
 			}
 		}
 	}
-	return translateError(err, errTranslator)
+	return translateError(err, opts.errorTranslator)
 }
 
 func (m *structMapper[T]) Iterator(ctx context.Context, db SqlInterface, args []any, options ...any) func(func(int, T) bool) {
-	query, postProcessors, limiter, errTranslator, err := m.rowMapOptions(options)
+	opts, err := m.rowMapOptionsNoKeyset(options)
 	if err == nil {
 		i := 0
 		var rows *sql.Rows
-		if rows, err = db.QueryContext(ctx, query, args...); err == nil {
+		db = opts.sqlInterface(db)
+		if rows, err = opts.queryContext(ctx, db, args); err == nil {
 			return func(yield func(int, T) bool) {
 				var fieldPtrs func(*T) []any
 				if fieldPtrs, err = m.getFieldMappers(rows); err == nil {
-					for err == nil && rows.Next() {
-						if limiter.LimitReached(i + 1) {
+					cont := true
+					yieldCompleted := func(completed []*T) {
+						for j := 0; cont && j < len(completed); j++ {
+							cont = yield(i, *completed[j])
+							i++
+						}
+					}
+					batch := opts.newBatch(ctx, db)
+					var completed []*T
+					readCount := 0
+					for cont && err == nil && rows.Next() {
+						if readCount++; batch.limitReached(readCount) {
 							break
 						}
-						var item T
-						if err = rows.Scan(fieldPtrs(&item)...); err == nil {
-							for _, pp := range postProcessors {
-								if err = pp.PostProcess(ctx, db, &item); err != nil {
-									break
-								}
-							}
-							if err == nil {
-								yield(i, item)
-							} else {
-								err = translateError(err, errTranslator)
-							}
-							i++
+						if completed, err = m.readRow(rows, fieldPtrs, batch); err == nil {
+							yieldCompleted(completed)
+						} else {
+							err = translateError(err, opts.errorTranslator)
+						}
+					}
+					if err == nil && cont {
+						if completed, err = batch.flush(); err == nil {
+							yieldCompleted(completed)
+						} else {
+							err = translateError(err, opts.errorTranslator)
 						}
 					}
 				}
 				_ = rows.Close()
 				if err != nil {
-					_ = translateError(err, errTranslator)
+					_ = translateError(err, opts.errorTranslator)
 				}
 			}
 		}
 	}
-	_ = translateError(err, errTranslator)
+	_ = translateError(err, opts.errorTranslator)
 	return func(func(int, T) bool) {}
 }
 
+func (m *structMapper[T]) ErrIterator(ctx context.Context, db SqlInterface, args []any, options ...any) iter.Seq2[T, error] {
+	return func(yield func(T, error) bool) {
+		var zero T
+		opts, err := m.rowMapOptionsNoKeyset(options)
+		if err != nil {
+			yield(zero, err)
+			return
+		}
+		db := opts.sqlInterface(db)
+		rows, err := opts.queryContext(ctx, db, args)
+		if err != nil {
+			yield(zero, translateError(err, opts.errorTranslator))
+			return
+		}
+		defer func() {
+			_ = rows.Close()
+		}()
+		var fieldPtrs func(*T) []any
+		if fieldPtrs, err = m.getFieldMappers(rows); err != nil {
+			yield(zero, translateError(err, opts.errorTranslator))
+			return
+		}
+		batch := opts.newBatch(ctx, db)
+		yieldCompleted := func(completed []*T) bool {
+			for _, item := range completed {
+				if !yield(*item, nil) {
+					return false
+				}
+			}
+			return true
+		}
+		var completed []*T
+		rowCount := 0
+		for rows.Next() {
+			rowCount++
+			if batch.limitReached(rowCount) {
+				break
+			}
+			if completed, err = m.readRow(rows, fieldPtrs, batch); err != nil {
+				yield(zero, translateError(err, opts.errorTranslator))
+				return
+			} else if !yieldCompleted(completed) {
+				return
+			}
+		}
+		if err = rows.Err(); err == nil {
+			completed, err = batch.flush()
+		}
+		if err != nil {
+			yield(zero, translateError(err, opts.errorTranslator))
+		} else {
+			yieldCompleted(completed)
+		}
+	}
+}
+
 func (m *structMapper[T]) FirstRow(ctx context.Context, sqli SqlInterface, args []any, options ...any) (result *T, err error) {
-	query, postProcessors, _, errTranslator, err := m.rowMapOptions(options)
+	opts, err := m.rowMapOptionsNoKeyset(options)
 	if err == nil {
 		var rows *sql.Rows
-		if rows, err = sqli.QueryContext(ctx, query, args...); err == nil {
+		sqli = opts.sqlInterface(sqli)
+		if rows, err = opts.queryContext(ctx, sqli, args); err == nil {
 			defer func() {
 				_ = rows.Close()
 			}()
 			var fieldPtrs func(*T) []any
 			if fieldPtrs, err = m.getFieldMappers(rows); err == nil {
 				if rows.Next() {
-					var item T
-					if err = rows.Scan(fieldPtrs(&item)...); err == nil {
-						for _, pp := range postProcessors {
-							if err = pp.PostProcess(ctx, sqli, &item); err != nil {
-								return nil, translateError(err, errTranslator)
-							}
-						}
-						result = &item
+					if result, err = m.readFirstRow(ctx, sqli, rows, fieldPtrs, opts); err != nil {
+						return nil, translateError(err, opts.errorTranslator)
 					}
 				}
 			}
 		}
 	}
-	return result, translateError(err, errTranslator)
+	return result, translateError(err, opts.errorTranslator)
 }
 
 func (m *structMapper[T]) ExactlyOneRow(ctx context.Context, sqli SqlInterface, args []any, options ...any) (result T, err error) {
-	query, postProcessors, _, errTranslator, err := m.rowMapOptions(options)
+	opts, err := m.rowMapOptionsNoKeyset(options)
 	if err == nil {
 		var rows *sql.Rows
-		if rows, err = sqli.QueryContext(ctx, query, args...); err == nil {
+		sqli = opts.sqlInterface(sqli)
+		if rows, err = opts.queryContext(ctx, sqli, args); err == nil {
 			defer func() {
 				_ = rows.Close()
 			}()
 			var fieldPtrs func(*T) []any
 			if fieldPtrs, err = m.getFieldMappers(rows); err == nil {
 				if rows.Next() {
-					if err = rows.Scan(fieldPtrs(&result)...); err == nil {
-						for _, pp := range postProcessors {
-							if err = pp.PostProcess(ctx, sqli, &result); err != nil {
-								return result, translateError(err, errTranslator)
-							}
-						}
+					var item *T
+					if item, err = m.readFirstRow(ctx, sqli, rows, fieldPtrs, opts); err == nil {
+						result = *item
 					}
 				} else {
 					err = sql.ErrNoRows
@@ -271,25 +488,23 @@ func (m *structMapper[T]) ExactlyOneRow(ctx context.Context, sqli SqlInterface,
 			}
 		}
 	}
-	return result, translateError(err, errTranslator)
+	return result, translateError(err, opts.errorTranslator)
 }
 
 func (m *structMapper[T]) processInitialOptions(options []any) (StructMapper[T], error) {
 	m.useTagName = sqlTag
-	seenQuery := false
+	var query *Query
 	for _, o := range options {
 		if o != nil {
 			switch option := o.(type) {
 			case Query:
-				if seenQuery {
+				if query != nil {
 					return nil, errors.New("cannot use multiple default queries")
 				}
-				seenQuery = true
 				if err := checkForgedColumns(option); err != nil {
 					return nil, err
 				}
-				qStr := Query("SELECT " + m.cols + " " + string(option))
-				m.defaultQuery = &qStr
+				query = &option
 			case ErrorOnUnknownColumns:
 				m.errorOnUnknownColumns = bool(option)
 			case ErrorOnUnMappedColumns:
@@ -304,60 +519,174 @@ func (m *structMapper[T]) processInitialOptions(options []any) (StructMapper[T],
 				m.fieldColumnNamers = append(m.fieldColumnNamers, option)
 			case ErrorTranslator:
 				m.errorTranslator = option
+			case Dialect:
+				m.dialect = option
+			case RowEncoding:
+				m.encoding = option
+			case BatchSize:
+				m.batchSize = int(option)
+			case TableAlias:
+				m.tableAlias = string(option)
+			case StructSubQuery:
+				if sq, ok := option.(*structSubQuery); ok {
+					m.subQueryOptions = append(m.subQueryOptions, sq)
+				}
+			case Grouping:
+				if err := option.validate(); err != nil {
+					return nil, err
+				}
+				m.grouping = &option
 			default:
 				return nil, fmt.Errorf("unknown option type: %T", o)
 			}
 		}
 	}
 	m.fieldColumnNamers = append(m.fieldColumnNamers, &defaultFieldColumnNamer{tagName: m.useTagName})
-	if err := m.checkDuplicateMappedColumns(); err != nil {
+	var err error
+	if m.subQueries, m.skipFields, err = resolveStructSubQueries(m.fieldColumnNamers, reflect.TypeOf((*T)(nil)).Elem(), m.subQueryOptions); err != nil {
 		return nil, err
 	}
+	if m.grouping != nil {
+		if m.groupFields, err = resolveStructGroupFields(m.fieldColumnNamers, reflect.TypeOf((*T)(nil)).Elem(), m.grouping.Groups, m.skipFields); err != nil {
+			return nil, err
+		}
+	}
+	if err = m.checkDuplicateMappedColumns(); err != nil {
+		return nil, err
+	}
+	if m.cols == "" {
+		if m.cols, err = m.deriveColumns(); err != nil {
+			return nil, err
+		}
+	}
+	if query != nil {
+		qStr := Query("SELECT " + m.cols + " " + string(*query))
+		m.defaultQuery = &qStr
+	}
 	return m, nil
 }
 
-func (m *structMapper[T]) rowMapOptions(options []any) (query string, postProcessors []StructPostProcessor[T], limiter Limiter, errorTranslator ErrorTranslator, err error) {
+type structMapOptions[T any] struct {
+	queryOptions
+	postProcessors  []StructPostProcessor[T]
+	limiter         Limiter
+	errorTranslator ErrorTranslator
+	encoding        RowEncoding
+	subQueries      []*structSubQueryField
+	exclusions      PropertyExclusions
+	batchSize       int
+}
+
+func (m *structMapper[T]) rowMapOptions(options []any) (opts *structMapOptions[T], err error) {
+	opts = &structMapOptions[T]{
+		postProcessors:  append([]StructPostProcessor[T]{}, m.postProcessors...),
+		limiter:         defaultLimiter,
+		errorTranslator: m.errorTranslator,
+		subQueries:      m.subQueries,
+		exclusions:      make(PropertyExclusions, 0),
+		batchSize:       m.batchSize,
+	}
+	opts.dialect = m.dialect
 	querySet := false
-	postProcessors = append(postProcessors, m.postProcessors...)
-	limiter = defaultLimiter
-	errorTranslator = m.errorTranslator
-	var qb strings.Builder
+	var keyset *Keyset
+	var rowLimit *RowLimit
+	var accept *Accept
 	if m.defaultQuery != nil {
 		querySet = true
-		qb.WriteString(string(*m.defaultQuery))
+		opts.query = string(*m.defaultQuery)
 	}
 	for _, o := range options {
 		if o != nil {
 			switch option := o.(type) {
 			case Query:
 				querySet = true
-				qb.Reset()
 				if err = checkForgedColumns(option); err != nil {
 					return
 				}
-				qb.WriteString("SELECT " + m.cols + " " + string(option))
+				opts.query = "SELECT " + m.cols + " " + string(option)
+				opts.argInserts = nil
 			case AddClause:
 				if !querySet {
 					err = errors.New("add clause must have a query set")
 					return
 				}
-				qb.WriteString(" " + string(option))
+				opts.addClause(string(option), nil)
+			case AddClauseWithArgs:
+				if !querySet {
+					err = errors.New("add clause must have a query set")
+					return
+				}
+				opts.addClause(option.Clause, option.Args)
 			case StructPostProcessor[T]:
-				postProcessors = append(postProcessors, option)
+				opts.postProcessors = append(opts.postProcessors, option)
 			case Limiter:
-				limiter = option
+				opts.limiter = option
 			case ErrorTranslator:
-				errorTranslator = option
+				opts.errorTranslator = option
+			case *Keyset:
+				keyset = option
+			case Dialect:
+				opts.dialect = option
+			case RowLimit:
+				rowLimit = &option
+			case RowEncoding:
+				opts.encoding = option
+			case Accept:
+				accept = &option
+			case BatchSize:
+				opts.batchSize = int(option)
+			case PropertyExclusions:
+				opts.exclusions = append(opts.exclusions, option...)
+			case PropertyExcluder:
+				opts.exclusions = append(opts.exclusions, option)
 			default:
-				err = fmt.Errorf("unknown option type: %T", o)
-				return
+				if excf, ok := o.(func(string, []string) bool); ok {
+					opts.exclusions = append(opts.exclusions, ConditionalExclude(excf))
+				} else {
+					err = fmt.Errorf("unknown option type: %T", o)
+					return
+				}
 			}
 		}
 	}
 	if !querySet {
 		err = errors.New("no default query")
+	} else if opts.encoding, err = resolveEncoding(opts.encoding, m.encoding, accept); err != nil {
+		return opts, err
+	} else if rowLimit != nil && keyset != nil {
+		err = errors.New("row limit cannot be used with keyset")
+	} else if m.grouping != nil && keyset != nil {
+		err = errors.New("keyset cannot be used with grouping")
+	} else if m.grouping != nil && rowLimit != nil {
+		err = errors.New("row limit cannot be used with grouping")
+	} else if rowLimit != nil {
+		err = opts.applyRowLimit(rowLimit)
+	} else if keyset != nil {
+		opts.limiter, err = opts.applyKeyset(keyset, func(property string) string {
+			return property
+		}, opts.limiter)
 	}
-	return qb.String(), postProcessors, limiter, errorTranslator, err
+	return opts, err
+}
+
+// rowMapOptionsNoKeyset resolves the row mapping options for methods that do not support keyset pagination (i.e. methods
+// other than Rows and WriteRows)
+func (m *structMapper[T]) rowMapOptionsNoKeyset(options []any) (*structMapOptions[T], error) {
+	opts, err := m.rowMapOptions(options)
+	if err == nil && opts.keyset != nil {
+		err = errKeysetUnsupported
+	}
+	return opts, err
+}
+
+func (m *structMapper[T]) keysetValue(row any, sort keysetSort) (any, error) {
+	m.mu.RLock()
+	acc, ok := m.columnAccessors[sort.column]
+	m.mu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("keyset sort property '%s' not mapped to field", sort.property)
+	}
+	return reflect.ValueOf(acc(row)).Elem().Interface(), nil
 }
 
 func checkForgedColumns(query Query) error {
@@ -383,6 +712,18 @@ func (m *structMapper[T]) getFieldMappers(rows *sql.Rows) (func(*T) []any, error
 		var knownCols map[string]bool
 		if columnMap, knownCols, err = m.mapColumns(columns); err == nil {
 			m.mapped = true
+			m.columnAccessors = columnMap
+			owned := make([]bool, len(columns))
+			if m.grouping != nil {
+				if m.groupColumns, m.mapError = m.resolveGroupColumns(columns); m.mapError != nil {
+					return nil, m.mapError
+				}
+				for i, owner := range m.groupColumns.owners {
+					if owned[i] = owner != -1; owned[i] {
+						knownCols[columns[i]] = true
+					}
+				}
+			}
 			if m.errorOnUnMappedColumns {
 				unmapped := make([]string, 0, len(knownCols))
 				for col, mapped := range knownCols {
@@ -410,7 +751,7 @@ func (m *structMapper[T]) getFieldMappers(rows *sql.Rows) (func(*T) []any, error
 			m.fieldMappers = func(t *T) []any {
 				ptrs := make([]any, len(columns))
 				for i, col := range columns {
-					if acc, ok := columnMap[col]; ok {
+					if acc, ok := columnMap[col]; ok && !owned[i] {
 						ptrs[i] = acc(t)
 					} else {
 						var discard any
@@ -424,6 +765,52 @@ func (m *structMapper[T]) getFieldMappers(rows *sql.Rows) (func(*T) []any, error
 	return m.fieldMappers, err
 }
 
+// readRow reads the current row into a new T and adds it to the batch - returning any completed rows
+//
+// when grouping, the row is merged into its parent (see Grouping) and any completed parents are added to the batch
+func (m *structMapper[T]) readRow(rows *sql.Rows, fieldPtrs func(*T) []any, batch *structRowBatch[T]) (completed []*T, err error) {
+	if m.grouping == nil {
+		item := new(T)
+		if err = rows.Scan(fieldPtrs(item)...); err == nil {
+			completed, err = batch.add(item)
+		}
+		return completed, err
+	}
+	if batch.grouper == nil {
+		batch.grouper = newRowGrouper(m.groupColumns, m.assignGroupRows, batch.opts.limiter)
+	}
+	key, item, items, err := m.readGroupedRow(rows, fieldPtrs)
+	if err != nil {
+		return nil, err
+	}
+	for _, parent := range batch.grouper.add(key, item, items) {
+		var added []*T
+		if added, err = batch.add(parent.(*T)); err != nil {
+			return nil, err
+		}
+		completed = append(completed, added...)
+	}
+	return completed, nil
+}
+
+// readFirstRow reads the current row into a new T and completes it - when grouping, rows are read until the first parent is complete
+func (m *structMapper[T]) readFirstRow(ctx context.Context, sqli SqlInterface, rows *sql.Rows, fieldPtrs func(*T) []any, opts *structMapOptions[T]) (*T, error) {
+	batch := opts.newBatch(ctx, sqli)
+	batch.size = 1
+	var completed []*T
+	var err error
+	for err == nil && len(completed) == 0 {
+		if completed, err = m.readRow(rows, fieldPtrs, batch); err == nil && len(completed) == 0 && !rows.Next() {
+			completed, err = batch.flush()
+			break
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+	return completed[0], nil
+}
+
 func (m *structMapper[T]) mapColumns(columns []string) (map[string]func(any) any, map[string]bool, error) {
 	rt := reflect.TypeOf((*T)(nil)).Elem()
 	knownCols := make(map[string]bool, len(columns))
@@ -431,11 +818,15 @@ func (m *structMapper[T]) mapColumns(columns []string) (map[string]func(any) any
 		knownCols[col] = false
 	}
 	result := make(map[string]func(any) any)
-	err := buildFieldMapRecursive(m.fieldColumnNamers, rt, nil, result, knownCols)
+	err := buildFieldMapRecursive(m.fieldColumnNamers, rt, nil, "", result, knownCols, m.skipFields)
 	return result, knownCols, err
 }
 
-func buildFieldMapRecursive(namers []FieldColumnNamer, rt reflect.Type, parentIndex []int, result map[string]func(any) any, knownCols map[string]bool) (err error) {
+// buildFieldMapRecursive builds the field accessors (by column name) for a struct type - skip is the fields (by index key)
+// that are not mapped to columns (i.e. fields populated by struct sub-queries or grouping)
+//
+// prefix is the column name prefix for the fields (see columnPrefix)
+func buildFieldMapRecursive(namers []FieldColumnNamer, rt reflect.Type, parentIndex []int, prefix string, result map[string]func(any) any, knownCols map[string]bool, skip map[string]bool) (err error) {
 	for i := 0; err == nil && i < rt.NumField(); i++ {
 		f := rt.Field(i)
 		if !f.IsExported() {
@@ -443,8 +834,10 @@ func buildFieldMapRecursive(namers []FieldColumnNamer, rt reflect.Type, parentIn
 		}
 		index := append([]int{}, parentIndex...)
 		index = append(index, f.Index...)
-		if f.Type.Kind() == reflect.Struct && !isScannable(f.Type) {
-			err = buildFieldMapRecursive(namers, f.Type, index, result, knownCols)
+		if skip[indexKey(index)] {
+			continue
+		} else if f.Type.Kind() == reflect.Struct && !isScannable(f.Type) {
+			err = buildFieldMapRecursive(namers, f.Type, index, prefix+columnPrefix(namers, f), result, knownCols, skip)
 			continue
 		}
 		useColName := ""
@@ -457,6 +850,7 @@ func buildFieldMapRecursive(namers []FieldColumnNamer, rt reflect.Type, parentIn
 		if !named || useColName == "-" || useColName == "" {
 			continue
 		}
+		useColName = prefix + useColName
 		if _, ok := knownCols[useColName]; ok {
 			knownCols[useColName] = true
 		}
@@ -487,21 +881,31 @@ func isScannable(t reflect.Type) bool {
 
 func (m *structMapper[T]) checkDuplicateMappedColumns() error {
 	rt := reflect.TypeOf((*T)(nil)).Elem()
-	return walkStruct(m.fieldColumnNamers, rt, make(map[string]struct{}))
+	return walkStructFields(m.fieldColumnNamers, rt, nil, "", make(map[string]struct{}), m.skipFields)
 }
 
 func walkStruct(namers []FieldColumnNamer, rt reflect.Type, seen map[string]struct{}) error {
+	return walkStructFields(namers, rt, nil, "", seen, nil)
+}
+
+// walkStructFields checks for duplicate column mappings - skip is the fields (by index key) that are not mapped to columns
+// and prefix is the column name prefix for the fields (see columnPrefix)
+func walkStructFields(namers []FieldColumnNamer, rt reflect.Type, parentIndex []int, prefix string, seen map[string]struct{}, skip map[string]bool) error {
 	for i := 0; i < rt.NumField(); i++ {
 		f := rt.Field(i)
 		if !f.IsExported() {
 			continue
 		}
+		index := append(parentIndex[:len(parentIndex):len(parentIndex)], i)
+		if skip[indexKey(index)] {
+			continue
+		}
 		t := f.Type
 		for t.Kind() == reflect.Ptr {
 			t = t.Elem()
 		}
 		if t.Kind() == reflect.Struct && !isScannable(t) {
-			if err := walkStruct(namers, t, seen); err != nil {
+			if err := walkStructFields(namers, t, index, prefix+columnPrefix(namers, f), seen, skip); err != nil {
 				return err
 			}
 			continue
@@ -516,6 +920,7 @@ func walkStruct(namers []FieldColumnNamer, rt reflect.Type, seen map[string]stru
 		if !named || useColName == "-" || useColName == "" {
 			continue
 		}
+		useColName = prefix + useColName
 		if _, exists := seen[useColName]; exists {
 			return fmt.Errorf("duplicate column mapping %q", useColName)
 		}
@@ -532,8 +937,23 @@ var _ FieldColumnNamer = &defaultFieldColumnNamer{}
 
 func (d *defaultFieldColumnNamer) ColumnName(structType reflect.Type, fld reflect.StructField) (string, bool) {
 	tag, ok := fld.Tag.Lookup(d.tagName)
-	if !ok || tag == "-" || tag == "" {
+	if !ok {
+		return "", false
+	}
+	name := parseColumnTag(tag).name
+	if name == "-" || name == "" {
 		return "", false
 	}
-	return tag, true
+	return name, true
+}
+
+// columnPrefix returns the column name prefix for the descendant fields of a nested struct field - declared by the
+// prefix tag option (e.g. `sql:",prefix=billing_"`) using the tag name of the default field column namer
+func columnPrefix(namers []FieldColumnNamer, fld reflect.StructField) string {
+	for _, namer := range namers {
+		if d, ok := namer.(*defaultFieldColumnNamer); ok {
+			return parseColumnTag(fld.Tag.Get(d.tagName)).prefix
+		}
+	}
+	return ""
 }
@@ -10,6 +10,7 @@ import (
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 	"reflect"
+	"regexp"
 	"strings"
 	"testing"
 	"time"
@@ -129,50 +130,50 @@ func TestStructMapper_rowMapOptions(t *testing.T) {
 	require.NotNil(t, sm)
 	raw := sm.(*structMapper[testStruct])
 	et := &testErrorTranslator{}
-	query, postProcessors, limiter, translator, err := raw.rowMapOptions([]any{
+	opts, err := raw.rowMapOptions([]any{
 		Query("FROM table2"), AddClause("WHERE id = ?"),
 		&testPostProcessor[testStruct]{},
 		defaultLimiter,
 		et,
 	})
 	require.NoError(t, err)
-	assert.Equal(t, "SELECT foo,bar FROM table2 WHERE id = ?", query)
-	assert.Len(t, postProcessors, 2)
-	assert.NotNil(t, limiter)
-	require.NotNil(t, translator)
-	require.Equal(t, et, translator)
+	assert.Equal(t, "SELECT foo,bar FROM table2 WHERE id = ?", opts.query)
+	assert.Len(t, opts.postProcessors, 2)
+	assert.NotNil(t, opts.limiter)
+	require.NotNil(t, opts.errorTranslator)
+	require.Equal(t, et, opts.errorTranslator)
 
 	sm = MustNewStructMapper[testStruct](`foo,bar`,
 		Query("FROM table"),
 		et)
 	require.NotNil(t, sm)
 	raw = sm.(*structMapper[testStruct])
-	_, _, _, translator, err = raw.rowMapOptions(nil)
+	opts, err = raw.rowMapOptions(nil)
 	require.NoError(t, err)
-	require.Equal(t, et, translator)
+	require.Equal(t, et, opts.errorTranslator)
 }
 
 func TestStructMapper_rowMapOptions_Errors(t *testing.T) {
 	sm := MustNewStructMapper[testStruct](`foo,bar`)
 	require.NotNil(t, sm)
 	raw := sm.(*structMapper[testStruct])
-	_, _, _, _, err := raw.rowMapOptions([]any{
+	_, err := raw.rowMapOptions([]any{
 		AddClause("WHERE id = ?"),
 	})
 	require.Error(t, err)
 	assert.Contains(t, err.Error(), "add clause must have a query set")
 
-	_, _, _, _, err = raw.rowMapOptions([]any{})
+	_, err = raw.rowMapOptions([]any{})
 	require.Error(t, err)
 	assert.Contains(t, err.Error(), "no default query")
 
-	_, _, _, _, err = raw.rowMapOptions([]any{
+	_, err = raw.rowMapOptions([]any{
 		Query(" ,extra_col FROM table"),
 	})
 	require.Error(t, err)
 	assert.Contains(t, err.Error(), "cannot forge extra columns using Query")
 
-	_, _, _, _, err = raw.rowMapOptions([]any{
+	_, err = raw.rowMapOptions([]any{
 		"not a valid option",
 	})
 	require.Error(t, err)
@@ -207,6 +208,45 @@ func TestStructMapper_Rows(t *testing.T) {
 	assert.Equal(t, "bar value 2", rows[1].Bar)
 }
 
+func TestStructMapper_Rows_AddClauseWithArgs(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer func() {
+		_ = db.Close()
+	}()
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT foo,bar FROM table WHERE foo = ? AND bar = ?")).
+		WithArgs("a", "b").
+		WillReturnRows(sqlmock.NewRows([]string{"foo", "bar"}).AddRow("a", "b"))
+
+	sm, err := NewStructMapper[testStruct](`foo,bar`, Query("FROM table WHERE foo = ?"), UseTagName("db"))
+	require.NoError(t, err)
+	rows, err := sm.Rows(context.Background(), db, []any{"a"}, AddClauseWithArgs{Clause: "AND bar = ?", Args: []any{"b"}})
+	require.NoError(t, err)
+	require.NoError(t, mock.ExpectationsWereMet())
+	assert.Len(t, rows, 1)
+}
+
+func TestStructMapper_Rows_RowLimit(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer func() {
+		_ = db.Close()
+	}()
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT foo,bar FROM table LIMIT 1 OFFSET 2")).
+		WillReturnRows(sqlmock.NewRows([]string{"foo", "bar"}).AddRow("a", "b"))
+
+	sm, err := NewStructMapper[testStruct](`foo,bar`, Query("FROM table"), UseTagName("db"))
+	require.NoError(t, err)
+	rows, err := sm.Rows(context.Background(), db, nil, RowLimit{Limit: 1, Offset: 2})
+	require.NoError(t, err)
+	require.NoError(t, mock.ExpectationsWereMet())
+	assert.Len(t, rows, 1)
+
+	_, err = sm.Rows(context.Background(), db, nil, RowLimit{Limit: 1}, &Keyset{Sort: []string{"foo"}, Size: 1})
+	require.Error(t, err)
+	assert.Equal(t, "row limit cannot be used with keyset", err.Error())
+}
+
 func TestStructMapper_Rows_Repeated(t *testing.T) {
 	db, mock, err := sqlmock.New()
 	require.NoError(t, err)
@@ -717,6 +757,120 @@ func TestStructMapper_Iterator_WithLimiter(t *testing.T) {
 	require.Equal(t, []int{0}, indices)
 }
 
+func TestStructMapper_Iterator_Break(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer func() {
+		_ = db.Close()
+	}()
+	mock.ExpectQuery("").WillReturnRows(sqlmock.NewRows([]string{"foo", "bar"}).
+		AddRow("FOO value", "bar value").
+		AddRow("FOO value 2", "bar value 2"))
+	sm, err := NewStructMapper[testStruct](`foo,bar`,
+		Query("FROM table"),
+		UseTagName("db"),
+	)
+	require.NoError(t, err)
+	items := make([]testStruct, 0)
+	for _, item := range sm.Iterator(context.Background(), db, nil) {
+		items = append(items, item)
+		break
+	}
+	require.Len(t, items, 1)
+}
+
+func TestStructMapper_ErrIterator(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer func() {
+		_ = db.Close()
+	}()
+	mock.ExpectQuery("").WillReturnRows(sqlmock.NewRows([]string{"foo", "bar"}).
+		AddRow("FOO value", "bar value").
+		AddRow("FOO value 2", "bar value 2").
+		AddRow("FOO value 3", "bar value 3")).RowsWillBeClosed()
+	sm, err := NewStructMapper[testStruct](`foo,bar`,
+		Query("FROM table"),
+		UseTagName("db"),
+		&testPostProcessor[testStruct]{},
+	)
+	require.NoError(t, err)
+	items := make([]testStruct, 0)
+	for item, err := range sm.ErrIterator(context.Background(), db, nil, &testLimiter{limit: 2}) {
+		require.NoError(t, err)
+		items = append(items, item)
+	}
+	require.NoError(t, mock.ExpectationsWereMet())
+	require.Len(t, items, 2)
+	require.Equal(t, "foo value", items[0].Foo)
+	require.Equal(t, "foo value 2", items[1].Foo)
+
+	mock.ExpectQuery("").WillReturnRows(sqlmock.NewRows([]string{"foo", "bar"}).
+		AddRow("FOO value", "bar value").
+		AddRow("FOO value 2", "bar value 2")).RowsWillBeClosed()
+	items = make([]testStruct, 0)
+	for item, err := range sm.ErrIterator(context.Background(), db, nil) {
+		require.NoError(t, err)
+		items = append(items, item)
+		break
+	}
+	require.NoError(t, mock.ExpectationsWereMet())
+	require.Len(t, items, 1)
+}
+
+func TestStructMapper_ErrIterator_Errors(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer func() {
+		_ = db.Close()
+	}()
+	sm, err := NewStructMapper[testStruct](`foo,bar`,
+		Query("FROM table"),
+		UseTagName("db"),
+	)
+	require.NoError(t, err)
+	collect := func(options ...any) (items []testStruct, errs []error) {
+		for item, err := range sm.ErrIterator(context.Background(), db, nil, options...) {
+			if err != nil {
+				errs = append(errs, err)
+			} else {
+				items = append(items, item)
+			}
+		}
+		return
+	}
+
+	items, errs := collect("not a valid option")
+	require.Len(t, items, 0)
+	require.Len(t, errs, 1)
+	require.Equal(t, "unknown option type: string", errs[0].Error())
+
+	et := &testWrappingErrorTranslator{}
+	mock.ExpectQuery("").WillReturnError(errors.New("query error"))
+	items, errs = collect(et)
+	require.Len(t, items, 0)
+	require.Len(t, errs, 1)
+	require.Equal(t, "translated: query error", errs[0].Error())
+
+	mock.ExpectQuery("").WillReturnRows(sqlmock.NewRows([]string{"foo", "bar"}).
+		AddRow("FOO value", "bar value").
+		AddRow("FOO value 2", "bar value 2"))
+	items, errs = collect(et, &testErrorPostProcessor[testStruct]{})
+	require.Len(t, items, 0)
+	require.Len(t, errs, 1)
+	require.Equal(t, "translated: fooey", errs[0].Error())
+
+	mock.ExpectQuery("").WillReturnRows(sqlmock.NewRows([]string{"foo", "bar"}).
+		AddRow("FOO value", "bar value").
+		AddRow("FOO value 2", "bar value 2").
+		RowError(1, errors.New("row error")))
+	items, errs = collect(et)
+	require.Len(t, items, 1)
+	require.Len(t, errs, 1)
+	require.Equal(t, "translated: row error", errs[0].Error())
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
 func TestIsScannable(t *testing.T) {
 	type testStruct struct{}
 	testCases := []struct {
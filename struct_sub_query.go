@@ -0,0 +1,607 @@
+package columbus
+
+import (
+	"context"
+	"database/sql/driver"
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+)
+
+// StructSubQueryTag is the field tag used to declare a struct sub-query on a field of a struct read by StructMapper - e.g.
+//
+//	type Person struct {
+//		Id        int64     `sql:"id"`
+//		Addresses []Address `subquery:"query=SELECT id,person_id,street FROM addresses WHERE person_id = ?;args=Id"`
+//	}
+//
+// the tag value is a semicolon separated list of:
+//
+//   - query=<sql> - the (complete) query for the sub-query
+//   - args=<fields> - comma separated names of the fields (of the struct containing the tagged field) used as args
+//   - keys=<fields> - comma separated names of the fields (of the sub-query struct) that correspond to the args - if
+//     specified, the sub-query is a batch struct sub-query (see NewBatchStructSubQuery)
+//   - emptynil - the field is left nil (rather than an empty slice) when there are no sub-query rows
+//
+// struct sub-queries can also be declared on the fields of nested structs and the fields of sub-query structs
+const StructSubQueryTag = "subquery"
+
+// StructSubQuery is an option that can be passed to NewStructMapper and populates a field of the struct by running a sub-query
+// that reads structs - each sub-query row is read using the same field tags (or FieldColumnNamer) as the parent struct
+//
+// the field can be a slice (of structs or pointers to structs), a struct or a pointer to a struct - for a struct (or pointer)
+// field, the first sub-query row is used
+//
+// any struct sub-queries (see StructSubQueryTag) declared on the sub-query struct are also executed
+//
+// a struct sub-query is not executed if its property (i.e. the `json` tag name of the field) is excluded - see PropertyExcluder
+//
+// use NewStructSubQuery or NewBatchStructSubQuery to create a StructSubQuery
+type StructSubQuery interface {
+	// FieldName returns the name of the field that the StructSubQuery populates
+	FieldName() string
+}
+
+// NewStructSubQuery creates a new struct sub-query that populates the named field - the query is executed for each row
+//
+// argFields are the names of the fields (of the struct) used as the args for the query - where the query should
+// contain the same number of '?' arg markers
+func NewStructSubQuery(fieldName string, query string, argFields []string, emptyNil bool) StructSubQuery {
+	return &structSubQuery{
+		fieldName: fieldName,
+		query:     query,
+		argFields: argFields,
+		emptyNil:  emptyNil,
+	}
+}
+
+// NewBatchStructSubQuery creates a new batch struct sub-query that populates the named field - the query is executed once
+// for each batch of rows (see BatchSize)
+//
+// the query should contain a single '?' arg marker, which is expanded to the arg values of all rows in the batch (as with
+// NewBatchSubQuery) - keyFields are the names of the fields (of the sub-query struct) that correspond to argFields and are
+// used to assign the sub-query rows back to each row
+func NewBatchStructSubQuery(fieldName string, query string, argFields []string, keyFields []string, emptyNil bool) StructSubQuery {
+	return &structSubQuery{
+		fieldName: fieldName,
+		query:     query,
+		argFields: argFields,
+		keyFields: keyFields,
+		batch:     true,
+		emptyNil:  emptyNil,
+	}
+}
+
+type structSubQuery struct {
+	fieldName string
+	query     string
+	argFields []string
+	keyFields []string
+	batch     bool
+	emptyNil  bool
+}
+
+func (sq *structSubQuery) FieldName() string {
+	return sq.fieldName
+}
+
+// parseStructSubQueryTag parses a struct sub-query declared by tag (see StructSubQueryTag)
+func parseStructSubQueryTag(fieldName string, tag string) (*structSubQuery, error) {
+	result := &structSubQuery{fieldName: fieldName}
+	for _, part := range strings.Split(tag, ";") {
+		k, v, _ := strings.Cut(strings.TrimSpace(part), "=")
+		switch strings.TrimSpace(k) {
+		case "query":
+			result.query = strings.TrimSpace(v)
+		case "args":
+			result.argFields = splitFieldNames(v)
+		case "keys":
+			result.keyFields = splitFieldNames(v)
+			result.batch = true
+		case "emptynil":
+			result.emptyNil = true
+		case "":
+		default:
+			return nil, fmt.Errorf("field '%s' has invalid struct sub-query tag option '%s'", fieldName, k)
+		}
+	}
+	return result, nil
+}
+
+func splitFieldNames(s string) []string {
+	result := make([]string, 0)
+	for _, name := range strings.Split(s, ",") {
+		if name = strings.TrimSpace(name); name != "" {
+			result = append(result, name)
+		}
+	}
+	return result
+}
+
+// structSubQueryField is a struct sub-query resolved for a struct type
+type structSubQueryField struct {
+	*structSubQuery
+	// index is the index of the field
+	index []int
+	// property is the property name (i.e. `json` tag name) of the field and path is the property path of the struct containing the field
+	property string
+	path     []string
+	// fieldType is the type of the field
+	fieldType reflect.Type
+	// argIndexes is the indexes of the arg fields and keyIndexes is the indexes of the key fields (of the sub-query struct)
+	argIndexes [][]int
+	keyIndexes [][]int
+	// child reads the sub-query rows
+	child *structChildMapper
+}
+
+// structChildMapper reads structs from sub-query rows (and executes any struct sub-queries declared on the struct)
+type structChildMapper struct {
+	rt         reflect.Type
+	namers     []FieldColumnNamer
+	subQueries []*structSubQueryField
+	// skip is the fields (by index key) that are populated by struct sub-queries - and so not mapped to columns
+	skip       map[string]bool
+	mutex      sync.RWMutex
+	columnMaps map[string]map[string]func(any) any
+}
+
+// structSubQueryResolver resolves the struct sub-queries for struct types - child mappers are shared by type (so that
+// recursive structs can be resolved)
+type structSubQueryResolver struct {
+	namers   []FieldColumnNamer
+	children map[reflect.Type]*structChildMapper
+}
+
+// resolveStructSubQueries resolves the struct sub-queries for a struct type - i.e. those declared by tag and the
+// option struct sub-queries - returning the sub-queries and the index keys of the fields they populate
+func resolveStructSubQueries(namers []FieldColumnNamer, rt reflect.Type, options []*structSubQuery) ([]*structSubQueryField, map[string]bool, error) {
+	r := &structSubQueryResolver{namers: namers, children: map[reflect.Type]*structChildMapper{}}
+	return r.resolve(rt, options)
+}
+
+func (r *structSubQueryResolver) resolve(rt reflect.Type, options []*structSubQuery) ([]*structSubQueryField, map[string]bool, error) {
+	result := make([]*structSubQueryField, 0)
+	skip := make(map[string]bool)
+	add := func(sq *structSubQuery, f reflect.StructField, container reflect.Type, containerIndex []int, path []string) error {
+		index := append(append([]int{}, containerIndex...), f.Index[len(f.Index)-1])
+		if skip[indexKey(index)] {
+			return fmt.Errorf("field '%s' has multiple struct sub-queries", sq.fieldName)
+		}
+		sqf, err := r.resolveField(sq, f, container, containerIndex, index, path)
+		if err == nil {
+			skip[indexKey(index)] = true
+			result = append(result, sqf)
+		}
+		return err
+	}
+	var walk func(t reflect.Type, index []int, path []string) error
+	walk = func(t reflect.Type, index []int, path []string) error {
+		for i := 0; i < t.NumField(); i++ {
+			f := t.Field(i)
+			if !f.IsExported() {
+				continue
+			}
+			if tag, ok := f.Tag.Lookup(StructSubQueryTag); ok {
+				sq, err := parseStructSubQueryTag(f.Name, tag)
+				if err == nil {
+					err = add(sq, f, t, index, path)
+				}
+				if err != nil {
+					return err
+				}
+			} else if f.Type.Kind() == reflect.Struct && !isScannable(f.Type) {
+				fieldPath := path
+				if name, embedded, ok := encodeFieldName(f); !ok {
+					continue
+				} else if !embedded {
+					fieldPath = append(path[:len(path):len(path)], name)
+				}
+				if err := walk(f.Type, append(index[:len(index):len(index)], i), fieldPath); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	}
+	if err := walk(rt, nil, nil); err != nil {
+		return nil, nil, err
+	}
+	for _, sq := range options {
+		f, ok := rt.FieldByName(sq.fieldName)
+		if !ok || !f.IsExported() {
+			return nil, nil, fmt.Errorf("struct sub-query field '%s' does not exist", sq.fieldName)
+		}
+		containerIndex := f.Index[:len(f.Index)-1]
+		container := rt
+		if len(containerIndex) > 0 {
+			container = rt.FieldByIndex(containerIndex).Type
+		}
+		if err := add(sq, f, container, containerIndex, nil); err != nil {
+			return nil, nil, err
+		}
+	}
+	return result, skip, nil
+}
+
+func (r *structSubQueryResolver) resolveField(sq *structSubQuery, f reflect.StructField, container reflect.Type, containerIndex []int, index []int, path []string) (*structSubQueryField, error) {
+	elemType := f.Type
+	if elemType.Kind() == reflect.Slice {
+		elemType = elemType.Elem()
+	}
+	if elemType.Kind() == reflect.Ptr {
+		elemType = elemType.Elem()
+	}
+	if elemType.Kind() != reflect.Struct || isScannable(elemType) {
+		return nil, fmt.Errorf("struct sub-query field '%s' must be a struct, pointer to struct or slice of structs", sq.fieldName)
+	}
+	if strings.TrimSpace(sq.query) == "" {
+		return nil, fmt.Errorf("struct sub-query field '%s' has no query", sq.fieldName)
+	} else if sq.batch && len(sq.keyFields) != len(sq.argFields) {
+		return nil, fmt.Errorf("batch struct sub-query field '%s' key fields (%d) does not match arg fields (%d)", sq.fieldName, len(sq.keyFields), len(sq.argFields))
+	}
+	property, _, _ := encodeFieldName(f)
+	result := &structSubQueryField{
+		structSubQuery: sq,
+		index:          index,
+		property:       property,
+		path:           path,
+		fieldType:      f.Type,
+		argIndexes:     make([][]int, len(sq.argFields)),
+		keyIndexes:     make([][]int, len(sq.keyFields)),
+	}
+	var err error
+	for i, name := range sq.argFields {
+		var argIndex []int
+		if argIndex, err = fieldIndexByName(container, name); err != nil {
+			return nil, fmt.Errorf("struct sub-query field '%s' arg %w", sq.fieldName, err)
+		}
+		result.argIndexes[i] = append(append([]int{}, containerIndex...), argIndex...)
+	}
+	for i, name := range sq.keyFields {
+		if result.keyIndexes[i], err = fieldIndexByName(elemType, name); err != nil {
+			return nil, fmt.Errorf("struct sub-query field '%s' key %w", sq.fieldName, err)
+		}
+	}
+	result.child, err = r.childMapper(elemType)
+	return result, err
+}
+
+func (r *structSubQueryResolver) childMapper(rt reflect.Type) (*structChildMapper, error) {
+	if child, ok := r.children[rt]; ok {
+		return child, nil
+	}
+	child := &structChildMapper{
+		rt:         rt,
+		namers:     r.namers,
+		columnMaps: map[string]map[string]func(any) any{},
+	}
+	r.children[rt] = child
+	var err error
+	child.subQueries, child.skip, err = r.resolve(rt, nil)
+	return child, err
+}
+
+// fieldIndexByName returns the index of a named field - nested fields can be specified using dot notation (e.g. `Address.Id`)
+func fieldIndexByName(rt reflect.Type, name string) ([]int, error) {
+	var result []int
+	t := rt
+	for _, part := range strings.Split(name, ".") {
+		for t.Kind() == reflect.Ptr {
+			t = t.Elem()
+		}
+		if t.Kind() != reflect.Struct {
+			return nil, fmt.Errorf("field '%s' does not exist", name)
+		}
+		f, ok := t.FieldByName(part)
+		if !ok || !f.IsExported() {
+			return nil, fmt.Errorf("field '%s' does not exist", name)
+		}
+		result = append(result, f.Index...)
+		t = f.Type
+	}
+	return result, nil
+}
+
+func indexKey(index []int) string {
+	return fmt.Sprint(index)
+}
+
+// fieldValue returns the value of a field (for use as an arg or key) - pointers are dereferenced (a nil pointer is a nil value)
+// and driver.Valuer values are converted to their driver value
+func fieldValue(rv reflect.Value, index []int) (any, error) {
+	fv, err := rv.FieldByIndexErr(index)
+	if err != nil {
+		return nil, nil
+	}
+	for fv.Kind() == reflect.Ptr {
+		if fv.IsNil() {
+			return nil, nil
+		}
+		fv = fv.Elem()
+	}
+	v := fv.Interface()
+	if valuer, ok := v.(driver.Valuer); ok {
+		return valuer.Value()
+	}
+	return v, nil
+}
+
+func fieldValues(rv reflect.Value, indexes [][]int) ([]any, error) {
+	result := make([]any, len(indexes))
+	var err error
+	for i := 0; err == nil && i < len(indexes); i++ {
+		result[i], err = fieldValue(rv, indexes[i])
+	}
+	return result, err
+}
+
+// executeStructSubQueries executes the struct sub-queries for the items (struct values) - path is the property path of the items
+func executeStructSubQueries(ctx context.Context, sqli SqlInterface, items []reflect.Value, subQueries []*structSubQueryField, exclusions PropertyExclusions, path []string) (err error) {
+	if len(items) == 0 {
+		return nil
+	}
+	for _, sq := range subQueries {
+		sqPath := append(path[:len(path):len(path)], sq.path...)
+		if exclusions.Exclude(sq.property, sqPath) {
+			continue
+		}
+		if sq.batch {
+			err = sq.executeBatch(ctx, sqli, items, exclusions, sqPath)
+		} else {
+			for i := 0; err == nil && i < len(items); i++ {
+				err = sq.execute(ctx, sqli, items[i], exclusions, sqPath)
+			}
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (sq *structSubQueryField) execute(ctx context.Context, sqli SqlInterface, item reflect.Value, exclusions PropertyExclusions, path []string) error {
+	args, err := fieldValues(item, sq.argIndexes)
+	if err != nil {
+		return err
+	}
+	children, err := sq.child.rows(ctx, sqli, sq.query, args)
+	if err == nil {
+		if err = executeStructSubQueries(ctx, sqli, children, sq.child.subQueries, exclusions, append(path[:len(path):len(path)], sq.property)); err == nil {
+			sq.assign(item, children)
+		}
+	}
+	return err
+}
+
+func (sq *structSubQueryField) executeBatch(ctx context.Context, sqli SqlInterface, items []reflect.Value, exclusions PropertyExclusions, path []string) error {
+	itemKeys := make([]string, len(items))
+	seen := make(map[string]struct{}, len(items))
+	args := make([]any, 0, len(items)*len(sq.argIndexes))
+	for i, item := range items {
+		itemArgs, err := fieldValues(item, sq.argIndexes)
+		if err != nil {
+			return err
+		}
+		itemKeys[i] = batchKey(itemArgs)
+		if _, ok := seen[itemKeys[i]]; !ok {
+			seen[itemKeys[i]] = struct{}{}
+			args = append(args, itemArgs...)
+		}
+	}
+	children, err := sq.child.rows(ctx, sqli, expandBatchQuery(sq.query, len(sq.argIndexes), len(seen)), args)
+	if err != nil {
+		return err
+	}
+	if err = executeStructSubQueries(ctx, sqli, children, sq.child.subQueries, exclusions, append(path[:len(path):len(path)], sq.property)); err != nil {
+		return err
+	}
+	matches := make(map[string][]reflect.Value, len(seen))
+	for _, child := range children {
+		keyValues, err := fieldValues(child, sq.keyIndexes)
+		if err != nil {
+			return err
+		}
+		key := batchKey(keyValues)
+		matches[key] = append(matches[key], child)
+	}
+	for i, item := range items {
+		sq.assign(item, matches[itemKeys[i]])
+	}
+	return nil
+}
+
+// assign assigns the sub-query rows (struct values) to the field of the item
+func (sq *structSubQueryField) assign(item reflect.Value, children []reflect.Value) {
+	fv := item.FieldByIndex(sq.index)
+	switch sq.fieldType.Kind() {
+	case reflect.Slice:
+		if len(children) == 0 && sq.emptyNil {
+			fv.SetZero()
+			return
+		}
+		slice := reflect.MakeSlice(sq.fieldType, 0, len(children))
+		ptrs := sq.fieldType.Elem().Kind() == reflect.Ptr
+		for _, child := range children {
+			if ptrs {
+				slice = reflect.Append(slice, child.Addr())
+			} else {
+				slice = reflect.Append(slice, child)
+			}
+		}
+		fv.Set(slice)
+	case reflect.Ptr:
+		if len(children) == 0 {
+			fv.SetZero()
+		} else {
+			fv.Set(children[0].Addr())
+		}
+	default:
+		if len(children) == 0 {
+			fv.SetZero()
+		} else {
+			fv.Set(children[0])
+		}
+	}
+}
+
+// rows executes the query and reads the rows as structs (the returned values are addressable struct values)
+func (c *structChildMapper) rows(ctx context.Context, sqli SqlInterface, query string, args []any) ([]reflect.Value, error) {
+	qo := &queryOptions{query: query}
+	rows, err := qo.queryContext(ctx, sqli, args)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		_ = rows.Close()
+	}()
+	columns, err := rows.Columns()
+	if err != nil {
+		return nil, err
+	}
+	columnMap, err := c.columnMap(columns)
+	if err != nil {
+		return nil, err
+	}
+	result := make([]reflect.Value, 0)
+	ptrs := make([]any, len(columns))
+	for rows.Next() {
+		item := reflect.New(c.rt)
+		obj := item.Interface()
+		for i, col := range columns {
+			if acc, ok := columnMap[col]; ok {
+				ptrs[i] = acc(obj)
+			} else {
+				var discard any
+				ptrs[i] = &discard
+			}
+		}
+		if err = rows.Scan(ptrs...); err != nil {
+			return nil, err
+		}
+		result = append(result, item.Elem())
+	}
+	return result, rows.Err()
+}
+
+// columnMap returns the field accessors (by column name) for the columns - cached by column list
+func (c *structChildMapper) columnMap(columns []string) (map[string]func(any) any, error) {
+	key := strings.Join(columns, "\x00")
+	c.mutex.RLock()
+	result, ok := c.columnMaps[key]
+	c.mutex.RUnlock()
+	if ok {
+		return result, nil
+	}
+	knownCols := make(map[string]bool, len(columns))
+	for _, col := range columns {
+		knownCols[col] = false
+	}
+	result = make(map[string]func(any) any)
+	if err := buildFieldMapRecursive(c.namers, c.rt, nil, "", result, knownCols, c.skip); err != nil {
+		return nil, err
+	}
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.columnMaps[key] = result
+	return result, nil
+}
+
+// completeRows executes the struct sub-queries and then the struct post processors for the items
+func (o *structMapOptions[T]) completeRows(ctx context.Context, sqli SqlInterface, items []*T) (err error) {
+	if len(o.subQueries) > 0 {
+		values := make([]reflect.Value, len(items))
+		for i, item := range items {
+			values[i] = reflect.ValueOf(item).Elem()
+		}
+		if err = executeStructSubQueries(ctx, sqli, values, o.subQueries, o.exclusions, nil); err != nil {
+			return err
+		}
+	}
+	for _, item := range items {
+		for _, pp := range o.postProcessors {
+			if err = pp.PostProcess(ctx, unwrapSqlInterface(sqli), item); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (o *structMapOptions[T]) newBatch(ctx context.Context, sqli SqlInterface) *structRowBatch[T] {
+	size := 1
+	for _, sq := range o.subQueries {
+		if sq.batch {
+			size = o.batchSize
+			break
+		}
+	}
+	return &structRowBatch[T]{
+		ctx:  ctx,
+		sqli: sqli,
+		opts: o,
+		size: size,
+	}
+}
+
+// structRowBatch collects read structs so that batch struct sub-queries can be executed once for many rows
+//
+// when there are no batch struct sub-queries, the batch size is 1 (i.e. each row is completed as it is added)
+type structRowBatch[T any] struct {
+	ctx   context.Context
+	sqli  SqlInterface
+	opts  *structMapOptions[T]
+	size  int
+	items []*T
+	// grouper is set when grouping (see structMapper.readRow)
+	grouper *rowGrouper
+}
+
+// add adds a read struct to the batch - returning the completed structs if the batch is full
+func (b *structRowBatch[T]) add(item *T) ([]*T, error) {
+	b.items = append(b.items, item)
+	if b.size > 0 && len(b.items) >= b.size {
+		return b.complete()
+	}
+	return nil, nil
+}
+
+// limitReached checks whether the Limiter is reached for the database row count - when grouping, the Limiter applies to
+// the grouped parents instead (see rowGrouper)
+func (b *structRowBatch[T]) limitReached(rowCount int) bool {
+	if b.grouper != nil {
+		return b.grouper.limited
+	}
+	return b.opts.limiter.LimitReached(rowCount)
+}
+
+// flush completes and returns any structs remaining in the batch (including any remaining grouped parents)
+func (b *structRowBatch[T]) flush() (completed []*T, err error) {
+	if b.grouper != nil {
+		for _, parent := range b.grouper.flush() {
+			var added []*T
+			if added, err = b.add(parent.(*T)); err != nil {
+				return nil, err
+			}
+			completed = append(completed, added...)
+		}
+	}
+	var added []*T
+	if added, err = b.complete(); err != nil {
+		return nil, err
+	}
+	return append(completed, added...), nil
+}
+
+// complete completes the structs in the batch
+func (b *structRowBatch[T]) complete() (completed []*T, err error) {
+	if len(b.items) > 0 {
+		completed = b.items
+		b.items = nil
+		if err = b.opts.completeRows(b.ctx, b.sqli, completed); err != nil {
+			completed = nil
+		}
+	}
+	return completed, err
+}
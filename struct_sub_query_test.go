@@ -0,0 +1,300 @@
+package columbus
+
+import (
+	"bytes"
+	"encoding/json"
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+type testSubQueryAddress struct {
+	Id       int64  `sql:"id" json:"id"`
+	PersonId int64  `sql:"person_id" json:"-"`
+	City     string `sql:"city" json:"city"`
+}
+
+type testSubQueryPerson struct {
+	Id        int64                 `sql:"id" json:"id"`
+	Name      string                `sql:"name" json:"name"`
+	Addresses []testSubQueryAddress `json:"addresses" subquery:"query=SELECT id,person_id,city FROM addresses WHERE person_id IN (?);args=Id;keys=PersonId"`
+}
+
+func TestStructSubQuery_Tag_Batch(t *testing.T) {
+	sm, err := NewStructMapper[testSubQueryPerson]("id,name", Query("FROM people"))
+	require.NoError(t, err)
+
+	db, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+	require.NoError(t, err)
+	defer func() {
+		_ = db.Close()
+	}()
+	mock.ExpectQuery("SELECT id,name FROM people").WillReturnRows(sqlmock.NewRows([]string{"id", "name"}).
+		AddRow(int64(1), "Bilbo").AddRow(int64(2), "Frodo").AddRow(int64(3), "Sam"))
+	mock.ExpectQuery("SELECT id,person_id,city FROM addresses WHERE person_id IN (?,?,?)").WithArgs(int64(1), int64(2), int64(3)).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "person_id", "city"}).
+			AddRow(int64(10), int64(1), "Hobbiton").AddRow(int64(11), int64(3), "Bywater").AddRow(int64(12), int64(1), "Rivendell"))
+
+	rows, err := sm.Rows(ctx, db, nil)
+	require.NoError(t, err)
+	require.NoError(t, mock.ExpectationsWereMet())
+	require.Len(t, rows, 3)
+	require.Len(t, rows[0].Addresses, 2)
+	assert.Equal(t, "Hobbiton", rows[0].Addresses[0].City)
+	assert.Equal(t, "Rivendell", rows[0].Addresses[1].City)
+	assert.NotNil(t, rows[1].Addresses)
+	assert.Len(t, rows[1].Addresses, 0)
+	require.Len(t, rows[2].Addresses, 1)
+	assert.Equal(t, "Bywater", rows[2].Addresses[0].City)
+}
+
+func TestStructSubQuery_Tag_BatchSize(t *testing.T) {
+	sm, err := NewStructMapper[testSubQueryPerson]("id,name", Query("FROM people"), BatchSize(2))
+	require.NoError(t, err)
+
+	db, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+	require.NoError(t, err)
+	defer func() {
+		_ = db.Close()
+	}()
+	mock.ExpectQuery("SELECT id,name FROM people").WillReturnRows(sqlmock.NewRows([]string{"id", "name"}).
+		AddRow(int64(1), "Bilbo").AddRow(int64(2), "Frodo").AddRow(int64(3), "Sam"))
+	mock.ExpectQuery("SELECT id,person_id,city FROM addresses WHERE person_id IN (?,?)").WithArgs(int64(1), int64(2)).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "person_id", "city"}).AddRow(int64(10), int64(1), "Hobbiton"))
+	mock.ExpectQuery("SELECT id,person_id,city FROM addresses WHERE person_id IN (?)").WithArgs(int64(3)).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "person_id", "city"}).AddRow(int64(11), int64(3), "Bywater"))
+
+	counts := make([]int, 0)
+	err = sm.Iterate(ctx, db, nil, func(row testSubQueryPerson) (bool, error) {
+		counts = append(counts, len(row.Addresses))
+		return true, nil
+	})
+	require.NoError(t, err)
+	require.NoError(t, mock.ExpectationsWereMet())
+	assert.Equal(t, []int{1, 0, 1}, counts)
+}
+
+func TestStructSubQuery_Excluded(t *testing.T) {
+	sm, err := NewStructMapper[testSubQueryPerson]("id,name", Query("FROM people"))
+	require.NoError(t, err)
+
+	db, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+	require.NoError(t, err)
+	defer func() {
+		_ = db.Close()
+	}()
+	mock.ExpectQuery("SELECT id,name FROM people").WillReturnRows(sqlmock.NewRows([]string{"id", "name"}).AddRow(int64(1), "Bilbo"))
+
+	rows, err := sm.Rows(ctx, db, nil, ConditionalExclude(func(property string, path []string) bool {
+		return property == "addresses"
+	}))
+	require.NoError(t, err)
+	require.NoError(t, mock.ExpectationsWereMet())
+	require.Len(t, rows, 1)
+	assert.Nil(t, rows[0].Addresses)
+}
+
+func TestStructSubQuery_Option(t *testing.T) {
+	type person struct {
+		Id      int64                `sql:"id"`
+		Name    string               `sql:"name"`
+		Address *testSubQueryAddress `json:"address"`
+		Home    testSubQueryAddress  `json:"home"`
+	}
+	sm, err := NewStructMapper[person]("id,name", Query("FROM people"),
+		NewStructSubQuery("Address", "SELECT id,city FROM addresses WHERE person_id = ?", []string{"Id"}, false),
+		NewStructSubQuery("Home", "SELECT id,city FROM homes WHERE person_id = ? AND name = ?", []string{"Id", "Name"}, false),
+	)
+	require.NoError(t, err)
+
+	db, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+	require.NoError(t, err)
+	defer func() {
+		_ = db.Close()
+	}()
+	mock.ExpectQuery("SELECT id,name FROM people").WillReturnRows(sqlmock.NewRows([]string{"id", "name"}).
+		AddRow(int64(1), "Bilbo").AddRow(int64(2), "Frodo"))
+	mock.ExpectQuery("SELECT id,city FROM addresses WHERE person_id = ?").WithArgs(int64(1)).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "city"}).AddRow(int64(10), "Hobbiton"))
+	mock.ExpectQuery("SELECT id,city FROM homes WHERE person_id = ? AND name = ?").WithArgs(int64(1), "Bilbo").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "city"}).AddRow(int64(20), "Bag End"))
+	mock.ExpectQuery("SELECT id,city FROM addresses WHERE person_id = ?").WithArgs(int64(2)).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "city"}))
+	mock.ExpectQuery("SELECT id,city FROM homes WHERE person_id = ? AND name = ?").WithArgs(int64(2), "Frodo").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "city"}))
+
+	rows, err := sm.Rows(ctx, db, nil)
+	require.NoError(t, err)
+	require.NoError(t, mock.ExpectationsWereMet())
+	require.Len(t, rows, 2)
+	require.NotNil(t, rows[0].Address)
+	assert.Equal(t, "Hobbiton", rows[0].Address.City)
+	assert.Equal(t, "Bag End", rows[0].Home.City)
+	assert.Nil(t, rows[1].Address)
+	assert.Equal(t, testSubQueryAddress{}, rows[1].Home)
+}
+
+func TestStructSubQuery_EmptyNil(t *testing.T) {
+	type person struct {
+		Id        int64                  `sql:"id"`
+		Addresses []*testSubQueryAddress `subquery:"query=SELECT id,city FROM addresses WHERE person_id = ?;args=Id;emptynil"`
+	}
+	sm, err := NewStructMapper[person]("id", Query("FROM people"))
+	require.NoError(t, err)
+
+	db, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+	require.NoError(t, err)
+	defer func() {
+		_ = db.Close()
+	}()
+	mock.ExpectQuery("SELECT id FROM people").WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(int64(1)))
+	mock.ExpectQuery("SELECT id,city FROM addresses WHERE person_id = ?").WithArgs(int64(1)).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "city"}))
+	mock.ExpectQuery("SELECT id FROM people").WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(int64(1)))
+	mock.ExpectQuery("SELECT id,city FROM addresses WHERE person_id = ?").WithArgs(int64(1)).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "city"}).AddRow(int64(10), "Hobbiton"))
+
+	row, err := sm.FirstRow(ctx, db, nil)
+	require.NoError(t, err)
+	require.NotNil(t, row)
+	assert.Nil(t, row.Addresses)
+	row, err = sm.FirstRow(ctx, db, nil)
+	require.NoError(t, err)
+	require.Len(t, row.Addresses, 1)
+	assert.Equal(t, "Hobbiton", row.Addresses[0].City)
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+type testSubQueryOrderLine struct {
+	OrderId int64  `sql:"order_id" json:"-"`
+	Product string `sql:"product" json:"product"`
+}
+
+type testSubQueryOrder struct {
+	Id       int64                   `sql:"id" json:"id"`
+	PersonId int64                   `sql:"person_id" json:"-"`
+	Lines    []testSubQueryOrderLine `json:"lines" subquery:"query=SELECT order_id,product FROM lines WHERE order_id IN (?);args=Id;keys=OrderId"`
+}
+
+func TestStructSubQuery_Nested(t *testing.T) {
+	type person struct {
+		Id     int64               `sql:"id" json:"id"`
+		Orders []testSubQueryOrder `json:"orders" subquery:"query=SELECT id,person_id FROM orders WHERE person_id IN (?);args=Id;keys=PersonId"`
+	}
+	sm, err := NewStructMapper[person]("id", Query("FROM people"))
+	require.NoError(t, err)
+
+	db, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+	require.NoError(t, err)
+	defer func() {
+		_ = db.Close()
+	}()
+	newRows := func() {
+		mock.ExpectQuery("SELECT id FROM people").WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(int64(1)).AddRow(int64(2)))
+		mock.ExpectQuery("SELECT id,person_id FROM orders WHERE person_id IN (?,?)").WithArgs(int64(1), int64(2)).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "person_id"}).AddRow(int64(100), int64(1)).AddRow(int64(101), int64(2)))
+	}
+	newRows()
+	mock.ExpectQuery("SELECT order_id,product FROM lines WHERE order_id IN (?,?)").WithArgs(int64(100), int64(101)).
+		WillReturnRows(sqlmock.NewRows([]string{"order_id", "product"}).AddRow(int64(100), "Ring").AddRow(int64(101), "Sword"))
+
+	w := &bytes.Buffer{}
+	err = sm.WriteRows(ctx, w, db, nil)
+	require.NoError(t, err)
+	require.NoError(t, mock.ExpectationsWereMet())
+	var written []map[string]any
+	require.NoError(t, json.Unmarshal(w.Bytes(), &written))
+	assert.Equal(t, []map[string]any{
+		{"id": float64(1), "orders": []any{map[string]any{"id": float64(100), "lines": []any{map[string]any{"product": "Ring"}}}}},
+		{"id": float64(2), "orders": []any{map[string]any{"id": float64(101), "lines": []any{map[string]any{"product": "Sword"}}}}},
+	}, written)
+
+	// nested sub-query excluded by path...
+	newRows()
+	rows, err := sm.Rows(ctx, db, nil, func(property string, path []string) bool {
+		return property == "lines" && len(path) == 1 && path[0] == "orders"
+	})
+	require.NoError(t, err)
+	require.NoError(t, mock.ExpectationsWereMet())
+	require.Len(t, rows, 2)
+	require.Len(t, rows[0].Orders, 1)
+	assert.Nil(t, rows[0].Orders[0].Lines)
+}
+
+func TestStructSubQuery_Errors(t *testing.T) {
+	type badType struct {
+		Id    int64    `sql:"id"`
+		Names []string `subquery:"query=SELECT name FROM names WHERE id = ?;args=Id"`
+	}
+	_, err := NewStructMapper[badType]("id")
+	require.Error(t, err)
+	assert.Equal(t, "struct sub-query field 'Names' must be a struct, pointer to struct or slice of structs", err.Error())
+
+	type missingArg struct {
+		Id        int64                 `sql:"id"`
+		Addresses []testSubQueryAddress `subquery:"query=SELECT id FROM addresses WHERE person_id = ?;args=PersonId"`
+	}
+	_, err = NewStructMapper[missingArg]("id")
+	require.Error(t, err)
+	assert.Equal(t, "struct sub-query field 'Addresses' arg field 'PersonId' does not exist", err.Error())
+
+	type missingKey struct {
+		Id        int64                 `sql:"id"`
+		Addresses []testSubQueryAddress `subquery:"query=SELECT id FROM addresses WHERE person_id IN (?);args=Id;keys=Unknown"`
+	}
+	_, err = NewStructMapper[missingKey]("id")
+	require.Error(t, err)
+	assert.Equal(t, "struct sub-query field 'Addresses' key field 'Unknown' does not exist", err.Error())
+
+	type keyMismatch struct {
+		Id        int64                 `sql:"id"`
+		Addresses []testSubQueryAddress `subquery:"query=SELECT id FROM addresses WHERE person_id IN (?);args=Id;keys=PersonId,Id"`
+	}
+	_, err = NewStructMapper[keyMismatch]("id")
+	require.Error(t, err)
+	assert.Equal(t, "batch struct sub-query field 'Addresses' key fields (2) does not match arg fields (1)", err.Error())
+
+	type noQuery struct {
+		Id        int64                 `sql:"id"`
+		Addresses []testSubQueryAddress `subquery:"args=Id"`
+	}
+	_, err = NewStructMapper[noQuery]("id")
+	require.Error(t, err)
+	assert.Equal(t, "struct sub-query field 'Addresses' has no query", err.Error())
+
+	type badOption struct {
+		Id        int64                 `sql:"id"`
+		Addresses []testSubQueryAddress `subquery:"query=SELECT id FROM addresses;unknown"`
+	}
+	_, err = NewStructMapper[badOption]("id")
+	require.Error(t, err)
+	assert.Equal(t, "field 'Addresses' has invalid struct sub-query tag option 'unknown'", err.Error())
+
+	_, err = NewStructMapper[testSubQueryPerson]("id", NewStructSubQuery("Unknown", "SELECT 1", nil, false))
+	require.Error(t, err)
+	assert.Equal(t, "struct sub-query field 'Unknown' does not exist", err.Error())
+
+	_, err = NewStructMapper[testSubQueryPerson]("id", NewStructSubQuery("Addresses", "SELECT 1", nil, false))
+	require.Error(t, err)
+	assert.Equal(t, "field 'Addresses' has multiple struct sub-queries", err.Error())
+}
+
+func TestStructSubQuery_QueryError(t *testing.T) {
+	sm, err := NewStructMapper[testSubQueryPerson]("id,name", Query("FROM people"))
+	require.NoError(t, err)
+
+	db, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+	require.NoError(t, err)
+	defer func() {
+		_ = db.Close()
+	}()
+	mock.ExpectQuery("SELECT id,name FROM people").WillReturnRows(sqlmock.NewRows([]string{"id", "name"}).AddRow(int64(1), "Bilbo"))
+	mock.ExpectQuery("SELECT id,person_id,city FROM addresses WHERE person_id IN (?)").WithArgs(int64(1)).
+		WillReturnError(assert.AnError)
+
+	rows, err := sm.Rows(ctx, db, nil)
+	require.Error(t, err)
+	assert.Nil(t, rows)
+	require.NoError(t, mock.ExpectationsWereMet())
+}
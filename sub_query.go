@@ -26,27 +26,36 @@ type SubQuery interface {
 type internalSubQuery interface {
 	SubQuery
 	getQuery() string
+	getArgProperties() []string
 }
 
 // NewSubQuery creates a new sub-query that creates an array property in the mapped row
-func NewSubQuery(propertyName string, query string, argColumns []string, mappings Mappings, emptyNil bool) SubQuery {
+//
+// options are any options used when reading the sub-query rows - e.g. AddClause, AddClauseWithArgs or Sort (or NamedArgs
+// to bind named placeholders to the row properties - see NamedArgs)
+func NewSubQuery(propertyName string, query string, argColumns []string, mappings Mappings, emptyNil bool, options ...any) SubQuery {
 	return &sliceSubQuery{subQuery{
 		propertyName: propertyName,
 		query:        query,
 		argColumns:   argColumns,
 		mappings:     mappings,
 		emptyNil:     emptyNil,
+		options:      options,
 	}}
 }
 
 // NewObjectSubQuery creates a new sub-query that creates an object property in the mapped row
-func NewObjectSubQuery(propertyName string, query string, argColumns []string, mappings Mappings, emptyNil bool, errNoRow bool) SubQuery {
+//
+// options are any options used when reading the sub-query row - e.g. AddClause, AddClauseWithArgs or Sort (or NamedArgs
+// to bind named placeholders to the row properties - see NamedArgs)
+func NewObjectSubQuery(propertyName string, query string, argColumns []string, mappings Mappings, emptyNil bool, errNoRow bool, options ...any) SubQuery {
 	if errNoRow {
 		return &exactObjectSubQuery{subQuery{
 			propertyName: propertyName,
 			query:        query,
 			argColumns:   argColumns,
 			mappings:     mappings,
+			options:      options,
 		}}
 	}
 	return &objectSubQuery{subQuery{
@@ -55,18 +64,23 @@ func NewObjectSubQuery(propertyName string, query string, argColumns []string, m
 		argColumns:   argColumns,
 		mappings:     mappings,
 		emptyNil:     emptyNil,
+		options:      options,
 	}}
 }
 
 // NewMergeSubQuery creates a new sub-query that reads an object for the mapped row and merges the properties from
 // that object into the mapped row
-func NewMergeSubQuery(query string, argColumns []string, mappings Mappings, noOverwrite bool) SubQuery {
+//
+// options are any options used when reading the sub-query row - e.g. AddClause, AddClauseWithArgs or Sort (or NamedArgs
+// to bind named placeholders to the row properties - see NamedArgs)
+func NewMergeSubQuery(query string, argColumns []string, mappings Mappings, noOverwrite bool, options ...any) SubQuery {
 	return &mergeSubQuery{
 		noOverwrite: noOverwrite,
 		subQuery: subQuery{
 			query:      query,
 			argColumns: argColumns,
 			mappings:   mappings,
+			options:    options,
 		}}
 }
 
@@ -76,6 +90,8 @@ type subQuery struct {
 	// propertyName is the property name to use in the row object
 	propertyName string
 	// query is the SQL query to use - it should contain the same number of '?' arg markers as the length of argColumns
+	// (the arg markers are rewritten according to the Dialect of the parent Mapper) - or, if there are no argColumns and
+	// a NamedArgs option is passed, named placeholders (e.g. `:id`) that are bound to the parent row properties by name
 	query string
 	// argColumns is the columns to use as args for the sub-query
 	argColumns []string
@@ -83,12 +99,25 @@ type subQuery struct {
 	emptyNil bool
 	// mappings is any column mappings used by the sub-query
 	mappings Mappings
+	// options is any options used when reading the sub-query rows
+	options []any
 }
 
 func (sq *subQuery) getQuery() string {
 	return sq.query
 }
 
+// getArgProperties returns the row properties used as args - the argColumns or, if bound using NamedArgs, the names of the
+// named placeholders in the query
+func (sq *subQuery) getArgProperties() []string {
+	if len(sq.argColumns) == 0 {
+		if _, ok := sq.namedArgs(); ok {
+			return placeholderNames(sq.query)
+		}
+	}
+	return sq.argColumns
+}
+
 func (sq *subQuery) ProvidesProperty() string {
 	return sq.propertyName
 }
@@ -105,7 +134,7 @@ func (sq *sliceSubQuery) Execute(ctx context.Context, sqli SqlInterface, row map
 	if err != nil {
 		return err
 	}
-	if rows, err := rm.Rows(ctx, sqli, args, exclusions); err != nil {
+	if rows, err := rm.Rows(ctx, sqli, args, sq.rowOptions(exclusions)...); err != nil {
 		return err
 	} else if sq.emptyNil && (rows == nil || len(rows) == 0) {
 		row[sq.propertyName] = nil
@@ -127,7 +156,7 @@ func (sq *objectSubQuery) Execute(ctx context.Context, sqli SqlInterface, row ma
 	if err != nil {
 		return err
 	}
-	if obj, err := rm.FirstRow(ctx, sqli, args, exclusions); err != nil {
+	if obj, err := rm.FirstRow(ctx, sqli, args, sq.rowOptions(exclusions)...); err != nil {
 		return err
 	} else if sq.emptyNil && (obj == nil || len(obj) == 0) {
 		row[sq.propertyName] = nil
@@ -149,7 +178,7 @@ func (sq *exactObjectSubQuery) Execute(ctx context.Context, sqli SqlInterface, r
 	if err != nil {
 		return err
 	}
-	if obj, err := rm.ExactlyOneRow(ctx, sqli, args, exclusions); err != nil {
+	if obj, err := rm.ExactlyOneRow(ctx, sqli, args, sq.rowOptions(exclusions)...); err != nil {
 		return err
 	} else {
 		row[sq.propertyName] = obj
@@ -170,7 +199,7 @@ func (sq *mergeSubQuery) Execute(ctx context.Context, sqli SqlInterface, row map
 	if err != nil {
 		return err
 	}
-	if obj, err := rm.FirstRow(ctx, sqli, args, exclusions); err != nil {
+	if obj, err := rm.FirstRow(ctx, sqli, args, sq.rowOptions(exclusions)...); err != nil {
 		return err
 	} else if sq.noOverwrite {
 		for k, v := range obj {
@@ -186,7 +215,63 @@ func (sq *mergeSubQuery) Execute(ctx context.Context, sqli SqlInterface, row map
 	return nil
 }
 
+// rowOptions returns the options for reading the sub-query rows (any NamedArgs option is not a row reading option)
+func (sq *subQuery) rowOptions(exclusions PropertyExclusions) []any {
+	result := make([]any, 0, len(sq.options)+1)
+	for _, o := range sq.options {
+		if _, ok := o.(NamedArgs); !ok {
+			result = append(result, o)
+		}
+	}
+	return append(result, exclusions)
+}
+
+// subQueryExclusions returns the exclusions passed to sub-queries - for the rows of a sub-query (i.e. a nested sub-query),
+// the sub-query paths are prefixed with the sub path (so that the exclusions see the full property path)
+func (o *mapOptions) subQueryExclusions() PropertyExclusions {
+	if len(o.subPath) == 0 {
+		return o.exclusions
+	}
+	return PropertyExclusions{&subPathExcluder{path: o.subPath, exclusions: o.exclusions}}
+}
+
+// subPathExcluder prefixes the paths of nested sub-query properties with the path of the parent sub-query
+type subPathExcluder struct {
+	path       []string
+	exclusions PropertyExclusions
+}
+
+var _ PropertyExcluder = (*subPathExcluder)(nil)
+
+func (e *subPathExcluder) Exclude(property string, path []string) bool {
+	return e.exclusions.Exclude(property, append(e.path[:len(e.path):len(e.path)], path...))
+}
+
+// getArgs returns the args for the sub-query from the row
+//
+// if the sub-query has no argColumns and NamedArgs is passed as a sub-query option, named placeholders in the query
+// (e.g. `:id`) are bound to the row properties by name
 func (sq *subQuery) getArgs(row map[string]any) ([]any, error) {
+	if len(sq.argColumns) == 0 {
+		if na, ok := sq.namedArgs(); ok {
+			return []any{rowNamedValues(row, na)}, nil
+		}
+		return []any{}, nil
+	}
+	return sq.argColumnValues(row)
+}
+
+// namedArgs returns the NamedArgs sub-query option (if any)
+func (sq *subQuery) namedArgs() (NamedArgs, bool) {
+	for _, o := range sq.options {
+		if na, ok := o.(NamedArgs); ok {
+			return na, true
+		}
+	}
+	return nil, false
+}
+
+func (sq *subQuery) argColumnValues(row map[string]any) ([]any, error) {
 	result := make([]any, 0, len(sq.argColumns))
 	for _, arg := range sq.argColumns {
 		if v, ok := row[arg]; ok {
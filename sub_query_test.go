@@ -5,6 +5,7 @@ import (
 	"errors"
 	"github.com/DATA-DOG/go-sqlmock"
 	"github.com/stretchr/testify/require"
+	"regexp"
 	"testing"
 )
 
@@ -30,6 +31,30 @@ func TestNewSubQuery_Execute(t *testing.T) {
 	require.NoError(t, mock.ExpectationsWereMet())
 }
 
+func TestNewSubQuery_Execute_WithOptions(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer func() {
+		_ = db.Close()
+	}()
+
+	sq := NewSubQuery("test",
+		`SELECT * FROM test_table WHERE id = ?`,
+		[]string{"parent_id"},
+		nil, false,
+		AddClauseWithArgs{Clause: "AND status = ?", Args: []any{"active"}})
+	row := map[string]any{
+		"parent_id": int64(16),
+	}
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT * FROM test_table WHERE id = ? AND status = ?")).
+		WithArgs(int64(16), "active").
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow("name"))
+	err = sq.Execute(ctx, db, row, nil)
+	require.NoError(t, err)
+	require.Equal(t, 1, len(row["test"].([]map[string]any)))
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
 func TestNewSubQuery_Execute_Twice(t *testing.T) {
 	db, mock, err := sqlmock.New()
 	require.NoError(t, err)
@@ -0,0 +1,466 @@
+package columbus
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"github.com/shopspring/decimal"
+	"io"
+	"math"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// XLSXEncoding is a RowEncoding that writes rows as an XLSX (Office Open XML) spreadsheet - with a header row of property names
+//
+// values are written as typed cells - numbers (ints, floats and decimal.Decimal) as numeric cells, time.Time as date cells
+// (formatted with the TimeFormat), bools as boolean cells and everything else as text
+//
+// as with CSVEncoding, nested objects are flattened into dotted header names (e.g. `address.city`) and arrays are written as JSON
+//
+// rows are streamed into the (compressed) worksheet as they are encoded - the remaining parts of the workbook are written
+// once all rows have been encoded
+type XLSXEncoding struct {
+	// SheetName is the name of the worksheet that rows are written to (if empty, "Sheet1" is used)
+	SheetName string
+	// SubQuerySheets indicates that array of object properties (e.g. slice sub-queries) are written to their own worksheet (named
+	// by the property name) rather than as JSON
+	//
+	// the first column of each sub-query worksheet, `_row`, is the row number (in the rows worksheet) of the row to which the item belongs
+	//
+	// the worksheets are determined from the nested structure (see EncodeInfo.Nested) - regardless of the first row values - other
+	// array of object values (e.g. JSON columns) are only written to their own worksheet if the first row has such a value
+	//
+	// the columns of a sub-query worksheet are the nested properties (if known) - otherwise they are derived, in name order, from
+	// the first item
+	//
+	// note: sub-query worksheets are held in memory until all rows have been encoded
+	SubQuerySheets bool
+	// TimeFormat is the number format for time.Time cells (if empty, "yyyy-mm-dd hh:mm:ss" is used)
+	TimeFormat string
+}
+
+var _ RowEncoding = (*XLSXEncoding)(nil)
+
+// XLSX is the RowEncoding that writes rows as an XLSX spreadsheet (see XLSXEncoding)
+var XLSX RowEncoding = &XLSXEncoding{}
+
+func (e *XLSXEncoding) ContentType() string {
+	return "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet"
+}
+
+func (e *XLSXEncoding) NewEncoder(w io.Writer) RowEncoder {
+	return &xlsxEncoder{
+		encoding: e,
+		zw:       zip.NewWriter(w),
+	}
+}
+
+const (
+	xlsxRowColumn = "_row"
+	// xlsxStyleTime and xlsxStyleHeader are the cellXfs indexes in the styles part (see xlsxEncoder.writeParts)
+	xlsxStyleTime   = 1
+	xlsxStyleHeader = 2
+)
+
+var xlsxEpoch = time.Date(1899, 12, 30, 0, 0, 0, 0, time.UTC)
+
+type xlsxEncoder struct {
+	encoding   *XLSXEncoding
+	zw         *zip.Writer
+	properties []string
+	nested     []NestedInfo
+	rows       *xlsxSheet
+	subSheets  []*xlsxSheet
+	begun      bool
+}
+
+// xlsxSheet is a worksheet being written - the rows worksheet is written directly to the zip, sub-query worksheets are buffered
+type xlsxSheet struct {
+	name     string
+	property string
+	w        io.Writer
+	buf      *bytes.Buffer
+	columns  []flatColumn
+	rowNum   int
+}
+
+func (e *xlsxEncoder) Begin(info EncodeInfo) (err error) {
+	e.properties = info.Properties
+	e.nested = info.Nested
+	name := e.encoding.SheetName
+	if name == "" {
+		name = "Sheet1"
+	}
+	e.rows = &xlsxSheet{name: xlsxSheetName(name, nil)}
+	if e.rows.w, err = e.zw.Create("xl/worksheets/sheet1.xml"); err == nil {
+		e.begun = true
+		err = e.rows.begin()
+	}
+	return err
+}
+
+func (e *xlsxEncoder) Row(row map[string]any) (err error) {
+	if e.rows.columns == nil {
+		if err = e.writeHeader(row); err != nil {
+			return err
+		}
+	}
+	if err = e.rows.writeRow(row, nil); err == nil {
+		for _, sheet := range e.subSheets {
+			if err = e.writeSubRows(sheet, row); err != nil {
+				break
+			}
+		}
+	}
+	return err
+}
+
+func (e *xlsxEncoder) End() (err error) {
+	if !e.begun {
+		return nil
+	}
+	if e.rows.columns == nil {
+		err = e.writeHeader(nil)
+	}
+	if err == nil {
+		if err = e.rows.end(); err == nil {
+			err = e.writeParts()
+		}
+	}
+	if cErr := e.zw.Close(); err == nil {
+		err = cErr
+	}
+	return err
+}
+
+// writeHeader resolves the columns (and sub-query worksheets) using the nested structure and the first row (if any) and
+// writes the header row
+func (e *xlsxEncoder) writeHeader(row map[string]any) error {
+	var skip func(path []string, v any) bool
+	if e.encoding.SubQuerySheets {
+		used := map[string]bool{strings.ToLower(e.rows.name): true}
+		nested := make(map[string]NestedInfo, len(e.nested))
+		for _, n := range e.nested {
+			nested[n.Property] = n
+		}
+		skip = func(path []string, v any) bool {
+			if len(path) != 1 {
+				return false
+			}
+			n, known := nested[path[0]]
+			if (known && !n.Array) || (!known && !isObjectArray(v)) {
+				return false
+			}
+			sheet := &xlsxSheet{
+				name:     xlsxSheetName(path[0], used),
+				property: path[0],
+				buf:      &bytes.Buffer{},
+			}
+			if n.Properties != nil {
+				sheet.columns = append([]flatColumn{{name: xlsxRowColumn, path: []string{xlsxRowColumn}, index: -1}},
+					flatColumns(n.Properties, nil, nil, 0, nil)...)
+			}
+			e.subSheets = append(e.subSheets, sheet)
+			return true
+		}
+	}
+	e.rows.columns = flatColumns(e.properties, e.nested, row, 0, skip)
+	for _, sheet := range e.subSheets {
+		sheet.w = sheet.buf
+		if err := sheet.begin(); err != nil {
+			return err
+		} else if sheet.columns != nil {
+			if err = sheet.writeHeader(); err != nil {
+				return err
+			}
+		}
+	}
+	return e.rows.writeHeader()
+}
+
+// writeSubRows writes the items of a sub-query property of a row to the sub-query worksheet
+func (e *xlsxEncoder) writeSubRows(sheet *xlsxSheet, row map[string]any) (err error) {
+	items := reflect.ValueOf(row[sheet.property])
+	if items.Kind() != reflect.Slice {
+		return nil
+	}
+	rowRef := map[string]any{xlsxRowColumn: e.rows.rowNum}
+	for i := 0; i < items.Len() && err == nil; i++ {
+		item, ok := items.Index(i).Interface().(map[string]any)
+		if !ok {
+			continue
+		}
+		if sheet.columns == nil {
+			keys := make([]string, 0, len(item))
+			for k := range item {
+				keys = append(keys, k)
+			}
+			slices.Sort(keys)
+			sheet.columns = append([]flatColumn{{name: xlsxRowColumn, path: []string{xlsxRowColumn}, index: -1}},
+				flatColumns(keys, nil, item, 0, nil)...)
+			if err = sheet.writeHeader(); err != nil {
+				return err
+			}
+		}
+		err = sheet.writeRow(item, rowRef)
+	}
+	return err
+}
+
+// writeParts writes the sub-query worksheets and the remaining parts of the workbook
+func (e *xlsxEncoder) writeParts() (err error) {
+	sheets := []*xlsxSheet{e.rows}
+	for i, sheet := range e.subSheets {
+		if sheet.columns == nil {
+			sheet.columns = []flatColumn{{name: xlsxRowColumn, path: []string{xlsxRowColumn}, index: -1}}
+			if err = sheet.writeHeader(); err != nil {
+				return err
+			}
+		}
+		if err = sheet.end(); err == nil {
+			err = e.writePart(fmt.Sprintf("xl/worksheets/sheet%d.xml", i+2), sheet.buf.String())
+		}
+		if err != nil {
+			return err
+		}
+		sheets = append(sheets, sheet)
+	}
+	var overrides, workbookSheets, workbookRels strings.Builder
+	for i, sheet := range sheets {
+		overrides.WriteString(fmt.Sprintf(`<Override PartName="/xl/worksheets/sheet%d.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.worksheet+xml"/>`, i+1))
+		workbookSheets.WriteString(fmt.Sprintf(`<sheet name="%s" sheetId="%d" r:id="rId%d"/>`, xmlEscape(sheet.name), i+1, i+1))
+		workbookRels.WriteString(fmt.Sprintf(`<Relationship Id="rId%d" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/worksheet" Target="worksheets/sheet%d.xml"/>`, i+1, i+1))
+	}
+	workbookRels.WriteString(fmt.Sprintf(`<Relationship Id="rId%d" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/styles" Target="styles.xml"/>`, len(sheets)+1))
+	timeFormat := e.encoding.TimeFormat
+	if timeFormat == "" {
+		timeFormat = "yyyy-mm-dd hh:mm:ss"
+	}
+	parts := []struct {
+		name    string
+		content string
+	}{
+		{"[Content_Types].xml", `<Types xmlns="http://schemas.openxmlformats.org/package/2006/content-types">` +
+			`<Default Extension="rels" ContentType="application/vnd.openxmlformats-package.relationships+xml"/>` +
+			`<Default Extension="xml" ContentType="application/xml"/>` +
+			`<Override PartName="/xl/workbook.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.sheet.main+xml"/>` +
+			`<Override PartName="/xl/styles.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.styles+xml"/>` +
+			overrides.String() + `</Types>`},
+		{"_rels/.rels", `<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">` +
+			`<Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/officeDocument" Target="xl/workbook.xml"/>` +
+			`</Relationships>`},
+		{"xl/workbook.xml", `<workbook xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main" xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships">` +
+			`<sheets>` + workbookSheets.String() + `</sheets></workbook>`},
+		{"xl/_rels/workbook.xml.rels", `<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">` +
+			workbookRels.String() + `</Relationships>`},
+		{"xl/styles.xml", `<styleSheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main">` +
+			`<numFmts count="1"><numFmt numFmtId="164" formatCode="` + xmlEscape(timeFormat) + `"/></numFmts>` +
+			`<fonts count="2"><font><sz val="11"/><name val="Calibri"/></font><font><b/><sz val="11"/><name val="Calibri"/></font></fonts>` +
+			`<fills count="2"><fill><patternFill patternType="none"/></fill><fill><patternFill patternType="gray125"/></fill></fills>` +
+			`<borders count="1"><border><left/><right/><top/><bottom/><diagonal/></border></borders>` +
+			`<cellStyleXfs count="1"><xf numFmtId="0" fontId="0" fillId="0" borderId="0"/></cellStyleXfs>` +
+			`<cellXfs count="3"><xf numFmtId="0" fontId="0" fillId="0" borderId="0" xfId="0"/>` +
+			`<xf numFmtId="164" fontId="0" fillId="0" borderId="0" xfId="0" applyNumberFormat="1"/>` +
+			`<xf numFmtId="0" fontId="1" fillId="0" borderId="0" xfId="0" applyFont="1"/></cellXfs>` +
+			`<cellStyles count="1"><cellStyle name="Normal" xfId="0" builtinId="0"/></cellStyles></styleSheet>`},
+	}
+	for _, part := range parts {
+		if err = e.writePart(part.name, part.content); err != nil {
+			break
+		}
+	}
+	return err
+}
+
+func (e *xlsxEncoder) writePart(name string, content string) error {
+	w, err := e.zw.Create(name)
+	if err == nil {
+		_, err = io.WriteString(w, xml.Header+content)
+	}
+	return err
+}
+
+func (s *xlsxSheet) begin() error {
+	_, err := io.WriteString(s.w, xml.Header+`<worksheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main"><sheetData>`)
+	return err
+}
+
+func (s *xlsxSheet) end() error {
+	_, err := io.WriteString(s.w, `</sheetData></worksheet>`)
+	return err
+}
+
+func (s *xlsxSheet) writeHeader() error {
+	s.rowNum++
+	buf := &bytes.Buffer{}
+	buf.WriteString(`<row r="` + strconv.Itoa(s.rowNum) + `">`)
+	for i, col := range s.columns {
+		if err := writeXlsxCell(buf, xlsxCellRef(i, s.rowNum), col.name, xlsxStyleHeader); err != nil {
+			return err
+		}
+	}
+	buf.WriteString(`</row>`)
+	_, err := s.w.Write(buf.Bytes())
+	return err
+}
+
+// writeRow writes a row - values for columns not in the row are taken from the (optional) extra values
+func (s *xlsxSheet) writeRow(row map[string]any, extra map[string]any) error {
+	s.rowNum++
+	buf := &bytes.Buffer{}
+	buf.WriteString(`<row r="` + strconv.Itoa(s.rowNum) + `">`)
+	for i, col := range s.columns {
+		v := col.value(row)
+		if ev, ok := extra[col.name]; ok {
+			v = ev
+		}
+		if err := writeXlsxCell(buf, xlsxCellRef(i, s.rowNum), v, 0); err != nil {
+			return err
+		}
+	}
+	buf.WriteString(`</row>`)
+	_, err := s.w.Write(buf.Bytes())
+	return err
+}
+
+// writeXlsxCell writes a typed cell (nil values are not written)
+func writeXlsxCell(buf *bytes.Buffer, ref string, v any, style int) error {
+	var str string
+	switch vt := v.(type) {
+	case nil:
+		return nil
+	case string:
+		str = vt
+	case []byte:
+		str = string(vt)
+	case bool:
+		b := "0"
+		if vt {
+			b = "1"
+		}
+		buf.WriteString(`<c r="` + ref + `" t="b"><v>` + b + `</v></c>`)
+		return nil
+	case decimal.Decimal:
+		writeXlsxNumber(buf, ref, vt.String(), style)
+		return nil
+	case time.Time:
+		if vt.Year() < 1900 {
+			// dates before 1900 are not supported by spreadsheet date serials
+			str = vt.Format(time.RFC3339Nano)
+			break
+		}
+		writeXlsxNumber(buf, ref, xlsxDateSerial(vt), xlsxStyleTime)
+		return nil
+	case encoding.TextMarshaler:
+		data, err := vt.MarshalText()
+		if err != nil {
+			return err
+		}
+		str = string(data)
+	default:
+		rv := reflect.ValueOf(v)
+		switch rv.Kind() {
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			writeXlsxNumber(buf, ref, strconv.FormatInt(rv.Int(), 10), style)
+			return nil
+		case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+			writeXlsxNumber(buf, ref, strconv.FormatUint(rv.Uint(), 10), style)
+			return nil
+		case reflect.Float32, reflect.Float64:
+			if f := rv.Float(); !math.IsNaN(f) && !math.IsInf(f, 0) {
+				writeXlsxNumber(buf, ref, strconv.FormatFloat(f, 'f', -1, rv.Type().Bits()), style)
+				return nil
+			}
+			str = strconv.FormatFloat(rv.Float(), 'f', -1, 64)
+		default:
+			data, err := json.Marshal(v)
+			if err != nil {
+				return err
+			}
+			str = string(data)
+		}
+	}
+	buf.WriteString(`<c r="` + ref + `" t="inlineStr"`)
+	if style != 0 {
+		buf.WriteString(` s="` + strconv.Itoa(style) + `"`)
+	}
+	buf.WriteString(`><is><t xml:space="preserve">` + xmlEscape(str) + `</t></is></c>`)
+	return nil
+}
+
+func writeXlsxNumber(buf *bytes.Buffer, ref string, n string, style int) {
+	buf.WriteString(`<c r="` + ref + `"`)
+	if style != 0 {
+		buf.WriteString(` s="` + strconv.Itoa(style) + `"`)
+	}
+	buf.WriteString(`><v>` + n + `</v></c>`)
+}
+
+// xlsxDateSerial returns the spreadsheet date serial (days since 1899-12-30) for the wall clock time of a time.Time
+func xlsxDateSerial(t time.Time) string {
+	wall := time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute(), t.Second(), t.Nanosecond(), time.UTC)
+	secs := wall.Unix() - xlsxEpoch.Unix()
+	days := float64(secs/86400) + (float64(secs%86400)+float64(wall.Nanosecond())/1e9)/86400
+	return strconv.FormatFloat(days, 'f', -1, 64)
+}
+
+// xlsxCellRef returns the cell reference (e.g. `A1`, `AB12`) for a zero based column index and row number
+func xlsxCellRef(col int, row int) string {
+	name := ""
+	for col++; col > 0; col = (col - 1) / 26 {
+		name = string(rune('A'+(col-1)%26)) + name
+	}
+	return name + strconv.Itoa(row)
+}
+
+// xlsxSheetName returns a valid (and, if used is non-nil, unique) worksheet name
+func xlsxSheetName(name string, used map[string]bool) string {
+	name = strings.Map(func(r rune) rune {
+		if strings.ContainsRune(`[]:*?/\`, r) {
+			return '_'
+		}
+		return r
+	}, name)
+	if rs := []rune(name); len(rs) > 31 {
+		name = string(rs[:31])
+	}
+	if used != nil {
+		base := name
+		for i := 2; used[strings.ToLower(name)]; i++ {
+			suffix := fmt.Sprintf(" (%d)", i)
+			rs := []rune(base)
+			name = string(rs[:min(len(rs), 31-len(suffix))]) + suffix
+		}
+		used[strings.ToLower(name)] = true
+	}
+	return name
+}
+
+// isObjectArray determines whether a value is an array of objects (e.g. the result of a slice sub-query)
+func isObjectArray(v any) bool {
+	switch vt := v.(type) {
+	case []map[string]any:
+		return true
+	case []any:
+		for _, item := range vt {
+			if _, ok := item.(map[string]any); !ok {
+				return false
+			}
+		}
+		return len(vt) > 0
+	}
+	return false
+}
+
+func xmlEscape(s string) string {
+	var sb strings.Builder
+	_ = xml.EscapeText(&sb, []byte(s))
+	return sb.String()
+}
@@ -0,0 +1,205 @@
+package columbus
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"io"
+	"testing"
+	"time"
+)
+
+func readXlsxParts(t *testing.T, data []byte) map[string]string {
+	zr, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	require.NoError(t, err)
+	result := map[string]string{}
+	for _, f := range zr.File {
+		r, err := f.Open()
+		require.NoError(t, err)
+		content, err := io.ReadAll(r)
+		require.NoError(t, err)
+		_ = r.Close()
+		result[f.Name] = string(content)
+	}
+	return result
+}
+
+func TestMapper_WriteRows_XLSX(t *testing.T) {
+	m, err := newMapper("id,name,city,amount,created,active", Mappings{
+		"city": {Path: []string{"address"}},
+		"amount": {PostProcess: func(ctx context.Context, sqli SqlInterface, row map[string]any, value any) (bool, any, error) {
+			if s, ok := value.(string); ok {
+				d, err := decimal.NewFromString(s)
+				return true, d, err
+			}
+			return false, nil, nil
+		}},
+	}, Query(`FROM table`))
+	require.NoError(t, err)
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer func() {
+		_ = db.Close()
+	}()
+	created := time.Date(2026, 1, 2, 12, 0, 0, 0, time.UTC)
+	mock.ExpectQuery("").WillReturnRows(sqlmock.NewRows([]string{"id", "name", "city", "amount", "created", "active"}).
+		AddRow(1, "foo & <bar>", "London", decimal.RequireFromString("1.50"), created, true).
+		AddRow(2, "baz", nil, nil, nil, false))
+	w := bytes.NewBuffer(nil)
+	err = m.WriteRows(ctx, w, db, nil, Accept(XLSX.ContentType()))
+	require.NoError(t, err)
+	require.NoError(t, mock.ExpectationsWereMet())
+
+	parts := readXlsxParts(t, w.Bytes())
+	assert.Len(t, parts, 6)
+	assert.Contains(t, parts, "[Content_Types].xml")
+	assert.Contains(t, parts, "_rels/.rels")
+	assert.Contains(t, parts, "xl/_rels/workbook.xml.rels")
+	assert.Contains(t, parts["xl/styles.xml"], `formatCode="yyyy-mm-dd hh:mm:ss"`)
+	assert.Contains(t, parts["xl/workbook.xml"], `<sheets><sheet name="Sheet1" sheetId="1" r:id="rId1"/></sheets>`)
+	assert.Contains(t, parts["xl/worksheets/sheet1.xml"], `<sheetData>`+
+		`<row r="1">`+
+		`<c r="A1" t="inlineStr" s="2"><is><t xml:space="preserve">id</t></is></c>`+
+		`<c r="B1" t="inlineStr" s="2"><is><t xml:space="preserve">name</t></is></c>`+
+		`<c r="C1" t="inlineStr" s="2"><is><t xml:space="preserve">address.city</t></is></c>`+
+		`<c r="D1" t="inlineStr" s="2"><is><t xml:space="preserve">amount</t></is></c>`+
+		`<c r="E1" t="inlineStr" s="2"><is><t xml:space="preserve">created</t></is></c>`+
+		`<c r="F1" t="inlineStr" s="2"><is><t xml:space="preserve">active</t></is></c>`+
+		`</row>`+
+		`<row r="2">`+
+		`<c r="A2"><v>1</v></c>`+
+		`<c r="B2" t="inlineStr"><is><t xml:space="preserve">foo &amp; &lt;bar&gt;</t></is></c>`+
+		`<c r="C2" t="inlineStr"><is><t xml:space="preserve">London</t></is></c>`+
+		`<c r="D2"><v>1.5</v></c>`+
+		`<c r="E2" s="1"><v>46024.5</v></c>`+
+		`<c r="F2" t="b"><v>1</v></c>`+
+		`</row>`+
+		`<row r="3">`+
+		`<c r="A3"><v>2</v></c>`+
+		`<c r="B3" t="inlineStr"><is><t xml:space="preserve">baz</t></is></c>`+
+		`<c r="F3" t="b"><v>0</v></c>`+
+		`</row>`+
+		`</sheetData>`)
+}
+
+func TestMapper_WriteRows_XLSX_SubQuerySheets(t *testing.T) {
+	m, err := newMapper("id", Query(`FROM table`),
+		NewSubQuery("items", `SELECT sku, qty FROM items WHERE id = ?`, []string{"id"}, nil, false),
+		NewSubQuery("tags", `SELECT tag FROM tags WHERE id = ?`, []string{"id"}, nil, false))
+	require.NoError(t, err)
+	db, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+	require.NoError(t, err)
+	defer func() {
+		_ = db.Close()
+	}()
+	mock.ExpectQuery("SELECT id FROM table").WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1).AddRow(2))
+	mock.ExpectQuery("SELECT sku, qty FROM items WHERE id = ?").WithArgs(1).WillReturnRows(sqlmock.NewRows([]string{"sku", "qty"}).AddRow("A", 1).AddRow("B", 2))
+	mock.ExpectQuery("SELECT tag FROM tags WHERE id = ?").WithArgs(1).WillReturnRows(sqlmock.NewRows([]string{"tag"}))
+	mock.ExpectQuery("SELECT sku, qty FROM items WHERE id = ?").WithArgs(2).WillReturnRows(sqlmock.NewRows([]string{"sku", "qty"}).AddRow("C", 3))
+	mock.ExpectQuery("SELECT tag FROM tags WHERE id = ?").WithArgs(2).WillReturnRows(sqlmock.NewRows([]string{"tag"}))
+	w := bytes.NewBuffer(nil)
+	err = m.WriteRows(ctx, w, db, nil, &XLSXEncoding{SheetName: "Orders", SubQuerySheets: true})
+	require.NoError(t, err)
+	require.NoError(t, mock.ExpectationsWereMet())
+
+	parts := readXlsxParts(t, w.Bytes())
+	assert.Contains(t, parts["xl/workbook.xml"], `<sheets>`+
+		`<sheet name="Orders" sheetId="1" r:id="rId1"/>`+
+		`<sheet name="items" sheetId="2" r:id="rId2"/>`+
+		`<sheet name="tags" sheetId="3" r:id="rId3"/>`+
+		`</sheets>`)
+	assert.Contains(t, parts["xl/worksheets/sheet1.xml"], `<sheetData>`+
+		`<row r="1"><c r="A1" t="inlineStr" s="2"><is><t xml:space="preserve">id</t></is></c></row>`+
+		`<row r="2"><c r="A2"><v>1</v></c></row>`+
+		`<row r="3"><c r="A3"><v>2</v></c></row>`+
+		`</sheetData>`)
+	assert.Contains(t, parts["xl/worksheets/sheet2.xml"], `<sheetData>`+
+		`<row r="1">`+
+		`<c r="A1" t="inlineStr" s="2"><is><t xml:space="preserve">_row</t></is></c>`+
+		`<c r="B1" t="inlineStr" s="2"><is><t xml:space="preserve">qty</t></is></c>`+
+		`<c r="C1" t="inlineStr" s="2"><is><t xml:space="preserve">sku</t></is></c>`+
+		`</row>`+
+		`<row r="2"><c r="A2"><v>2</v></c><c r="B2"><v>1</v></c><c r="C2" t="inlineStr"><is><t xml:space="preserve">A</t></is></c></row>`+
+		`<row r="3"><c r="A3"><v>2</v></c><c r="B3"><v>2</v></c><c r="C3" t="inlineStr"><is><t xml:space="preserve">B</t></is></c></row>`+
+		`<row r="4"><c r="A4"><v>3</v></c><c r="B4"><v>3</v></c><c r="C4" t="inlineStr"><is><t xml:space="preserve">C</t></is></c></row>`+
+		`</sheetData>`)
+	assert.Contains(t, parts["xl/worksheets/sheet3.xml"], `<sheetData>`+
+		`<row r="1"><c r="A1" t="inlineStr" s="2"><is><t xml:space="preserve">_row</t></is></c></row>`+
+		`</sheetData>`)
+}
+
+func TestMapper_WriteRows_XLSX_SubQuerySheets_NilFirstRow(t *testing.T) {
+	m, err := newMapper("id", Query(`FROM table`),
+		NewSubQuery("items", `SELECT sku FROM items WHERE id = ?`, []string{"id"}, nil, true))
+	require.NoError(t, err)
+	db, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+	require.NoError(t, err)
+	defer func() {
+		_ = db.Close()
+	}()
+	mock.ExpectQuery("SELECT id FROM table").WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1).AddRow(2))
+	mock.ExpectQuery("SELECT sku FROM items WHERE id = ?").WithArgs(1).WillReturnRows(sqlmock.NewRows([]string{"sku"}))
+	mock.ExpectQuery("SELECT sku FROM items WHERE id = ?").WithArgs(2).WillReturnRows(sqlmock.NewRows([]string{"sku"}).AddRow("C"))
+	w := bytes.NewBuffer(nil)
+	err = m.WriteRows(ctx, w, db, nil, &XLSXEncoding{SubQuerySheets: true})
+	require.NoError(t, err)
+	require.NoError(t, mock.ExpectationsWereMet())
+
+	parts := readXlsxParts(t, w.Bytes())
+	assert.Contains(t, parts["xl/workbook.xml"], `<sheet name="items" sheetId="2" r:id="rId2"/>`)
+	assert.Contains(t, parts["xl/worksheets/sheet1.xml"], `<sheetData>`+
+		`<row r="1"><c r="A1" t="inlineStr" s="2"><is><t xml:space="preserve">id</t></is></c></row>`+
+		`<row r="2"><c r="A2"><v>1</v></c></row>`+
+		`<row r="3"><c r="A3"><v>2</v></c></row>`+
+		`</sheetData>`)
+	assert.Contains(t, parts["xl/worksheets/sheet2.xml"], `<sheetData>`+
+		`<row r="1">`+
+		`<c r="A1" t="inlineStr" s="2"><is><t xml:space="preserve">_row</t></is></c>`+
+		`<c r="B1" t="inlineStr" s="2"><is><t xml:space="preserve">sku</t></is></c>`+
+		`</row>`+
+		`<row r="2"><c r="A2"><v>3</v></c><c r="B2" t="inlineStr"><is><t xml:space="preserve">C</t></is></c></row>`+
+		`</sheetData>`)
+}
+
+func TestXLSXEncoding_SubQuerySheets_NestedProperties(t *testing.T) {
+	w := bytes.NewBuffer(nil)
+	enc := (&XLSXEncoding{SubQuerySheets: true}).NewEncoder(w)
+	require.NoError(t, enc.Begin(EncodeInfo{
+		Properties: []string{"id", "lines"},
+		Nested:     []NestedInfo{{Property: "lines", Array: true, Properties: []string{"sku", "qty"}}},
+	}))
+	require.NoError(t, enc.Row(map[string]any{"id": int64(1), "lines": nil}))
+	require.NoError(t, enc.End())
+
+	parts := readXlsxParts(t, w.Bytes())
+	assert.Contains(t, parts["xl/worksheets/sheet2.xml"], `<sheetData>`+
+		`<row r="1">`+
+		`<c r="A1" t="inlineStr" s="2"><is><t xml:space="preserve">_row</t></is></c>`+
+		`<c r="B1" t="inlineStr" s="2"><is><t xml:space="preserve">sku</t></is></c>`+
+		`<c r="C1" t="inlineStr" s="2"><is><t xml:space="preserve">qty</t></is></c>`+
+		`</row>`+
+		`</sheetData>`)
+}
+
+func TestXlsxCellRef(t *testing.T) {
+	assert.Equal(t, "A1", xlsxCellRef(0, 1))
+	assert.Equal(t, "Z2", xlsxCellRef(25, 2))
+	assert.Equal(t, "AA3", xlsxCellRef(26, 3))
+	assert.Equal(t, "AZ4", xlsxCellRef(51, 4))
+	assert.Equal(t, "BA5", xlsxCellRef(52, 5))
+	assert.Equal(t, "ZZ6", xlsxCellRef(701, 6))
+	assert.Equal(t, "AAA7", xlsxCellRef(702, 7))
+}
+
+func TestXlsxSheetName(t *testing.T) {
+	used := map[string]bool{"sheet1": true}
+	assert.Equal(t, "a_b_c", xlsxSheetName("a[b]c", used))
+	assert.Equal(t, "Sheet1 (2)", xlsxSheetName("Sheet1", used))
+	assert.Equal(t, "A_B_C (2)", xlsxSheetName("A[B]C", used))
+	assert.Equal(t, "abcdefghijklmnopqrstuvwxyzabcde", xlsxSheetName("abcdefghijklmnopqrstuvwxyzabcdefgh", used))
+	assert.Equal(t, "abcdefghijklmnopqrstuvwxyza (2)", xlsxSheetName("abcdefghijklmnopqrstuvwxyzabcdefgh", used))
+}
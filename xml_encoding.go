@@ -0,0 +1,287 @@
+package columbus
+
+import (
+	"encoding"
+	"encoding/json"
+	"encoding/xml"
+	"github.com/shopspring/decimal"
+	"io"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// XMLEncoding is a RowEncoding that writes rows as XML - each row is written as a row element (within a root element) with
+// each property as a child element
+//
+// nested objects (e.g. properties mapped with a Path or object sub-queries) are written as child elements, and arrays (e.g. slice
+// sub-queries) are written as an element containing an item element for each item
+//
+// element order is derived from the column order (followed by sub-query and row post processor properties) - the properties of
+// nested objects that are not mapped columns (e.g. JSON columns or sub-query items) are written in name order
+//
+// property names that are not valid XML names have invalid characters replaced with an underscore
+//
+// rows are streamed (each row is written, and the writer flushed, as it is encoded) - when a single row is written (i.e.
+// WriteFirstRow or WriteExactlyOneRow) the row element is the document element (i.e. there is no root element)
+type XMLEncoding struct {
+	// RootElement is the name of the root element (if empty, "rows" is used)
+	RootElement string
+	// RowElement is the name of the element for each row (if empty, "row" is used)
+	RowElement string
+	// ItemElement is the name of the element for each array item (if empty, "item" is used)
+	ItemElement string
+	// Attributes is the properties that are written as attributes (of the row, or nested object, element) rather than as
+	// child elements - nested properties are specified using dot notation (e.g. `address.code`) and the properties of array
+	// items are specified using the array property (e.g. `items.sku`)
+	//
+	// object and array values are not written as attributes - and null values are omitted
+	Attributes []string
+	// OmitNulls indicates that elements for null values are not written (by default, an empty element is written)
+	OmitNulls bool
+	// TimeFormat is the format used for time.Time values (if empty, time.RFC3339Nano is used)
+	TimeFormat string
+	// Indent is the indent used for each nesting level (if empty, the XML is not indented)
+	Indent string
+	// MediaType is the content type of the encoding (if empty, `application/xml`)
+	MediaType string
+}
+
+var _ RowEncoding = (*XMLEncoding)(nil)
+
+// XML is the RowEncoding that writes rows as XML (see XMLEncoding)
+var XML RowEncoding = &XMLEncoding{}
+
+func (e *XMLEncoding) ContentType() string {
+	if e.MediaType != "" {
+		return e.MediaType
+	}
+	return "application/xml"
+}
+
+func (e *XMLEncoding) NewEncoder(w io.Writer) RowEncoder {
+	enc := xml.NewEncoder(w)
+	if e.Indent != "" {
+		enc.Indent("", e.Indent)
+	}
+	attrs := make(map[string]bool, len(e.Attributes))
+	for _, a := range e.Attributes {
+		attrs[a] = true
+	}
+	return &xmlEncoder{
+		encoding:   e,
+		w:          w,
+		enc:        enc,
+		attributes: attrs,
+	}
+}
+
+type xmlEncoder struct {
+	encoding   *XMLEncoding
+	w          io.Writer
+	enc        *xml.Encoder
+	attributes map[string]bool
+	single     bool
+	order      *xmlNode
+}
+
+// xmlNode is the element order (derived from the properties) of an object
+type xmlNode struct {
+	names    []string
+	children map[string]*xmlNode
+}
+
+func (n *xmlNode) add(path []string) {
+	if len(path) == 0 {
+		return
+	}
+	child, ok := n.children[path[0]]
+	if !ok {
+		child = &xmlNode{children: map[string]*xmlNode{}}
+		n.names = append(n.names, path[0])
+		n.children[path[0]] = child
+	}
+	child.add(path[1:])
+}
+
+func (e *xmlEncoder) Begin(info EncodeInfo) (err error) {
+	e.single = info.Single
+	e.order = &xmlNode{children: map[string]*xmlNode{}}
+	for _, p := range info.Properties {
+		e.order.add(strings.Split(p, "."))
+	}
+	if err = e.enc.EncodeToken(xml.ProcInst{Target: "xml", Inst: []byte(`version="1.0" encoding="UTF-8"`)}); err == nil && !e.single {
+		err = e.enc.EncodeToken(xml.StartElement{Name: xml.Name{Local: xmlElementName(e.encoding.RootElement, "rows")}})
+	}
+	if err == nil {
+		err = e.enc.Flush()
+	}
+	return err
+}
+
+func (e *xmlEncoder) Row(row map[string]any) (err error) {
+	if err = e.writeObject(xmlElementName(e.encoding.RowElement, "row"), row, nil, e.order); err == nil {
+		if err = e.enc.Flush(); err == nil {
+			err = flush(e.w)
+		}
+	}
+	return err
+}
+
+func (e *xmlEncoder) End() (err error) {
+	if !e.single {
+		err = e.enc.EncodeToken(xml.EndElement{Name: xml.Name{Local: xmlElementName(e.encoding.RootElement, "rows")}})
+	}
+	if err == nil {
+		err = e.enc.Flush()
+	}
+	return err
+}
+
+// writeObject writes an object element - properties are written in the order of the node (if any) followed by any other
+// properties in name order
+func (e *xmlEncoder) writeObject(name string, obj map[string]any, path []string, node *xmlNode) (err error) {
+	names := make([]string, 0, len(obj))
+	var others []string
+	if node != nil {
+		for _, n := range node.names {
+			if _, ok := obj[n]; ok {
+				names = append(names, n)
+			}
+		}
+	}
+	for n := range obj {
+		if node == nil || node.children[n] == nil {
+			others = append(others, n)
+		}
+	}
+	slices.Sort(others)
+	names = append(names, others...)
+	start := xml.StartElement{Name: xml.Name{Local: name}}
+	elements := make([]string, 0, len(names))
+	for _, n := range names {
+		if v := obj[n]; e.attributes[strings.Join(append(path[:len(path):len(path)], n), ".")] && !isArray(v) {
+			if v == nil {
+				continue
+			} else if _, isObj := v.(map[string]any); !isObj {
+				s, err := e.format(v)
+				if err != nil {
+					return err
+				}
+				start.Attr = append(start.Attr, xml.Attr{Name: xml.Name{Local: xmlElementName(n, "")}, Value: s})
+				continue
+			}
+		}
+		elements = append(elements, n)
+	}
+	if err = e.enc.EncodeToken(start); err != nil {
+		return err
+	}
+	for _, n := range elements {
+		var child *xmlNode
+		if node != nil {
+			child = node.children[n]
+		}
+		if err = e.writeValue(xmlElementName(n, ""), obj[n], append(path[:len(path):len(path)], n), child); err != nil {
+			return err
+		}
+	}
+	return e.enc.EncodeToken(start.End())
+}
+
+func (e *xmlEncoder) writeValue(name string, v any, path []string, node *xmlNode) (err error) {
+	if v == nil {
+		if e.encoding.OmitNulls {
+			return nil
+		}
+		start := xml.StartElement{Name: xml.Name{Local: name}}
+		if err = e.enc.EncodeToken(start); err == nil {
+			err = e.enc.EncodeToken(start.End())
+		}
+		return err
+	} else if obj, ok := v.(map[string]any); ok {
+		return e.writeObject(name, obj, path, node)
+	} else if isArray(v) {
+		start := xml.StartElement{Name: xml.Name{Local: name}}
+		if err = e.enc.EncodeToken(start); err != nil {
+			return err
+		}
+		itemName := xmlElementName(e.encoding.ItemElement, "item")
+		rv := reflect.ValueOf(v)
+		for i := 0; i < rv.Len() && err == nil; i++ {
+			err = e.writeValue(itemName, rv.Index(i).Interface(), path, nil)
+		}
+		if err == nil {
+			err = e.enc.EncodeToken(start.End())
+		}
+		return err
+	}
+	s, err := e.format(v)
+	if err != nil {
+		return err
+	}
+	return e.enc.EncodeElement(s, xml.StartElement{Name: xml.Name{Local: name}})
+}
+
+// format formats a (non-object, non-array) value as text
+func (e *xmlEncoder) format(v any) (string, error) {
+	switch vt := v.(type) {
+	case string:
+		return vt, nil
+	case []byte:
+		return string(vt), nil
+	case bool:
+		return strconv.FormatBool(vt), nil
+	case int:
+		return strconv.Itoa(vt), nil
+	case int64:
+		return strconv.FormatInt(vt, 10), nil
+	case int32:
+		return strconv.FormatInt(int64(vt), 10), nil
+	case float64:
+		return strconv.FormatFloat(vt, 'f', -1, 64), nil
+	case float32:
+		return strconv.FormatFloat(float64(vt), 'f', -1, 32), nil
+	case decimal.Decimal:
+		return vt.String(), nil
+	case time.Time:
+		if e.encoding.TimeFormat != "" {
+			return vt.Format(e.encoding.TimeFormat), nil
+		}
+		return vt.Format(time.RFC3339Nano), nil
+	case encoding.TextMarshaler:
+		data, err := vt.MarshalText()
+		return string(data), err
+	}
+	data, err := json.Marshal(v)
+	return string(data), err
+}
+
+// xmlElementName returns a valid XML name for a name (or the default name if the name is empty)
+func xmlElementName(name string, def string) string {
+	if name == "" {
+		name = def
+	}
+	var sb strings.Builder
+	for i, r := range name {
+		switch {
+		case r == '_' || unicode.IsLetter(r):
+		case i > 0 && (r == '-' || r == '.' || unicode.IsDigit(r)):
+		default:
+			if i == 0 && unicode.IsDigit(r) {
+				sb.WriteRune('_')
+				sb.WriteRune(r)
+				continue
+			}
+			r = '_'
+		}
+		sb.WriteRune(r)
+	}
+	if sb.Len() == 0 {
+		return "_"
+	}
+	return sb.String()
+}
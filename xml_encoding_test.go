@@ -0,0 +1,104 @@
+package columbus
+
+import (
+	"bytes"
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+func TestMapper_WriteRows_XML(t *testing.T) {
+	m, err := newMapper("id,code,name,city,created", Mappings{
+		"code": {Path: []string{"address"}},
+		"city": {Path: []string{"address"}},
+	}, Query(`FROM table`),
+		NewSubQuery("items", `SELECT sku, qty FROM items WHERE id = ?`, []string{"id"}, nil, false))
+	require.NoError(t, err)
+	db, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+	require.NoError(t, err)
+	defer func() {
+		_ = db.Close()
+	}()
+	created := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	mock.ExpectQuery("SELECT id,code,name,city,created FROM table").WillReturnRows(sqlmock.NewRows([]string{"id", "code", "name", "city", "created"}).
+		AddRow(1, "A\"1", "foo & <bar>", "London", created).
+		AddRow(2, nil, "baz", nil, nil))
+	mock.ExpectQuery("SELECT sku, qty FROM items WHERE id = ?").WithArgs(1).WillReturnRows(sqlmock.NewRows([]string{"sku", "qty"}).AddRow("X", 1).AddRow("Y", 2))
+	mock.ExpectQuery("SELECT sku, qty FROM items WHERE id = ?").WithArgs(2).WillReturnRows(sqlmock.NewRows([]string{"sku", "qty"}))
+	w := &testFlushWriter{}
+	err = m.WriteRows(ctx, w, db, nil, &XMLEncoding{
+		RootElement: "people",
+		RowElement:  "person",
+		Attributes:  []string{"id", "address.code", "items.sku"},
+	})
+	require.NoError(t, err)
+	require.NoError(t, mock.ExpectationsWereMet())
+	const row1 = `<person id="1"><address code="A&#34;1"><city>London</city></address><name>foo &amp; &lt;bar&gt;</name>` +
+		`<created>2026-01-02T03:04:05Z</created><items><item sku="X"><qty>1</qty></item><item sku="Y"><qty>2</qty></item></items></person>`
+	const row2 = `<person id="2"><address><city></city></address><name>baz</name><created></created><items></items></person>`
+	assert.Equal(t, `<?xml version="1.0" encoding="UTF-8"?><people>`+row1+row2+`</people>`, w.String())
+	assert.Equal(t, []string{
+		`<?xml version="1.0" encoding="UTF-8"?><people>` + row1,
+		`<?xml version="1.0" encoding="UTF-8"?><people>` + row1 + row2,
+	}, w.flushed)
+}
+
+func TestMapper_WriteFirstRow_XML(t *testing.T) {
+	m, err := newMapper("id,tags", Query(`FROM table`))
+	require.NoError(t, err)
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer func() {
+		_ = db.Close()
+	}()
+	mock.ExpectQuery("").WillReturnRows(sqlmock.NewRows([]string{"id", "tags"}).AddRow(1, nil))
+	w := bytes.NewBuffer(nil)
+	err = m.WriteFirstRow(ctx, w, db, nil, &XMLEncoding{OmitNulls: true, Indent: "  "})
+	require.NoError(t, err)
+	require.NoError(t, mock.ExpectationsWereMet())
+	assert.Equal(t, "<?xml version=\"1.0\" encoding=\"UTF-8\"?><row>\n  <id>1</id>\n</row>", w.String())
+
+	mock.ExpectQuery("").WillReturnRows(sqlmock.NewRows([]string{"id", "tags"}))
+	w = bytes.NewBuffer(nil)
+	err = m.WriteRows(ctx, w, db, nil, Accept("application/xml"))
+	require.NoError(t, err)
+	require.NoError(t, mock.ExpectationsWereMet())
+	assert.Equal(t, `<?xml version="1.0" encoding="UTF-8"?><rows></rows>`, w.String())
+}
+
+func TestXMLEncoding_Arrays(t *testing.T) {
+	w := bytes.NewBuffer(nil)
+	enc := (&XMLEncoding{ItemElement: "value"}).NewEncoder(w)
+	require.NoError(t, enc.Begin(EncodeInfo{Properties: []string{"tags", "data"}}))
+	require.NoError(t, enc.Row(map[string]any{
+		"tags": []string{"a", "b"},
+		"data": map[string]any{"z": 1, "a": []any{true, nil}, "1st": "x"},
+	}))
+	require.NoError(t, enc.End())
+	assert.Equal(t, `<?xml version="1.0" encoding="UTF-8"?><rows><row>`+
+		`<tags><value>a</value><value>b</value></tags>`+
+		`<data><_1st>x</_1st><a><value>true</value><value></value></a><z>1</z></data>`+
+		`</row></rows>`, w.String())
+}
+
+func TestXmlElementName(t *testing.T) {
+	testCases := map[string]string{
+		"":           "_",
+		"name":       "name",
+		"first-name": "first-name",
+		"a.b":        "a.b",
+		"1st":        "_1st",
+		"-a":         "_a",
+		"a b":        "a_b",
+		"_x":         "_x",
+		"é":          "é",
+	}
+	for name, expect := range testCases {
+		t.Run(name, func(t *testing.T) {
+			assert.Equal(t, expect, xmlElementName(name, ""))
+		})
+	}
+	assert.Equal(t, "row", xmlElementName("", "row"))
+}